@@ -241,6 +241,11 @@ func init() {
 		utils.ShowDeprecated,
 		// See snapshot.go
 		snapshotCommand,
+		//add
+		// See plugincmd.go
+		pluginOverheadCommand,
+		pluginValidateCommand,
+		//add
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
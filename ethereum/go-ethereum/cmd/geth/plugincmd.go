@@ -0,0 +1,141 @@
+package main
+
+//add new file
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ethereum/go-ethereum/cmd/pluginManage"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	pluginRoundsFlag = &cli.IntFlag{
+		Name:  "rounds",
+		Usage: "Number of times every block is processed in each mode",
+		Value: 5,
+	}
+	pluginOverheadCommand = &cli.Command{
+		Action:    pluginOverhead,
+		Name:      "pluginoverhead",
+		Usage:     "Measure the block processing overhead of the installed plugins",
+		ArgsUsage: "<genesisPath> <chainFile>",
+		Flags:     []cli.Flag{pluginRoundsFlag},
+		Description: `
+The pluginoverhead command builds an in-memory chain from the genesis file,
+then processes every block of the RLP encoded chain file with the plugin
+subsystem switched off and with the installed plugins loaded, and prints the
+difference in time, allocations and gas-equivalent cost, broken down by
+plugin opcode.`,
+	}
+	pluginValidateCommand = &cli.Command{
+		Action:    pluginValidate,
+		Name:      "pluginvalidate",
+		Usage:     "Check plugins without loading them into a node",
+		ArgsUsage: "<plugin.so> [<plugin.so>...]",
+		Description: `
+The pluginvalidate command opens every plugin, checks that it was built
+against the same packages as this binary, that its Register() manifest
+parses and subscribes to known opcodes, and that every function the
+manifest names exists with the signature the node calls it with. It prints
+a pass/fail line per check and fails if any plugin is invalid.`,
+	}
+)
+
+func pluginValidate(ctx *cli.Context) error {
+	if ctx.Args().Len() == 0 {
+		utils.Fatalf("This command requires at least one plugin file.")
+	}
+	invalid := 0
+	for _, path := range ctx.Args().Slice() {
+		report := pluginManage.ValidatePlugin(path)
+		fmt.Print(report)
+		if !report.Passed() {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d plugins are invalid", invalid, ctx.Args().Len())
+	}
+	return nil
+}
+
+func pluginOverhead(ctx *cli.Context) error {
+	if ctx.Args().Len() != 2 {
+		utils.Fatalf("This command requires a genesis file and a chain file.")
+	}
+	file, err := os.Open(ctx.Args().Get(0))
+	if err != nil {
+		utils.Fatalf("Failed to read genesis file: %v", err)
+	}
+	genesis := new(core.Genesis)
+	err = json.NewDecoder(file).Decode(genesis)
+	file.Close()
+	if err != nil {
+		utils.Fatalf("invalid genesis file: %v", err)
+	}
+	config := *genesis.Config
+	config.TransferDataPlg = pluginManage.NewPluginManages()
+	pluginManage.SetUpPlugin(config.TransferDataPlg)
+	genesis.Config = &config
+
+	db := rawdb.NewMemoryDatabase()
+	defer db.Close()
+	genesis.MustCommit(db)
+
+	var engine consensus.Engine = ethash.NewFullFaker()
+	if config.Clique != nil {
+		engine = clique.New(config.Clique, db)
+	}
+	chain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		utils.Fatalf("Failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	blocks, err := readChainFile(ctx.Args().Get(1))
+	if err != nil {
+		utils.Fatalf("Failed to read chain file: %v", err)
+	}
+	report, err := core.MeasurePluginOverhead(chain, blocks, ctx.Int(pluginRoundsFlag.Name))
+	if err != nil {
+		utils.Fatalf("Measurement failed: %v", err)
+	}
+	fmt.Print(report)
+	return nil
+}
+
+// readChainFile decodes the blocks of an exported chain, skipping genesis.
+func readChainFile(path string) ([]*types.Block, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var blocks []*types.Block
+	stream := rlp.NewStream(file, 0)
+	for {
+		block := new(types.Block)
+		if err := stream.Decode(block); err == io.EOF {
+			return blocks, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("at block %d: %v", len(blocks), err)
+		}
+		if block.NumberU64() > 0 {
+			blocks = append(blocks, block)
+		}
+	}
+}
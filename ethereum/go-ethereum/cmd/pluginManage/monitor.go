@@ -6,18 +6,27 @@ import (
 	// "fmt"
 )
 
-
 //add new file
 
-type SendFuncType func(*collector.AllCollector) (byte,string)
+type SendFuncType func(*collector.AllCollector) (byte, string)
 
 type MonitorType struct {
-	Status 		bool
-	SendFunc 	SendFuncType
-	Opcode 		string
-	Logger 		*WarnTxLog
-	IAL_Optinon	string
-	PluginName 	string
+	Status      bool
+	SendFunc    SendFuncType
+	Opcode      string
+	Logger      *WarnTxLog
+	IAL_Optinon string
+	PluginName  string
+	Mode        string //"monitor" (default) or "enforce"
+	Delivery    string //"event" (default), "block" or "aggregate"
+	BatchFunc   BatchFuncType
+	Async       bool            //handled on the plugin's own worker pool
+	DryRun      bool            //block decisions are only reported
+	FromBlock   uint64          //first block the plugin receives events of
+	ToBlock     uint64          //last block, 0 for no end
+	EventFilter EventFilterFunc //evaluated before the event is built, nil takes every event
+	Selectors   SelectorSet     //calls and transactions the plugin takes by selector, nil takes every one
+	Sampling    SamplingConfig  //transactions the plugin takes out of the global sample
 }
 
 func (m *MonitorType) SetStatus(Status bool) {
@@ -33,7 +42,7 @@ func (m *MonitorType) SetSendFunc(SendFunc SendFuncType) {
 func (m *MonitorType) GetSendFunc() SendFuncType {
 	return m.SendFunc
 }
-func (m *MonitorType) Send(data *collector.AllCollector) (byte,string) {
+func (m *MonitorType) Send(data *collector.AllCollector) (byte, string) {
 	return m.SendFunc(data)
 }
 
@@ -51,6 +60,91 @@ func (m *MonitorType) GetIAL_Optinon() string {
 	return m.IAL_Optinon
 }
 
+func (m *MonitorType) SetMode(Mode string) {
+	m.Mode = Mode
+}
+func (m *MonitorType) GetMode() string {
+	return m.Mode
+}
+
+// IsEnforce reports whether the plugin may block transactions. Enforce
+// plugins see every transaction, sampling only applies to monitor plugins.
+func (m *MonitorType) IsEnforce() bool {
+	return m.Mode == "enforce"
+}
+
+func (m *MonitorType) SetDelivery(Delivery string) {
+	m.Delivery = Delivery
+}
+func (m *MonitorType) GetDelivery() string {
+	return m.Delivery
+}
+
+// IsBlockDelivery reports whether the plugin gets its events in one batch at
+// the end of each block. Enforce plugins must answer per event and are never
+// batched.
+func (m *MonitorType) IsBlockDelivery() bool {
+	return m.Delivery == "block" && !m.IsEnforce()
+}
+
+// IsAggregate reports whether the events of the plugin are folded into a
+// summary it receives once per block with handle_BLOCK_END. Like block
+// delivery it is not available to enforce plugins.
+func (m *MonitorType) IsAggregate() bool {
+	return m.Delivery == "aggregate" && !m.IsEnforce()
+}
+
+// SetSelectors limits the call and transaction events of the plugin to the
+// ones whose calldata starts with one of selectors.
+func (m *MonitorType) SetSelectors(Selectors SelectorSet) {
+	m.Selectors = Selectors
+}
+
+// SetSampling limits the plugin to a sample of the transactions the global
+// sampling lets through. Both decisions take the same bits of the hash, so
+// with the same kind of setting a plugin sampling no more than the global
+// sampling gets its share of all transactions. Enforce plugins see every
+// transaction and ignore it.
+func (m *MonitorType) SetSampling(Sampling SamplingConfig) {
+	m.Sampling = Sampling
+}
+
+func (m *MonitorType) SetBatchFunc(BatchFunc BatchFuncType) {
+	m.BatchFunc = BatchFunc
+}
+
+func (m *MonitorType) SetAsync(Async bool) {
+	m.Async = Async
+}
+
+// IsAsync reports whether the plugin's events are handed to its worker pool
+// instead of being sent inline. Enforce plugins must answer before the
+// transaction goes on and always run inline.
+func (m *MonitorType) IsAsync() bool {
+	return m.Async && !m.IsEnforce()
+}
+
+func (m *MonitorType) SetDryRun(DryRun bool) {
+	m.DryRun = DryRun
+}
+func (m *MonitorType) IsDryRun() bool {
+	return m.DryRun
+}
+
+func (m *MonitorType) SetBlockRange(FromBlock, ToBlock uint64) {
+	m.FromBlock = FromBlock
+	m.ToBlock = ToBlock
+}
+
+// InRange reports whether the plugin takes the events of block number.
+func (m *MonitorType) InRange(number uint64) bool {
+	return number >= m.FromBlock && (m.ToBlock == 0 || number <= m.ToBlock)
+}
+
+func (m *MonitorType) SetEventFilter(EventFilter EventFilterFunc) {
+	m.EventFilter = EventFilter
+}
+
 func (m *MonitorType) SetPluginName(PluginName string) {
 	m.PluginName = PluginName
 }
@@ -58,23 +152,21 @@ func (m *MonitorType) GetPluginName() string {
 	return m.PluginName
 }
 
-
 func (m *MonitorType) SetLogger(FileName string) {
 	m.Logger = NewPluginLogger()
-	filepath := "./plugin_log/" + FileName + "datalog/" +FileName+"datalog" 
+	filepath := "./plugin_log/" + FileName + "datalog/" + FileName + "datalog"
 	m.Logger.InitialFileLog(filepath)
 
 	logpath := "./plugin_log/" + FileName + "datalog"
 	// fmt.Println("Data log path:",logpath)
-	_,err_1 := os.Stat(logpath)
+	_, err_1 := os.Stat(logpath)
 	// fmt.Println(err_1)
 	// fmt.Println(os.IsNotExist(err_1))
-	if err_1 == nil || os.IsNotExist(err_1){
-		os.Mkdir(logpath,os.ModePerm)
+	if err_1 == nil || os.IsNotExist(err_1) {
+		os.Mkdir(logpath, os.ModePerm)
 	}
 
-
-} 
+}
 
 func (m *MonitorType) GetLogger() *WarnTxLog {
 	return m.Logger
@@ -0,0 +1,98 @@
+package pluginManage
+
+//add new file
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/zhidandeng/collector"
+)
+
+// ABIConfig names the ABI file of a monitored contract. Calls into the
+// contract carry their input decoded against it.
+type ABIConfig struct {
+	Address common.Address `json:"address"`
+	File    string         `json:"file"` // solc style JSON ABI
+}
+
+// LoadABIs reads the ABI files of configs and registers them on the manager.
+func (plg *PluginManages) LoadABIs(configs []ABIConfig) error {
+	for _, config := range configs {
+		file, err := os.Open(config.File)
+		if err != nil {
+			return fmt.Errorf("abi of %s: %v", config.Address, err)
+		}
+		parsed, err := abi.JSON(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("abi of %s: %v", config.Address, err)
+		}
+		plg.SetABI(config.Address, parsed)
+	}
+	return nil
+}
+
+// SetABI registers the ABI calls into address are decoded against.
+func (plg *PluginManages) SetABI(address common.Address, contract abi.ABI) {
+	if plg.abis == nil {
+		plg.abis = make(map[common.Address]*abi.ABI)
+	}
+	plg.abis[address] = &contract
+}
+
+// decodeInput fills the decoded form of the call input of data when the
+// callee has an ABI. Input the ABI does not describe is left undecoded.
+func (plg *PluginManages) decodeInput(data *collector.AllCollector) {
+	if len(plg.abis) == 0 || len(data.TransInfo.CallInfo.InputData) < 4 || !common.IsHexAddress(data.TransInfo.To) {
+		return
+	}
+	contract, ok := plg.abis[common.HexToAddress(data.TransInfo.To)]
+	if !ok {
+		return
+	}
+	if decoded, ok := decodeCall(contract, data.TransInfo.CallInfo.InputData); ok {
+		data.TransInfo.CallInfo.DecodedInput = decoded
+	}
+}
+
+func decodeCall(contract *abi.ABI, input []byte) (collector.DecodedCall, bool) {
+	method, err := contract.MethodById(input[:4])
+	if err != nil {
+		return collector.DecodedCall{}, false
+	}
+	values, err := method.Inputs.Unpack(input[4:])
+	if err != nil || len(values) != len(method.Inputs) {
+		return collector.DecodedCall{}, false
+	}
+	decoded := collector.DecodedCall{Method: method.RawName, Signature: method.Sig}
+	for i, arg := range method.Inputs {
+		decoded.Args = append(decoded.Args, collector.DecodedArg{
+			Name:  arg.Name,
+			Type:  arg.Type.String(),
+			Value: formatABIValue(values[i]),
+		})
+	}
+	return decoded, true
+}
+
+// formatABIValue renders an unpacked argument: numbers in decimal, addresses
+// and byte strings in 0x prefixed hex.
+func formatABIValue(value interface{}) string {
+	switch v := value.(type) {
+	case []byte:
+		return hexutil.Encode(v)
+	case common.Address:
+		return v.Hex()
+	}
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		raw := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(raw), rv)
+		return hexutil.Encode(raw)
+	}
+	return fmt.Sprint(value)
+}
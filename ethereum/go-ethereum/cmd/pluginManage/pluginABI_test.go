@@ -0,0 +1,154 @@
+package pluginManage
+
+import (
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhidandeng/collector"
+)
+
+const erc20TransferABI = `[{"type":"function","name":"transfer","stateMutability":"nonpayable",
+	"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],
+	"outputs":[{"name":"","type":"bool"}]}]`
+
+func TestDecodeCallInput(t *testing.T) {
+	var (
+		token     = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+		other     = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+		recipient = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	)
+	path := filepath.Join(t.TempDir(), "erc20.json")
+	if err := ioutil.WriteFile(path, []byte(erc20TransferABI), 0600); err != nil {
+		t.Fatal(err)
+	}
+	manage := NewPluginManages()
+	if err := manage.LoadABIs([]ABIConfig{{Address: token, File: path}}); err != nil {
+		t.Fatal(err)
+	}
+	var events []*collector.AllCollector
+	testMonitor(manage, "decoder", "", "EXTERNALINFOSTART", func(data *collector.AllCollector) (byte, string) {
+		events = append(events, data)
+		return 0x00, ""
+	})
+	parsed, _ := abi.JSON(strings.NewReader(erc20TransferABI))
+	input, err := parsed.Pack("transfer", recipient, big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(to common.Address, input []byte) {
+		info := collector.NewTransCollector()
+		info.Op = "EXTERNALINFOSTART"
+		info.To = to.String()
+		info.CallInfo.InputData = input
+		manage.Start()
+		manage.SendDataToPlugin(info.Op, info.SendTransInfo(info.Op))
+	}
+	send(token, input)
+	send(other, input)
+	send(token, input[:20])
+
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+	decoded := events[0].TransInfo.CallInfo.DecodedInput
+	if decoded.Method != "transfer" || decoded.Signature != "transfer(address,uint256)" || len(decoded.Args) != 2 {
+		t.Fatalf("decoded %+v", decoded)
+	}
+	want := []collector.DecodedArg{
+		{Name: "to", Type: "address", Value: recipient.Hex()},
+		{Name: "value", Type: "uint256", Value: "1000"},
+	}
+	for i, arg := range decoded.Args {
+		if arg != want[i] {
+			t.Errorf("argument %d: %+v, want %+v", i, arg, want[i])
+		}
+	}
+	if events[0].TransInfo.CallInfo.InputData == nil {
+		t.Error("raw input dropped")
+	}
+	if decoded := events[1].TransInfo.CallInfo.DecodedInput; decoded.Method != "" {
+		t.Errorf("call to a contract without abi decoded as %+v", decoded)
+	}
+	if decoded := events[2].TransInfo.CallInfo.DecodedInput; decoded.Method != "" {
+		t.Errorf("truncated input decoded as %+v", decoded)
+	}
+}
+
+func TestDecodeHeldInput(t *testing.T) {
+	var (
+		token   = common.Address{0xaa}
+		watched = common.Address{0xbb}
+		sender  = common.Address{0xcc}
+	)
+	parsed, _ := abi.JSON(strings.NewReader(erc20TransferABI))
+	input, err := parsed.Pack("transfer", sender, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manage := NewPluginManages()
+	manage.SetABI(token, parsed)
+	manage.SetFilter(FilterConfig{Addresses: []common.Address{watched}})
+
+	var events []*collector.AllCollector
+	testMonitor(manage, "decoder", "", "CALLSTART", func(data *collector.AllCollector) (byte, string) {
+		events = append(events, data)
+		return 0x00, ""
+	})
+	// The call into the token is held back until the transaction reaches the
+	// watched address, and released with the next event.
+	manage.Start()
+	manage.BeginTx(testTxHash(0), sender, &token)
+	manage.TxState().ALL_STACK = []string{token.String()}
+	info := collector.NewTransCollector()
+	info.Op = "CALLSTART"
+	info.To = token.String()
+	info.CallInfo.InputData = input
+	manage.SendDataToPlugin(info.Op, info.SendTransInfo(info.Op))
+	manage.TxState().ALL_STACK = append(manage.TxState().ALL_STACK, watched.String())
+	manage.SendDataToPlugin("CALLSTART", collector.SendFlag("CALLSTART"))
+
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if decoded := events[0].TransInfo.CallInfo.DecodedInput; decoded.Method != "transfer" {
+		t.Errorf("held call decoded as %+v", decoded)
+	}
+}
+
+func TestDecodeSampledOutInput(t *testing.T) {
+	token := common.Address{0xaa}
+	parsed, _ := abi.JSON(strings.NewReader(erc20TransferABI))
+	input, err := parsed.Pack("transfer", token, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manage := NewPluginManages()
+	manage.SetABI(token, parsed)
+	// The enforcer still sees the events of transactions out of the sample.
+	manage.SetSampling(SamplingConfig{Rate: 1 << 62})
+
+	var enforced []*collector.AllCollector
+	testMonitor(manage, "enforce", "enforce", "CALLSTART", func(data *collector.AllCollector) (byte, string) {
+		enforced = append(enforced, data)
+		return 0x00, ""
+	})
+	manage.Start()
+	manage.BeginTx(testTxHash(1), common.Address{}, &token)
+	info := collector.NewTransCollector()
+	info.Op = "CALLSTART"
+	info.To = token.String()
+	info.CallInfo.InputData = input
+	manage.SendDataToPlugin(info.Op, info.SendTransInfo(info.Op))
+
+	if len(enforced) != 1 {
+		t.Fatalf("enforcer got %d events, want 1", len(enforced))
+	}
+	if decoded := enforced[0].TransInfo.CallInfo.DecodedInput; decoded.Method != "transfer" {
+		t.Errorf("sampled out call decoded as %+v", decoded)
+	}
+}
@@ -0,0 +1,68 @@
+//go:build amqp
+// +build amqp
+
+package pluginManage
+
+//add new file
+
+import (
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func init() {
+	SetAMQPDialer(dialAMQP)
+}
+
+// amqpChannel adapts a RabbitMQ client channel to AMQPChannel.
+type amqpChannel struct {
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+	confirms chan amqp.Confirmation
+}
+
+func dialAMQP(url string) (AMQPChannel, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &amqpChannel{conn: conn, channel: channel}, nil
+}
+
+func (c *amqpChannel) Confirm() error {
+	if err := c.channel.Confirm(false); err != nil {
+		return err
+	}
+	c.confirms = c.channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+	return nil
+}
+
+func (c *amqpChannel) Publish(exchange, key string, body []byte) error {
+	err := c.channel.Publish(exchange, key, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         body,
+	})
+	if err != nil || c.confirms == nil {
+		return err
+	}
+	confirm, ok := <-c.confirms
+	if !ok {
+		return amqp.ErrClosed
+	}
+	if !confirm.Ack {
+		return fmt.Errorf("broker rejected delivery %d", confirm.DeliveryTag)
+	}
+	return nil
+}
+
+func (c *amqpChannel) Close() error {
+	c.channel.Close()
+	return c.conn.Close()
+}
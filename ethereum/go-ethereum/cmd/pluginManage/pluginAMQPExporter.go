@@ -0,0 +1,229 @@
+package pluginManage
+
+//add new file
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	defaultAMQPBuffer    = 4096
+	defaultAMQPReconnect = 2 * time.Second
+)
+
+// errAMQPBufferFull is returned by Export when the broker does not keep up
+// and the publish buffer is full. The event is dropped.
+var errAMQPBufferFull = errors.New("amqp publish buffer full")
+
+// AMQPConfig holds the broker side settings of an amqp exporter. The broker
+// address is the URL of the exporter.
+type AMQPConfig struct {
+	Exchange          string `json:"exchange"`
+	RoutingKey        string `json:"routingkey"`        // prefix of the routing key, "<prefix>.<opcode>"
+	Confirm           bool   `json:"confirm"`           // wait for the broker to confirm every publish
+	BufferSize        int    `json:"buffersize"`        // events queued while the broker is slow or away
+	ReconnectInterval string `json:"reconnectinterval"` // pause between connection attempts
+}
+
+// AMQPChannel is the part of an AMQP 0-9-1 channel the exporter publishes on.
+type AMQPChannel interface {
+	// Confirm puts the channel into confirm mode.
+	Confirm() error
+	// Publish sends body to exchange. In confirm mode it returns once the
+	// broker acknowledged the message and fails if the broker rejected it.
+	Publish(exchange, key string, body []byte) error
+	Close() error
+}
+
+// AMQPDialer opens a channel on the broker at url.
+type AMQPDialer func(url string) (AMQPChannel, error)
+
+// amqpDial is the dialer of the amqp exporter type. It is installed by
+// builds with the amqp tag.
+var amqpDial AMQPDialer
+
+// SetAMQPDialer installs the dialer used by amqp exporters from the config.
+func SetAMQPDialer(dial AMQPDialer) {
+	amqpDial = dial
+}
+
+type amqpMessage struct {
+	key  string
+	body []byte
+}
+
+// AMQPExporter publishes every event to a RabbitMQ exchange with a routing
+// key derived from the opcode. Publishing happens on a background goroutine:
+// Export only queues, so a slow or lost broker never holds up block import.
+// The connection is re-established as long as the exporter is open.
+type AMQPExporter struct {
+	name      string
+	url       string
+	config    AMQPConfig
+	reconnect time.Duration
+	dial      AMQPDialer
+
+	channel AMQPChannel // only used by loop
+	queue   chan amqpMessage
+	dropped metrics.Counter
+
+	lock   sync.RWMutex
+	closed bool
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// NewAMQPExporter creates an amqp exporter connecting through dial.
+func NewAMQPExporter(config ExporterConfig, dial AMQPDialer) (*AMQPExporter, error) {
+	if dial == nil {
+		return nil, fmt.Errorf("amqp exporter %q: amqp support not compiled in, build with -tags amqp", config.Name)
+	}
+	if config.AMQP.Exchange == "" {
+		return nil, fmt.Errorf("amqp exporter %q has no exchange", config.Name)
+	}
+	reconnect := defaultAMQPReconnect
+	if config.AMQP.ReconnectInterval != "" {
+		var err error
+		if reconnect, err = time.ParseDuration(config.AMQP.ReconnectInterval); err != nil || reconnect <= 0 {
+			return nil, fmt.Errorf("amqp exporter %q has invalid reconnect interval %q", config.Name, config.AMQP.ReconnectInterval)
+		}
+	}
+	buffer := config.AMQP.BufferSize
+	if buffer <= 0 {
+		buffer = defaultAMQPBuffer
+	}
+	e := &AMQPExporter{
+		name:      config.Name,
+		url:       config.URL,
+		config:    config.AMQP,
+		reconnect: reconnect,
+		dial:      dial,
+		queue:     make(chan amqpMessage, buffer),
+		dropped:   metrics.GetOrRegisterCounter("plugin/exporter/"+config.Name+"/dropped", nil),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go e.loop()
+	return e, nil
+}
+
+func (e *AMQPExporter) Name() string { return e.name }
+
+// RoutingKey returns the routing key events of opcode are published with.
+func (e *AMQPExporter) RoutingKey(opcode string) string {
+	if e.config.RoutingKey == "" {
+		return opcode
+	}
+	return e.config.RoutingKey + "." + opcode
+}
+
+// Export queues env for publishing. It fails without blocking when the
+// buffer is full.
+func (e *AMQPExporter) Export(env *Envelope) error {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	if e.closed {
+		return fmt.Errorf("amqp exporter %q is closed", e.name)
+	}
+	select {
+	case e.queue <- amqpMessage{key: e.RoutingKey(env.Opcode), body: body}:
+		return nil
+	default:
+		e.dropped.Inc(1)
+		return errAMQPBufferFull
+	}
+}
+
+func (e *AMQPExporter) loop() {
+	defer close(e.done)
+	for {
+		select {
+		case msg := <-e.queue:
+			e.publish(msg, true)
+		case <-e.quit:
+			// Export can not queue anymore, publish what is left with a
+			// single attempt each.
+			for {
+				select {
+				case msg := <-e.queue:
+					e.publish(msg, false)
+				default:
+					if e.channel != nil {
+						e.channel.Close()
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// publish sends msg, reconnecting until it goes through when retry is set
+// and the exporter is not closed.
+func (e *AMQPExporter) publish(msg amqpMessage, retry bool) {
+	for {
+		err := e.connect()
+		if err == nil {
+			if err = e.channel.Publish(e.config.Exchange, msg.key, msg.body); err == nil {
+				return
+			}
+			e.channel.Close()
+			e.channel = nil
+		}
+		log.Warn("Plugin amqp exporter publish failed", "exporter", e.name, "key", msg.key, "err", err)
+		if !retry {
+			e.dropped.Inc(1)
+			return
+		}
+		select {
+		case <-time.After(e.reconnect):
+		case <-e.quit:
+			retry = false
+		}
+	}
+}
+
+func (e *AMQPExporter) connect() error {
+	if e.channel != nil {
+		return nil
+	}
+	channel, err := e.dial(e.url)
+	if err != nil {
+		return err
+	}
+	if e.config.Confirm {
+		if err := channel.Confirm(); err != nil {
+			channel.Close()
+			return err
+		}
+	}
+	e.channel = channel
+	return nil
+}
+
+// Close stops accepting events and returns once the queued ones are
+// published or given up on.
+func (e *AMQPExporter) Close() error {
+	e.lock.Lock()
+	if e.closed {
+		e.lock.Unlock()
+		return nil
+	}
+	e.closed = true
+	e.lock.Unlock()
+
+	close(e.quit)
+	<-e.done
+	return nil
+}
@@ -0,0 +1,150 @@
+package pluginManage
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhidandeng/collector"
+)
+
+type amqpPublish struct {
+	exchange, key string
+	body          []byte
+}
+
+// mockAMQP hands out channels recording their publishes. The first channel
+// fails once it has published failAfter messages, like a dropped connection,
+// and the dial after it is refused.
+type mockAMQP struct {
+	lock      sync.Mutex
+	dials     int
+	confirms  int
+	failAfter int
+	published []amqpPublish
+}
+
+type mockAMQPChannel struct {
+	broker *mockAMQP
+	first  bool
+	sent   int
+	closed bool
+}
+
+func (m *mockAMQP) dial(url string) (AMQPChannel, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.dials++
+	if m.dials == 2 {
+		return nil, errors.New("connection refused")
+	}
+	return &mockAMQPChannel{broker: m, first: m.dials == 1}, nil
+}
+
+func (c *mockAMQPChannel) Confirm() error {
+	c.broker.lock.Lock()
+	defer c.broker.lock.Unlock()
+	c.broker.confirms++
+	return nil
+}
+
+func (c *mockAMQPChannel) Publish(exchange, key string, body []byte) error {
+	c.broker.lock.Lock()
+	defer c.broker.lock.Unlock()
+
+	if c.closed || (c.first && c.sent == c.broker.failAfter) {
+		return errors.New("connection lost")
+	}
+	c.sent++
+	c.broker.published = append(c.broker.published, amqpPublish{exchange, key, body})
+	return nil
+}
+
+func (c *mockAMQPChannel) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestAMQPExporterRoutingAndReconnect(t *testing.T) {
+	broker := &mockAMQP{failAfter: 2}
+	exp, err := NewAMQPExporter(ExporterConfig{
+		Name: "bus",
+		URL:  "amqp://localhost",
+		AMQP: AMQPConfig{Exchange: "chain", RoutingKey: "noda", Confirm: true, ReconnectInterval: "1ms"},
+	}, broker.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opcodes := []string{"TXSTART", "EXTERNALINFOSTART", "TRANS_CALL", "EXTERNALINFOEND", "TXEND"}
+	for i, opcode := range opcodes {
+		env := &Envelope{Opcode: opcode, BlockNumber: uint64(i), Payload: collector.SendFlag(opcode)}
+		if err := exp.Export(env); err != nil {
+			t.Fatalf("export %s: %v", opcode, err)
+		}
+	}
+	// Wait for the publisher to get through the outage before closing, Close
+	// itself does not reconnect.
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		broker.lock.Lock()
+		n := len(broker.published)
+		broker.lock.Unlock()
+		if n == len(opcodes) {
+			break
+		}
+	}
+	if err := exp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The first channel dies after two messages, the first redial fails,
+	// the second one carries the rest.
+	if broker.dials < 3 {
+		t.Errorf("exporter dialed %d times, want at least 3", broker.dials)
+	}
+	if broker.confirms != broker.dials-1 {
+		t.Errorf("%d of %d channels put in confirm mode", broker.confirms, broker.dials-1)
+	}
+	if len(broker.published) != len(opcodes) {
+		t.Fatalf("published %d messages, want %d", len(broker.published), len(opcodes))
+	}
+	for i, msg := range broker.published {
+		if msg.exchange != "chain" || msg.key != "noda."+opcodes[i] {
+			t.Errorf("message %d published to %s/%s, want chain/noda.%s", i, msg.exchange, msg.key, opcodes[i])
+		}
+		var env Envelope
+		if err := json.Unmarshal(msg.body, &env); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if env.Opcode != opcodes[i] || env.BlockNumber != uint64(i) || env.Payload.Option != opcodes[i] {
+			t.Errorf("message %d carries %+v", i, env)
+		}
+	}
+	if err := exp.Export(&Envelope{Opcode: "TXSTART"}); err == nil {
+		t.Error("export after close succeeded")
+	}
+}
+
+func TestAMQPExporterNeverBlocks(t *testing.T) {
+	release := make(chan struct{})
+	dial := func(string) (AMQPChannel, error) {
+		<-release
+		return nil, errors.New("broker away")
+	}
+	exp, err := NewAMQPExporter(ExporterConfig{Name: "bus", AMQP: AMQPConfig{Exchange: "chain", BufferSize: 4}}, dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One message is held by the stuck publisher, four fit in the buffer.
+	var full int
+	for i := 0; i < 10; i++ {
+		if err := exp.Export(&Envelope{Opcode: "TXSTART"}); err == errAMQPBufferFull {
+			full++
+		}
+	}
+	if full < 5 {
+		t.Errorf("%d exports rejected, want at least 5", full)
+	}
+	close(release)
+	exp.Close()
+}
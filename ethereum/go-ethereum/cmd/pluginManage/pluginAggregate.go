@@ -0,0 +1,59 @@
+package pluginManage
+
+//add new file
+
+import (
+	"math/big"
+
+	"github.com/zhidandeng/collector"
+)
+
+// blockAggregate is what an aggregating plugin has been sent of the current
+// block so far.
+type blockAggregate struct {
+	summary    collector.AggregateCollector
+	totalValue *big.Int
+	lastTx     string
+}
+
+// accumulate folds data into the block aggregate of monitor's plugin instead
+// of sending it.
+func (plg *PluginManages) accumulate(monitor *MonitorType, data *collector.AllCollector) {
+	if plg.aggregates == nil {
+		plg.aggregates = make(map[string]*blockAggregate)
+	}
+	agg, ok := plg.aggregates[monitor.PluginName]
+	if !ok {
+		agg = &blockAggregate{summary: *collector.NewAggregateCollector(), totalValue: new(big.Int)}
+		plg.aggregates[monitor.PluginName] = agg
+	}
+	agg.summary.Events++
+	// instruction payloads carry no hash, the transaction being executed does
+	if plg.tx.TxHash != agg.lastTx {
+		agg.summary.Transactions++
+		agg.lastTx = plg.tx.TxHash
+	}
+	trans := data.TransInfo
+	if trans.Op == "" {
+		return
+	}
+	if value, ok := new(big.Int).SetString(trans.Value, 10); ok {
+		agg.totalValue.Add(agg.totalValue, value)
+	}
+	agg.summary.GasUsed += trans.GasUsed
+	agg.summary.Trans = append(agg.summary.Trans, trans)
+}
+
+// aggregated returns the handle_BLOCK_END payload for monitor's plugin, data
+// carrying the aggregate of the block, and starts a new one.
+func (plg *PluginManages) aggregated(monitor *MonitorType, data *collector.AllCollector) *collector.AllCollector {
+	summary := *collector.NewAggregateCollector()
+	if agg, ok := plg.aggregates[monitor.PluginName]; ok {
+		summary = agg.summary
+		summary.TotalValue = agg.totalValue.String()
+		delete(plg.aggregates, monitor.PluginName)
+	}
+	payload := *data
+	payload.BlockInfo.Aggregate = summary
+	return &payload
+}
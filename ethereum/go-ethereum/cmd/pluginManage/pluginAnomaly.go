@@ -0,0 +1,64 @@
+package pluginManage
+
+//add new file
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/zhidandeng/collector"
+)
+
+// Thresholds reported in handle_TX_ANOMALY.
+const (
+	AnomalyCalldata = "calldata"
+	AnomalyValue    = "value"
+)
+
+// AnomalyConfig sets the limits above which a transaction raises
+// handle_TX_ANOMALY before it executes. A zero or empty limit is disabled.
+type AnomalyConfig struct {
+	MaxCalldata uint64 `json:"maxcalldata"` // bytes of calldata
+	MaxValue    string `json:"maxvalue"`    // wei, decimal
+}
+
+type anomalyLimits struct {
+	maxCalldata uint64
+	maxValue    *big.Int
+}
+
+// SetAnomaly replaces the transaction anomaly thresholds.
+func (plg *PluginManages) SetAnomaly(config AnomalyConfig) error {
+	limits := anomalyLimits{maxCalldata: config.MaxCalldata}
+	if config.MaxValue != "" {
+		value, ok := new(big.Int).SetString(config.MaxValue, 10)
+		if !ok || value.Sign() <= 0 {
+			return fmt.Errorf("invalid anomaly maxvalue %q", config.MaxValue)
+		}
+		limits.maxValue = value
+	}
+	plg.anomaly = limits
+	return nil
+}
+
+// TxAnomalies returns the thresholds a transaction with the given calldata
+// and value exceeds.
+func (plg *PluginManages) TxAnomalies(calldata []byte, value *big.Int) []collector.AnomalyCollector {
+	var anomalies []collector.AnomalyCollector
+	if limit := plg.anomaly.maxCalldata; limit > 0 && uint64(len(calldata)) > limit {
+		anomalies = append(anomalies, collector.AnomalyCollector{
+			Threshold: AnomalyCalldata,
+			Limit:     strconv.FormatUint(limit, 10),
+			Actual:    strconv.Itoa(len(calldata)),
+		})
+	}
+	if limit := plg.anomaly.maxValue; limit != nil && value != nil && value.Cmp(limit) > 0 {
+		anomalies = append(anomalies, collector.AnomalyCollector{
+			Threshold: AnomalyValue,
+			Limit:     limit.String(),
+			Actual:    value.String(),
+		})
+	}
+	return anomalies
+}
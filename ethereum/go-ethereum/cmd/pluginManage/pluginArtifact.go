@@ -0,0 +1,133 @@
+package pluginManage
+
+//add new file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// defaultArtifactCache is where downloaded plugins are kept when the
+// configuration names no cache directory.
+const defaultArtifactCache = "./plugin_cache"
+
+// ArtifactConfig names a plugin built elsewhere and served over http, by an
+// S3 compatible object store for instance. The file is only downloaded when
+// the cached copy does not match SHA256, so changing the checksum in the
+// configuration is what rolls out a new build.
+type ArtifactConfig struct {
+	Name   string     `json:"name"` // file name in the cache, the last path element of URL by default
+	URL    string     `json:"url"`
+	SHA256 string     `json:"sha256"` // hex checksum of the plugin file, required
+	Auth   AuthConfig `json:"auth"`   // credentials sent to the store
+}
+
+func (a ArtifactConfig) fileName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	name := a.URL
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	return filepath.Base(name)
+}
+
+// FetchArtifact returns the path of the plugin in the cache directory,
+// downloading it first unless the cached copy has the configured checksum.
+// A download not matching the checksum is discarded.
+func FetchArtifact(artifact ArtifactConfig, cache string) (string, error) {
+	want := strings.ToLower(strings.TrimPrefix(artifact.SHA256, "0x"))
+	if len(want) != 2*sha256.Size {
+		return "", fmt.Errorf("plugin artifact %s has no valid sha256 checksum", artifact.URL)
+	}
+	if cache == "" {
+		cache = defaultArtifactCache
+	}
+	path := filepath.Join(cache, artifact.fileName())
+	if sum, err := fileChecksum(path); err == nil && sum == want {
+		return path, nil
+	}
+	if err := os.MkdirAll(cache, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(cache, artifact.fileName()+".download-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	sum, err := downloadArtifact(artifact, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if sum != want {
+		return "", fmt.Errorf("plugin artifact %s has checksum %s, want %s", artifact.URL, sum, want)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	log.Info("Downloaded plugin artifact", "url", artifact.URL, "path", path, "sha256", sum)
+	return path, nil
+}
+
+// downloadArtifact writes the artifact to out and returns its checksum.
+func downloadArtifact(artifact ArtifactConfig, out io.Writer) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, artifact.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	artifact.Auth.Apply(req)
+	resp, err := (&http.Client{Timeout: 5 * time.Minute}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("plugin artifact %s: %s", artifact.URL, resp.Status)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// loadArtifacts fetches the configured artifacts and registers them through
+// register, RegisterPlugin outside of tests.
+func loadArtifacts(manage *PluginManages, artifacts []ArtifactConfig, cache string, register func(*PluginManages, string) bool) error {
+	for _, artifact := range artifacts {
+		path, err := FetchArtifact(artifact, cache)
+		if err != nil {
+			return err
+		}
+		register(manage, path)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package pluginManage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPluginArtifactCache(t *testing.T) {
+	artifact := []byte("\x7fELF not really a plugin")
+	var downloads int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer store-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		w.Write(artifact)
+	}))
+	defer srv.Close()
+
+	sum := sha256.Sum256(artifact)
+	config := ArtifactConfig{
+		URL:    srv.URL + "/builds/P9.so?versionId=3",
+		SHA256: hex.EncodeToString(sum[:]),
+		Auth:   AuthConfig{Token: "store-token"},
+	}
+	cache := t.TempDir()
+
+	var loaded []string
+	register := func(manage *PluginManages, path string) bool {
+		loaded = append(loaded, path)
+		return true
+	}
+	manage := NewPluginManages()
+	for i := 0; i < 2; i++ {
+		if err := loadArtifacts(manage, []ArtifactConfig{config}, cache, register); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := filepath.Join(cache, "P9.so")
+	if len(loaded) != 2 || loaded[0] != want || loaded[1] != want {
+		t.Fatalf("loaded %v, want %s twice", loaded, want)
+	}
+	if n := atomic.LoadInt32(&downloads); n != 1 {
+		t.Errorf("downloaded %d times, want once", n)
+	}
+	if cached, err := ioutil.ReadFile(want); err != nil || !bytes.Equal(cached, artifact) {
+		t.Errorf("cached artifact %q, %v", cached, err)
+	}
+
+	// A new checksum is a new build: downloaded again, and refused when the
+	// store serves something else.
+	config.SHA256 = hex.EncodeToString(make([]byte, sha256.Size))
+	if _, err := FetchArtifact(config, cache); err == nil {
+		t.Error("artifact with a mismatching checksum accepted")
+	}
+	if n := atomic.LoadInt32(&downloads); n != 2 {
+		t.Errorf("downloaded %d times after the checksum changed, want twice", n)
+	}
+	if cached, err := ioutil.ReadFile(want); err != nil || !bytes.Equal(cached, artifact) {
+		t.Errorf("refused download replaced the cached artifact: %q, %v", cached, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(cache, "*")); len(files) != 1 {
+		t.Errorf("cache holds %v, want only the artifact", files)
+	}
+
+	config.SHA256 = hex.EncodeToString(sum[:])
+	config.Auth = AuthConfig{}
+	if _, err := FetchArtifact(config, t.TempDir()); err == nil {
+		t.Error("download without credentials succeeded")
+	}
+}
@@ -0,0 +1,73 @@
+package pluginManage
+
+//add new file
+
+import (
+	"sort"
+
+	"github.com/zhidandeng/collector"
+)
+
+// BatchFuncType receives every event of a block in a single call.
+type BatchFuncType func([]*collector.AllCollector)
+
+// blockBatch collects the events of one block-delivery plugin, together with
+// the monitor each one was addressed to.
+type blockBatch struct {
+	batchFunc BatchFuncType
+	monitors  []*MonitorType
+	events    []*collector.AllCollector
+}
+
+// buffer queues data for the block-delivery plugin of monitor.
+func (plg *PluginManages) buffer(monitor *MonitorType, data *collector.AllCollector) {
+	if plg.batches == nil {
+		plg.batches = make(map[string]*blockBatch)
+	}
+	batch, ok := plg.batches[monitor.PluginName]
+	if !ok {
+		batch = &blockBatch{batchFunc: monitor.BatchFunc}
+		plg.batches[monitor.PluginName] = batch
+	}
+	batch.monitors = append(batch.monitors, monitor)
+	batch.events = append(batch.events, data)
+}
+
+// FlushBlock hands every block-delivery plugin the events buffered since the
+// block started, in emission order. Plugins without a batch function get
+// them replayed one by one. Exporters batching per block are told to flush.
+func (plg *PluginManages) FlushBlock() {
+	if done := plg.timeDispatch(); done != nil {
+		defer done()
+	}
+	for _, entry := range plg.exporterList() {
+		if flusher, ok := entry.exporter.(BlockFlusher); ok {
+			flusher.FlushBlock()
+		}
+	}
+	names := make([]string, 0, len(plg.batches))
+	for name := range plg.batches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		batch := plg.batches[name]
+		if batch.batchFunc != nil {
+			batch.batchFunc(batch.events)
+			continue
+		}
+		for i, data := range batch.events {
+			plg.callPlugin(batch.monitors[i], data)
+		}
+	}
+	plg.batches = nil
+	// aggregates of plugins that did not take handle_BLOCK_END
+	plg.aggregates = nil
+}
+
+// DiscardBlock drops the buffered events of a block that was not processed
+// to the end, so no plugin ever sees part of a block.
+func (plg *PluginManages) DiscardBlock() {
+	plg.batches = nil
+	plg.aggregates = nil
+}
@@ -0,0 +1,69 @@
+package pluginManage
+
+import (
+	"testing"
+
+	"github.com/zhidandeng/collector"
+)
+
+func TestBlockDeliveryReplayAndDiscard(t *testing.T) {
+	manage := NewPluginManages()
+
+	var received []string
+	for _, opcode := range []string{"TXSTART", "TXEND"} {
+		op := opcode
+		monitor := testMonitor(manage, "batched", "", op, func(data *collector.AllCollector) (byte, string) {
+			received = append(received, op+":"+data.Option)
+			return 0x00, ""
+		})
+		monitor.SetDelivery("block")
+	}
+	send := func(opcodes ...string) {
+		manage.Start()
+		for _, opcode := range opcodes {
+			manage.SendDataToPlugin(opcode, collector.SendFlag(opcode))
+		}
+		manage.Stop()
+	}
+	send("TXSTART", "TXEND")
+	send("TXSTART", "TXEND")
+	if len(received) != 0 {
+		t.Fatalf("block delivery plugin received %v before the block ended", received)
+	}
+	// Without a batch function the events are replayed to the monitor of
+	// their opcode.
+	manage.FlushBlock()
+	want := []string{"TXSTART:TXSTART", "TXEND:TXEND", "TXSTART:TXSTART", "TXEND:TXEND"}
+	if len(received) != len(want) {
+		t.Fatalf("received %v, want %v", received, want)
+	}
+	for i := range want {
+		if received[i] != want[i] {
+			t.Fatalf("received %v, want %v", received, want)
+		}
+	}
+	// A block that fails half way is never delivered.
+	received = nil
+	send("TXSTART")
+	manage.DiscardBlock()
+	manage.FlushBlock()
+	if len(received) != 0 {
+		t.Errorf("discarded block delivered %v", received)
+	}
+}
+
+func TestBlockDeliveryIgnoresEnforce(t *testing.T) {
+	manage := NewPluginManages()
+	var received int
+	monitor := testMonitor(manage, "enforcer", "enforce", "TXSTART", func(*collector.AllCollector) (byte, string) {
+		received++
+		return 0x00, ""
+	})
+	monitor.SetDelivery("block")
+
+	manage.Start()
+	manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+	if received != 1 {
+		t.Errorf("enforce plugin was batched")
+	}
+}
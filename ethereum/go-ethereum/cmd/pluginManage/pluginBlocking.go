@@ -0,0 +1,88 @@
+package pluginManage
+
+//add new file
+
+import (
+	"fmt"
+)
+
+// BlockingConfig restricts the opcodes whose events may carry a block
+// decision of an enforce plugin. With an empty Allow list every opcode may,
+// Deny overrides Allow. A block returned for any other opcode is ignored.
+type BlockingConfig struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+type blockingPolicy struct {
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+// SetBlockingPolicy replaces the opcodes allowed to block transactions.
+func (plg *PluginManages) SetBlockingPolicy(config BlockingConfig) error {
+	allow, err := plg.opcodeSet(config.Allow)
+	if err != nil {
+		return err
+	}
+	deny, err := plg.opcodeSet(config.Deny)
+	if err != nil {
+		return err
+	}
+	plg.blocking = blockingPolicy{allow: allow, deny: deny}
+	return nil
+}
+
+// opcodeSet turns a list of opcode names into a set of their current names,
+// nil for an empty list.
+func (plg *PluginManages) opcodeSet(opcodes []string) (map[string]bool, error) {
+	if len(opcodes) == 0 {
+		return nil, nil
+	}
+	set := make(map[string]bool, len(opcodes))
+	for _, opcode := range opcodes {
+		opcode = plg.canonicalOpcode(opcode)
+		if IsOpExist(opcode) != 1 {
+			return nil, fmt.Errorf("blocking policy names unknown opcode %q", opcode)
+		}
+		set[opcode] = true
+	}
+	return set, nil
+}
+
+// BlockingPolicy returns the opcodes allowed and denied to block.
+func (plg *PluginManages) BlockingPolicy() BlockingConfig {
+	var config BlockingConfig
+	for opcode := range plg.blocking.allow {
+		config.Allow = append(config.Allow, opcode)
+	}
+	for opcode := range plg.blocking.deny {
+		config.Deny = append(config.Deny, opcode)
+	}
+	return config
+}
+
+// mayBlock reports whether a block decision on opcode is acted upon.
+func (plg *PluginManages) mayBlock(opcode string) bool {
+	if plg.blocking.deny[opcode] {
+		return false
+	}
+	return plg.blocking.allow == nil || plg.blocking.allow[opcode]
+}
+
+// Enforcing reports whether a plugin that may block transactions is
+// registered, an enforce plugin not in dry-run mode. Only then is the state
+// snapshot to revert a blocked transaction to recorded.
+func (plg *PluginManages) Enforcing() bool {
+	if !plg.Enabled() {
+		return false
+	}
+	for _, monitors := range plg.plugins {
+		for _, monitor := range monitors {
+			if monitor.IsEnforce() && !monitor.IsDryRun() {
+				return true
+			}
+		}
+	}
+	return false
+}
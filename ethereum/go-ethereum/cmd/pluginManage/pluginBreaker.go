@@ -0,0 +1,265 @@
+package pluginManage
+
+//add new file
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/zhidandeng/collector"
+)
+
+// States of a plugin circuit breaker.
+const (
+	BreakerClosed   = "closed"    // the plugin is called
+	BreakerOpen     = "open"      // the plugin is skipped until the cooldown passed
+	BreakerHalfOpen = "half-open" // a single trial call decides between closed and open
+)
+
+const (
+	defaultBreakerWindow   = time.Minute
+	defaultBreakerCooldown = 5 * time.Minute
+)
+
+// breakerNow is the clock of the circuit breakers.
+var breakerNow = time.Now
+
+// BreakerConfig sets up the circuit breakers guarding the in-process plugins.
+// A plugin call fails when it panics or takes longer than Timeout. Failures
+// consecutive failures within Window open the circuit of the plugin: it is
+// skipped, as if it had allowed the event, until Cooldown passed and a trial
+// call succeeds. A call completing in more than Slow does not fail, it is
+// logged and reported as handle_PLUGIN_SLOW to give early notice of a plugin
+// slowing down.
+type BreakerConfig struct {
+	Failures int    `json:"failures"` // 0 turns the breakers off
+	Window   string `json:"window"`
+	Cooldown string `json:"cooldown"`
+	Timeout  string `json:"timeout"` // unset means calls never time out
+	Slow     string `json:"slow"`    // calls taking longer are reported as handle_PLUGIN_SLOW
+}
+
+type breakerSettings struct {
+	failures int
+	window   time.Duration
+	cooldown time.Duration
+	timeout  time.Duration
+	slow     time.Duration
+}
+
+func (c BreakerConfig) settings() (breakerSettings, error) {
+	settings := breakerSettings{failures: c.Failures, window: defaultBreakerWindow, cooldown: defaultBreakerCooldown}
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"window", c.Window, &settings.window},
+		{"cooldown", c.Cooldown, &settings.cooldown},
+		{"timeout", c.Timeout, &settings.timeout},
+		{"slow", c.Slow, &settings.slow},
+	} {
+		if field.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(field.value)
+		if err != nil || duration <= 0 {
+			return settings, fmt.Errorf("invalid breaker %s %q", field.name, field.value)
+		}
+		*field.dest = duration
+	}
+	return settings, nil
+}
+
+// circuitBreaker tracks the failures of one plugin. Async plugins call it
+// from their workers, so it has its own lock.
+type circuitBreaker struct {
+	name     string
+	settings breakerSettings
+
+	lock     sync.Mutex
+	state    string
+	failures int       // consecutive failures while closed
+	first    time.Time // first of those failures
+	opened   time.Time
+	trial    bool // the half-open trial call is in flight
+}
+
+func newCircuitBreaker(name string, settings breakerSettings) *circuitBreaker {
+	return &circuitBreaker{name: name, settings: settings, state: BreakerClosed}
+}
+
+// allow reports whether the plugin may be called now.
+func (b *circuitBreaker) allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if breakerNow().Sub(b.opened) < b.settings.cooldown {
+			return false
+		}
+		b.transition(BreakerHalfOpen)
+		b.trial = true
+		return true
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+// record accounts the outcome of a call let through by allow.
+func (b *circuitBreaker) record(failed bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := breakerNow()
+	if b.state == BreakerHalfOpen {
+		b.trial = false
+		if failed {
+			b.opened = now
+			b.transition(BreakerOpen)
+		} else {
+			b.failures = 0
+			b.transition(BreakerClosed)
+		}
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	if b.failures == 0 || now.Sub(b.first) > b.settings.window {
+		b.failures, b.first = 0, now
+	}
+	if b.failures++; b.failures >= b.settings.failures {
+		b.opened = now
+		b.transition(BreakerOpen)
+	}
+}
+
+func (b *circuitBreaker) transition(state string) {
+	log.Warn("Plugin circuit breaker changed state", "plugin", b.name, "from", b.state, "to", state, "failures", b.failures)
+	b.state = state
+}
+
+func (b *circuitBreaker) status() (string, int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state, b.failures
+}
+
+// SetBreaker configures the circuit breakers. Changing the settings resets
+// the state of every breaker.
+func (plg *PluginManages) SetBreaker(config BreakerConfig) error {
+	settings, err := config.settings()
+	if err != nil {
+		return err
+	}
+	plg.breakerLock.Lock()
+	defer plg.breakerLock.Unlock()
+
+	plg.breakerSettings = settings
+	plg.breakers = nil
+	return nil
+}
+
+// breaker returns the circuit breaker of the named plugin, nil when the
+// breakers are off.
+func (plg *PluginManages) breaker(name string) *circuitBreaker {
+	plg.breakerLock.Lock()
+	defer plg.breakerLock.Unlock()
+
+	if plg.breakerSettings.failures <= 0 {
+		return nil
+	}
+	if breaker, ok := plg.breakers[name]; ok {
+		return breaker
+	}
+	if plg.breakers == nil {
+		plg.breakers = make(map[string]*circuitBreaker)
+	}
+	breaker := newCircuitBreaker(name, plg.breakerSettings)
+	plg.breakers[name] = breaker
+	return breaker
+}
+
+// callPlugin hands data to the plugin of monitor. A panic of the plugin is
+// recovered and, like a call running over the timeout, counted by the
+// breaker; the event is then treated as allowed.
+func (plg *PluginManages) callPlugin(monitor *MonitorType, data *collector.AllCollector) (level byte, reason string) {
+	breaker := plg.breaker(monitor.GetPluginName())
+	if breaker != nil && !breaker.allow() {
+		return 0x00, ""
+	}
+	slow := plg.slowThreshold()
+	start := time.Now()
+	defer func() {
+		failed, elapsed := false, time.Since(start)
+		if r := recover(); r != nil {
+			log.Error("Plugin panicked", "plugin", monitor.GetPluginName(), "opcode", data.Option, "err", r)
+			level, reason, failed = 0x00, "", true
+		} else if breaker != nil && breaker.settings.timeout > 0 && elapsed > breaker.settings.timeout {
+			log.Warn("Plugin call timed out", "plugin", monitor.GetPluginName(), "opcode", data.Option, "elapsed", elapsed)
+			failed = true
+		}
+		if breaker != nil {
+			breaker.record(failed)
+		}
+		if !failed && slow > 0 && elapsed > slow {
+			plg.pluginSlow(monitor, data.Option, elapsed, slow)
+		}
+	}()
+	return monitor.Send(data)
+}
+
+// PluginInfo describes a registered plugin.
+type PluginInfo struct {
+	Name     string   `json:"name"`
+	Mode     string   `json:"mode"`
+	Opcodes  []string `json:"opcodes"`
+	Breaker  string   `json:"breaker"`  // state of the circuit breaker
+	Failures int      `json:"failures"` // consecutive failures counted by the breaker
+}
+
+// ListPlugins returns the registered plugins ordered by name.
+func (plg *PluginManages) ListPlugins() []PluginInfo {
+	byName := make(map[string]*PluginInfo)
+	plg.pluginsLock.RLock()
+	for opcode, monitors := range plg.plugins {
+		for _, monitor := range monitors {
+			info, ok := byName[monitor.GetPluginName()]
+			if !ok {
+				info = &PluginInfo{Name: monitor.GetPluginName(), Mode: "monitor", Breaker: BreakerClosed}
+				if monitor.IsEnforce() {
+					info.Mode = "enforce"
+				}
+				byName[info.Name] = info
+			}
+			info.Opcodes = append(info.Opcodes, opcode)
+		}
+	}
+	plg.pluginsLock.RUnlock()
+	plg.breakerLock.Lock()
+	for name, breaker := range plg.breakers {
+		if info, ok := byName[name]; ok {
+			info.Breaker, info.Failures = breaker.status()
+		}
+	}
+	plg.breakerLock.Unlock()
+
+	list := make([]PluginInfo, 0, len(byName))
+	for _, info := range byName {
+		sort.Strings(info.Opcodes)
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
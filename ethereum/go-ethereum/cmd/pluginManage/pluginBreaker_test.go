@@ -0,0 +1,197 @@
+package pluginManage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zhidandeng/collector"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breakerNow = func() time.Time { return now }
+	defer func() { breakerNow = time.Now }()
+
+	manage := NewPluginManages()
+	if err := manage.SetBreaker(BreakerConfig{Failures: 3, Window: "1m", Cooldown: "10m"}); err != nil {
+		t.Fatal(err)
+	}
+	var calls int
+	broken := true
+	testMonitor(manage, "flaky", "", "TXSTART", func(data *collector.AllCollector) (byte, string) {
+		calls++
+		if broken {
+			panic("plugin bug")
+		}
+		return 0x00, ""
+	})
+	healthy := 0
+	testMonitor(manage, "healthy", "", "TXSTART", func(data *collector.AllCollector) (byte, string) {
+		healthy++
+		return 0x00, ""
+	})
+	send := func() {
+		manage.Start()
+		manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+	}
+	state := func(name string) string {
+		for _, info := range manage.ListPlugins() {
+			if info.Name == name {
+				return info.Breaker
+			}
+		}
+		t.Fatalf("plugin %s not listed", name)
+		return ""
+	}
+
+	// Failures spread wider than the window do not add up.
+	send()
+	send()
+	now = now.Add(2 * time.Minute)
+	send()
+	if state("flaky") != BreakerClosed {
+		t.Fatalf("breaker %s after failures outside the window", state("flaky"))
+	}
+	// Three in a row open it and the plugin is no longer called.
+	send()
+	send()
+	if state("flaky") != BreakerOpen {
+		t.Fatalf("breaker %s after 3 consecutive failures, want open", state("flaky"))
+	}
+	send()
+	if calls != 5 {
+		t.Fatalf("open breaker let a call through: %d calls", calls)
+	}
+	if healthy != 6 || state("healthy") != BreakerClosed {
+		t.Fatalf("healthy plugin called %d times with breaker %s", healthy, state("healthy"))
+	}
+	// After the cooldown a failing trial opens it again.
+	now = now.Add(10 * time.Minute)
+	send()
+	if calls != 6 || state("flaky") != BreakerOpen {
+		t.Fatalf("failed trial: %d calls, breaker %s", calls, state("flaky"))
+	}
+	send()
+	if calls != 6 {
+		t.Fatal("breaker reopened by a failed trial let a call through")
+	}
+	// A successful trial closes it.
+	now = now.Add(10 * time.Minute)
+	broken = false
+	send()
+	if calls != 7 || state("flaky") != BreakerClosed {
+		t.Fatalf("successful trial: %d calls, breaker %s", calls, state("flaky"))
+	}
+	send()
+	if calls != 8 {
+		t.Fatalf("closed breaker skipped a call: %d calls", calls)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breakerNow = func() time.Time { return now }
+	defer func() { breakerNow = time.Now }()
+
+	settings, err := BreakerConfig{Failures: 1, Cooldown: "1m"}.settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := newCircuitBreaker("slow", settings)
+	breaker.record(true)
+	if state, _ := breaker.status(); state != BreakerOpen {
+		t.Fatalf("breaker %s, want open", state)
+	}
+	now = now.Add(time.Minute)
+	if !breaker.allow() {
+		t.Fatal("no trial call after the cooldown")
+	}
+	if state, _ := breaker.status(); state != BreakerHalfOpen {
+		t.Fatalf("breaker %s during the trial, want half-open", state)
+	}
+	if breaker.allow() {
+		t.Fatal("second call let through while the trial is in flight")
+	}
+}
+
+func TestCircuitBreakerTimeout(t *testing.T) {
+	manage := NewPluginManages()
+	if err := manage.SetBreaker(BreakerConfig{Failures: 2, Timeout: "1ms"}); err != nil {
+		t.Fatal(err)
+	}
+	testMonitor(manage, "slow", "", "TXSTART", func(data *collector.AllCollector) (byte, string) {
+		time.Sleep(5 * time.Millisecond)
+		return 0x00, ""
+	})
+	for i := 0; i < 2; i++ {
+		manage.Start()
+		manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+	}
+	if list := manage.ListPlugins(); len(list) != 1 || list[0].Breaker != BreakerOpen {
+		t.Fatalf("plugins %+v, want the slow one open", list)
+	}
+}
+
+func TestPluginSlowWarning(t *testing.T) {
+	manage := NewPluginManages()
+	if err := manage.SetBreaker(BreakerConfig{Failures: 2, Timeout: "1s", Slow: "5ms"}); err != nil {
+		t.Fatal(err)
+	}
+	testMonitor(manage, "slow", "", "TXSTART", func(data *collector.AllCollector) (byte, string) {
+		time.Sleep(20 * time.Millisecond)
+		return 0x00, ""
+	})
+	testMonitor(manage, "fast", "", "TXEND", func(data *collector.AllCollector) (byte, string) {
+		return 0x00, ""
+	})
+	var reports []collector.SlowPluginCollector
+	testMonitor(manage, "watch", "", "handle_PLUGIN_SLOW", func(data *collector.AllCollector) (byte, string) {
+		reports = append(reports, data.TransInfo.SlowPluginInfo)
+		return 0x00, ""
+	})
+	for i := 0; i < 2; i++ {
+		manage.Start()
+		manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+		manage.SendDataToPlugin("TXEND", collector.SendFlag("TXEND"))
+	}
+	if len(reports) != 2 {
+		t.Fatalf("got %d slow reports, want 2", len(reports))
+	}
+	for _, report := range reports {
+		if report.Plugin != "slow" || report.Opcode != "TXSTART" || report.Duration < int64(20*time.Millisecond) || report.Threshold != int64(5*time.Millisecond) {
+			t.Errorf("slow report %+v", report)
+		}
+	}
+	// Slow calls within the timeout are no failures.
+	if list := manage.ListPlugins(); len(list) != 3 || list[1].Breaker != BreakerClosed {
+		t.Errorf("plugins %+v, want the slow one closed", list)
+	}
+	if err := manage.SetBreaker(BreakerConfig{Slow: "soon"}); err == nil {
+		t.Error("invalid slow threshold accepted")
+	}
+}
+
+// Tests that the plugin list can be read over RPC while registration requests
+// are applied on the block processing side.
+func TestListPluginsConcurrentRegister(t *testing.T) {
+	manage := NewPluginManages()
+	testMonitor(manage, "steady", "monitor", "TXSTART", func(*collector.AllCollector) (byte, string) {
+		return 0x00, ""
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			testMonitor(manage, "churn", "monitor", "TXEND", func(*collector.AllCollector) (byte, string) {
+				return 0x00, ""
+			})
+			manage.UnRegisterPlg("churn")
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		if len(manage.ListPlugins()) == 0 {
+			t.Fatal("steady plugin missing from the list")
+		}
+	}
+	<-done
+}
@@ -0,0 +1,110 @@
+package pluginManage
+
+//add new file
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// PluginConfigPath is the manager level configuration read by SetUpPlugin. It
+// lives next to the plugin .so files and is optional.
+const PluginConfigPath = "/home/dan/plugin/plugin_config.json"
+
+// PluginConfig holds the manager level settings of the plugin subsystem.
+type PluginConfig struct {
+	// Disabled turns the plugin subsystem off entirely: no plugin is loaded
+	// and block processing skips every plugin hook.
+	Disabled   bool             `json:"disabled"`
+	Debug      bool             `json:"debug"` // print every event to stdout, for development
+	Exporters  []ExporterConfig `json:"exporters"`
+	Sampling   SamplingConfig   `json:"sampling"`
+	Filter     FilterConfig     `json:"filter"`
+	Dedup      bool             `json:"dedup"`
+	MaxDepth   int              `json:"maxcalldepth"` // deepest call layer emitted, deeper calls are summarized
+	MaxPayload int              `json:"maxpayload"`   // bytes of internal call input and output kept, 0 for all
+	Pools      PoolConfig       `json:"pools"`
+	Sockets    []SocketConfig   `json:"sockets"` // plugins running in sidecar processes
+	Log        LogConfig        `json:"log"`
+	ABIs       []ABIConfig      `json:"abis"` // contracts whose call inputs are delivered decoded
+	Breaker    BreakerConfig    `json:"breaker"`
+	Anomaly    AnomalyConfig    `json:"anomaly"`       // handle_TX_ANOMALY thresholds
+	Blocking   BlockingConfig   `json:"blocking"`      // opcodes enforce plugins may block on
+	Fields     []string         `json:"fields"`        // optional collector fields to fill in
+	Artifacts  []ArtifactConfig `json:"artifacts"`     // plugins downloaded before loading
+	Cache      string           `json:"artifactcache"` // directory the artifacts are kept in
+	Stall      StallConfig      `json:"stall"`         // sheds monitor plugins when dispatch falls behind
+	Redact     RedactConfig     `json:"redact"`        // calldata hidden from the exporters
+	Proofs     []ProofAccount   `json:"proofs"`        // accounts handle_ACCOUNT_PROOF proves
+}
+
+// LoadPluginConfig reads the configuration from path. A missing file is not an
+// error and yields the empty configuration.
+func LoadPluginConfig(path string) (*PluginConfig, error) {
+	config := &PluginConfig{}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ApplyConfig wires the configured exporters and settings into the manager.
+func (plg *PluginManages) ApplyConfig(config *PluginConfig) error {
+	plg.SetEnabled(!config.Disabled)
+	plg.SetSampling(config.Sampling)
+	plg.SetFilter(config.Filter)
+	plg.SetDedup(config.Dedup)
+	plg.SetMaxCallDepth(config.MaxDepth)
+	plg.SetMaxPayload(config.MaxPayload)
+	plg.SetPools(config.Pools)
+	if err := plg.SetLogConfig(config.Log); err != nil {
+		return err
+	}
+	if err := plg.LoadABIs(config.ABIs); err != nil {
+		return err
+	}
+	if err := plg.SetBreaker(config.Breaker); err != nil {
+		return err
+	}
+	if err := plg.SetAnomaly(config.Anomaly); err != nil {
+		return err
+	}
+	if err := plg.SetBlockingPolicy(config.Blocking); err != nil {
+		return err
+	}
+	if err := plg.SetStall(config.Stall); err != nil {
+		return err
+	}
+	if err := plg.SetRedaction(config.Redact); err != nil {
+		return err
+	}
+	fields, err := ParseFieldMask(config.Fields)
+	if err != nil {
+		return err
+	}
+	plg.SetFields(fields)
+	plg.SetProofAccounts(config.Proofs)
+	if config.Debug {
+		plg.AddExporter(NewStdoutExporter(ExporterConfig{}, nil))
+	}
+	for _, expcfg := range config.Exporters {
+		exporter, err := NewExporter(expcfg)
+		if err != nil {
+			return err
+		}
+		plg.AddExporter(exporter, expcfg.Opcodes...)
+	}
+	for _, sockcfg := range config.Sockets {
+		if err := plg.AddSocketPlugin(sockcfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
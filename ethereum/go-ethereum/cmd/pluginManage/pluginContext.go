@@ -0,0 +1,29 @@
+package pluginManage
+
+//add new file
+
+import (
+	"context"
+)
+
+// SetContext ties the dispatch to ctx, Process sets it for every block. Once
+// ctx is cancelled no more events are delivered or exported, the events
+// still queued for async plugins are abandoned and a subscriber that does
+// not keep up is no longer waited for.
+func (plg *PluginManages) SetContext(ctx context.Context) {
+	plg.ctx = ctx
+}
+
+// dispatchContext returns the context of the dispatch, never nil.
+func (plg *PluginManages) dispatchContext() context.Context {
+	if plg.ctx == nil {
+		return context.Background()
+	}
+	return plg.ctx
+}
+
+// cancelled reports whether the processing the events belong to was
+// cancelled.
+func (plg *PluginManages) cancelled() bool {
+	return plg.ctx != nil && plg.ctx.Err() != nil
+}
@@ -0,0 +1,81 @@
+package pluginManage
+
+//add new file
+
+import (
+	"github.com/zhidandeng/collector"
+)
+
+// repeatedCall counts the suppressed copies of one call payload.
+type repeatedCall struct {
+	opcode string
+	data   *collector.AllCollector
+	count  uint64
+}
+
+// SetDedup switches the suppression of byte-identical call events within a
+// transaction. It is off by default.
+func (plg *PluginManages) SetDedup(enabled bool) {
+	plg.dedup = enabled
+}
+
+// Dedup reports whether identical call events are suppressed.
+func (plg *PluginManages) Dedup() bool {
+	return plg.dedup
+}
+
+// duplicate reports whether data repeats a call event already emitted by the
+// current transaction, counting the repeat if so.
+func (plg *PluginManages) duplicate(opcode string, data *collector.AllCollector) bool {
+	if !plg.dedup || !callOps[opcode] {
+		return false
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return false
+	}
+	key := opcode + string(payload)
+	if plg.txCalls == nil {
+		plg.txCalls = make(map[string]*repeatedCall)
+	}
+	if call, ok := plg.txCalls[key]; ok {
+		call.count++
+		if call.count == 1 {
+			plg.repeats = append(plg.repeats, call)
+		}
+		return true
+	}
+	plg.txCalls[key] = &repeatedCall{opcode: opcode, data: data}
+	return false
+}
+
+// EndTx emits the summary events of the transaction that just executed,
+// ahead of its TXEND.
+func (plg *PluginManages) EndTx() {
+	if len(plg.repeats) > 0 {
+		plg.emitRepeats()
+	}
+	if plg.deepCalls != nil {
+		plg.emitDeepCalls()
+	}
+}
+
+// emitRepeats sends one handle_CALL_REPEAT event per suppressed call payload
+// of the transaction, carrying the call without its code and input and the
+// number of copies left out.
+func (plg *PluginManages) emitRepeats() {
+	repeats := plg.repeats
+	plg.txCalls, plg.repeats = nil, nil
+	if !plg.GetOpcodeRegister("handle_CALL_REPEAT") {
+		return
+	}
+	for _, call := range repeats {
+		info := call.data.TransInfo
+		info.Op = "handle_CALL_REPEAT"
+		info.CallType = call.opcode
+		info.CallInfo = collector.CallCollector{}
+		info.CreateInfo = collector.CreateCollector{}
+		info.RepeatCount = call.count
+		plg.SendDataToPlugin(info.Op, info.SendTransInfo(info.Op))
+	}
+}
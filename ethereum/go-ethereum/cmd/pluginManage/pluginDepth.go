@@ -0,0 +1,61 @@
+package pluginManage
+
+//add new file
+
+import (
+	"github.com/zhidandeng/collector"
+)
+
+// SetMaxCallDepth caps the call layer whose internal calls are emitted one
+// by one, the transaction itself being layer 1. The calls of deeper layers
+// are left out and summarized in one handle_DEEP_CALLS event ahead of TXEND,
+// which bounds the events of a transaction recursing on purpose. 0, the
+// default, emits calls at any depth.
+func (plg *PluginManages) SetMaxCallDepth(depth int) {
+	plg.maxCallDepth = depth
+}
+
+// MaxCallDepth returns the deepest call layer emitted, 0 without a cap.
+func (plg *PluginManages) MaxCallDepth() int {
+	return plg.maxCallDepth
+}
+
+// tooDeep reports whether data is a call below the depth cap, adding it to
+// the summary of the transaction if so. The handle_PRECOMPILE event of a
+// call repeats its TRANS_CALL and is left out without being counted.
+func (plg *PluginManages) tooDeep(opcode string, data *collector.AllCollector) bool {
+	layer := data.TransInfo.CallLayer
+	if plg.maxCallDepth <= 0 || !callOps[opcode] || layer <= plg.maxCallDepth {
+		return false
+	}
+	if opcode == "handle_PRECOMPILE" {
+		return true
+	}
+	if plg.deepCalls == nil {
+		plg.deepCalls = collector.NewDeepCallsCollector()
+		plg.deepCalls.MaxDepth = plg.maxCallDepth
+	}
+	plg.deepCalls.Calls++
+	if layer > plg.deepCalls.Deepest {
+		plg.deepCalls.Deepest = layer
+	}
+	// the subtree of a call right below the cap covers all deeper ones
+	if layer == plg.maxCallDepth+1 {
+		plg.deepCalls.GasUsed += data.TransInfo.GasUsedSubtree
+	}
+	return true
+}
+
+// emitDeepCalls sends the handle_DEEP_CALLS summary of the transaction.
+func (plg *PluginManages) emitDeepCalls() {
+	summary := plg.deepCalls
+	plg.deepCalls = nil
+	if !plg.GetOpcodeRegister("handle_DEEP_CALLS") {
+		return
+	}
+	info := collector.NewTransCollector()
+	info.Op = "handle_DEEP_CALLS"
+	info.TxHash = plg.txHash.String()
+	info.DeepCalls = *summary
+	plg.SendDataToPlugin(info.Op, info.SendTransInfo(info.Op))
+}
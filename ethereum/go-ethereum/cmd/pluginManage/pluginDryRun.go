@@ -0,0 +1,27 @@
+package pluginManage
+
+//add new file
+
+import (
+	"github.com/zhidandeng/collector"
+)
+
+// wouldBlock records the block decision of a dry-run plugin: it is logged
+// as a warning and emitted as handle_WOULD_BLOCK, while the transaction
+// goes on as if the plugin had allowed it.
+func (plg *PluginManages) wouldBlock(monitor *MonitorType, opcode string, level byte, reason string, data *collector.AllCollector) {
+	plg.StandardWarningReport(monitor.GetPluginName(), "dry-run block: "+reason, monitor.GetLogger(), opcode, 2, data)
+	if !plg.GetOpcodeRegister("handle_WOULD_BLOCK") {
+		return
+	}
+	info := collector.NewTransCollector()
+	info.Op = "handle_WOULD_BLOCK"
+	info.TxHash = plg.tx.TxHash
+	info.WouldBlockInfo = collector.WouldBlockCollector{
+		Plugin: monitor.GetPluginName(),
+		Opcode: opcode,
+		Level:  level,
+		Reason: reason,
+	}
+	plg.SendDataToPlugin(info.Op, info.SendTransInfo(info.Op))
+}
@@ -0,0 +1,314 @@
+package pluginManage
+
+//add new file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	defaultESIndex     = "noda-{date}"
+	defaultESRange     = 100000
+	defaultESBatch     = 500
+	defaultESBuffer    = 10000
+	defaultESInterval  = 5 * time.Second
+	defaultESRetries   = 3
+	defaultESRetryWait = 500 * time.Millisecond
+)
+
+// errESBufferFull is returned by Export when Elasticsearch does not keep up
+// and the buffer is full. The event is dropped.
+var errESBufferFull = errors.New("elasticsearch buffer full")
+
+// ESConfig holds the indexing settings of an elasticsearch exporter. The
+// cluster address is the URL of the exporter; requests carry basic auth
+// when User is set and the exporter's Auth settings otherwise.
+type ESConfig struct {
+	// Index is the index name pattern. {date} expands to the UTC day the
+	// event is indexed, {blockrange} to the RangeSize aligned block range
+	// of the event, e.g. noda-1000000-1099999.
+	Index         string `json:"index"`
+	RangeSize     uint64 `json:"rangesize"`
+	BatchSize     int    `json:"batchsize"`     // events per bulk request
+	FlushInterval string `json:"flushinterval"` // longest time an event waits for its bulk request
+	BufferSize    int    `json:"buffersize"`    // events queued while a bulk request is in flight
+	User          string `json:"user"`
+	Password      string `json:"password"`
+}
+
+// esDoc is one queued document with the index it goes to.
+type esDoc struct {
+	index string
+	body  []byte
+}
+
+type esBulkItem struct {
+	Status int `json:"status"`
+	Error  struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+type esBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []struct {
+		Index esBulkItem `json:"index"`
+	} `json:"items"`
+}
+
+// ESExporter indexes events in Elasticsearch through the _bulk API. Events
+// are queued by Export and sent in batches by a background goroutine once a
+// batch is full or the flush interval passed. Items the cluster rejects
+// with a retryable status are resent on their own, the others are dropped.
+type ESExporter struct {
+	name       string
+	url        string
+	config     ESConfig
+	auth       AuthConfig
+	interval   time.Duration
+	maxRetries int
+	retryWait  time.Duration
+	client     *http.Client
+
+	queue   chan esDoc
+	dropped metrics.Counter
+
+	lock   sync.RWMutex
+	closed bool
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// NewESExporter creates an elasticsearch exporter from config.
+func NewESExporter(config ExporterConfig) (*ESExporter, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("elasticsearch exporter %q has no url", config.Name)
+	}
+	es := config.ES
+	if es.Index == "" {
+		es.Index = defaultESIndex
+	}
+	if es.RangeSize == 0 {
+		es.RangeSize = defaultESRange
+	}
+	if es.BatchSize <= 0 {
+		es.BatchSize = defaultESBatch
+	}
+	if es.BufferSize <= 0 {
+		es.BufferSize = defaultESBuffer
+	}
+	interval := defaultESInterval
+	if es.FlushInterval != "" {
+		var err error
+		if interval, err = time.ParseDuration(es.FlushInterval); err != nil || interval <= 0 {
+			return nil, fmt.Errorf("elasticsearch exporter %q has invalid flush interval %q", config.Name, es.FlushInterval)
+		}
+	}
+	retries := config.MaxRetries
+	if retries <= 0 {
+		retries = defaultESRetries
+	}
+	e := &ESExporter{
+		name:       config.Name,
+		url:        strings.TrimRight(config.URL, "/") + "/_bulk",
+		config:     es,
+		auth:       config.Auth,
+		interval:   interval,
+		maxRetries: retries,
+		retryWait:  defaultESRetryWait,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		queue:      make(chan esDoc, es.BufferSize),
+		dropped:    metrics.GetOrRegisterCounter("plugin/exporter/"+config.Name+"/dropped", nil),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go e.loop()
+	return e, nil
+}
+
+func (e *ESExporter) Name() string { return e.name }
+
+// IndexName expands the index pattern for an event of block number.
+func (e *ESExporter) IndexName(number uint64, now time.Time) string {
+	index := strings.Replace(e.config.Index, "{date}", now.UTC().Format("2006.01.02"), -1)
+	if strings.Contains(index, "{blockrange}") {
+		start := number / e.config.RangeSize * e.config.RangeSize
+		index = strings.Replace(index, "{blockrange}", fmt.Sprintf("%d-%d", start, start+e.config.RangeSize-1), -1)
+	}
+	return index
+}
+
+// Export queues env for the next bulk request. It fails without blocking
+// when the buffer is full.
+func (e *ESExporter) Export(env *Envelope) error {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	if e.closed {
+		return fmt.Errorf("elasticsearch exporter %q is closed", e.name)
+	}
+	select {
+	case e.queue <- esDoc{index: e.IndexName(env.BlockNumber, time.Now()), body: body}:
+		return nil
+	default:
+		e.dropped.Inc(1)
+		return errESBufferFull
+	}
+}
+
+func (e *ESExporter) loop() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	batch := make([]esDoc, 0, e.config.BatchSize)
+	for {
+		select {
+		case doc := <-e.queue:
+			if batch = append(batch, doc); len(batch) >= e.config.BatchSize {
+				e.bulk(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				e.bulk(batch)
+				batch = batch[:0]
+			}
+		case <-e.quit:
+			for n := len(e.queue); n > 0; n-- {
+				batch = append(batch, <-e.queue)
+			}
+			for len(batch) > 0 {
+				size := len(batch)
+				if size > e.config.BatchSize {
+					size = e.config.BatchSize
+				}
+				e.bulk(batch[:size])
+				batch = batch[size:]
+			}
+			return
+		}
+	}
+}
+
+// bulk indexes docs, resending the items that failed with a retryable
+// status until they are in or the retries are used up.
+func (e *ESExporter) bulk(docs []esDoc) {
+	for attempt := 0; len(docs) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(e.retryWait * time.Duration(attempt))
+		}
+		failed, err := e.send(docs)
+		if err != nil {
+			log.Warn("Plugin elasticsearch bulk request failed", "exporter", e.name, "docs", len(docs), "attempt", attempt+1, "err", err)
+			failed = docs
+		}
+		if attempt+1 >= e.maxRetries {
+			if len(failed) > 0 {
+				log.Warn("Plugin elasticsearch exporter gave up on documents", "exporter", e.name, "docs", len(failed))
+				e.dropped.Inc(int64(len(failed)))
+			}
+			return
+		}
+		docs = failed
+	}
+}
+
+// send posts one bulk request and returns the documents worth retrying.
+func (e *ESExporter) send(docs []esDoc) ([]esDoc, error) {
+	var body bytes.Buffer
+	for _, doc := range docs {
+		body.WriteString(`{"index":{"_index":` + strconv.Quote(doc.index) + "}}\n")
+		body.Write(doc.body)
+		body.WriteByte('\n')
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if e.config.User != "" {
+		req.SetBasicAuth(e.config.User, e.config.Password)
+	} else {
+		e.auth.Apply(req)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if esRetryable(resp.StatusCode) {
+			return nil, fmt.Errorf("got %s", resp.Status)
+		}
+		log.Warn("Plugin elasticsearch bulk request rejected", "exporter", e.name, "docs", len(docs), "status", resp.Status)
+		e.dropped.Inc(int64(len(docs)))
+		return nil, nil
+	}
+	var result esBulkResponse
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, err
+	}
+	if !result.Errors {
+		return nil, nil
+	}
+	if len(result.Items) != len(docs) {
+		return nil, fmt.Errorf("bulk response has %d items for %d documents", len(result.Items), len(docs))
+	}
+	var retry []esDoc
+	for i, item := range result.Items {
+		switch status := item.Index.Status; {
+		case status < 300:
+		case esRetryable(status):
+			retry = append(retry, docs[i])
+		default:
+			log.Warn("Plugin elasticsearch rejected document", "exporter", e.name, "index", docs[i].index, "status", status, "err", item.Index.Error.Reason)
+			e.dropped.Inc(1)
+		}
+	}
+	return retry, nil
+}
+
+// esRetryable reports whether a status is worth another attempt: the cluster
+// pushing back or failing internally.
+func esRetryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// Close stops accepting events and returns once the queued ones went out in
+// their bulk requests.
+func (e *ESExporter) Close() error {
+	e.lock.Lock()
+	if e.closed {
+		e.lock.Unlock()
+		return nil
+	}
+	e.closed = true
+	e.lock.Unlock()
+
+	close(e.quit)
+	<-e.done
+	e.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,102 @@
+package pluginManage
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhidandeng/collector"
+)
+
+func TestESExporterBulkAndPartialRetry(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		requests [][]string // NDJSON lines of every bulk request
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/_bulk" || req.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("unexpected request %s %s", req.URL.Path, req.Header.Get("Content-Type"))
+		}
+		if user, pass, _ := req.BasicAuth(); user != "elastic" || pass != "changeme" {
+			t.Errorf("request without credentials")
+		}
+		var lines []string
+		for scanner := bufio.NewScanner(req.Body); scanner.Scan(); {
+			lines = append(lines, scanner.Text())
+		}
+		lock.Lock()
+		requests = append(requests, lines)
+		first := len(requests) == 1
+		lock.Unlock()
+
+		if first {
+			// indexed, pushed back, rejected for good
+			fmt.Fprint(w, `{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":429,"error":{"type":"es_rejected_execution_exception"}}},{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"errors":false,"items":[{"index":{"status":201}}]}`)
+	}))
+	defer server.Close()
+
+	exp, err := NewESExporter(ExporterConfig{
+		Name: "es",
+		URL:  server.URL,
+		ES:   ESConfig{Index: "noda-{blockrange}", RangeSize: 1000, BatchSize: 3, FlushInterval: "1h", User: "elastic", Password: "changeme"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp.retryWait = time.Millisecond
+
+	opcodes := []string{"TXSTART", "TRANS_CALL", "TXEND"}
+	for i, opcode := range opcodes {
+		env := &Envelope{Opcode: opcode, BlockNumber: uint64(999 + i), Payload: collector.SendFlag(opcode)}
+		if err := exp.Export(env); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The full batch goes out on its own, the retry follows it.
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		lock.Lock()
+		n := len(requests)
+		lock.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d bulk requests, want 2", n)
+		}
+	}
+	exp.Close()
+
+	first := requests[0]
+	if len(first) != 2*len(opcodes) {
+		t.Fatalf("first bulk request has %d lines, want %d", len(first), 2*len(opcodes))
+	}
+	wantIndex := []string{"noda-0-999", "noda-1000-1999", "noda-1000-1999"}
+	for i, opcode := range opcodes {
+		if want := `{"index":{"_index":"` + wantIndex[i] + `"}}`; first[2*i] != want {
+			t.Errorf("action line %d is %s, want %s", i, first[2*i], want)
+		}
+		var env Envelope
+		if err := json.Unmarshal([]byte(first[2*i+1]), &env); err != nil {
+			t.Fatalf("document %d: %v", i, err)
+		}
+		if env.Opcode != opcode {
+			t.Errorf("document %d is %s, want %s", i, env.Opcode, opcode)
+		}
+	}
+	// Only the pushed back document is sent again.
+	retry := requests[1]
+	if len(retry) != 2 || !strings.Contains(retry[1], `"opcode":"TRANS_CALL"`) {
+		t.Errorf("retry request is %v, want the TRANS_CALL document only", retry)
+	}
+	if len(requests) != 2 {
+		t.Errorf("got %d bulk requests, want 2", len(requests))
+	}
+}
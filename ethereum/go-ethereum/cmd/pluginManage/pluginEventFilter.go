@@ -0,0 +1,59 @@
+package pluginManage
+
+//add new file
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EventFilterFunc decides in the host whether a plugin wants the opcode event
+// about to be emitted by the contract currently executing. It runs before the
+// collector payload is built, so it must not rely on anything but its
+// arguments and has to be cheap. contract is the zero address while no
+// contract is on the call stack, e.g. during a contract creation transaction.
+type EventFilterFunc func(opcode string, contract common.Address) bool
+
+// ContractFilter returns a filter accepting the events of the given contracts.
+func ContractFilter(contracts []common.Address) EventFilterFunc {
+	set := make(map[common.Address]bool, len(contracts))
+	for _, contract := range contracts {
+		set[contract] = true
+	}
+	return func(opcode string, contract common.Address) bool {
+		return set[contract]
+	}
+}
+
+// currentContract returns the contract on top of the call stack.
+func (plg *PluginManages) currentContract() common.Address {
+	if len(plg.tx.CALL_STACK) == 0 {
+		return common.Address{}
+	}
+	top := plg.tx.CALL_STACK[len(plg.tx.CALL_STACK)-1]
+	if i := strings.IndexByte(top, '#'); i >= 0 {
+		top = top[:i]
+	}
+	return common.HexToAddress(top)
+}
+
+// wants reports whether monitor takes the opcode event about to be emitted:
+// the current block is in its range, the current transaction in its sample
+// and its event filter, if any, accepts the event. Block level events do not
+// belong to a transaction or contract and are neither sampled nor filtered.
+func (plg *PluginManages) wants(monitor *MonitorType, opcode string) bool {
+	if !monitor.InRange(plg.blockNumber) {
+		return false
+	}
+	if blockLevelOps[opcode] {
+		return true
+	}
+	if !monitor.IsEnforce() && !monitor.Sampling.Sampled(plg.txHash) {
+		return false
+	}
+	if monitor.EventFilter == nil {
+		return true
+	}
+	return monitor.EventFilter(opcode, plg.currentContract())
+}
@@ -0,0 +1,92 @@
+package pluginManage
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhidandeng/collector"
+)
+
+// emitSload plays the interpreter emitting an SLOAD in contract: the payload
+// is only built when a plugin wants the event. It reports whether it was.
+func emitSload(manage *PluginManages, contract common.Address) bool {
+	manage.TxState().CALL_STACK = []string{contract.String() + "#1"}
+	if !manage.GetOpcodeRegister("SLOAD") {
+		return false
+	}
+	ins := collector.NewCollector()
+	ins.OpName = "SLOAD"
+	ins.AccountValue.CallContract = contract.String()
+	manage.SendDataToPlugin("SLOAD", ins.SendInsInfo())
+	return true
+}
+
+func TestEventFilter(t *testing.T) {
+	watched, other := common.Address{0xaa}, common.Address{0xbb}
+
+	manage := NewPluginManages()
+	var received []string
+	monitor := testMonitor(manage, "watcher", "", "SLOAD", func(data *collector.AllCollector) (byte, string) {
+		received = append(received, data.InsInfo.AccountValue.CallContract)
+		return 0x00, ""
+	})
+	monitor.SetEventFilter(ContractFilter([]common.Address{watched}))
+	manage.Start()
+
+	if emitSload(manage, other) {
+		t.Fatal("event of a filtered out contract was built")
+	}
+	if !emitSload(manage, watched) {
+		t.Fatal("event of a watched contract was not built")
+	}
+	// Another plugin taking everything makes the event built, the filtered
+	// one still only receives its contract.
+	var all int
+	testMonitor(manage, "all", "", "SLOAD", func(data *collector.AllCollector) (byte, string) {
+		all++
+		return 0x00, ""
+	}).SetStatus(true)
+	if !emitSload(manage, other) {
+		t.Fatal("event wanted by an unfiltered plugin was not built")
+	}
+	if len(received) != 1 || received[0] != watched.String() || all != 1 {
+		t.Fatalf("filtered plugin received %v, unfiltered %d events", received, all)
+	}
+}
+
+// BenchmarkEventFilter emits SLOADs spread over 100 contracts to a plugin
+// watching one of them, with the filter applied in the host or by the
+// plugin itself after dispatch.
+func BenchmarkEventFilter(b *testing.B) {
+	contracts := make([]common.Address, 100)
+	for i := range contracts {
+		contracts[i] = common.Address{byte(i + 1)}
+	}
+	watched := ContractFilter(contracts[:1])
+
+	run := func(b *testing.B, inHost bool) {
+		manage := NewPluginManages()
+		monitor := testMonitor(manage, "watcher", "", "SLOAD", func(data *collector.AllCollector) (byte, string) {
+			// the plugin looks at the contract either way, the host filter
+			// only saves building and dispatching the events it discards
+			watched("SLOAD", common.HexToAddress(data.InsInfo.AccountValue.CallContract))
+			return 0x00, ""
+		})
+		if inHost {
+			monitor.SetEventFilter(watched)
+		}
+		manage.Start()
+
+		built := 0
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if emitSload(manage, contracts[i%len(contracts)]) {
+				built++
+			}
+		}
+		b.ReportMetric(float64(built)/float64(b.N), "payloads/op")
+	}
+	b.Run("plugin", func(b *testing.B) { run(b, false) })
+	b.Run("host", func(b *testing.B) { run(b, true) })
+}
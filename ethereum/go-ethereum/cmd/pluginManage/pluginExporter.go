@@ -0,0 +1,318 @@
+package pluginManage
+
+//add new file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/zhidandeng/collector"
+)
+
+// ErrExporterUnauthorized is returned once the collection service has rejected
+// the credentials of an exporter. The exporter stops retrying after that.
+var ErrExporterUnauthorized = errors.New("exporter credentials rejected")
+
+// Envelope is the stable top-level record an exporter ships for every
+// collector event.
+type Envelope struct {
+	Opcode      string                  `json:"opcode"`
+	ChainID     string                  `json:"chainid"`
+	BlockNumber uint64                  `json:"blocknumber"`
+	TxHash      string                  `json:"txhash"`
+	Payload     *collector.AllCollector `json:"payload"`
+}
+
+// Exporter pushes collector data out of the node to an external service.
+type Exporter interface {
+	Name() string
+	Export(env *Envelope) error
+	Close() error
+}
+
+// BlockFlusher is implemented by exporters that hold events back until the
+// block they belong to is processed.
+type BlockFlusher interface {
+	FlushBlock()
+}
+
+// ExporterConfig describes one exporter in plugin_config.json.
+type ExporterConfig struct {
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`
+	URL           string            `json:"url"`
+	Path          string            `json:"path"`
+	FsyncInterval string            `json:"fsyncinterval"`
+	Opcodes       []string          `json:"opcodes"`
+	Format        string            `json:"format"`  // payload encoding of an http exporter, "json" (default) or "cbor"
+	Formats       map[string]string `json:"formats"` // payload encoding by opcode, overriding Format
+	Auth          AuthConfig        `json:"auth"`
+	MaxRetries    int               `json:"maxretries"`
+	Size          int               `json:"size"`  // events kept by a ring exporter
+	Queue         int               `json:"queue"` // events queued for a goroutine exporting them, 0 exports inline but for http, negative always inline
+	AMQP          AMQPConfig        `json:"amqp"`
+	NATS          NATSConfig        `json:"nats"`
+	ES            ESConfig          `json:"elasticsearch"`
+}
+
+// defaultHTTPQueue is the queue of an http exporter configured without one,
+// posts are network round trips block processing must not wait for.
+const defaultHTTPQueue = 1024
+
+// NewExporter creates the exporter described by config. Every exporter of the
+// configuration gets the events of its own opcodes from the one emission; one
+// with a queue is isolated from the others, see QueuedExporter. HTTP exporters
+// are queued unless the queue is set negative.
+func NewExporter(config ExporterConfig) (Exporter, error) {
+	exporter, err := newExporter(config)
+	if err != nil {
+		return nil, err
+	}
+	size := config.Queue
+	if size == 0 && (config.Type == "http" || config.Type == "") {
+		size = defaultHTTPQueue
+	}
+	if size <= 0 {
+		return exporter, nil
+	}
+	return NewQueuedExporter(exporter, size), nil
+}
+
+func newExporter(config ExporterConfig) (Exporter, error) {
+	if !collector.ValidFormat(config.Format) {
+		return nil, fmt.Errorf("unknown format %q for exporter %q", config.Format, config.Name)
+	}
+	if config.Format != "" && config.Format != collector.FormatJSON && config.Type != "http" && config.Type != "" {
+		return nil, fmt.Errorf("exporter %q of type %s only ships json", config.Name, config.Type)
+	}
+	for opcode, format := range config.Formats {
+		if !collector.ValidFormat(format) {
+			return nil, fmt.Errorf("unknown format %q for opcode %s of exporter %q", format, opcode, config.Name)
+		}
+		if format != "" && format != collector.FormatJSON && config.Type != "http" && config.Type != "" {
+			return nil, fmt.Errorf("exporter %q of type %s only ships json", config.Name, config.Type)
+		}
+	}
+	switch config.Type {
+	case "http", "":
+		return NewHTTPExporter(config), nil
+	case "file":
+		return NewFileExporter(config)
+	case "ring":
+		return NewRingExporter(config), nil
+	case "stdout":
+		return NewStdoutExporter(config, nil), nil
+	case "amqp":
+		return NewAMQPExporter(config, amqpDial)
+	case "nats":
+		return NewNATSExporter(config)
+	case "elasticsearch":
+		return NewESExporter(config)
+	default:
+		return nil, fmt.Errorf("unknown exporter type %q for exporter %q", config.Type, config.Name)
+	}
+}
+
+// encodePayload encodes data in format, JSON unless CBOR is asked for.
+func encodePayload(format string, data *collector.AllCollector) ([]byte, error) {
+	if format == collector.FormatCBOR {
+		return collector.MarshalCBOR(data)
+	}
+	return json.Marshal(data)
+}
+
+// AuthConfig holds the bearer token or API key an exporter attaches to its
+// requests. The token itself is never printed, String only reports where it
+// came from.
+type AuthConfig struct {
+	Scheme   string `json:"scheme"`   // "bearer" (default) or "apikey"
+	Header   string `json:"header"`   // header used by the apikey scheme, X-API-Key by default
+	Token    string `json:"token"`    // literal token
+	TokenEnv string `json:"tokenenv"` // environment variable holding the token, wins over Token
+}
+
+func (a *AuthConfig) token() string {
+	if a.TokenEnv != "" {
+		if token := os.Getenv(a.TokenEnv); token != "" {
+			return token
+		}
+	}
+	return a.Token
+}
+
+func (a *AuthConfig) header() string {
+	if a.Header != "" {
+		return a.Header
+	}
+	return "X-API-Key"
+}
+
+func (a *AuthConfig) isAPIKey() bool {
+	return strings.EqualFold(a.Scheme, "apikey")
+}
+
+// Enabled reports whether a token is configured.
+func (a *AuthConfig) Enabled() bool {
+	return a.token() != ""
+}
+
+// Apply attaches the credentials to an outgoing request.
+func (a *AuthConfig) Apply(req *http.Request) {
+	token := a.token()
+	if token == "" {
+		return
+	}
+	if a.isAPIKey() {
+		req.Header.Set(a.header(), token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+func (a AuthConfig) String() string {
+	switch {
+	case a.TokenEnv != "":
+		return "token from $" + a.TokenEnv
+	case a.Token != "":
+		return "token from config"
+	default:
+		return "no token"
+	}
+}
+
+// HTTPExporter posts every collector event to a collection service, as JSON
+// or in the configured format tagged by the Content-Type header. The format
+// may differ by opcode, e.g. CBOR for a high-volume event and JSON for the
+// rest.
+type HTTPExporter struct {
+	name       string
+	url        string
+	format     string
+	formats    map[string]string // format by opcode, overriding format
+	auth       AuthConfig
+	maxRetries int
+	retryWait  time.Duration
+	client     *http.Client
+
+	lock     sync.Mutex // guards format and rejected, never held across a post
+	rejected error
+}
+
+// NewHTTPExporter creates an HTTP exporter from config.
+func NewHTTPExporter(config ExporterConfig) *HTTPExporter {
+	retries := config.MaxRetries
+	if retries <= 0 {
+		retries = 3
+	}
+	exporter := &HTTPExporter{
+		name:       config.Name,
+		url:        config.URL,
+		format:     config.Format,
+		formats:    make(map[string]string, len(config.Formats)),
+		auth:       config.Auth,
+		maxRetries: retries,
+		retryWait:  200 * time.Millisecond,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	for opcode, format := range config.Formats {
+		exporter.formats[opcode] = format
+	}
+	return exporter
+}
+
+func (e *HTTPExporter) Name() string { return e.name }
+
+// SetFormat changes the encoding of the following posts, but for the opcodes
+// configured with their own.
+func (e *HTTPExporter) SetFormat(format string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.format = format
+}
+
+// Export posts data, retrying on transport errors and 5xx responses. Every
+// attempt sends the same body and the event key as Idempotency-Key, so the
+// service can drop the copies of a post that got through but failed to
+// answer. A 401 or 403 answer disables the exporter: the call and every later
+// one fail with ErrExporterUnauthorized without touching the network again.
+func (e *HTTPExporter) Export(env *Envelope) error {
+	opcode := env.Opcode
+	e.lock.Lock()
+	rejected, format := e.rejected, e.format
+	e.lock.Unlock()
+
+	if rejected != nil {
+		return rejected
+	}
+	if own, ok := e.formats[opcode]; ok {
+		format = own
+	}
+	body, err := encodePayload(format, env.Payload)
+	if err != nil {
+		return err
+	}
+	var key string
+	if env.Payload != nil {
+		key = env.Payload.EventKey
+	}
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = e.post(opcode, format, key, body)
+		if err == nil || !retry || attempt+1 >= e.maxRetries {
+			break
+		}
+		time.Sleep(e.retryWait * time.Duration(attempt+1))
+	}
+	if errors.Is(err, ErrExporterUnauthorized) {
+		e.lock.Lock()
+		e.rejected = err
+		e.lock.Unlock()
+		log.Error("Plugin exporter credentials rejected, exporter disabled", "exporter", e.name, "auth", e.auth, "err", err)
+	}
+	return err
+}
+
+// post sends one request and reports whether a failure is worth retrying.
+func (e *HTTPExporter) post(opcode, format, key string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", collector.ContentType(format))
+	req.Header.Set("X-Noda-Opcode", opcode)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	e.auth.Apply(req)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return false, fmt.Errorf("%w: exporter %q got %s", ErrExporterUnauthorized, e.name, resp.Status)
+	case resp.StatusCode >= 500:
+		return true, fmt.Errorf("exporter %q got %s", e.name, resp.Status)
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("exporter %q got %s", e.name, resp.Status)
+	}
+	return false, nil
+}
+
+func (e *HTTPExporter) Close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
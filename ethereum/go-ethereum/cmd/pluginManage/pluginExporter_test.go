@@ -0,0 +1,305 @@
+package pluginManage
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhidandeng/collector"
+)
+
+func TestHTTPExporterSendsBearerToken(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	os.Setenv("NODA_TEST_EXPORTER_TOKEN", "s3cret")
+	defer os.Unsetenv("NODA_TEST_EXPORTER_TOKEN")
+
+	exp := NewHTTPExporter(ExporterConfig{Name: "test", URL: srv.URL, Auth: AuthConfig{TokenEnv: "NODA_TEST_EXPORTER_TOKEN"}})
+	if err := exp.Export(&Envelope{Opcode: "TXSTART", Payload: collector.SendFlag("TXSTART")}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if got != "Bearer s3cret" {
+		t.Fatalf("authorization header mismatch: have %q, want %q", got, "Bearer s3cret")
+	}
+}
+
+func TestHTTPExporterSendsAPIKey(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Collector-Key")
+	}))
+	defer srv.Close()
+
+	exp := NewHTTPExporter(ExporterConfig{URL: srv.URL, Auth: AuthConfig{Scheme: "apikey", Header: "X-Collector-Key", Token: "k"}})
+	if err := exp.Export(&Envelope{Opcode: "TXSTART", Payload: collector.SendFlag("TXSTART")}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if got != "k" {
+		t.Fatalf("api key header mismatch: have %q, want %q", got, "k")
+	}
+}
+
+func TestHTTPExporterCBOR(t *testing.T) {
+	var (
+		contentType string
+		received    collector.AllCollector
+		decodeErr   error
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ := ioutil.ReadAll(r.Body)
+		decodeErr = collector.Unmarshal(collector.FormatCBOR, body, &received)
+	}))
+	defer srv.Close()
+
+	exp, err := NewExporter(ExporterConfig{Name: "cbor", URL: srv.URL, Format: collector.FormatCBOR, Queue: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := collector.NewTransCollector()
+	tc.TxHash = "0x01"
+	tc.CallInfo.InputData = []byte{0xa9, 0x05, 0x9c, 0xbb}
+	if err := exp.Export(&Envelope{Opcode: "EXTERNALINFOSTART", Payload: tc.SendTransInfo("EXTERNALINFOSTART")}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if contentType != "application/cbor" {
+		t.Errorf("content type %q, want application/cbor", contentType)
+	}
+	if decodeErr != nil || received.TransInfo.TxHash != "0x01" || len(received.TransInfo.CallInfo.InputData) != 4 {
+		t.Errorf("received %+v (%v)", received.TransInfo, decodeErr)
+	}
+	if _, err := NewExporter(ExporterConfig{Name: "file", Type: "file", Path: "x", Format: collector.FormatCBOR}); err == nil {
+		t.Error("file exporter accepted the cbor format")
+	}
+}
+
+func TestFormatSwitchAtBlockBoundary(t *testing.T) {
+	var (
+		lock  sync.Mutex
+		posts []string // content type and decoded tx hash of every post
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		format := collector.FormatJSON
+		if r.Header.Get("Content-Type") == "application/cbor" {
+			format = collector.FormatCBOR
+		}
+		var received collector.AllCollector
+		if err := collector.Unmarshal(format, body, &received); err != nil {
+			t.Errorf("undecodable %s post: %v", format, err)
+		}
+		lock.Lock()
+		posts = append(posts, format+" "+received.TransInfo.TxHash)
+		lock.Unlock()
+	}))
+	defer srv.Close()
+
+	manage := NewPluginManages()
+	exp, err := NewExporter(ExporterConfig{Name: "http", URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manage.AddExporter(exp, "EXTERNALINFOSTART")
+	emit := func(hash string) {
+		manage.BeginTx(common.HexToHash(hash), common.Address{}, nil)
+		tc := collector.NewTransCollector()
+		tc.TxHash = hash
+		manage.SendDataToPlugin("EXTERNALINFOSTART", tc.SendTransInfo("EXTERNALINFOSTART"))
+	}
+	manage.SetBlockContext(big.NewInt(1), big.NewInt(1))
+	emit("0x01")
+	if err := manage.RequestFormat("protobuf"); err == nil {
+		t.Error("unknown format accepted")
+	}
+	if err := manage.RequestFormat(collector.FormatCBOR); err != nil {
+		t.Fatal(err)
+	}
+	emit("0x02") // the block already started in JSON
+	manage.SetBlockContext(big.NewInt(1), big.NewInt(2))
+	emit("0x03")
+	if format := manage.Format(); format != collector.FormatCBOR {
+		t.Errorf("active format %q, want cbor", format)
+	}
+	// The http exporter is queued, the switch must still land between the
+	// events of the two blocks.
+	if _, ok := exp.(*QueuedExporter); !ok {
+		t.Errorf("http exporter is %T, want it queued", exp)
+	}
+	exp.Close()
+	want := []string{"json 0x01", "json 0x02", "cbor 0x03"}
+	if !reflect.DeepEqual(posts, want) {
+		t.Errorf("posts %v, want %v", posts, want)
+	}
+}
+
+func TestHTTPExporterRejectedToken(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	exp := NewHTTPExporter(ExporterConfig{URL: srv.URL, MaxRetries: 5, Auth: AuthConfig{Token: "bad"}})
+	for i := 0; i < 3; i++ {
+		err := exp.Export(&Envelope{Opcode: "TXSTART", Payload: collector.SendFlag("TXSTART")})
+		if !errors.Is(err, ErrExporterUnauthorized) {
+			t.Fatalf("export %d: have error %v, want %v", i, err, ErrExporterUnauthorized)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("exporter kept retrying after rejection: %d requests", n)
+	}
+}
+
+func TestAuthConfigString(t *testing.T) {
+	auth := AuthConfig{Token: "s3cret"}
+	if s := auth.String(); s != "token from config" {
+		t.Errorf("String leaks or mislabels token: %q", s)
+	}
+}
+
+func TestHTTPExporterRetryKeepsEventKey(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		headers []string
+		keys    []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data collector.AllCollector
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &data); err != nil {
+			t.Errorf("undecodable post: %v", err)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		headers = append(headers, r.Header.Get("Idempotency-Key"))
+		keys = append(keys, data.EventKey)
+		// the first attempt is processed but answered with an error
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	manage := NewPluginManages()
+	exp := NewHTTPExporter(ExporterConfig{Name: "test", URL: srv.URL})
+	exp.retryWait = time.Millisecond
+	manage.AddExporter(exp, "TXSTART")
+	manage.SetBlockContext(big.NewInt(1), big.NewInt(7))
+	manage.Start()
+	manage.BeginTx(testTxHash(1), common.Address{}, nil)
+	manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+
+	want := TraceID(7, testTxHash(1)) + "-1"
+	if len(keys) != 2 {
+		t.Fatalf("got %d posts, want the failed one and its retry", len(keys))
+	}
+	for i := range keys {
+		if keys[i] != want || headers[i] != want {
+			t.Errorf("post %d: event key %q, header %q, want %q", i, keys[i], headers[i], want)
+		}
+	}
+}
+
+func TestHTTPExporterFormatPerOpcode(t *testing.T) {
+	var (
+		lock  sync.Mutex
+		posts = make(map[string]string)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		format := collector.FormatJSON
+		if r.Header.Get("Content-Type") == "application/cbor" {
+			format = collector.FormatCBOR
+		}
+		var received collector.AllCollector
+		if err := collector.Unmarshal(format, body, &received); err != nil {
+			t.Errorf("undecodable %s post: %v", format, err)
+		}
+		lock.Lock()
+		posts[r.Header.Get("X-Noda-Opcode")] = format
+		lock.Unlock()
+	}))
+	defer srv.Close()
+
+	config := ExporterConfig{Name: "http", URL: srv.URL, Queue: -1, Formats: map[string]string{
+		"LOG1":            collector.FormatJSON,
+		"EXTERNALINFOEND": collector.FormatCBOR,
+	}}
+	manage := NewPluginManages()
+	exp, err := NewExporter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manage.AddExporter(exp)
+	emit := func(block int64) {
+		manage.SetBlockContext(big.NewInt(1), big.NewInt(block))
+		manage.BeginTx(testTxHash(int(block)), common.Address{}, nil)
+		manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+		manage.SendDataToPlugin("LOG1", collector.NewCollector().SendInsInfo())
+		manage.SendDataToPlugin("EXTERNALINFOEND", collector.NewTransCollector().SendTransInfo("EXTERNALINFOEND"))
+	}
+	emit(1)
+	want := map[string]string{"TXSTART": collector.FormatJSON, "LOG1": collector.FormatJSON, "EXTERNALINFOEND": collector.FormatCBOR}
+	if !reflect.DeepEqual(posts, want) {
+		t.Errorf("posts by opcode %v, want %v", posts, want)
+	}
+	// A runtime switch moves the opcodes without a format of their own.
+	if err := manage.RequestFormat(collector.FormatCBOR); err != nil {
+		t.Fatal(err)
+	}
+	emit(2)
+	want["TXSTART"] = collector.FormatCBOR
+	if !reflect.DeepEqual(posts, want) {
+		t.Errorf("posts by opcode after the switch %v, want %v", posts, want)
+	}
+
+	config.Type, config.Path = "file", filepath.Join(t.TempDir(), "events.ndjson")
+	if _, err := NewExporter(config); err == nil {
+		t.Error("file exporter accepted a cbor opcode")
+	}
+	config.Type, config.Formats = "http", map[string]string{"LOG1": "protobuf"}
+	if _, err := NewExporter(config); err == nil {
+		t.Error("unknown opcode format accepted")
+	}
+}
+
+// Tests that a post in flight does not hold up a format switch.
+func TestHTTPExporterSetFormatDuringPost(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	exp := NewHTTPExporter(ExporterConfig{Name: "slow", URL: srv.URL, MaxRetries: 1})
+	go exp.Export(&Envelope{Opcode: "TXSTART", Payload: collector.SendFlag("TXSTART")})
+	time.Sleep(50 * time.Millisecond) // let the post reach the server
+
+	switched := make(chan struct{})
+	go func() {
+		exp.SetFormat(collector.FormatCBOR)
+		close(switched)
+	}()
+	select {
+	case <-switched:
+	case <-time.After(time.Second):
+		t.Fatal("SetFormat blocked by the post in flight")
+	}
+}
@@ -0,0 +1,64 @@
+package pluginManage
+
+//add new file
+
+import (
+	"fmt"
+)
+
+// FieldMask selects the optional collector fields that are filled in. They
+// cost extra state reads for every transaction and stay empty by default.
+type FieldMask uint
+
+const (
+	// FieldPreState fills trans_prestate of EXTERNALINFOSTART with the
+	// sender and recipient accounts as they were before the transaction.
+	FieldPreState FieldMask = 1 << iota
+	// FieldPostState fills trans_poststate of EXTERNALINFOEND with the same
+	// accounts after the transaction.
+	FieldPostState
+)
+
+// fieldNames are the names the optional fields go by in the configuration.
+var fieldNames = map[string]FieldMask{
+	"prestate":  FieldPreState,
+	"poststate": FieldPostState,
+}
+
+// ParseFieldMask turns a list of field names into a mask.
+func ParseFieldMask(names []string) (FieldMask, error) {
+	var mask FieldMask
+	for _, name := range names {
+		field, ok := fieldNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown optional field %q", name)
+		}
+		mask |= field
+	}
+	return mask, nil
+}
+
+// SetFields replaces the optional fields of the configuration. The fields
+// plugins require are filled in regardless.
+func (plg *PluginManages) SetFields(mask FieldMask) {
+	plg.fields = mask
+}
+
+// RequireFields adds the optional fields a plugin declared it needs, so a
+// plugin only asking for the state after a transaction does not make every
+// transaction read it before as well.
+func (plg *PluginManages) RequireFields(mask FieldMask) {
+	plg.required |= mask
+}
+
+// Fields returns the optional fields that are filled in, those of the
+// configuration and those plugins require.
+func (plg *PluginManages) Fields() FieldMask {
+	return plg.fields | plg.required
+}
+
+// WantsField reports whether the optional field is to be filled in, false
+// when the plugin subsystem is off.
+func (plg *PluginManages) WantsField(field FieldMask) bool {
+	return plg.Enabled() && plg.Fields()&field != 0
+}
@@ -0,0 +1,127 @@
+package pluginManage
+
+//add new file
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// FileExporter appends every event to a file as NDJSON: exactly one Envelope
+// encoded as a single-line JSON object per line. Unlike the per-plugin
+// datalog it has a fixed layout that log shippers can tail.
+type FileExporter struct {
+	name string
+	path string
+
+	lock  sync.Mutex
+	file  *os.File
+	out   *bufio.Writer
+	dirty bool
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// NewFileExporter opens (or creates) the target file in append mode and
+// starts the background fsync loop.
+func NewFileExporter(config ExporterConfig) (*FileExporter, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("file exporter %q has no path", config.Name)
+	}
+	interval := time.Second
+	if config.FsyncInterval != "" {
+		var err error
+		if interval, err = time.ParseDuration(config.FsyncInterval); err != nil || interval <= 0 {
+			return nil, fmt.Errorf("file exporter %q has invalid fsync interval %q", config.Name, config.FsyncInterval)
+		}
+	}
+	file, err := os.OpenFile(config.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	e := &FileExporter{
+		name: config.Name,
+		path: config.Path,
+		file: file,
+		out:  bufio.NewWriter(file),
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go e.syncLoop(interval)
+	return e, nil
+}
+
+func (e *FileExporter) Name() string { return e.name }
+
+// Export writes env as one line. The line is buffered and becomes durable at
+// the next fsync tick or on Close.
+func (e *FileExporter) Export(env *Envelope) error {
+	line, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, err := e.out.Write(line); err != nil {
+		return err
+	}
+	e.dirty = true
+	return e.out.WriteByte('\n')
+}
+
+// Sync flushes the buffered lines and fsyncs the file.
+func (e *FileExporter) Sync() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.sync()
+}
+
+func (e *FileExporter) sync() error {
+	if !e.dirty {
+		return nil
+	}
+	if err := e.out.Flush(); err != nil {
+		return err
+	}
+	e.dirty = false
+	return e.file.Sync()
+}
+
+func (e *FileExporter) syncLoop(interval time.Duration) {
+	defer close(e.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.Sync(); err != nil {
+				log.Warn("Plugin file exporter fsync failed", "exporter", e.name, "err", err)
+			}
+		case <-e.quit:
+			return
+		}
+	}
+}
+
+func (e *FileExporter) Close() error {
+	close(e.quit)
+	<-e.done
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if err := e.sync(); err != nil {
+		e.file.Close()
+		return err
+	}
+	return e.file.Close()
+}
@@ -0,0 +1,66 @@
+package pluginManage
+
+import (
+	"bufio"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhidandeng/collector"
+)
+
+func TestFileExporterNDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	exp, err := NewFileExporter(ExporterConfig{Name: "file", Path: path, FsyncInterval: "10ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manage := NewPluginManages()
+	manage.AddExporter(exp)
+	manage.SetBlockContext(big.NewInt(1337), big.NewInt(42))
+
+	manage.TxState().TxHash = "0x01"
+	manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+	tc := collector.NewTransCollector()
+	tc.Op = "EXTERNALINFOSTART"
+	tc.TxHash = "0x01"
+	manage.SendDataToPlugin("EXTERNALINFOSTART", tc.SendTransInfo("EXTERNALINFOSTART"))
+	manage.TxState().TxHash = "0x02"
+	manage.SendDataToPlugin("TXEND", collector.SendFlag("TXEND"))
+
+	if err := exp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	want := []struct{ opcode, txhash string }{
+		{"TXSTART", "0x01"}, {"EXTERNALINFOSTART", "0x01"}, {"TXEND", "0x02"},
+	}
+	scanner := bufio.NewScanner(file)
+	var lines int
+	for ; scanner.Scan(); lines++ {
+		var env map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &env); err != nil {
+			t.Fatalf("line %d does not parse on its own: %v", lines, err)
+		}
+		for _, key := range []string{"opcode", "chainid", "blocknumber", "txhash", "payload"} {
+			if _, ok := env[key]; !ok {
+				t.Errorf("line %d misses envelope field %q", lines, key)
+			}
+		}
+		if lines < len(want) && (env["opcode"] != want[lines].opcode || env["txhash"] != want[lines].txhash) {
+			t.Errorf("line %d: have %v/%v, want %v/%v", lines, env["opcode"], env["txhash"], want[lines].opcode, want[lines].txhash)
+		}
+		if env["chainid"] != "1337" || env["blocknumber"] != float64(42) {
+			t.Errorf("line %d: wrong block context %v/%v", lines, env["chainid"], env["blocknumber"])
+		}
+	}
+	if lines != len(want) {
+		t.Fatalf("have %d lines, want %d", lines, len(want))
+	}
+}
@@ -0,0 +1,168 @@
+package pluginManage
+
+//add new file
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhidandeng/collector"
+)
+
+// FilterConfig narrows the collector stream to the contracts of interest.
+// It can be replaced at runtime through the admin RPC.
+type FilterConfig struct {
+	// Addresses keeps only the transactions whose sender, recipient or any
+	// internal call target is in the set. Empty keeps every transaction.
+	Addresses []common.Address `json:"addresses"`
+
+	// CallAddresses keeps only the internal call and create events whose
+	// target is in the set; the transaction level events are unaffected.
+	// Empty keeps every call.
+	CallAddresses []common.Address `json:"calladdresses"`
+}
+
+// callOps carry the CallCollector or CreateCollector of an internal call.
+var callOps = map[string]bool{
+	"TRANS_CALL":         true,
+	"TRANS_CALLCODE":     true,
+	"TRANS_DELEGATECALL": true,
+	"TRANS_STATICCALL":   true,
+	"TRANS_CREATE":       true,
+	"TRANS_CREATE2":      true,
+	"handle_PRECOMPILE":  true,
+}
+
+// addressFilter is the live, concurrency safe form of FilterConfig.
+type addressFilter struct {
+	lock     sync.RWMutex
+	config   FilterConfig
+	txAddr   map[string]bool
+	callAddr map[string]bool
+}
+
+func (f *addressFilter) set(config FilterConfig) {
+	txAddr := make(map[string]bool)
+	for _, addr := range config.Addresses {
+		txAddr[addr.String()] = true
+	}
+	callAddr := make(map[string]bool)
+	for _, addr := range config.CallAddresses {
+		callAddr[addr.String()] = true
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.config = config
+	f.txAddr = txAddr
+	f.callAddr = callAddr
+}
+
+func (f *addressFilter) get() FilterConfig {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.config
+}
+
+// matchTx reports whether any of addrs is of interest; always true when no
+// transaction filter is configured.
+func (f *addressFilter) matchTx(addrs ...string) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if len(f.txAddr) == 0 {
+		return true
+	}
+	for _, addr := range addrs {
+		if f.txAddr[addr] {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCall reports whether the internal call to addr is of interest; always
+// true when no call filter is configured.
+func (f *addressFilter) matchCall(addr string) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return len(f.callAddr) == 0 || f.callAddr[addr]
+}
+
+// heldEvent is an event of a not-yet-matching transaction.
+type heldEvent struct {
+	opcode string
+	data   *collector.AllCollector
+}
+
+// SetFilter replaces the address filter.
+func (plg *PluginManages) SetFilter(config FilterConfig) {
+	plg.filter.set(config)
+}
+
+// Filter returns the active address filter.
+func (plg *PluginManages) Filter() FilterConfig {
+	return plg.filter.get()
+}
+
+// beginTxFilter checks the sender and recipient of a new transaction.
+func (plg *PluginManages) beginTxFilter(from common.Address, to *common.Address) {
+	plg.held = nil
+	plg.heldScanned = 0
+	if to != nil {
+		plg.txMatched = plg.filter.matchTx(from.String(), to.String())
+	} else {
+		plg.txMatched = plg.filter.matchTx(from.String())
+	}
+}
+
+// inFilter reports whether opcode events of the current transaction may go
+// to monitor plugins and exporters. A transaction that did not match on its
+// sender or recipient matches as soon as one of its call targets does; the
+// events held back until then are released at that point, or dropped when
+// the transaction ends without a match.
+func (plg *PluginManages) inFilter(opcode string) bool {
+	if plg.txMatched || blockLevelOps[opcode] {
+		return true
+	}
+	for ; plg.heldScanned < len(plg.tx.ALL_STACK); plg.heldScanned++ {
+		if plg.filter.matchTx(plg.tx.ALL_STACK[plg.heldScanned]) {
+			plg.txMatched = true
+			plg.releaseHeld()
+			return true
+		}
+	}
+	if opcode == "TXEND" {
+		plg.held = nil
+	}
+	return false
+}
+
+// callAllowed reports whether the call level filter lets an internal call
+// event through. Any other event passes.
+func (plg *PluginManages) callAllowed(opcode string, data *collector.AllCollector) bool {
+	if !callOps[opcode] {
+		return true
+	}
+	return plg.filter.matchCall(data.TransInfo.To)
+}
+
+func (plg *PluginManages) holdBack(opcode string, data *collector.AllCollector) {
+	if opcode == "TXEND" {
+		return
+	}
+	plg.held = append(plg.held, heldEvent{opcode, data})
+}
+
+func (plg *PluginManages) releaseHeld() {
+	held := plg.held
+	plg.held = nil
+	// held events were decoded as they arrived
+	for _, event := range held {
+		plg.sequence(event.data)
+		plg.export(event.opcode, event.data)
+		plg.deliver(event.opcode, event.data, deliverMonitor)
+	}
+}
@@ -0,0 +1,76 @@
+package pluginManage
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhidandeng/collector"
+)
+
+func TestAddressFilter(t *testing.T) {
+	var (
+		watched = common.Address{0xaa}
+		other   = common.Address{0xbb}
+		sender  = common.Address{0xcc}
+	)
+	manage := NewPluginManages()
+	manage.SetFilter(FilterConfig{Addresses: []common.Address{watched}})
+
+	var monitored, enforced []string
+	for _, opcode := range []string{"TXSTART", "CALLSTART", "TXEND"} {
+		testMonitor(manage, "monitor", "", opcode, func(data *collector.AllCollector) (byte, string) {
+			monitored = append(monitored, data.Option)
+			return 0x00, ""
+		})
+		testMonitor(manage, "enforce", "enforce", opcode, func(data *collector.AllCollector) (byte, string) {
+			enforced = append(enforced, data.Option)
+			return 0x00, ""
+		})
+	}
+	// runTx plays a transaction to to, calling into the given contracts.
+	runTx := func(i int, to common.Address, calls ...common.Address) {
+		manage.Start()
+		manage.BeginTx(testTxHash(i), sender, &to)
+		manage.TxState().ALL_STACK = []string{to.String()}
+		manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+		for _, call := range calls {
+			manage.SendDataToPlugin("CALLSTART", collector.SendFlag("CALLSTART"))
+			manage.TxState().ALL_STACK = append(manage.TxState().ALL_STACK, call.String())
+		}
+		manage.SendDataToPlugin("TXEND", collector.SendFlag("TXEND"))
+	}
+	check := func(name string, have []string, want ...string) {
+		t.Helper()
+		if len(have) != len(want) {
+			t.Fatalf("%s received %v, want %v", name, have, want)
+		}
+		for i := range want {
+			if have[i] != want[i] {
+				t.Fatalf("%s received %v, want %v", name, have, want)
+			}
+		}
+	}
+
+	// Direct match on the recipient.
+	runTx(0, watched)
+	check("monitor", monitored, "TXSTART", "TXEND")
+	check("enforce", enforced, "TXSTART", "TXEND")
+
+	// No match: the monitor sees nothing, the enforcer everything.
+	monitored, enforced = nil, nil
+	runTx(1, other, other)
+	check("monitor", monitored)
+	check("enforce", enforced, "TXSTART", "CALLSTART", "TXEND")
+
+	// Internal match: the events held back so far are released in order.
+	monitored, enforced = nil, nil
+	runTx(2, other, other, watched)
+	check("monitor", monitored, "TXSTART", "CALLSTART", "CALLSTART", "TXEND")
+	check("enforce", enforced, "TXSTART", "CALLSTART", "CALLSTART", "TXEND")
+
+	// Clearing the filter lets everything through again.
+	monitored = nil
+	manage.SetFilter(FilterConfig{})
+	runTx(3, other)
+	check("monitor", monitored, "TXSTART", "TXEND")
+}
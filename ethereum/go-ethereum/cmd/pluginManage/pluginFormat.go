@@ -0,0 +1,76 @@
+package pluginManage
+
+//add new file
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/zhidandeng/collector"
+)
+
+var formatSwitchCounter = metrics.NewRegisteredCounter("plugin/format/switches", nil)
+
+// FormatSwitcher is implemented by exporters whose payload format can be
+// changed while the node runs.
+type FormatSwitcher interface {
+	SetFormat(format string)
+}
+
+// RequestFormat asks for every http exporter and socket plugin to encode
+// their payloads in format from the next block on, so that a block is never
+// delivered in two formats. It may be called from any goroutine.
+func (plg *PluginManages) RequestFormat(format string) error {
+	if !collector.ValidFormat(format) {
+		return fmt.Errorf("unknown format %q", format)
+	}
+	if format == "" {
+		format = collector.FormatJSON
+	}
+	plg.requestLock.Lock()
+	defer plg.requestLock.Unlock()
+	plg.pendingFormat = format
+	return nil
+}
+
+// Format returns the payload format last switched to at runtime, empty while
+// every exporter still uses its configured one.
+func (plg *PluginManages) Format() string {
+	plg.requestLock.Lock()
+	defer plg.requestLock.Unlock()
+	return plg.format
+}
+
+// applyFormat carries out a pending format request. It runs at the start of
+// a block, before any of its events is exported.
+func (plg *PluginManages) applyFormat() {
+	plg.requestLock.Lock()
+	format, previous := plg.pendingFormat, plg.format
+	plg.pendingFormat = ""
+	if format != "" {
+		plg.format = format
+	}
+	plg.requestLock.Unlock()
+
+	if format == "" {
+		return
+	}
+	for _, entry := range plg.exporterList() {
+		if switcher, ok := entry.exporter.(FormatSwitcher); ok {
+			switcher.SetFormat(format)
+		}
+	}
+	for _, socket := range plg.sockets {
+		socket.SetFormat(format)
+	}
+	for _, known := range []string{collector.FormatJSON, collector.FormatCBOR} {
+		active := int64(0)
+		if known == format {
+			active = 1
+		}
+		metrics.GetOrRegisterGauge("plugin/format/"+known, nil).Update(active)
+	}
+	formatSwitchCounter.Inc(1)
+	log.Info("Plugin payload format switched", "from", previous, "to", format, "block", plg.blockNumber)
+}
@@ -0,0 +1,86 @@
+package pluginManage
+
+//add new file
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrStateUnavailable is returned for blocks whose state the node no longer
+// holds, which on a non-archive node is everything but the recent blocks.
+var ErrStateUnavailable = errors.New("historical state not available")
+
+// StateReader is the read-only view of the state at one block.
+type StateReader interface {
+	GetBalance(addr common.Address) *big.Int
+	GetNonce(addr common.Address) uint64
+	GetCode(addr common.Address) []byte
+	GetState(addr common.Address, key common.Hash) common.Hash
+}
+
+// StateAtFunc opens the state at the end of the canonical block number.
+type StateAtFunc func(number uint64) (StateReader, error)
+
+// History gives plugins read access to the state of past blocks. Every read
+// opens its own copy of the state, the transaction being executed is never
+// touched.
+type History struct {
+	stateAt StateAtFunc
+}
+
+func (h *History) state(number uint64) (StateReader, error) {
+	if h == nil || h.stateAt == nil {
+		return nil, ErrStateUnavailable
+	}
+	return h.stateAt(number)
+}
+
+// BalanceAt returns the balance of addr at block number.
+func (h *History) BalanceAt(addr common.Address, number uint64) (*big.Int, error) {
+	state, err := h.state(number)
+	if err != nil {
+		return nil, err
+	}
+	return state.GetBalance(addr), nil
+}
+
+// NonceAt returns the nonce of addr at block number.
+func (h *History) NonceAt(addr common.Address, number uint64) (uint64, error) {
+	state, err := h.state(number)
+	if err != nil {
+		return 0, err
+	}
+	return state.GetNonce(addr), nil
+}
+
+// CodeAt returns the code of addr at block number.
+func (h *History) CodeAt(addr common.Address, number uint64) ([]byte, error) {
+	state, err := h.state(number)
+	if err != nil {
+		return nil, err
+	}
+	return state.GetCode(addr), nil
+}
+
+// StorageAt returns the storage slot key of addr at block number.
+func (h *History) StorageAt(addr common.Address, key common.Hash, number uint64) (common.Hash, error) {
+	state, err := h.state(number)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return state.GetState(addr, key), nil
+}
+
+// SetStateAt installs the source of historical state, normally the chain the
+// manager is configured on.
+func (plg *PluginManages) SetStateAt(stateAt StateAtFunc) {
+	plg.history.stateAt = stateAt
+}
+
+// History returns the historical state handle handed to plugins.
+func (plg *PluginManages) History() *History {
+	return &plg.history
+}
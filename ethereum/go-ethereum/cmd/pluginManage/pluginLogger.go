@@ -9,16 +9,19 @@ import (
 	"path/filepath"
 	// "time"
 	"strconv"
+
+	"github.com/zhidandeng/collector"
 )
 
 type WarnTxLog struct {
-	LogFile  *os.File
-	FileName string
-	FileCount int
+	LogFile      *os.File
+	FileName     string
+	FileCount    int
 	InitFileName string
+	Format       string //LogFormatText (default) or LogFormatJSON
 }
 
-func NewPluginLogger() *WarnTxLog{
+func NewPluginLogger() *WarnTxLog {
 	wtlog := WarnTxLog{}
 	return &wtlog
 }
@@ -41,10 +44,10 @@ func GetFileSize(filename string) int64 {
 	return result
 }
 
-func IsFileExists(filename string) bool{
-	_,err := os.Stat(filename)
-	if err != nil{
-		if os.IsExist(err){
+func IsFileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	if err != nil {
+		if os.IsExist(err) {
 			return true
 		}
 		return false
@@ -54,10 +57,10 @@ func IsFileExists(filename string) bool{
 }
 
 func (wtlog *WarnTxLog) CheckIfCreateNewFile() {
-	if IsFileExists(wtlog.FileName){
+	if IsFileExists(wtlog.FileName) {
 		if GetFileSize(wtlog.FileName) > 300722733 {
 			wtlog.FileCount += 1
-			filename := wtlog.InitFileName+ strconv.Itoa(wtlog.FileCount)
+			filename := wtlog.InitFileName + strconv.Itoa(wtlog.FileCount)
 			f, _ := os.Create(filename)
 			wtlog.FileName = filename
 			wtlog.LogFile = f
@@ -82,3 +85,50 @@ func (wtlog *WarnTxLog) InitialFileLog(filename string) {
 	wtlog.InitFileName = filename
 }
 
+// Formats of the plugin log files.
+const (
+	LogFormatText = "text" // "txhash,contract,Warning:reason" lines
+	LogFormatJSON = "json" // one logEntry object per line
+)
+
+// LogConfig selects the format of the plugin log files.
+type LogConfig struct {
+	Format  string            `json:"format"`  // format of every plugin, text when unset
+	Plugins map[string]string `json:"plugins"` // format by plugin name
+}
+
+func (c LogConfig) validate() error {
+	if !validLogFormat(c.Format) {
+		return fmt.Errorf("unknown plugin log format %q", c.Format)
+	}
+	for name, format := range c.Plugins {
+		if !validLogFormat(format) {
+			return fmt.Errorf("unknown log format %q of plugin %q", format, name)
+		}
+	}
+	return nil
+}
+
+func validLogFormat(format string) bool {
+	return format == "" || format == LogFormatText || format == LogFormatJSON
+}
+
+// format returns the log format of the named plugin.
+func (c LogConfig) format(name string) string {
+	if format, ok := c.Plugins[name]; ok && format != "" {
+		return format
+	}
+	return c.Format
+}
+
+// logEntry is a line of a json plugin log: a warning raised by the plugin
+// together with the event it was raised on.
+type logEntry struct {
+	Level    string                  `json:"level"` // "warning" or "serious"
+	Plugin   string                  `json:"plugin"`
+	Opcode   string                  `json:"opcode"`
+	TxHash   string                  `json:"txhash"`
+	Contract string                  `json:"contract"`
+	Reason   string                  `json:"reason"`
+	Data     *collector.AllCollector `json:"data"`
+}
@@ -0,0 +1,87 @@
+package pluginManage
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhidandeng/collector"
+)
+
+// logWarnings runs a transaction through a plugin raising a warning on every
+// event and returns the lines of its log file.
+func logWarnings(t *testing.T, config LogConfig) []string {
+	t.Helper()
+	manage := NewPluginManages()
+	if err := manage.SetLogConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	monitor := new(MonitorType)
+	monitor.SetPluginName("auditor")
+	monitor.SetOpcode("TXSTART")
+	monitor.Logger = NewPluginLogger()
+	monitor.Logger.InitialFileLog(filepath.Join(t.TempDir(), "auditordatalog"))
+	monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+		return 0x01, "suspicious, but allowed"
+	})
+	manage.RegisterOpcode("TXSTART", monitor)
+
+	for i := 0; i < 2; i++ {
+		hash := testTxHash(i)
+		manage.TxState().TxHash = hash.String()
+		manage.Start()
+		manage.BeginTx(hash, common.Address{0xcc}, nil)
+		manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+	}
+	file, err := os.Open(monitor.Logger.FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var lines []string
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2: %q", len(lines), lines)
+	}
+	return lines
+}
+
+func TestLogFormatText(t *testing.T) {
+	for i, line := range logWarnings(t, LogConfig{}) {
+		fields := strings.SplitN(line, ",", 3)
+		if len(fields) != 3 || fields[0] != testTxHash(i).String() || fields[2] != "Warning:suspicious, but allowed" {
+			t.Errorf("line %d: %q", i, line)
+		}
+	}
+}
+
+func TestLogFormatJSON(t *testing.T) {
+	config := LogConfig{Format: LogFormatText, Plugins: map[string]string{"auditor": LogFormatJSON}}
+	for i, line := range logWarnings(t, config) {
+		var entry logEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("line %d: %v: %q", i, err, line)
+		}
+		if entry.Level != "warning" || entry.Plugin != "auditor" || entry.Opcode != "TXSTART" || entry.Reason != "suspicious, but allowed" {
+			t.Errorf("line %d: %+v", i, entry)
+		}
+		if entry.TxHash != testTxHash(i).String() {
+			t.Errorf("line %d: tx hash %s, want %s", i, entry.TxHash, testTxHash(i))
+		}
+		if entry.Data == nil || entry.Data.Option != "TXSTART" {
+			t.Errorf("line %d: event %+v not logged", i, entry.Data)
+		}
+	}
+}
+
+func TestLogFormatInvalid(t *testing.T) {
+	manage := NewPluginManages()
+	if err := manage.SetLogConfig(LogConfig{Plugins: map[string]string{"auditor": "xml"}}); err == nil {
+		t.Fatal("unknown log format accepted")
+	}
+}
@@ -3,26 +3,254 @@ package pluginManage
 //add new file
 
 import (
+	"context"
 	"github.com/zhidandeng/collector"
+	"math/big"
 	"strings"
+	"sync"
+	"time"
 	// "fmt"
-	"github.com/ethereum/go-ethereum/dan"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/dzd"
+	"github.com/ethereum/go-ethereum/log"
 )
 
 //2019.03.01 version plugin
 
 type PluginManages struct {
-	plugins map[string][]*MonitorType
+	pluginsLock   sync.RWMutex // guards plugins against the RPC readers
+	plugins       map[string][]*MonitorType
+	tx            *dzd.TxState      // plugin state of the transaction being executed
+	aliases       map[string]string // deprecated opcode name -> current name
+	exportersLock sync.RWMutex
+	exporters     []*exporterEntry // replaced, never modified in place, see exporterList
+	events        *eventCounters
+
+	chainID     string // chain of the block being processed
+	blockNumber uint64 // number of the block being processed
+	seq         uint64 // sequence number of the last event of the block
+
+	sampling  SamplingConfig
+	txSampled bool        // whether the current transaction is in the sample
+	txHash    common.Hash // hash of the current transaction, for the per plugin samples
+	traceID   string      // correlation id of the current transaction
+
+	filter      addressFilter
+	txMatched   bool        // whether the current transaction passed the filter
+	held        []heldEvent // events of the current transaction awaiting a match
+	heldScanned int         // call targets of the current transaction already checked
+
+	batches    map[string]*blockBatch     // events of the current block per block-delivery plugin
+	aggregates map[string]*blockAggregate // events of the current block per aggregating plugin
+
+	dedup   bool
+	txCalls map[string]*repeatedCall // call payloads of the current transaction
+	repeats []*repeatedCall          // payloads of txCalls seen more than once
+
+	maxCallDepth int                           // deepest call layer emitted, 0 for all
+	deepCalls    *collector.DeepCallsCollector // calls of the current transaction below the cap
+	maxPayload   int                           // bytes of call input and output kept, 0 for all
+	stall        stallDetector                 // sheds monitor plugins when dispatch falls behind
+
+	closers map[string]func() // Close() of the loaded plugins by name
+	history History           // past state handed to the plugins
+
+	disabled bool
+	measure  map[string]*DispatchStat // dispatch cost per opcode while measuring
+
+	poolConfig  PoolConfig
+	poolLock    sync.Mutex
+	pools       map[string]*workerPool // worker pools of the async plugins by name
+	poolsClosed bool
+
+	logConfig     LogConfig
+	anomaly       anomalyLimits               // transaction anomaly thresholds
+	blocking      blockingPolicy              // opcodes whose block decisions are acted upon
+	fields        FieldMask                   // optional collector fields filled in
+	required      FieldMask                   // optional collector fields registered plugins declared
+	abis          map[common.Address]*abi.ABI // contract ABIs call inputs are decoded against
+	redact        *redactPolicy               // calldata hidden from the exporters, nil for none
+	proofAccounts []ProofAccount              // accounts proven at the end of every block
+
+	destroyedLock sync.Mutex
+	destroyed     map[common.Address]common.Hash // code hash of the contracts destroyed, by address
+
+	breakerLock     sync.Mutex
+	breakerSettings breakerSettings
+	breakers        map[string]*circuitBreaker // circuit breakers by plugin name
+
+	ctx context.Context // cancels the dispatch of the block being processed
+
+	requestLock sync.Mutex
+	regPath     string // plugin to load before the next transaction
+	unPlg       string // plugin to unload before the next transaction
+
+	pendingFormat string             // payload format to switch to at the next block
+	format        string             // payload format switched to at runtime
+	sockets       []*SocketTransport // transports of the sidecar plugins
+
+	tagLock sync.Mutex // guards tx.TAGS, async plugins tag from their workers
+}
+
+// exporterEntry binds an exporter to the opcodes it ships; an empty opcode set
+// means every opcode that is emitted.
+type exporterEntry struct {
+	exporter Exporter
+	opcodes  map[string]bool
 }
 
 var clearvalue []*MonitorType
 
 func NewPluginManages() *PluginManages {
-	return &PluginManages{make(map[string][]*MonitorType)}
+	aliases := make(map[string]string, len(opcodeAliases))
+	for legacy, current := range opcodeAliases {
+		aliases[legacy] = current
+	}
+	return &PluginManages{plugins: make(map[string][]*MonitorType), tx: new(dzd.TxState), aliases: aliases, events: newEventCounters(), txSampled: true, txMatched: true}
+}
+
+// TxState returns the plugin state of the transaction being executed, nil
+// for a nil manager.
+func (plg *PluginManages) TxState() *dzd.TxState {
+	if plg == nil {
+		return nil
+	}
+	return plg.tx
+}
+
+// AddOpcodeAlias makes subscriptions to the deprecated name legacy receive
+// the events of current.
+func (plg *PluginManages) AddOpcodeAlias(legacy, current string) {
+	plg.aliases[legacy] = current
+}
+
+// canonicalOpcode resolves a deprecated opcode name to its current one.
+func (plg *PluginManages) canonicalOpcode(opcode string) string {
+	if current, ok := plg.aliases[opcode]; ok {
+		return current
+	}
+	return opcode
+}
+
+// Enabled reports whether the plugin subsystem takes part in block
+// processing. A nil manager is disabled, so chains configured without one
+// run like a plain node.
+func (plg *PluginManages) Enabled() bool {
+	return plg != nil && !plg.disabled
+}
+
+// SetEnabled switches the whole plugin subsystem on or off.
+func (plg *PluginManages) SetEnabled(enabled bool) {
+	plg.disabled = !enabled
+}
+
+// AddExporter ships the data of the given opcodes to exporter. The opcodes are
+// reported as registered so that the collectors get filled even when no
+// plugin subscribes to them. It may be called while a block is being
+// processed, the events already being dispatched do not reach exporter.
+func (plg *PluginManages) AddExporter(exporter Exporter, opcodes ...string) {
+	entry := &exporterEntry{exporter: exporter, opcodes: make(map[string]bool)}
+	for _, opcode := range opcodes {
+		entry.opcodes[opcode] = true
+	}
+	plg.exportersLock.Lock()
+	defer plg.exportersLock.Unlock()
+	n := len(plg.exporters)
+	plg.exporters = append(plg.exporters[:n:n], entry)
+}
+
+// RemoveExporter stops shipping data to exporter, without closing it.
+func (plg *PluginManages) RemoveExporter(exporter Exporter) {
+	plg.exportersLock.Lock()
+	defer plg.exportersLock.Unlock()
+	plg.removeExporter(func(entry *exporterEntry) bool { return entry.exporter == exporter })
+}
+
+// removeExporter drops the first exporter matching and returns it. The caller
+// holds exportersLock.
+func (plg *PluginManages) removeExporter(match func(*exporterEntry) bool) *exporterEntry {
+	for i, entry := range plg.exporters {
+		if match(entry) {
+			plg.exporters = append(plg.exporters[:i:i], plg.exporters[i+1:]...)
+			return entry
+		}
+	}
+	return nil
+}
+
+// exporterList returns the current exporters. The slice is never modified,
+// writers publish a new one, so it can be ranged over without a lock while
+// subscriptions come and go.
+func (plg *PluginManages) exporterList() []*exporterEntry {
+	plg.exportersLock.RLock()
+	defer plg.exportersLock.RUnlock()
+	return plg.exporters
+}
+
+func (plg *PluginManages) isExported(opcode string) bool {
+	for _, entry := range plg.exporterList() {
+		if len(entry.opcodes) == 0 || entry.opcodes[opcode] {
+			return true
+		}
+	}
+	return false
+}
+
+// SetBlockContext records the chain and block the following events belong to.
+// Moving to another block restarts the per-block event counts.
+func (plg *PluginManages) SetBlockContext(chainID *big.Int, number *big.Int) {
+	plg.chainID = ""
+	if chainID != nil {
+		plg.chainID = chainID.String()
+	}
+	if number.Uint64() != plg.blockNumber {
+		plg.events.resetBlock()
+	}
+	plg.blockNumber = number.Uint64()
+	plg.seq = 0
+	plg.applyFormat()
+}
+
+func (plg *PluginManages) export(opcode string, data *collector.AllCollector) {
+	exporters := plg.exporterList()
+	if len(exporters) == 0 {
+		return
+	}
+	env := &Envelope{
+		Opcode:      opcode,
+		ChainID:     plg.chainID,
+		BlockNumber: plg.blockNumber,
+		Payload:     data,
+	}
+	if plg.redact != nil {
+		env.Payload = plg.redact.apply(opcode, data)
+	}
+	// block level events would otherwise carry the previous transaction
+	if !blockLevelOps[opcode] {
+		env.TxHash = plg.tx.TxHash
+	}
+	for _, entry := range exporters {
+		if len(entry.opcodes) != 0 && !entry.opcodes[opcode] {
+			continue
+		}
+		if err := entry.exporter.Export(env); err != nil {
+			log.Warn("Plugin exporter failed", "exporter", entry.exporter.Name(), "opcode", opcode, "err", err)
+		}
+	}
 }
 
 func (plg *PluginManages) RegisterOpcode(opcode string, monitor *MonitorType) {
+	if current := plg.canonicalOpcode(opcode); current != opcode {
+		log.Warn("Plugin subscribes to deprecated opcode name", "plugin", monitor.GetPluginName(), "opcode", opcode, "use", current)
+		opcode = current
+		monitor.SetOpcode(opcode)
+	}
+	if monitor.Logger != nil && monitor.Logger.Format == "" {
+		monitor.Logger.Format = plg.logConfig.format(monitor.GetPluginName())
+	}
+	plg.pluginsLock.Lock()
+	defer plg.pluginsLock.Unlock()
 	res := IsOpExist(opcode)
 	// fmt.Println("res:",res)
 	switch res {
@@ -33,6 +261,7 @@ func (plg *PluginManages) RegisterOpcode(opcode string, monitor *MonitorType) {
 		registerIALOp := ReturnIALArray(opcode)
 		for _, value := range registerIALOp {
 			// fmt.Println("value:",value)
+			value = plg.canonicalOpcode(value)
 			monitor.SetStatus(false)
 			monitor.SetOpcode(value)
 			plg.plugins[value] = append(plg.plugins[value], monitor)
@@ -50,33 +279,130 @@ func (plg *PluginManages) RegisterOpcode(opcode string, monitor *MonitorType) {
 }
 
 func (plg *PluginManages) GetOpcodeRegister(opcode string) bool {
-	_, isTrue := plg.plugins[opcode]
-	return isTrue
+	if !plg.Enabled() {
+		return false
+	}
+	// emitters use current names, a deprecated one only comes from outside
+	opcode = plg.canonicalOpcode(opcode)
+	if !plg.inSample(opcode) {
+		return plg.hasEnforcer(opcode)
+	}
+	return plg.subscribed(opcode) || plg.isExported(opcode)
 }
 
+// subscribed reports whether a plugin takes the opcode event about to be
+// emitted. Plugins limited to a block range count as unsubscribed outside it,
+// plugins with an event filter when it rejects the event.
+func (plg *PluginManages) subscribed(opcode string) bool {
+	for _, monitor := range plg.plugins[opcode] {
+		if plg.wants(monitor, opcode) {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver targets of SendDataToPlugin
+const (
+	deliverAll     = iota // every subscribed plugin
+	deliverEnforce        // enforce plugins only
+	deliverMonitor        // monitor plugins only
+)
+
 func (plg *PluginManages) SendDataToPlugin(opcode string, data *collector.AllCollector) bool {
 	// if dzd.TxHash == "0x847194c9081008ede0ca7dbbb037408a15b6b96b11bca07f032af001c2edd083" || dzd.TxHash == "0x1fa290fac8231ff6936ae22b2d6116ecf7dfe5cda6823ce44cd803ef620aab84"{
 	// 	fmt.Println("dzd.TxHash :",dzd.TxHash)
+	plg.events.inc(opcode)
+	if plg.cancelled() {
+		return false
+	}
+	plg.trace(opcode, data)
+	if plg.measure != nil {
+		defer plg.measureDispatch(opcode, time.Now())
+	}
+	if done := plg.timeDispatch(); done != nil {
+		defer done()
+	}
+	// enforce plugins see every event, decoded the same way
+	plg.decodeInput(data)
+	if !plg.inSample(opcode) {
+		return plg.deliver(opcode, data, deliverEnforce)
+	}
+	if !plg.callAllowed(opcode, data) || plg.tooDeep(opcode, data) || plg.duplicate(opcode, data) {
+		return plg.deliver(opcode, data, deliverEnforce)
+	}
+	if !plg.inFilter(opcode) {
+		plg.holdBack(opcode, data)
+		return plg.deliver(opcode, data, deliverEnforce)
+	}
+	plg.sequence(data)
+	plg.export(opcode, data)
+	return plg.deliver(opcode, data, deliverAll)
+}
+
+func (plg *PluginManages) deliver(opcode string, data *collector.AllCollector, target int) bool {
 	if monitor_arr, isTrue := plg.plugins[opcode]; isTrue {
 		for index := 0; index < len(monitor_arr); index++ {
 			// true_opcode :=  plg.plugins[opcode][index].GetIAL_Optinon()
-			if plg.plugins[opcode][index].GetStatus() {
+			enforce := plg.plugins[opcode][index].IsEnforce()
+			if (target == deliverEnforce && !enforce) || (target == deliverMonitor && enforce) {
+				continue
+			}
+			if !plg.wants(plg.plugins[opcode][index], opcode) || !plg.plugins[opcode][index].selected(opcode, data) {
+				continue
+			}
+			// block level events arrive outside of Start/Stop
+			if plg.plugins[opcode][index].GetStatus() || blockLevelOps[opcode] {
+				if plg.plugins[opcode][index].IsBlockDelivery() {
+					plg.buffer(plg.plugins[opcode][index], data)
+					continue
+				}
+				if plg.plugins[opcode][index].IsAggregate() {
+					if opcode == "handle_BLOCK_END" {
+						plg.callPlugin(plg.plugins[opcode][index], plg.aggregated(plg.plugins[opcode][index], data))
+					} else {
+						plg.accumulate(plg.plugins[opcode][index], data)
+					}
+					continue
+				}
+				if plg.plugins[opcode][index].IsAsync() {
+					plg.dispatchAsync(plg.plugins[opcode][index], opcode, data)
+					continue
+				}
 
 				// fmt.Println("senddata:",data)
 				// fmt.Println("new:", plg.plugins[opcode][index])
-				warning_level, results := ((plg.plugins[opcode])[index]).Send(data)
+				warning_level, results := plg.callPlugin(plg.plugins[opcode][index], data)
+				if (warning_level == 0x02 || warning_level == 0x03) && !plg.mayBlock(opcode) {
+					log.Warn("Plugin block decision ignored, opcode may not block", "plugin", plg.plugins[opcode][index].GetPluginName(), "opcode", opcode, "reason", results)
+					continue
+				}
 				switch warning_level {
 				case 0x01:
-					StandardWarningReport(((plg.plugins[opcode])[index]).GetPluginName(), results, ((plg.plugins[opcode])[index]).GetLogger(), opcode, 2)
+					plg.StandardWarningReport(((plg.plugins[opcode])[index]).GetPluginName(), results, ((plg.plugins[opcode])[index]).GetLogger(), opcode, 2, data)
 				case 0x02:
-					StandardWarningReport(((plg.plugins[opcode])[index]).GetPluginName(), results, ((plg.plugins[opcode])[index]).GetLogger(), opcode, 3)
+					if plg.plugins[opcode][index].IsDryRun() {
+						plg.wouldBlock(plg.plugins[opcode][index], opcode, warning_level, results, data)
+						continue
+					}
+					plg.StandardWarningReport(((plg.plugins[opcode])[index]).GetPluginName(), results, ((plg.plugins[opcode])[index]).GetLogger(), opcode, 3, data)
 					((plg.plugins[opcode])[index]).SetStatus(false)
-					dzd.BLOCKING_FLAG = true
+					if !plg.tx.BLOCKING_FLAG {
+						plg.tx.BLOCKING_PLUGIN, plg.tx.BLOCKING_REASON = plg.plugins[opcode][index].GetPluginName(), results
+					}
+					plg.tx.BLOCKING_FLAG = true
 					continue
 				case 0x03:
-					StandardWarningReport(((plg.plugins[opcode])[index]).GetPluginName(), results, ((plg.plugins[opcode])[index]).GetLogger(), opcode, 3)
+					if plg.plugins[opcode][index].IsDryRun() {
+						plg.wouldBlock(plg.plugins[opcode][index], opcode, warning_level, results, data)
+						continue
+					}
+					plg.StandardWarningReport(((plg.plugins[opcode])[index]).GetPluginName(), results, ((plg.plugins[opcode])[index]).GetLogger(), opcode, 3, data)
 					((plg.plugins[opcode])[index]).SetStatus(false)
-					dzd.BLOCKING_FLAG = true
+					if !plg.tx.BLOCKING_FLAG {
+						plg.tx.BLOCKING_PLUGIN, plg.tx.BLOCKING_REASON = plg.plugins[opcode][index].GetPluginName(), results
+					}
+					plg.tx.BLOCKING_FLAG = true
 					continue
 				default:
 					continue
@@ -108,37 +434,98 @@ func (plg *PluginManages) Stop() {
 	}
 }
 
-func StandardWarningReport(PluginName, comments string, logger *WarnTxLog, opcode string, level int) {
-	txhash := dzd.TxHash
-	var contract string
-	if opcode == "EXTERNALINFOSTART" && len(dzd.CALL_STACK) == 0 {
-		contract = "EXTERNALCREATE"
-	} else {
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
-		temp_arr := strings.Split(temp_str, "#")
-		contract = temp_arr[0]
+func (plg *PluginManages) StandardWarningReport(PluginName, comments string, logger *WarnTxLog, opcode string, level int, data *collector.AllCollector) {
+	writeWarningReport(PluginName, comments, logger, opcode, plg.tx.TxHash, plg.warningContract(opcode), level, data)
+}
+
+// SetLogConfig sets the log formats of the plugins registered afterwards.
+func (plg *PluginManages) SetLogConfig(config LogConfig) error {
+	if err := config.validate(); err != nil {
+		return err
 	}
+	plg.logConfig = config
+	return nil
+}
 
-	logger.CheckIfCreateNewFile()
-	logger.OpenFile()
+// warningContract names the contract executing when opcode is emitted.
+func (plg *PluginManages) warningContract(opcode string) string {
+	if opcode == "EXTERNALINFOSTART" && len(plg.tx.CALL_STACK) == 0 {
+		return "EXTERNALCREATE"
+	}
+	if len(plg.tx.CALL_STACK) == 0 {
+		return ""
+	}
+	temp_str := plg.tx.CALL_STACK[len(plg.tx.CALL_STACK)-1]
+	temp_arr := strings.Split(temp_str, "#")
+	return temp_arr[0]
+}
+
+func writeWarningReport(PluginName, comments string, logger *WarnTxLog, opcode, txhash, contract string, level int, data *collector.AllCollector) {
 	var logstr string
-	if level == 2 {
+	if logger.Format == LogFormatJSON {
+		entry := logEntry{Level: "warning", Plugin: PluginName, Opcode: opcode, TxHash: txhash, Contract: contract, Reason: comments, Data: data}
+		if level != 2 {
+			entry.Level = "serious"
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			log.Warn("Plugin warning not logged", "plugin", PluginName, "opcode", opcode, "err", err)
+			return
+		}
+		logstr = string(line) + "\n"
+	} else if level == 2 {
 		logstr = txhash + "," + contract + ",Warning:" + comments + "\n"
 	} else {
 		logstr = txhash + "," + contract + ",Serious:" + comments + "\n"
 	}
+	logger.CheckIfCreateNewFile()
+	logger.OpenFile()
 	logger.WriteLog(logstr)
 	logger.CloseFile()
 
 }
 
-//feifei-unreg
-func (plg *PluginManages) UnRegisterPlg() {
+// RequestRegister asks for the plugin at path to be loaded before the next
+// transaction of this manager's chain. It may be called from any goroutine.
+func (plg *PluginManages) RequestRegister(path string) {
+	plg.requestLock.Lock()
+	defer plg.requestLock.Unlock()
+	plg.regPath = path
+}
+
+// RequestUnregister asks for the named plugin to be unloaded before the next
+// transaction of this manager's chain. It may be called from any goroutine.
+func (plg *PluginManages) RequestUnregister(name string) {
+	plg.requestLock.Lock()
+	defer plg.requestLock.Unlock()
+	plg.unPlg = name
+}
+
+// ApplyRequests carries out the pending register and unregister requests.
+// It runs between transactions, on the goroutine processing the chain.
+func (plg *PluginManages) ApplyRequests() {
+	plg.requestLock.Lock()
+	regPath, unPlg := plg.regPath, plg.unPlg
+	plg.regPath, plg.unPlg = "", ""
+	plg.requestLock.Unlock()
+
+	if regPath != "" {
+		RegisterPlugin(plg, regPath)
+	}
+	if unPlg != "" {
+		plg.UnRegisterPlg(unPlg)
+	}
+}
+
+// feifei-unreg
+func (plg *PluginManages) UnRegisterPlg(name string) {
+	plg.pluginsLock.Lock()
+	defer plg.pluginsLock.Unlock()
 	for plgkey, valuelist := range plg.plugins {
 		for index := 0; index < len(valuelist); index++ {
 			//如果valuelist长度为1，就可以删除这个key。否则直接注销是没法注销的
 			plgname := (valuelist[index]).GetPluginName()
-			if plgname == dan.UnPlg {
+			if plgname == name {
 				if len(valuelist) == 1 {
 					plg.plugins[plgkey] = clearvalue
 				} else {
@@ -0,0 +1,22 @@
+package pluginManage
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testMonitor registers an in-process monitor for opcode that hands every
+// event to send.
+func testMonitor(manage *PluginManages, name, mode, opcode string, send SendFuncType) *MonitorType {
+	monitor := new(MonitorType)
+	monitor.SetPluginName(name)
+	monitor.SetMode(mode)
+	monitor.SetOpcode(opcode)
+	monitor.SetSendFunc(send)
+	manage.RegisterOpcode(opcode, monitor)
+	return monitor
+}
+
+func testTxHash(i int) common.Hash {
+	return crypto.Keccak256Hash([]byte{byte(i >> 16), byte(i >> 8), byte(i)})
+}
@@ -0,0 +1,114 @@
+package pluginManage
+
+//add new file
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// eventCounter counts the events emitted for one opcode, both in the current
+// block and since the node started.
+type eventCounter struct {
+	block uint64
+	total uint64
+
+	blockGauge   metrics.Gauge
+	totalCounter metrics.Counter
+}
+
+// eventCounters tracks emission throughput per opcode and exports it as
+// plugin/events/<opcode> (cumulative) and plugin/events/<opcode>/block.
+type eventCounters struct {
+	lock     sync.Mutex
+	counters map[string]*eventCounter
+}
+
+func newEventCounters() *eventCounters {
+	return &eventCounters{counters: make(map[string]*eventCounter)}
+}
+
+func (ec *eventCounters) inc(opcode string) {
+	ec.lock.Lock()
+	defer ec.lock.Unlock()
+
+	counter, ok := ec.counters[opcode]
+	if !ok {
+		counter = &eventCounter{
+			blockGauge:   metrics.GetOrRegisterGauge("plugin/events/"+opcode+"/block", nil),
+			totalCounter: metrics.GetOrRegisterCounter("plugin/events/"+opcode, nil),
+		}
+		ec.counters[opcode] = counter
+	}
+	counter.block++
+	counter.total++
+	counter.blockGauge.Update(int64(counter.block))
+	counter.totalCounter.Inc(1)
+}
+
+// resetBlock starts the per-block counts of a new block.
+func (ec *eventCounters) resetBlock() {
+	ec.lock.Lock()
+	defer ec.lock.Unlock()
+
+	for _, counter := range ec.counters {
+		counter.block = 0
+		counter.blockGauge.Update(0)
+	}
+}
+
+func (ec *eventCounters) get(opcode string) (uint64, uint64) {
+	ec.lock.Lock()
+	defer ec.lock.Unlock()
+
+	if counter, ok := ec.counters[opcode]; ok {
+		return counter.block, counter.total
+	}
+	return 0, 0
+}
+
+// BlockEventCount returns how many opcode events were emitted in the current block.
+func (plg *PluginManages) BlockEventCount(opcode string) uint64 {
+	block, _ := plg.events.get(opcode)
+	return block
+}
+
+// EventCount returns how many opcode events were emitted since startup.
+func (plg *PluginManages) EventCount(opcode string) uint64 {
+	_, total := plg.events.get(opcode)
+	return total
+}
+
+// DispatchStat is the time spent handing one opcode's events to plugins and
+// exporters while a measurement runs.
+type DispatchStat struct {
+	Events uint64
+	Time   time.Duration
+}
+
+// StartMeasure starts timing the dispatch of every emitted event.
+func (plg *PluginManages) StartMeasure() {
+	plg.measure = make(map[string]*DispatchStat)
+}
+
+// StopMeasure ends the measurement and returns the dispatch cost per opcode.
+func (plg *PluginManages) StopMeasure() map[string]DispatchStat {
+	stats := make(map[string]DispatchStat, len(plg.measure))
+	for opcode, stat := range plg.measure {
+		stats[opcode] = *stat
+	}
+	plg.measure = nil
+	return stats
+}
+
+func (plg *PluginManages) measureDispatch(opcode string, start time.Time) {
+	stat, ok := plg.measure[opcode]
+	if !ok {
+		stat = new(DispatchStat)
+		plg.measure[opcode] = stat
+	}
+	stat.Events++
+	stat.Time += time.Since(start)
+}
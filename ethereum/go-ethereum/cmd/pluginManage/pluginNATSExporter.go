@@ -0,0 +1,361 @@
+package pluginManage
+
+//add new file
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	defaultNATSSubject   = "noda"
+	defaultNATSBuffer    = 4096
+	defaultNATSReconnect = 2 * time.Second
+	natsHandshakeTimeout = 5 * time.Second
+)
+
+// errNATSBufferFull is returned by Export when the server connection does
+// not keep up and the publish buffer is full. The event is dropped.
+var errNATSBufferFull = errors.New("nats publish buffer full")
+
+// NATSConfig holds the subject and credentials of a nats exporter. The
+// server address is the URL of the exporter, nats://host:port. A token is
+// taken from the Auth settings of the exporter.
+type NATSConfig struct {
+	Subject           string `json:"subject"`       // base subject, "noda" by default
+	ChainSubject      bool   `json:"chainsubject"`  // append the chain id to the subject
+	OpcodeSubject     bool   `json:"opcodesubject"` // append the opcode to the subject
+	User              string `json:"user"`
+	Password          string `json:"password"`
+	BufferSize        int    `json:"buffersize"`        // events queued while the server is slow or away
+	ReconnectInterval string `json:"reconnectinterval"` // pause between connection attempts
+}
+
+// natsConnect is the payload of the CONNECT command.
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+}
+
+type natsMessage struct {
+	subject string
+	body    []byte
+}
+
+// natsConn is one connection to the server. The publisher and the reader
+// answering the server's PINGs share the writer.
+type natsConn struct {
+	conn net.Conn
+	lock sync.Mutex
+	out  *bufio.Writer
+	dead chan struct{} // closed when the reader hits an error
+}
+
+// NATSExporter publishes every event to a NATS subject. Export only queues;
+// a background goroutine writes the PUB commands, which are flushed to the
+// server at the end of every block, and redials the server after a lost
+// connection. Like NATS core itself it delivers at most once: events written
+// to a connection that dies before the flush are lost.
+type NATSExporter struct {
+	name      string
+	addr      string
+	config    NATSConfig
+	auth      AuthConfig
+	reconnect time.Duration
+
+	conn    *natsConn // only used by loop
+	queue   chan natsMessage
+	flush   chan struct{}
+	dropped metrics.Counter
+
+	lock   sync.RWMutex
+	closed bool
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// NewNATSExporter creates a nats exporter from config.
+func NewNATSExporter(config ExporterConfig) (*NATSExporter, error) {
+	addr, err := natsAddr(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("nats exporter %q: %v", config.Name, err)
+	}
+	reconnect := defaultNATSReconnect
+	if config.NATS.ReconnectInterval != "" {
+		if reconnect, err = time.ParseDuration(config.NATS.ReconnectInterval); err != nil || reconnect <= 0 {
+			return nil, fmt.Errorf("nats exporter %q has invalid reconnect interval %q", config.Name, config.NATS.ReconnectInterval)
+		}
+	}
+	buffer := config.NATS.BufferSize
+	if buffer <= 0 {
+		buffer = defaultNATSBuffer
+	}
+	e := &NATSExporter{
+		name:      config.Name,
+		addr:      addr,
+		config:    config.NATS,
+		auth:      config.Auth,
+		reconnect: reconnect,
+		queue:     make(chan natsMessage, buffer),
+		flush:     make(chan struct{}, 1),
+		dropped:   metrics.GetOrRegisterCounter("plugin/exporter/"+config.Name+"/dropped", nil),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go e.loop()
+	return e, nil
+}
+
+func natsAddr(rawurl string) (string, error) {
+	if rawurl == "" {
+		return "", errors.New("no server url")
+	}
+	if !strings.Contains(rawurl, "://") {
+		rawurl = "nats://" + rawurl
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), "4222"), nil
+	}
+	return u.Host, nil
+}
+
+func (e *NATSExporter) Name() string { return e.name }
+
+// Subject returns the subject events of opcode on chain are published to.
+func (e *NATSExporter) Subject(chainID, opcode string) string {
+	subject := e.config.Subject
+	if subject == "" {
+		subject = defaultNATSSubject
+	}
+	if e.config.ChainSubject && chainID != "" {
+		subject += "." + chainID
+	}
+	if e.config.OpcodeSubject {
+		subject += "." + opcode
+	}
+	return subject
+}
+
+// Export queues env for publishing. It fails without blocking when the
+// buffer is full.
+func (e *NATSExporter) Export(env *Envelope) error {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	if e.closed {
+		return fmt.Errorf("nats exporter %q is closed", e.name)
+	}
+	select {
+	case e.queue <- natsMessage{subject: e.Subject(env.ChainID, env.Opcode), body: body}:
+		return nil
+	default:
+		e.dropped.Inc(1)
+		return errNATSBufferFull
+	}
+}
+
+// FlushBlock asks the publisher to push the events written so far to the
+// server. It does not wait for that to happen.
+func (e *NATSExporter) FlushBlock() {
+	select {
+	case e.flush <- struct{}{}:
+	default:
+	}
+}
+
+func (e *NATSExporter) loop() {
+	defer close(e.done)
+	for {
+		if e.conn == nil {
+			if err := e.connect(); err != nil {
+				log.Warn("Plugin nats exporter connect failed", "exporter", e.name, "addr", e.addr, "err", err)
+				select {
+				case <-time.After(e.reconnect):
+					continue
+				case <-e.quit:
+					e.drain()
+					return
+				}
+			}
+		}
+		select {
+		case msg := <-e.queue:
+			e.write(msg)
+		case <-e.flush:
+			// the events of the block were queued before the signal
+			for n := len(e.queue); n > 0; n-- {
+				e.write(<-e.queue)
+			}
+			e.flushConn()
+		case <-e.conn.dead:
+			log.Warn("Plugin nats exporter lost connection", "exporter", e.name, "addr", e.addr)
+			e.dropConn()
+		case <-e.quit:
+			e.drain()
+			return
+		}
+	}
+}
+
+// drain publishes what is still queued on the current connection, if any,
+// and closes it.
+func (e *NATSExporter) drain() {
+	for {
+		select {
+		case msg := <-e.queue:
+			if e.conn == nil {
+				e.dropped.Inc(1)
+				continue
+			}
+			e.write(msg)
+		default:
+			if e.conn != nil {
+				e.flushConn()
+				e.dropConn()
+			}
+			return
+		}
+	}
+}
+
+func (e *NATSExporter) write(msg natsMessage) {
+	c := e.conn
+	if c == nil {
+		e.dropped.Inc(1)
+		return
+	}
+	c.lock.Lock()
+	fmt.Fprintf(c.out, "PUB %s %d\r\n", msg.subject, len(msg.body))
+	c.out.Write(msg.body)
+	_, err := c.out.WriteString("\r\n")
+	c.lock.Unlock()
+	if err != nil {
+		log.Warn("Plugin nats exporter publish failed", "exporter", e.name, "subject", msg.subject, "err", err)
+		e.dropped.Inc(1)
+		e.dropConn()
+	}
+}
+
+func (e *NATSExporter) flushConn() {
+	c := e.conn
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	err := c.out.Flush()
+	c.lock.Unlock()
+	if err != nil {
+		log.Warn("Plugin nats exporter flush failed", "exporter", e.name, "err", err)
+		e.dropConn()
+	}
+}
+
+func (e *NATSExporter) dropConn() {
+	if e.conn != nil {
+		e.conn.conn.Close()
+		e.conn = nil
+	}
+}
+
+// connect dials the server and runs the CONNECT handshake, confirmed by a
+// PING/PONG round trip so that rejected credentials surface here.
+func (e *NATSExporter) connect() error {
+	conn, err := net.DialTimeout("tcp", e.addr, natsHandshakeTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(natsHandshakeTimeout))
+	in := bufio.NewReader(conn)
+	line, err := in.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO") {
+		conn.Close()
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(line))
+	}
+	options := natsConnect{Name: "noda-" + e.name, Lang: "go", User: e.config.User, Pass: e.config.Password, Token: e.auth.token()}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	out := bufio.NewWriter(conn)
+	fmt.Fprintf(out, "CONNECT %s\r\nPING\r\n", connect)
+	if err := out.Flush(); err != nil {
+		conn.Close()
+		return err
+	}
+	if line, err = in.ReadString('\n'); err != nil {
+		conn.Close()
+		return err
+	}
+	if line = strings.TrimSpace(line); line != "PONG" {
+		conn.Close()
+		return fmt.Errorf("server refused connection: %s", line)
+	}
+	conn.SetDeadline(time.Time{})
+
+	e.conn = &natsConn{conn: conn, out: out, dead: make(chan struct{})}
+	go e.readLoop(e.conn, in)
+	return nil
+}
+
+// readLoop answers the keep-alive PINGs of the server and reports errors.
+func (e *NATSExporter) readLoop(c *natsConn, in *bufio.Reader) {
+	defer close(c.dead)
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PING":
+			c.lock.Lock()
+			c.out.WriteString("PONG\r\n")
+			c.out.Flush()
+			c.lock.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			log.Warn("Plugin nats exporter got server error", "exporter", e.name, "err", line)
+		}
+	}
+}
+
+// Close stops accepting events and returns once the queued ones are written
+// and flushed to the server or given up on.
+func (e *NATSExporter) Close() error {
+	e.lock.Lock()
+	if e.closed {
+		e.lock.Unlock()
+		return nil
+	}
+	e.closed = true
+	e.lock.Unlock()
+
+	close(e.quit)
+	<-e.done
+	return nil
+}
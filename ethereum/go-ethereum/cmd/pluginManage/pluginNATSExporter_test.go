@@ -0,0 +1,152 @@
+package pluginManage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhidandeng/collector"
+)
+
+type natsPublish struct {
+	subject string
+	body    []byte
+}
+
+// fakeNATS speaks enough of the NATS protocol to accept publishers.
+type fakeNATS struct {
+	listener net.Listener
+
+	lock      sync.Mutex
+	connects  []string
+	conns     []net.Conn
+	published []natsPublish
+}
+
+func newFakeNATS(t *testing.T) *fakeNATS {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeNATS{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fakeNATS) serve(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\"}\r\n")
+	in := bufio.NewReader(conn)
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT":
+			s.lock.Lock()
+			s.connects = append(s.connects, strings.TrimSpace(strings.TrimPrefix(line, "CONNECT")))
+			s.conns = append(s.conns, conn)
+			s.lock.Unlock()
+		case "PING":
+			fmt.Fprintf(conn, "PONG\r\n")
+		case "PUB":
+			var size int
+			fmt.Sscan(fields[len(fields)-1], &size)
+			body := make([]byte, size+2)
+			if _, err := io.ReadFull(in, body); err != nil {
+				return
+			}
+			s.lock.Lock()
+			s.published = append(s.published, natsPublish{fields[1], body[:size]})
+			s.lock.Unlock()
+		}
+	}
+}
+
+// wait polls until cond holds under the server lock.
+func (s *fakeNATS) wait(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s.lock.Lock()
+		ok := cond()
+		s.lock.Unlock()
+		if ok {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestNATSExporterSubjects(t *testing.T) {
+	server := newFakeNATS(t)
+	manage := NewPluginManages()
+	exp, err := NewNATSExporter(ExporterConfig{
+		Name: "mesh",
+		URL:  "nats://" + server.listener.Addr().String(),
+		Auth: AuthConfig{Token: "s3cret"},
+		NATS: NATSConfig{Subject: "chain", ChainSubject: true, OpcodeSubject: true, ReconnectInterval: "1ms"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manage.AddExporter(exp)
+	manage.SetBlockContext(big.NewInt(1), big.NewInt(7))
+
+	opcodes := []string{"TXSTART", "TRANS_CALL", "TXEND"}
+	for _, opcode := range opcodes {
+		manage.SendDataToPlugin(opcode, collector.SendFlag(opcode))
+	}
+	manage.FlushBlock()
+	server.wait(t, "first block", func() bool { return len(server.published) == len(opcodes) })
+
+	for i, msg := range server.published {
+		if want := "chain.1." + opcodes[i]; msg.subject != want {
+			t.Errorf("message %d on %q, want %q", i, msg.subject, want)
+		}
+		var env Envelope
+		if err := json.Unmarshal(msg.body, &env); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if env.Opcode != opcodes[i] || env.ChainID != "1" || env.Payload.Option != opcodes[i] {
+			t.Errorf("message %d carries %+v", i, env)
+		}
+	}
+	if !strings.Contains(server.connects[0], `"auth_token":"s3cret"`) {
+		t.Errorf("token missing from CONNECT %s", server.connects[0])
+	}
+	// After the server drops the connection the exporter comes back and
+	// publishes the next block.
+	server.lock.Lock()
+	server.conns[0].Close()
+	server.lock.Unlock()
+	server.wait(t, "reconnect", func() bool { return len(server.connects) == 2 })
+
+	manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+	manage.FlushBlock()
+	server.wait(t, "second block", func() bool { return len(server.published) == len(opcodes)+1 })
+
+	if err := manage.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -30,7 +30,7 @@ var registerOp = map[string]int{
 	"SAR":            0,
 	"ADDMOD":         0,
 	"MULMOD":         0,
-	"SHA3":           0,
+	"KECCAK256":      0,
 	"ADDRESS":        0,
 	"BALANCE":        0,
 	"ORIGIN":         0,
@@ -145,51 +145,76 @@ var registerOp = map[string]int{
 	"SELFDESTRUCT":   0,
 
 	//add plugin
-	"EXTERNALINFOSTART":	0,
-	"EXTERNALINFOEND":		0,
-	"CREATESTART":			0,
-	"CREATEEND":			0,
-	"CREATE2START":			0,
-	"CREATE2END":			0,
-	"CALLSTART":			0,
-	"CALLEND":				0,
-	"CALLCODESTART":		0,
-	"CALLCODEEND":			0,
-	"DELEGATECALLSTART":	0,
-	"DELEGATECALLEND":		0,
-	"STATICCALLSTART":		0,
-	"STATICCALLEND":		0,
-	"ENDSIGNAL":			0,
-	"BLOCK_INFO":			0,
-	"TXSTART":				0,
-	"TXEND":				0,
-	"TRANS_CREATE":			0,
-	"TRANS_CREATE2":		0,
-	"TRANS_CALL":			0,
-	"TRANS_CALLCODE":		0,
-	"TRANS_DELEGATECALL":	0,
-	"TRANS_STATICCALL":		0,
-	"TRANS_SUICIDE":		0,
+	"EXTERNALINFOSTART":      0,
+	"EXTERNALINFOEND":        0,
+	"CREATESTART":            0,
+	"CREATEEND":              0,
+	"CREATE2START":           0,
+	"CREATE2END":             0,
+	"CALLSTART":              0,
+	"CALLEND":                0,
+	"CALLCODESTART":          0,
+	"CALLCODEEND":            0,
+	"DELEGATECALLSTART":      0,
+	"DELEGATECALLEND":        0,
+	"STATICCALLSTART":        0,
+	"STATICCALLEND":          0,
+	"ENDSIGNAL":              0,
+	"BLOCK_INFO":             0,
+	"TXSTART":                0,
+	"TXEND":                  0,
+	"TRANS_CREATE":           0,
+	"TRANS_CREATE2":          0,
+	"TRANS_CALL":             0,
+	"TRANS_CALLCODE":         0,
+	"TRANS_DELEGATECALL":     0,
+	"TRANS_STATICCALL":       0,
+	"TRANS_SUICIDE":          0,
+	"handle_BLOCK_INFO":      0,
+	"handle_BLOCK_END":       0,
+	"handle_PRECOMPILE":      0,
+	"handle_REENTRANCY":      0,
+	"handle_CALL_REPEAT":     0,
+	"handle_NONCE_ANOMALY":   0,
+	"handle_WOULD_BLOCK":     0,
+	"handle_STATE_REVERTED":  0,
+	"handle_FORK_RULES":      0,
+	"handle_TX_ANOMALY":      0,
+	"handle_BLOCK_GAS_STATS": 0,
+	"handle_TOUCHED_SET":     0,
+	"handle_VALUE_TRANSFER":  0,
+	"handle_DEEP_CALLS":      0,
+	"handle_PLUGIN_SLOW":     0,
+	"handle_TX_SUMMARY":      0,
+	"handle_PLUGIN_SHED":     0,
+	"handle_REDEPLOY":        0,
+	"handle_ACCOUNT_PROOF":   0,
 }
 
-var registerIALOp = map[string][]string {
-	"IAL_BYTECODE":			[]string{"EXTERNALINFOEND","EXTERNALINFOEND","TRANS_CREATE","TRANS_CREATE2"},
-	"IAL_INVOKE":			[]string{"EXTERNALINFOSTART","EXTERNALINFOEND","TRANS_CALL","TRANS_CALLCODE","TRANS_DELEGATECALL","TRANS_STATICCALL"},
-	"IAL_MEMORY":			[]string{"SHA3","CALLDATACOPY","CODECOPY","RETURNDATACOPY","MLAOD","MSTORE","MSTORE8","CREATESTART","CREATEEND","CREATE2START","CREATE2END","CALLSTART","CALLEND","CALLCODESTART","CALLCODEEND","DELEGATECALLSTART","DELEGATECALLEND","STATICCALLSTART","STATICCALLEND","RETURN"},
-	"IAL_STORAGE":			[]string{"SLOAD","SSTORE"},
-	"IAL_ETH":				[]string{"TRANS_CREATE","TRANS_CALL","TRANS_CALLCODE","TRANS_SUICIDE"},
-	"IAL_BALANCE":			[]string{"EXTERNALINFOSTART","EXTERNALINFOEND","CALLSTART","CALLEND","CALLCODESTART","CALLCODEEND","CREATESTART","CREATEEND","CREATE2START","CREATE2END","SELFDESTRUCT"},
-	"IAL_CONTROLFLOW":		[]string{"JUMP","JUMPI"},
-	"IAL_COMPARISON":		[]string{"LT","GT","SLT","SGT","NOT","EQ","ISZERO"},
-	"IAL_ARITHMETIC":		[]string{"ADD","MUL","SUB","DIV","SDIV","MOD","SMOD","ADDMOD","MULMOD","EXP"},
-	"IAL_EVENT":			[]string{"LOG0","LOG1","LOG2","LOG3","LOG4"},
+var registerIALOp = map[string][]string{
+	"IAL_BYTECODE":    []string{"EXTERNALINFOEND", "EXTERNALINFOEND", "TRANS_CREATE", "TRANS_CREATE2"},
+	"IAL_INVOKE":      []string{"EXTERNALINFOSTART", "EXTERNALINFOEND", "TRANS_CALL", "TRANS_CALLCODE", "TRANS_DELEGATECALL", "TRANS_STATICCALL"},
+	"IAL_MEMORY":      []string{"KECCAK256", "CALLDATACOPY", "CODECOPY", "RETURNDATACOPY", "MLAOD", "MSTORE", "MSTORE8", "CREATESTART", "CREATEEND", "CREATE2START", "CREATE2END", "CALLSTART", "CALLEND", "CALLCODESTART", "CALLCODEEND", "DELEGATECALLSTART", "DELEGATECALLEND", "STATICCALLSTART", "STATICCALLEND", "RETURN"},
+	"IAL_STORAGE":     []string{"SLOAD", "SSTORE"},
+	"IAL_ETH":         []string{"TRANS_CREATE", "TRANS_CALL", "TRANS_CALLCODE", "TRANS_SUICIDE"},
+	"IAL_BALANCE":     []string{"EXTERNALINFOSTART", "EXTERNALINFOEND", "CALLSTART", "CALLEND", "CALLCODESTART", "CALLCODEEND", "CREATESTART", "CREATEEND", "CREATE2START", "CREATE2END", "SELFDESTRUCT"},
+	"IAL_CONTROLFLOW": []string{"JUMP", "JUMPI"},
+	"IAL_COMPARISON":  []string{"LT", "GT", "SLT", "SGT", "NOT", "EQ", "ISZERO"},
+	"IAL_ARITHMETIC":  []string{"ADD", "MUL", "SUB", "DIV", "SDIV", "MOD", "SMOD", "ADDMOD", "MULMOD", "EXP"},
+	"IAL_EVENT":       []string{"LOG0", "LOG1", "LOG2", "LOG3", "LOG4"},
+}
+
+// opcodeAliases maps deprecated opcode names to the ones events are emitted
+// under, so plugins subscribing with an old name keep getting data.
+var opcodeAliases = map[string]string{
+	"SHA3": "KECCAK256", // renamed in the EVM
 }
 
 func IsOpExist(opcode string) int {
-	if _, opok := registerOp[opcode];opok{
+	if _, opok := registerOp[opcode]; opok {
 		return 1
 	}
-	if _, IALok := registerIALOp[opcode];IALok{
+	if _, IALok := registerIALOp[opcode]; IALok {
 		return 2
 	}
 	return 0
@@ -201,4 +226,4 @@ func ReturnIALArray(opcode string) []string {
 
 func RetunOpcodeMap() map[string]int {
 	return registerOp
-}
\ No newline at end of file
+}
@@ -0,0 +1,32 @@
+package pluginManage
+
+import (
+	"testing"
+
+	"github.com/zhidandeng/collector"
+)
+
+func TestOpcodeAlias(t *testing.T) {
+	manage := NewPluginManages()
+	var received []string
+	testMonitor(manage, "legacy", "", "SHA3", func(data *collector.AllCollector) (byte, string) {
+		received = append(received, data.Option)
+		return 0x00, ""
+	})
+	manage.AddOpcodeAlias("BLOCK_START", "handle_BLOCK_INFO")
+	testMonitor(manage, "legacy", "", "BLOCK_START", func(data *collector.AllCollector) (byte, string) {
+		received = append(received, data.Option)
+		return 0x00, ""
+	})
+	for _, opcode := range []string{"KECCAK256", "SHA3", "handle_BLOCK_INFO"} {
+		if !manage.GetOpcodeRegister(opcode) {
+			t.Errorf("%s not registered through its alias", opcode)
+		}
+	}
+	manage.Start()
+	manage.SendDataToPlugin("KECCAK256", collector.SendFlag("KECCAK256"))
+	manage.SendDataToPlugin("handle_BLOCK_INFO", collector.SendFlag("handle_BLOCK_INFO"))
+	if len(received) != 2 || received[0] != "KECCAK256" || received[1] != "handle_BLOCK_INFO" {
+		t.Errorf("legacy subscriptions received %v", received)
+	}
+}
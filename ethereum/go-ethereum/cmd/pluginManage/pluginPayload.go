@@ -0,0 +1,29 @@
+package pluginManage
+
+//add new file
+
+// SetMaxPayload caps the bytes of call input and output copied into the
+// collectors, 0 keeps them whole.
+func (plg *PluginManages) SetMaxPayload(size int) {
+	if size < 0 {
+		size = 0
+	}
+	plg.maxPayload = size
+}
+
+// MaxPayload returns the cap on copied call input and output, 0 for none.
+func (plg *PluginManages) MaxPayload() int {
+	return plg.maxPayload
+}
+
+// CapPayload returns a copy of data cut to the payload cap. Call data lives
+// in EVM memory the call may overwrite, so it is always copied.
+func (plg *PluginManages) CapPayload(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	if plg.maxPayload > 0 && len(data) > plg.maxPayload {
+		data = data[:plg.maxPayload]
+	}
+	return append([]byte{}, data...)
+}
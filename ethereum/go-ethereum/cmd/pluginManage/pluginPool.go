@@ -0,0 +1,172 @@
+package pluginManage
+
+//add new file
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/zhidandeng/collector"
+)
+
+const (
+	defaultPoolWorkers = 2    // workers of a plugin without a configured size
+	defaultPoolQueue   = 1024 // events an async plugin may fall behind by
+)
+
+// PoolConfig sizes the worker pools of the async plugins.
+type PoolConfig struct {
+	Workers   int            `json:"workers"`   // default workers per plugin
+	QueueSize int            `json:"queuesize"` // default queued events per plugin
+	Plugins   map[string]int `json:"plugins"`   // workers by plugin name
+}
+
+// asyncEvent is an event queued for an async plugin. The transaction and
+// contract are captured at emission, the manager has moved on when a worker
+// gets to the event.
+type asyncEvent struct {
+	monitor  *MonitorType
+	opcode   string
+	data     *collector.AllCollector
+	txHash   string
+	contract string
+	ctx      context.Context // abandons the event once the processing is cancelled
+}
+
+// workerPool runs the events of one async plugin on its own goroutines. Its
+// queue is bounded: when the plugin falls behind, its newest events are
+// dropped instead of stalling block processing and the other plugins.
+type workerPool struct {
+	call    func(*MonitorType, *collector.AllCollector) (byte, string)
+	queue   chan asyncEvent
+	wg      sync.WaitGroup
+	logLock sync.Mutex // the warning log of a plugin is not safe for concurrent use
+	drops   uint64
+	dropped metrics.Counter
+}
+
+func newWorkerPool(name string, workers, queueSize int, call func(*MonitorType, *collector.AllCollector) (byte, string)) *workerPool {
+	pool := &workerPool{
+		call:    call,
+		queue:   make(chan asyncEvent, queueSize),
+		dropped: metrics.GetOrRegisterCounter("plugin/async/"+name+"/dropped", nil),
+	}
+	pool.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go pool.loop()
+	}
+	return pool
+}
+
+func (pool *workerPool) loop() {
+	defer pool.wg.Done()
+	for event := range pool.queue {
+		if event.ctx.Err() != nil {
+			continue
+		}
+		level, results := pool.call(event.monitor, event.data)
+		if level == 0x00 {
+			continue
+		}
+		// async plugins only monitor, a blocking verdict is reported as serious
+		warn := 2
+		if level != 0x01 {
+			warn = 3
+		}
+		pool.logLock.Lock()
+		writeWarningReport(event.monitor.GetPluginName(), results, event.monitor.GetLogger(), event.opcode, event.txHash, event.contract, warn, event.data)
+		pool.logLock.Unlock()
+	}
+}
+
+func (pool *workerPool) submit(event asyncEvent) bool {
+	select {
+	case pool.queue <- event:
+		return true
+	default:
+		atomic.AddUint64(&pool.drops, 1)
+		pool.dropped.Inc(1)
+		return false
+	}
+}
+
+// close stops accepting events and waits until the queued ones are handled.
+func (pool *workerPool) close() {
+	close(pool.queue)
+	pool.wg.Wait()
+}
+
+// SetPools sets the pool sizes used for async plugins started afterwards.
+func (plg *PluginManages) SetPools(config PoolConfig) {
+	plg.poolConfig = config
+}
+
+// PoolDropped returns how many events of the async plugin were dropped
+// because its queue was full.
+func (plg *PluginManages) PoolDropped(pluginName string) uint64 {
+	plg.poolLock.Lock()
+	defer plg.poolLock.Unlock()
+
+	if pool, ok := plg.pools[pluginName]; ok {
+		return atomic.LoadUint64(&pool.drops)
+	}
+	return 0
+}
+
+// pool returns the worker pool of an async plugin, starting it on first use.
+// It returns nil once the pools are shut down.
+func (plg *PluginManages) pool(pluginName string) *workerPool {
+	plg.poolLock.Lock()
+	defer plg.poolLock.Unlock()
+
+	if plg.poolsClosed {
+		return nil
+	}
+	if pool, ok := plg.pools[pluginName]; ok {
+		return pool
+	}
+	workers := plg.poolConfig.Plugins[pluginName]
+	if workers <= 0 {
+		workers = plg.poolConfig.Workers
+	}
+	if workers <= 0 {
+		workers = defaultPoolWorkers
+	}
+	queueSize := plg.poolConfig.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultPoolQueue
+	}
+	if plg.pools == nil {
+		plg.pools = make(map[string]*workerPool)
+	}
+	pool := newWorkerPool(pluginName, workers, queueSize, plg.callPlugin)
+	plg.pools[pluginName] = pool
+	return pool
+}
+
+// dispatchAsync queues data for the pool of an async monitor.
+func (plg *PluginManages) dispatchAsync(monitor *MonitorType, opcode string, data *collector.AllCollector) {
+	event := asyncEvent{monitor: monitor, opcode: opcode, data: data, txHash: plg.tx.TxHash, contract: plg.warningContract(opcode), ctx: plg.dispatchContext()}
+	pool := plg.pool(monitor.GetPluginName())
+	if pool == nil {
+		return
+	}
+	if !pool.submit(event) {
+		log.Debug("Async plugin queue full, event dropped", "plugin", monitor.GetPluginName(), "opcode", opcode)
+	}
+}
+
+// closePools drains and stops every worker pool.
+func (plg *PluginManages) closePools() {
+	plg.poolLock.Lock()
+	pools := plg.pools
+	plg.pools, plg.poolsClosed = nil, true
+	plg.poolLock.Unlock()
+
+	for _, pool := range pools {
+		pool.close()
+	}
+}
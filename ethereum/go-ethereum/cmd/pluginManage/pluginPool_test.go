@@ -0,0 +1,120 @@
+package pluginManage
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zhidandeng/collector"
+)
+
+func TestAsyncPoolIsolation(t *testing.T) {
+	manage := NewPluginManages()
+	manage.SetPools(PoolConfig{QueueSize: 16, Plugins: map[string]int{"fast": 4}})
+
+	// The slow plugin hangs on its first events until the test ends.
+	release := make(chan struct{})
+	slow := testMonitor(manage, "slow", "", "TXSTART", func(*collector.AllCollector) (byte, string) {
+		<-release
+		return 0x00, ""
+	})
+	slow.SetAsync(true)
+
+	var fastCount uint64
+	fast := testMonitor(manage, "fast", "", "TXSTART", func(*collector.AllCollector) (byte, string) {
+		atomic.AddUint64(&fastCount, 1)
+		return 0x00, ""
+	})
+	fast.SetAsync(true)
+
+	const events = 1000
+	manage.Start()
+	start := time.Now()
+	for i := 0; i < events; i++ {
+		manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+		// Let the fast pool keep up, its queue is as small as the slow one's.
+		for i >= 8 && atomic.LoadUint64(&fastCount) < uint64(i-8) {
+			if time.Since(start) > 5*time.Second {
+				t.Fatalf("fast plugin stalled at %d of %d events", atomic.LoadUint64(&fastCount), i+1)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for atomic.LoadUint64(&fastCount) < events {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("fast plugin handled %d of %d events", atomic.LoadUint64(&fastCount), events)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if dropped := manage.PoolDropped("fast"); dropped != 0 {
+		t.Errorf("fast plugin dropped %d events", dropped)
+	}
+	// The slow plugin took what fits in its workers and queue, the rest was
+	// dropped without holding up the dispatch.
+	if dropped := manage.PoolDropped("slow"); dropped < events-16-defaultPoolWorkers {
+		t.Errorf("slow plugin dropped %d events, want at least %d", dropped, events-16-defaultPoolWorkers)
+	}
+	close(release)
+	if err := manage.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+}
+
+func TestAsyncPoolDrainedOnShutdown(t *testing.T) {
+	manage := NewPluginManages()
+	var received uint64
+	monitor := testMonitor(manage, "async", "", "TXSTART", func(*collector.AllCollector) (byte, string) {
+		time.Sleep(time.Millisecond)
+		atomic.AddUint64(&received, 1)
+		return 0x00, ""
+	})
+	monitor.SetAsync(true)
+
+	const events = 50
+	manage.Start()
+	for i := 0; i < events; i++ {
+		manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+	}
+	if err := manage.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if n := atomic.LoadUint64(&received); n != events {
+		t.Errorf("%d of %d queued events handled before shutdown returned", n, events)
+	}
+	// Events after shutdown are dropped instead of starting a new pool.
+	manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+}
+
+func TestAsyncPoolCancel(t *testing.T) {
+	manage := NewPluginManages()
+	manage.SetPools(PoolConfig{Workers: 1, QueueSize: 16})
+	ctx, cancel := context.WithCancel(context.Background())
+	manage.SetContext(ctx)
+
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var handled uint64
+	slow := testMonitor(manage, "slow", "", "TXSTART", func(*collector.AllCollector) (byte, string) {
+		started <- struct{}{}
+		<-release
+		atomic.AddUint64(&handled, 1)
+		return 0x00, ""
+	})
+	slow.SetAsync(true)
+
+	manage.Start()
+	for i := 0; i < 5; i++ {
+		manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+	}
+	// The first event is in flight, the other four wait in the queue.
+	<-started
+	cancel()
+	manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+	close(release)
+	if err := manage.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if handled := atomic.LoadUint64(&handled); handled != 1 {
+		t.Errorf("plugin handled %d events after the cancellation, want only the one in flight", handled)
+	}
+}
@@ -0,0 +1,29 @@
+package pluginManage
+
+//add new file
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ProofAccount is an account handle_ACCOUNT_PROOF proves against the state
+// root at the end of every block, so light clients and bridges get the proof
+// without an eth_getProof round trip.
+type ProofAccount struct {
+	Address common.Address `json:"address"`
+	Slots   []common.Hash  `json:"slots"` // storage slots proven along with the account
+}
+
+// SetProofAccounts replaces the accounts handle_ACCOUNT_PROOF proves.
+func (plg *PluginManages) SetProofAccounts(accounts []ProofAccount) {
+	plg.proofAccounts = accounts
+}
+
+// ProofAccounts returns the accounts handle_ACCOUNT_PROOF proves, none when
+// nobody takes the event.
+func (plg *PluginManages) ProofAccounts() []ProofAccount {
+	if !plg.GetOpcodeRegister("handle_ACCOUNT_PROOF") {
+		return nil
+	}
+	return plg.proofAccounts
+}
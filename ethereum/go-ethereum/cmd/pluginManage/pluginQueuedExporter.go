@@ -0,0 +1,151 @@
+package pluginManage
+
+//add new file
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// QueuedExporter runs an exporter on a goroutine of its own, so that a slow
+// or failing sink neither holds up block processing nor the sinks configured
+// after it. Export only queues; once the queue is full events are dropped and
+// counted in plugin/exporter/<name>/dropped.
+type QueuedExporter struct {
+	exporter Exporter
+	queue    chan queuedEvent
+	dropped  metrics.Counter
+
+	lock     sync.Mutex
+	closed   bool
+	queued   uint64         // events queued so far
+	switches []formatSwitch // format switches the loop has yet to apply
+	done     chan struct{}
+}
+
+// queuedEvent is an event to export or, without one, a block end to pass on
+// to a BlockFlusher in order with the events.
+type queuedEvent struct {
+	env *Envelope
+}
+
+// formatSwitch is a format switch that takes effect once the events queued
+// before it are exported.
+type formatSwitch struct {
+	format string
+	at     uint64 // number of events queued when the switch was requested
+}
+
+// NewQueuedExporter queues up to size events for exporter.
+func NewQueuedExporter(exporter Exporter, size int) *QueuedExporter {
+	e := &QueuedExporter{
+		exporter: exporter,
+		queue:    make(chan queuedEvent, size),
+		dropped:  metrics.GetOrRegisterCounter("plugin/exporter/"+exporter.Name()+"/dropped", nil),
+		done:     make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+func (e *QueuedExporter) Name() string { return e.exporter.Name() }
+
+// Export queues env. It fails without blocking when the queue is full.
+func (e *QueuedExporter) Export(env *Envelope) error {
+	if !e.enqueue(queuedEvent{env: env}) {
+		return fmt.Errorf("exporter %q queue full, event dropped", e.Name())
+	}
+	return nil
+}
+
+// FlushBlock passes the block end on once the events queued before it are
+// exported. If the queue is full it is dropped and the block is flushed with
+// the next one.
+func (e *QueuedExporter) FlushBlock() {
+	if _, ok := e.exporter.(BlockFlusher); ok {
+		e.enqueue(queuedEvent{})
+	}
+}
+
+// SetFormat switches the encoding of an exporter that supports it once the
+// events queued before are exported. It does not wait for the queue, the
+// switch is recorded at the current queue position and never dropped.
+func (e *QueuedExporter) SetFormat(format string) {
+	if _, ok := e.exporter.(FormatSwitcher); !ok {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if !e.closed {
+		e.switches = append(e.switches, formatSwitch{format, e.queued})
+	}
+}
+
+func (e *QueuedExporter) enqueue(event queuedEvent) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.closed {
+		return false
+	}
+	select {
+	case e.queue <- event:
+		e.queued++
+		return true
+	default:
+		e.dropped.Inc(1)
+		return false
+	}
+}
+
+// switchFormat applies the format switches requested before the event at
+// position taken was queued.
+func (e *QueuedExporter) switchFormat(taken uint64) {
+	e.lock.Lock()
+	var due []formatSwitch
+	for len(e.switches) > 0 && e.switches[0].at <= taken {
+		due = append(due, e.switches[0])
+		e.switches = e.switches[1:]
+	}
+	e.lock.Unlock()
+
+	for _, sw := range due {
+		e.exporter.(FormatSwitcher).SetFormat(sw.format)
+	}
+}
+
+func (e *QueuedExporter) loop() {
+	defer close(e.done)
+
+	var taken uint64
+	for event := range e.queue {
+		e.switchFormat(taken)
+		taken++
+		if event.env == nil {
+			e.exporter.(BlockFlusher).FlushBlock()
+			continue
+		}
+		if err := e.exporter.Export(event.env); err != nil {
+			log.Warn("Plugin exporter failed", "exporter", e.Name(), "opcode", event.env.Opcode, "err", err)
+		}
+	}
+	// switches requested after the last event still reach the exporter
+	e.switchFormat(taken)
+}
+
+// Close exports what is queued and closes the exporter.
+func (e *QueuedExporter) Close() error {
+	e.lock.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.lock.Unlock()
+
+	<-e.done
+	return e.exporter.Close()
+}
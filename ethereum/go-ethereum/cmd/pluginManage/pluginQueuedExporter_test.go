@@ -0,0 +1,165 @@
+package pluginManage
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhidandeng/collector"
+)
+
+// recordingExporter keeps the opcodes it exports, after waiting for release
+// when it has one.
+type recordingExporter struct {
+	name    string
+	release chan struct{}
+	fail    bool
+
+	lock    sync.Mutex
+	opcodes []string
+	closed  bool
+}
+
+func (e *recordingExporter) Name() string { return e.name }
+
+func (e *recordingExporter) Export(env *Envelope) error {
+	if e.release != nil {
+		<-e.release
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.opcodes = append(e.opcodes, env.Opcode)
+	if e.fail {
+		return errors.New("sink down")
+	}
+	return nil
+}
+
+func (e *recordingExporter) Close() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.closed = true
+	return nil
+}
+
+func (e *recordingExporter) exported() []string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([]string(nil), e.opcodes...)
+}
+
+func TestMultipleSinks(t *testing.T) {
+	manage := NewPluginManages()
+
+	// The alerting sink hangs until released and fails every export, the
+	// data lake sink must not notice.
+	alerts := &recordingExporter{name: "webhook", release: make(chan struct{}), fail: true}
+	lake := &recordingExporter{name: "lake"}
+	queued := NewQueuedExporter(alerts, 2)
+	manage.AddExporter(queued, "handle_TX_ANOMALY")
+	manage.AddExporter(lake, "TXSTART", "TXEND")
+
+	const txs = 5
+	for i := 0; i < txs; i++ {
+		manage.Start()
+		manage.BeginTx(testTxHash(i), common.Address{}, nil)
+		for _, opcode := range []string{"TXSTART", "handle_TX_ANOMALY", "TXEND"} {
+			if manage.GetOpcodeRegister(opcode) {
+				manage.SendDataToPlugin(opcode, collector.SendFlag(opcode))
+			}
+		}
+	}
+	var want []string
+	for i := 0; i < txs; i++ {
+		want = append(want, "TXSTART", "TXEND")
+	}
+	if have := lake.exported(); !reflect.DeepEqual(have, want) {
+		t.Errorf("lake sink exported %v, want %v", have, want)
+	}
+
+	// One anomaly is being exported, two are queued, the rest were dropped.
+	close(alerts.release)
+	if err := queued.Close(); err != nil {
+		t.Fatal(err)
+	}
+	have := alerts.exported()
+	if len(have) < 2 || len(have) > 3 {
+		t.Errorf("webhook sink exported %d events, want 2 or 3", len(have))
+	}
+	for _, opcode := range have {
+		if opcode != "handle_TX_ANOMALY" {
+			t.Errorf("webhook sink exported %s", opcode)
+		}
+	}
+	if !alerts.closed {
+		t.Error("queued exporter did not close its sink")
+	}
+	if err := queued.Export(&Envelope{Opcode: "handle_TX_ANOMALY"}); err == nil {
+		t.Error("closed queued exporter accepted an event")
+	}
+}
+
+func TestNewExporterQueue(t *testing.T) {
+	exporter, err := NewExporter(ExporterConfig{Name: "recent", Type: "ring", Queue: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Close()
+	if _, ok := exporter.(*QueuedExporter); !ok {
+		t.Errorf("exporter with a queue is a %T", exporter)
+	}
+	exporter, err = NewExporter(ExporterConfig{Name: "recent", Type: "ring"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := exporter.(*RingExporter); !ok {
+		t.Errorf("exporter without a queue is a %T", exporter)
+	}
+}
+
+// switchingExporter records format switches in line with the opcodes.
+type switchingExporter struct {
+	recordingExporter
+}
+
+func (e *switchingExporter) SetFormat(format string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.opcodes = append(e.opcodes, "format "+format)
+}
+
+func TestQueuedExporterSetFormatFullQueue(t *testing.T) {
+	sink := &switchingExporter{recordingExporter{name: "switching", release: make(chan struct{})}}
+	queued := NewQueuedExporter(sink, 2)
+
+	// Fill the queue while the sink hangs on its first event.
+	accepted := 0
+	for queued.Export(&Envelope{Opcode: "TXSTART"}) == nil {
+		accepted++
+	}
+	done := make(chan struct{})
+	go func() {
+		queued.SetFormat(collector.FormatCBOR)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("format switch blocked on the full queue")
+	}
+	close(sink.release)
+	queued.Close()
+
+	// The switch lands after the events queued before it.
+	var want []string
+	for i := 0; i < accepted; i++ {
+		want = append(want, "TXSTART")
+	}
+	want = append(want, "format "+collector.FormatCBOR)
+	if have := sink.exported(); !reflect.DeepEqual(have, want) {
+		t.Errorf("exported %v, want %v", have, want)
+	}
+}
@@ -0,0 +1,119 @@
+package pluginManage
+
+//add new file
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zhidandeng/collector"
+)
+
+// RedactConfig keeps the calldata of some contracts or functions on the node.
+// The exporters get it with everything after the selector replaced, the
+// plugins, enforce ones included, still get it raw.
+type RedactConfig struct {
+	Contracts   []common.Address `json:"contracts"`   // calls into these contracts
+	Selectors   []string         `json:"selectors"`   // calls of these functions, into any contract
+	Placeholder string           `json:"placeholder"` // replaces the data, its keccak256 hash when empty
+	Logs        bool             `json:"logs"`        // also replace the LOG data of Contracts
+}
+
+type redactPolicy struct {
+	contracts   map[common.Address]bool
+	selectors   SelectorSet
+	placeholder []byte
+	logs        bool
+}
+
+// SetRedaction replaces the redaction policy of the exporters. An empty
+// configuration turns redaction off.
+func (plg *PluginManages) SetRedaction(config RedactConfig) error {
+	selectors, err := ParseSelectors(config.Selectors)
+	if err != nil {
+		return err
+	}
+	if len(config.Contracts) == 0 && selectors == nil {
+		plg.redact = nil
+		return nil
+	}
+	policy := &redactPolicy{
+		contracts: make(map[common.Address]bool, len(config.Contracts)),
+		selectors: selectors,
+		logs:      config.Logs,
+	}
+	for _, contract := range config.Contracts {
+		policy.contracts[contract] = true
+	}
+	if config.Placeholder != "" {
+		policy.placeholder = []byte(config.Placeholder)
+	}
+	plg.redact = policy
+	return nil
+}
+
+// apply returns data as the exporters may see it: data itself when nothing
+// in it is to be hidden, a copy with the calldata replaced otherwise.
+func (p *redactPolicy) apply(opcode string, data *collector.AllCollector) *collector.AllCollector {
+	redacted := *data
+	changed := false
+
+	call := &redacted.TransInfo.CallInfo
+	if input := call.InputData; len(input) > 0 || len(call.Input) > 0 {
+		if len(input) == 0 {
+			input = call.Input
+		}
+		if p.matches(redacted.TransInfo.To, input) {
+			call.InputData = p.calldata(call.InputData)
+			call.Input = p.calldata(call.Input)
+			call.DecodedInput.Args = nil
+			changed = true
+		}
+	}
+	ins := &redacted.InsInfo
+	if contract := ins.AccountValue.CallContract; contract != "" {
+		if strings.HasPrefix(opcode, "LOG") {
+			if p.logs && p.contracts[common.HexToAddress(contract)] && len(ins.OpInOut.RetArgs) > 0 {
+				ins.OpInOut.RetArgs = p.replace(ins.OpInOut.RetArgs)
+				changed = true
+			}
+		} else if len(ins.OpInOut.InputData) > 0 && p.matches(contract, ins.OpInOut.InputData) {
+			ins.OpInOut.InputData = p.calldata(ins.OpInOut.InputData)
+			changed = true
+		}
+	}
+	if !changed {
+		return data
+	}
+	return &redacted
+}
+
+// matches reports whether the calldata input of a call into contract is to
+// be hidden.
+func (p *redactPolicy) matches(contract string, input []byte) bool {
+	if contract != "" && p.contracts[common.HexToAddress(contract)] {
+		return true
+	}
+	if p.selectors == nil || len(input) < 4 {
+		return false
+	}
+	var selector [4]byte
+	copy(selector[:], input)
+	return p.selectors[selector]
+}
+
+// calldata replaces input but its selector.
+func (p *redactPolicy) calldata(input []byte) []byte {
+	if len(input) <= 4 {
+		return input
+	}
+	return append(append([]byte{}, input[:4]...), p.replace(input[4:])...)
+}
+
+func (p *redactPolicy) replace(data []byte) []byte {
+	if p.placeholder != nil {
+		return append([]byte{}, p.placeholder...)
+	}
+	return crypto.Keccak256(data)
+}
@@ -0,0 +1,89 @@
+package pluginManage
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zhidandeng/collector"
+)
+
+// payloadExporter keeps the payloads it is handed.
+type payloadExporter struct{ payloads []*collector.AllCollector }
+
+func (e *payloadExporter) Name() string { return "payloads" }
+func (e *payloadExporter) Close() error { return nil }
+func (e *payloadExporter) Export(env *Envelope) error {
+	e.payloads = append(e.payloads, env.Payload)
+	return nil
+}
+
+func TestRedactExportedCalldata(t *testing.T) {
+	private, public := common.Address{0xa1}, common.Address{0xa2}
+	manage := NewPluginManages()
+	if err := manage.SetRedaction(RedactConfig{Contracts: []common.Address{private}, Logs: true}); err != nil {
+		t.Fatal(err)
+	}
+	exp := new(payloadExporter)
+	manage.AddExporter(exp)
+	var seen [][]byte
+	testMonitor(manage, "enforcer", "enforce", "EXTERNALINFOSTART", func(data *collector.AllCollector) (byte, string) {
+		seen = append(seen, data.TransInfo.CallInfo.InputData)
+		return 0x00, ""
+	})
+	manage.SetBlockContext(big.NewInt(1), big.NewInt(1))
+	manage.Start()
+
+	input := []byte{0xa9, 0x05, 0x9c, 0xbb, 0x01, 0x02, 0x03}
+	for i, to := range []common.Address{private, public} {
+		manage.BeginTx(testTxHash(i), common.Address{}, &to)
+		tc := collector.NewTransCollector()
+		tc.To = to.String()
+		tc.CallInfo.InputData = input
+		tc.CallInfo.DecodedInput.Args = []collector.DecodedArg{{Name: "secret", Value: "0x010203"}}
+		manage.SendDataToPlugin("EXTERNALINFOSTART", tc.SendTransInfo("EXTERNALINFOSTART"))
+
+		ins := collector.NewCollector()
+		ins.AccountValue.CallContract = to.String()
+		ins.OpInOut.RetArgs = []byte("log data")
+		manage.SendDataToPlugin("LOG1", ins.SendInsInfo())
+	}
+	if len(exp.payloads) != 4 {
+		t.Fatalf("exported %d events, want 4", len(exp.payloads))
+	}
+	redacted := append(input[:4:4], crypto.Keccak256(input[4:])...)
+	if call := exp.payloads[0].TransInfo.CallInfo; !bytes.Equal(call.InputData, redacted) || call.DecodedInput.Args != nil {
+		t.Errorf("calldata of %x exported as %x %v, want %x without args", private, call.InputData, call.DecodedInput.Args, redacted)
+	}
+	if data := exp.payloads[1].InsInfo.OpInOut.RetArgs; !bytes.Equal(data, crypto.Keccak256([]byte("log data"))) {
+		t.Errorf("log data of %x exported as %q", private, data)
+	}
+	if call := exp.payloads[2].TransInfo.CallInfo; !bytes.Equal(call.InputData, input) || len(call.DecodedInput.Args) != 1 {
+		t.Errorf("calldata of %x exported as %x, want it raw", public, call.InputData)
+	}
+	if data := exp.payloads[3].InsInfo.OpInOut.RetArgs; string(data) != "log data" {
+		t.Errorf("log data of %x exported as %q, want it raw", public, data)
+	}
+	if len(seen) != 2 || !bytes.Equal(seen[0], input) || !bytes.Equal(seen[1], input) {
+		t.Errorf("enforce plugin saw %x, want the raw calldata twice", seen)
+	}
+
+	// A placeholder replaces the data of the matching selector in any contract.
+	if err := manage.SetRedaction(RedactConfig{Selectors: []string{"0xa9059cbb"}, Placeholder: "redacted"}); err != nil {
+		t.Fatal(err)
+	}
+	exp.payloads = nil
+	manage.BeginTx(testTxHash(2), common.Address{}, &public)
+	tc := collector.NewTransCollector()
+	tc.To = public.String()
+	tc.CallInfo.InputData = input
+	manage.SendDataToPlugin("EXTERNALINFOSTART", tc.SendTransInfo("EXTERNALINFOSTART"))
+	if len(exp.payloads) != 1 || string(exp.payloads[0].TransInfo.CallInfo.InputData) != "\xa9\x05\x9c\xbbredacted" {
+		t.Errorf("selector match exported as %q", exp.payloads[0].TransInfo.CallInfo.InputData)
+	}
+	if err := manage.SetRedaction(RedactConfig{Selectors: []string{"0xa9"}}); err == nil {
+		t.Error("short selector accepted")
+	}
+}
@@ -0,0 +1,36 @@
+package pluginManage
+
+//add new file
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Contracts redeployed at the address of a self-destructed one, e.g. through
+// CREATE2 with the same salt and init code, may run different code than the
+// one users approved. The manager remembers the code hash of every contract
+// destroyed while handle_REDEPLOY is registered so a later deployment at the
+// address can be flagged. The record lives in memory: it covers the blocks
+// processed since the node started and is not rolled back by a reorg.
+
+// RecordDestroyed remembers that the contract at addr, running the code of
+// codeHash, was destroyed by a transaction that went through.
+func (plg *PluginManages) RecordDestroyed(addr common.Address, codeHash common.Hash) {
+	plg.destroyedLock.Lock()
+	defer plg.destroyedLock.Unlock()
+
+	if plg.destroyed == nil {
+		plg.destroyed = make(map[common.Address]common.Hash)
+	}
+	plg.destroyed[addr] = codeHash
+}
+
+// Destroyed returns the code hash of the last contract destroyed at addr and
+// whether there was one.
+func (plg *PluginManages) Destroyed(addr common.Address) (common.Hash, bool) {
+	plg.destroyedLock.Lock()
+	defer plg.destroyedLock.Unlock()
+
+	codeHash, ok := plg.destroyed[addr]
+	return codeHash, ok
+}
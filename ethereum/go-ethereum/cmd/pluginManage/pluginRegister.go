@@ -0,0 +1,76 @@
+package pluginManage
+
+//add new file
+
+import (
+	"fmt"
+)
+
+// OpcodeRegistration is what a plugin subscribes to an opcode with: the
+// function receiving the events and the settings overridden for them. The
+// manifest may give it as a bare function name, the form Register() used to
+// return before options existed.
+type OpcodeRegistration struct {
+	Func    string        `json:"func"`
+	Options OpcodeOptions `json:"options"`
+}
+
+// OpcodeOptions override the plugin wide settings of RegisterInfo for one
+// opcode. Options left out keep the plugin's.
+type OpcodeOptions struct {
+	Mode      string          `json:"mode"`
+	Sampling  *SamplingConfig `json:"sampling"`
+	Selectors []string        `json:"selectors"`
+}
+
+// UnmarshalJSON accepts both the object and the legacy string form.
+func (r *OpcodeRegistration) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*r = OpcodeRegistration{Func: name}
+		return nil
+	}
+	type plain OpcodeRegistration
+	var reg plain
+	if err := json.Unmarshal(data, &reg); err != nil {
+		return err
+	}
+	if reg.Func == "" {
+		return fmt.Errorf("opcode registration without func")
+	}
+	*r = OpcodeRegistration(reg)
+	return nil
+}
+
+// ParseRegisterInfo decodes the manifest returned by the Register() function
+// of a plugin.
+func ParseRegisterInfo(data []byte) (*RegisterInfo, error) {
+	info := new(RegisterInfo)
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+	if _, err := ParseFieldMask(info.Fields); err != nil {
+		return nil, err
+	}
+	for opcode, reg := range info.OpCode {
+		if _, err := ParseSelectors(reg.Options.Selectors); err != nil {
+			return nil, fmt.Errorf("opcode %s: %v", opcode, err)
+		}
+	}
+	return info, nil
+}
+
+// apply sets the overridden settings on the monitor of the opcode, which has
+// the plugin wide ones already.
+func (o OpcodeOptions) apply(monitor *MonitorType) {
+	if o.Mode != "" {
+		monitor.SetMode(o.Mode)
+	}
+	if o.Sampling != nil {
+		monitor.SetSampling(*o.Sampling)
+	}
+	if len(o.Selectors) > 0 {
+		selectors, _ := ParseSelectors(o.Selectors) // checked by ParseRegisterInfo
+		monitor.SetSelectors(selectors)
+	}
+}
@@ -0,0 +1,63 @@
+package pluginManage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRegisterInfo(t *testing.T) {
+	legacy := `{"pluginname":"P1","mode":"enforce","option":{"EXTERNALINFOSTART":"Handle_EXTERNALINFOSTART","CALLSTART":"Handle_CALLSTART"}}`
+	info, err := ParseRegisterInfo([]byte(legacy))
+	if err != nil {
+		t.Fatalf("legacy manifest: %v", err)
+	}
+	want := map[string]OpcodeRegistration{
+		"EXTERNALINFOSTART": {Func: "Handle_EXTERNALINFOSTART"},
+		"CALLSTART":         {Func: "Handle_CALLSTART"},
+	}
+	if !reflect.DeepEqual(info.OpCode, want) {
+		t.Errorf("legacy manifest: %+v, want %+v", info.OpCode, want)
+	}
+
+	structured := `{"pluginname":"P1","mode":"enforce","option":{
+		"EXTERNALINFOSTART":"Handle_EXTERNALINFOSTART",
+		"TRANS_CALL":{"func":"Handle_CALL","options":{"mode":"monitor","sampling":{"rate":10},"selectors":["0xa9059cbb"]}}
+	}}`
+	info, err = ParseRegisterInfo([]byte(structured))
+	if err != nil {
+		t.Fatalf("structured manifest: %v", err)
+	}
+	want = map[string]OpcodeRegistration{
+		"EXTERNALINFOSTART": {Func: "Handle_EXTERNALINFOSTART"},
+		"TRANS_CALL": {Func: "Handle_CALL", Options: OpcodeOptions{
+			Mode:      "monitor",
+			Sampling:  &SamplingConfig{Rate: 10},
+			Selectors: []string{"0xa9059cbb"},
+		}},
+	}
+	if !reflect.DeepEqual(info.OpCode, want) {
+		t.Errorf("structured manifest: %+v, want %+v", info.OpCode, want)
+	}
+
+	// The options override the plugin wide settings only where given.
+	var monitor MonitorType
+	monitor.SetMode(info.Mode)
+	info.OpCode["EXTERNALINFOSTART"].Options.apply(&monitor)
+	if monitor.GetMode() != "enforce" || monitor.Sampling != (SamplingConfig{}) {
+		t.Errorf("legacy registration changed the monitor: mode %q sampling %+v", monitor.GetMode(), monitor.Sampling)
+	}
+	info.OpCode["TRANS_CALL"].Options.apply(&monitor)
+	if monitor.GetMode() != "monitor" || monitor.Sampling.Rate != 10 || len(monitor.Selectors) != 1 {
+		t.Errorf("options not applied: mode %q sampling %+v selectors %v", monitor.GetMode(), monitor.Sampling, monitor.Selectors)
+	}
+
+	for _, bad := range []string{
+		`{"option":{"TRANS_CALL":{"options":{"mode":"monitor"}}}}`,
+		`{"option":{"TRANS_CALL":{"func":"Handle_CALL","options":{"selectors":["0x12"]}}}}`,
+		`{"option":{"TRANS_CALL":42}}`,
+	} {
+		if _, err := ParseRegisterInfo([]byte(bad)); err == nil {
+			t.Errorf("manifest %s accepted", bad)
+		}
+	}
+}
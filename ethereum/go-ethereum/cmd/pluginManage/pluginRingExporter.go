@@ -0,0 +1,123 @@
+package pluginManage
+
+//add new file
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// defaultRingSize is the number of events a ring exporter keeps when its
+// size is not configured.
+const defaultRingSize = 10000
+
+// ErrNoRingExporter is returned by RecentEvents when no ring exporter is
+// configured.
+var ErrNoRingExporter = errors.New("no ring exporter configured")
+
+// RingExporter keeps the most recent events in memory, overwriting the
+// oldest once it is full, so they can be queried over RPC.
+type RingExporter struct {
+	name string
+
+	lock   sync.RWMutex
+	events []*Envelope
+	next   int // slot the next event is written to
+	full   bool
+}
+
+// NewRingExporter creates a ring exporter holding config.Size events.
+func NewRingExporter(config ExporterConfig) *RingExporter {
+	size := config.Size
+	if size <= 0 {
+		size = defaultRingSize
+	}
+	return &RingExporter{name: config.Name, events: make([]*Envelope, size)}
+}
+
+func (e *RingExporter) Name() string { return e.name }
+
+func (e *RingExporter) Export(env *Envelope) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.events[e.next] = env
+	e.next = (e.next + 1) % len(e.events)
+	if e.next == 0 {
+		e.full = true
+	}
+	return nil
+}
+
+func (e *RingExporter) Close() error { return nil }
+
+// EventQuery selects retained events. Zero fields match everything; ToBlock
+// 0 leaves the block range open ended.
+type EventQuery struct {
+	FromBlock uint64          `json:"fromblock"`
+	ToBlock   uint64          `json:"toblock"`
+	TxHash    string          `json:"txhash"`
+	Opcode    string          `json:"opcode"`
+	Address   *common.Address `json:"address"` // contract called, created or executing
+	Limit     int             `json:"limit"`   // newest events kept when more match
+}
+
+func (q *EventQuery) match(env *Envelope) bool {
+	if env.BlockNumber < q.FromBlock || (q.ToBlock != 0 && env.BlockNumber > q.ToBlock) {
+		return false
+	}
+	if q.TxHash != "" && !strings.EqualFold(q.TxHash, env.TxHash) {
+		return false
+	}
+	if q.Opcode != "" && q.Opcode != env.Opcode {
+		return false
+	}
+	if q.Address != nil {
+		if env.Payload == nil {
+			return false
+		}
+		trans, ins := env.Payload.TransInfo, env.Payload.InsInfo
+		for _, addr := range []string{trans.To, trans.CreateInfo.ContractAddr, ins.AccountValue.ToAddr, ins.AccountValue.CallContract} {
+			if addr != "" && common.HexToAddress(addr) == *q.Address {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Query returns the retained events matching q, oldest first.
+func (e *RingExporter) Query(q EventQuery) []*Envelope {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	start, count := 0, e.next
+	if e.full {
+		start, count = e.next, len(e.events)
+	}
+	matched := []*Envelope{}
+	for i := 0; i < count; i++ {
+		env := e.events[(start+i)%len(e.events)]
+		if q.match(env) {
+			matched = append(matched, env)
+		}
+	}
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[len(matched)-q.Limit:]
+	}
+	return matched
+}
+
+// RecentEvents queries the first ring exporter of the manager.
+func (plg *PluginManages) RecentEvents(q EventQuery) ([]*Envelope, error) {
+	for _, entry := range plg.exporterList() {
+		if ring, ok := entry.exporter.(*RingExporter); ok {
+			return ring.Query(q), nil
+		}
+	}
+	return nil, ErrNoRingExporter
+}
@@ -0,0 +1,68 @@
+package pluginManage
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhidandeng/collector"
+)
+
+func ringEvent(block uint64, tx, opcode string, to common.Address) *Envelope {
+	data := collector.NewTransCollector()
+	data.To = to.String()
+	return &Envelope{Opcode: opcode, BlockNumber: block, TxHash: tx, Payload: data.SendTransInfo(opcode)}
+}
+
+func TestRingExporterQuery(t *testing.T) {
+	exp, err := NewExporter(ExporterConfig{Name: "recent", Type: "ring", Size: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manage := NewPluginManages()
+	if _, err := manage.RecentEvents(EventQuery{}); err != ErrNoRingExporter {
+		t.Fatalf("query without ring exporter returned %v", err)
+	}
+	manage.AddExporter(exp)
+
+	a, b := common.Address{0xaa}, common.Address{0xbb}
+	events := []*Envelope{
+		ringEvent(1, "0x01", "TXSTART", a), // evicted by the fifth
+		ringEvent(2, "0x02", "TXSTART", a),
+		ringEvent(2, "0x02", "EXTERNALINFOSTART", b),
+		ringEvent(3, "0x03", "TXSTART", b),
+		ringEvent(4, "0x04", "EXTERNALINFOSTART", a),
+	}
+	for _, env := range events {
+		exp.Export(env)
+	}
+	tests := []struct {
+		name  string
+		query EventQuery
+		want  []*Envelope
+	}{
+		{"all", EventQuery{}, events[1:]},
+		{"block range", EventQuery{FromBlock: 2, ToBlock: 3}, events[1:4]},
+		{"open range", EventQuery{FromBlock: 3}, events[3:]},
+		{"tx hash", EventQuery{TxHash: "0x02"}, events[1:3]},
+		{"opcode", EventQuery{Opcode: "EXTERNALINFOSTART"}, []*Envelope{events[2], events[4]}},
+		{"address", EventQuery{Address: &b}, events[2:4]},
+		{"combined", EventQuery{Opcode: "TXSTART", Address: &a}, events[1:2]},
+		{"limit", EventQuery{Limit: 2}, events[3:]},
+		{"evicted", EventQuery{TxHash: "0x01"}, nil},
+	}
+	for _, tt := range tests {
+		have, err := manage.RecentEvents(tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(have) != len(tt.want) {
+			t.Errorf("%s: got %d events, want %d", tt.name, len(have), len(tt.want))
+			continue
+		}
+		for i := range have {
+			if have[i] != tt.want[i] {
+				t.Errorf("%s: event %d is %+v, want %+v", tt.name, i, have[i], tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package pluginManage
+
+//add new file
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SamplingConfig selects the transactions that emit collector data. Rate keeps
+// one transaction in Rate, Percent keeps the given share; when both are unset
+// every transaction is kept. The decision only depends on the transaction
+// hash, so the same transactions are sampled on every node and every replay.
+type SamplingConfig struct {
+	Rate    uint64  `json:"rate"`
+	Percent float64 `json:"percent"`
+}
+
+// Sampled reports whether the transaction with the given hash is in the sample.
+func (s SamplingConfig) Sampled(hash common.Hash) bool {
+	value := binary.BigEndian.Uint64(hash[:8])
+	switch {
+	case s.Rate > 1:
+		return value%s.Rate == 0
+	case s.Percent > 0 && s.Percent < 100:
+		return float64(value%10000) < s.Percent*100
+	}
+	return true
+}
+
+// blockLevelOps are emitted once per block and are never sampled away.
+var blockLevelOps = map[string]bool{
+	"handle_BLOCK_INFO":      true,
+	"handle_BLOCK_END":       true,
+	"handle_FORK_RULES":      true,
+	"handle_BLOCK_GAS_STATS": true,
+	"handle_PLUGIN_SHED":     true,
+	"handle_ACCOUNT_PROOF":   true,
+}
+
+// BeginTx makes the sampling and filtering decisions for the transaction
+// about to execute.
+func (plg *PluginManages) BeginTx(hash common.Hash, from common.Address, to *common.Address) {
+	plg.txHash = hash
+	plg.traceID = TraceID(plg.blockNumber, hash)
+	plg.txSampled = plg.sampling.Sampled(hash)
+	plg.beginTxFilter(from, to)
+	plg.txCalls, plg.repeats = nil, nil
+	plg.deepCalls = nil
+	plg.resetTags()
+}
+
+// SetSampling replaces the transaction sampling settings.
+func (plg *PluginManages) SetSampling(sampling SamplingConfig) {
+	plg.sampling = sampling
+}
+
+// SamplingConfig returns the transaction sampling settings.
+func (plg *PluginManages) SamplingConfig() SamplingConfig {
+	return plg.sampling
+}
+
+// inSample reports whether opcode events of the current transaction are
+// delivered to monitor plugins and exporters. None are while the stall
+// detector sheds them.
+func (plg *PluginManages) inSample(opcode string) bool {
+	return (plg.txSampled && !plg.stall.shed) || blockLevelOps[opcode]
+}
+
+// hasEnforcer reports whether an enforce plugin subscribes to opcode. Those
+// see every transaction regardless of sampling.
+func (plg *PluginManages) hasEnforcer(opcode string) bool {
+	for _, monitor := range plg.plugins[opcode] {
+		if monitor.IsEnforce() && plg.wants(monitor, opcode) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package pluginManage
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhidandeng/collector"
+)
+
+func TestSamplingFraction(t *testing.T) {
+	for _, tt := range []struct {
+		config SamplingConfig
+		want   float64
+	}{
+		{SamplingConfig{}, 1},
+		{SamplingConfig{Rate: 10}, 0.1},
+		{SamplingConfig{Rate: 4}, 0.25},
+		{SamplingConfig{Percent: 5}, 0.05},
+		{SamplingConfig{Percent: 50}, 0.5},
+	} {
+		const txs = 20000
+		var sampled int
+		for i := 0; i < txs; i++ {
+			hash := testTxHash(i)
+			if tt.config.Sampled(hash) {
+				sampled++
+			}
+			if tt.config.Sampled(hash) != tt.config.Sampled(hash) {
+				t.Fatalf("%+v: sampling of %x is not deterministic", tt.config, hash)
+			}
+		}
+		if have := float64(sampled) / txs; math.Abs(have-tt.want) > 0.01 {
+			t.Errorf("%+v: sampled fraction %.3f, want %.3f", tt.config, have, tt.want)
+		}
+	}
+}
+
+func TestSamplingDispatch(t *testing.T) {
+	manage := NewPluginManages()
+	manage.SetSampling(SamplingConfig{Rate: 5})
+
+	var monitored, enforced, blocks int
+	testMonitor(manage, "monitor", "", "TXSTART", func(*collector.AllCollector) (byte, string) {
+		monitored++
+		return 0x00, ""
+	})
+	testMonitor(manage, "enforce", "enforce", "TXSTART", func(*collector.AllCollector) (byte, string) {
+		enforced++
+		return 0x00, ""
+	})
+	testMonitor(manage, "block", "", "handle_BLOCK_INFO", func(*collector.AllCollector) (byte, string) {
+		blocks++
+		return 0x00, ""
+	})
+	const txs = 1000
+	var want int
+	for i := 0; i < txs; i++ {
+		manage.Start()
+		manage.BeginTx(testTxHash(i), common.Address{}, nil)
+		if manage.SamplingConfig().Sampled(testTxHash(i)) {
+			want++
+		}
+		if manage.GetOpcodeRegister("TXSTART") {
+			manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+		}
+		if manage.GetOpcodeRegister("handle_BLOCK_INFO") {
+			manage.SendDataToPlugin("handle_BLOCK_INFO", collector.SendFlag("handle_BLOCK_INFO"))
+		}
+	}
+	if monitored != want {
+		t.Errorf("monitor plugin saw %d transactions, want %d", monitored, want)
+	}
+	if want < txs/5-50 || want > txs/5+50 {
+		t.Errorf("sampled %d of %d transactions, want about %d", want, txs, txs/5)
+	}
+	if enforced != txs {
+		t.Errorf("enforce plugin saw %d transactions, want all %d", enforced, txs)
+	}
+	if blocks != txs {
+		t.Errorf("block level events were sampled: %d of %d delivered", blocks, txs)
+	}
+}
+
+func TestPluginSampling(t *testing.T) {
+	manage := NewPluginManages()
+	global := SamplingConfig{Percent: 50}
+	manage.SetSampling(global)
+
+	seen := make(map[string][]int)
+	var tx int
+	for _, name := range []string{"tracer", "logger"} {
+		name := name
+		monitor := testMonitor(manage, name, "", "TXSTART", func(*collector.AllCollector) (byte, string) {
+			seen[name] = append(seen[name], tx)
+			return 0x00, ""
+		})
+		if name == "tracer" {
+			monitor.SetSampling(SamplingConfig{Percent: 10})
+		}
+	}
+	var wantTracer, wantLogger []int
+	for tx = 0; tx < 2000; tx++ {
+		hash := testTxHash(tx)
+		if global.Sampled(hash) {
+			wantLogger = append(wantLogger, tx)
+			if (SamplingConfig{Percent: 10}).Sampled(hash) {
+				wantTracer = append(wantTracer, tx)
+			}
+		}
+		manage.Start()
+		manage.BeginTx(hash, common.Address{}, nil)
+		if manage.GetOpcodeRegister("TXSTART") {
+			manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+		}
+	}
+	if !reflect.DeepEqual(seen["logger"], wantLogger) {
+		t.Errorf("logger saw %d transactions, want the %d of the global sample", len(seen["logger"]), len(wantLogger))
+	}
+	if !reflect.DeepEqual(seen["tracer"], wantTracer) {
+		t.Errorf("tracer saw %d transactions, want the %d of its sample within the global one", len(seen["tracer"]), len(wantTracer))
+	}
+	// both samples are drawn from the same hash bits, so the 10% of the
+	// tracer lie within the 50% of the global sample
+	if n := len(wantTracer); n < 150 || n > 250 {
+		t.Errorf("tracer sample of %d transactions, want about 200", n)
+	}
+}
@@ -0,0 +1,56 @@
+package pluginManage
+
+//add new file
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/zhidandeng/collector"
+)
+
+// SelectorSet holds the 4 byte function selectors a plugin is interested in.
+type SelectorSet map[[4]byte]bool
+
+// ParseSelectors parses hex encoded selectors such as "0x095ea7b3".
+func ParseSelectors(selectors []string) (SelectorSet, error) {
+	if len(selectors) == 0 {
+		return nil, nil
+	}
+	set := make(SelectorSet, len(selectors))
+	for _, selector := range selectors {
+		raw, err := hexutil.Decode(selector)
+		if err != nil || len(raw) != 4 {
+			return nil, fmt.Errorf("invalid selector %q", selector)
+		}
+		var key [4]byte
+		copy(key[:], raw)
+		set[key] = true
+	}
+	return set, nil
+}
+
+// selectorOps carry the calldata of a call in their CallCollector.
+var selectorOps = map[string]bool{
+	"EXTERNALINFOSTART":  true,
+	"TRANS_CALL":         true,
+	"TRANS_CALLCODE":     true,
+	"TRANS_DELEGATECALL": true,
+	"TRANS_STATICCALL":   true,
+}
+
+// selected reports whether a plugin limited to some selectors takes data.
+// Only call and transaction events are matched against the selectors, the
+// plugin's other events pass.
+func (m *MonitorType) selected(opcode string, data *collector.AllCollector) bool {
+	if m.Selectors == nil || !selectorOps[opcode] {
+		return true
+	}
+	input := data.TransInfo.CallInfo.InputData
+	if len(input) < 4 {
+		return false
+	}
+	var selector [4]byte
+	copy(selector[:], input)
+	return m.Selectors[selector]
+}
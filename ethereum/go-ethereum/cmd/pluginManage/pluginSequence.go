@@ -0,0 +1,43 @@
+package pluginManage
+
+//add new file
+
+import (
+	"strconv"
+
+	"github.com/zhidandeng/collector"
+)
+
+// Event order
+//
+// Within a block the host emits events in execution order: the block level
+// events first, then for every transaction in turn its events in the order
+// the EVM produced them, and the block end events last. Each event that
+// reaches the monitor plugins and exporters is numbered in that order: Seq
+// starts at 1 with the first event of every block and grows by one per
+// event, so a consumer of the whole stream can put events reordered on the
+// wire back in place and spot the ones it missed. Events held back by the
+// address filter are numbered when they are released, which keeps them
+// ahead of the event releasing them. Events left out by sampling or filters
+// are not numbered, and a consumer of a subset of the opcodes sees gaps.
+//
+// The number also makes up the EventKey of the event, together with its
+// trace id or, for a block level event, the block number. The key is the same
+// on every copy of the event a sink retries or a consumer receives again, so
+// deduplicating on it gets each event processed once.
+
+// sequence numbers data as the next event of the block.
+func (plg *PluginManages) sequence(data *collector.AllCollector) {
+	plg.seq++
+	data.Seq = plg.seq
+	data.EventKey = plg.eventKey(data)
+}
+
+// eventKey is the idempotency key of a numbered event.
+func (plg *PluginManages) eventKey(data *collector.AllCollector) string {
+	seq := strconv.FormatUint(data.Seq, 10)
+	if data.TraceID != "" {
+		return data.TraceID + "-" + seq
+	}
+	return "block-" + plg.chainID + "-" + strconv.FormatUint(plg.blockNumber, 10) + "-" + seq
+}
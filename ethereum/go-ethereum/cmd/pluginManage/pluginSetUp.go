@@ -4,94 +4,215 @@ package pluginManage
 
 import (
 	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/json-iterator/go"
 	"github.com/zhidandeng/collector"
 	"os"
 	"path/filepath"
 	"plugin"
-	"github.com/json-iterator/go"
 )
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
 type RegisterInfo struct {
-	PluginName string   `json:"pluginname"`
-	OpCode     map[string]string `json:"option"`
+	PluginName string                        `json:"pluginname"`
+	OpCode     map[string]OpcodeRegistration `json:"option"` //function receiving the events of each opcode, with per opcode options
+	Mode       string                        `json:"mode"`
+	Delivery   string                        `json:"delivery"`  //"block" batches the events of a block, "aggregate" sums them up in handle_BLOCK_END
+	BatchFunc  string                        `json:"batchfunc"` //optional func([]*collector.AllCollector) receiving the batch
+	Async      bool                          `json:"async"`     //run the plugin on its own worker pool
+	DryRun     bool                          `json:"dryrun"`    //report block decisions as handle_WOULD_BLOCK instead of reverting
+	FromBlock  uint64                        `json:"fromblock"` //only receive events of blocks in [fromblock, toblock]
+	ToBlock    uint64                        `json:"toblock"`   //0 leaves the range open
+	Contracts  []common.Address              `json:"contracts"` //only receive the events of these contracts
+	Filter     string                        `json:"filter"`    //optional func(string, common.Address) bool deciding per event, evaluated before the event is built
+	Selectors  []string                      `json:"selectors"` //only receive the calls and transactions with these function selectors
+	Sampling   SamplingConfig                `json:"sampling"`  //only receive this sample of the transactions the global sampling keeps
+	Fields     []string                      `json:"fields"`    //optional collector fields the plugin needs, e.g. "prestate" or "poststate"
 }
 
-func SetUpPlugin(manage *PluginManages){
-	pluginFiles,_ := filepath.Glob("/home/dan/plugin/*.so")
+func SetUpPlugin(manage *PluginManages) {
+	pluginFiles, _ := filepath.Glob("/home/dan/plugin/*.so")
 	log_path := "./plugin_log"
-	_,err := os.Stat(log_path)
-	if err == nil || os.IsNotExist(err){
-		os.Mkdir(log_path,os.ModePerm)
+	_, err := os.Stat(log_path)
+	if err == nil || os.IsNotExist(err) {
+		os.Mkdir(log_path, os.ModePerm)
+	}
+	config, err := LoadPluginConfig(PluginConfigPath)
+	if err != nil {
+		fmt.Println("Can not load plugin config", err, "from path :", PluginConfigPath)
+		panic(err)
+	}
+	if err := manage.ApplyConfig(config); err != nil {
+		fmt.Println("Can not apply plugin config", err, "from path :", PluginConfigPath)
+		panic(err)
+	}
+	if !manage.Enabled() {
+		fmt.Println("plugin subsystem disabled by", PluginConfigPath)
+		return
 	}
 	for _, value := range pluginFiles {
 		fmt.Println("plugin:", value)
-		fmt.Println("path:",manage)
+		fmt.Println("path:", manage)
 		RegisterPlugin(manage, value)
 	}
-	
+	if err := loadArtifacts(manage, config.Artifacts, config.Cache, RegisterPlugin); err != nil {
+		fmt.Println("Can not fetch plugin artifact", err)
+		panic(err)
+	}
+
 }
 
 func RegisterPlugin(manage *PluginManages, path string) bool {
 	plugin, err := plugin.Open(path)
 	if err != nil {
-		fmt.Println("ex:",plugin)
-		fmt.Println("error open plugin: ", err, "from path :", path)
-		os.Exit(-1)
+		log.Error("Can not open plugin", "path", path, "err", err)
+		return false
 	}
-
-	register_method, err := plugin.Lookup("Register")
-	if(register_method != nil){
-		fmt.Println("symbol is not nil")
+	return registerPlugin(manage, plugin, path)
 }
-	if err != nil {
-		fmt.Println("Can not find register function:Register() in plugin", err, "from path :", path)
-		panic(err)
-	}
 
-	register_res, b_err := register_method.(func() []byte)
-	if(register_res == nil){
-		fmt.Println("register_res is nil")
+// pluginSymbols is what the registration looks up in a plugin, an opened
+// *plugin.Plugin outside of tests.
+type pluginSymbols interface {
+	Lookup(symName string) (plugin.Symbol, error)
 }
-	if !b_err{
-		fmt.Println("b_err is flase")
-		panic(b_err)
-	}
-	var register_info RegisterInfo
-	fmt.Println("json.Unmarshal...")
-	fmt.Println("%v",register_info)
-	err = json.Unmarshal(register_res(), &register_info)
 
-	fmt.Println("something is wrong?")
+// registerPlugin registers the handlers of the plugin loaded from path. A
+// handler that can not be used is logged and left out, the others are still
+// registered; a plugin whose manifest, batch function, filter or selectors
+// can not be used is logged and skipped. It returns whether all handlers were
+// registered.
+func registerPlugin(manage *PluginManages, plugin pluginSymbols, path string) bool {
+	register_method, err := plugin.Lookup("Register")
 	if err != nil {
-		fmt.Println("err is :",err)
-		fmt.Println("Can not parse the struct RegisterInfo from the function:Register() in plugin", err, "from path :", path)
-		panic(err)
+		log.Error("Skipping plugin without Register function", "path", path, "err", err)
+		return false
+	}
+	register_res, ok := register_method.(func() []byte)
+	if !ok {
+		log.Error("Skipping plugin with unexpected Register function", "path", path, "have", fmt.Sprintf("%T", register_method), "want", fmt.Sprintf("%T", register_res))
+		return false
+	}
+	register_info, err := ParseRegisterInfo(register_res())
+	if err != nil {
+		log.Error("Skipping plugin with invalid registration", "path", path, "err", err)
+		return false
+	}
+	log.Info("Registering plugin", "plugin", register_info.PluginName, "path", path)
+	var batchfunc BatchFuncType
+	if register_info.BatchFunc != "" {
+		symBatch, err := plugin.Lookup(register_info.BatchFunc)
+		if err != nil {
+			log.Error("Skipping plugin without its batch function", "plugin", register_info.PluginName, "symbol", register_info.BatchFunc, "path", path, "err", err)
+			return false
+		}
+		batch, ok := symBatch.(func([]*collector.AllCollector))
+		if !ok {
+			log.Error("Skipping plugin with unexpected batch function", "plugin", register_info.PluginName, "symbol", register_info.BatchFunc, "path", path, "have", fmt.Sprintf("%T", symBatch), "want", fmt.Sprintf("%T", batch))
+			return false
+		}
+		batchfunc = batch
+	}
+	var eventfilter EventFilterFunc
+	if len(register_info.Contracts) > 0 {
+		eventfilter = ContractFilter(register_info.Contracts)
+	}
+	if register_info.Filter != "" {
+		symFilter, err := plugin.Lookup(register_info.Filter)
+		if err != nil {
+			log.Error("Skipping plugin without its filter function", "plugin", register_info.PluginName, "symbol", register_info.Filter, "path", path, "err", err)
+			return false
+		}
+		filter, ok := symFilter.(func(string, common.Address) bool)
+		if !ok {
+			log.Error("Skipping plugin with unexpected filter function", "plugin", register_info.PluginName, "symbol", register_info.Filter, "path", path, "have", fmt.Sprintf("%T", symFilter), "want", fmt.Sprintf("%T", filter))
+			return false
+		}
+		if contracts := eventfilter; contracts != nil {
+			eventfilter = func(opcode string, contract common.Address) bool {
+				return contracts(opcode, contract) && filter(opcode, contract)
+			}
+		} else {
+			eventfilter = filter
+		}
+	}
+	selectors, err := ParseSelectors(register_info.Selectors)
+	if err != nil {
+		log.Error("Skipping plugin with invalid selectors", "plugin", register_info.PluginName, "path", path, "err", err)
+		return false
 	}
-	fmt.Println("Data log path:./plugin_log/" , register_info.PluginName , "datalog")
+	// Close is optional and lets the plugin release its resources on shutdown
+	if symClose, err := plugin.Lookup("Close"); err == nil {
+		if closefunc, ok := symClose.(func()); ok {
+			manage.SetCloseFunc(register_info.PluginName, closefunc)
+		} else {
+			log.Error("Ignoring plugin Close of unexpected type", "plugin", register_info.PluginName, "path", path, "have", fmt.Sprintf("%T", symClose))
+		}
+	}
+	// SetHistory is optional and hands the plugin read access to past state
+	if symHistory, err := plugin.Lookup("SetHistory"); err == nil {
+		if sethistory, ok := symHistory.(func(*History)); ok {
+			sethistory(manage.History())
+		} else {
+			log.Error("Ignoring plugin SetHistory of unexpected type", "plugin", register_info.PluginName, "path", path, "have", fmt.Sprintf("%T", symHistory))
+		}
+	}
+	// SetTagger is optional and hands the plugin a function tagging the
+	// transaction being executed
+	if symTagger, err := plugin.Lookup("SetTagger"); err == nil {
+		if settagger, ok := symTagger.(func(func(string))); ok {
+			settagger(manage.TagTx)
+		} else {
+			log.Error("Ignoring plugin SetTagger of unexpected type", "plugin", register_info.PluginName, "path", path, "have", fmt.Sprintf("%T", symTagger))
+		}
+	}
+	fields, _ := ParseFieldMask(register_info.Fields) // checked by ParseRegisterInfo
+	manage.RequireFields(fields)
+	registered := true
 	register_map := register_info.OpCode
-	for opcode,sendfunc := range(register_map){
+	for opcode, registration := range register_map {
+		sendfunc := registration.Func
 		var monitor MonitorType
 		monitor.SetPluginName(register_info.PluginName)
+		monitor.SetMode(register_info.Mode)
+		monitor.SetDelivery(register_info.Delivery)
+		monitor.SetBatchFunc(batchfunc)
+		monitor.SetAsync(register_info.Async)
+		monitor.SetDryRun(register_info.DryRun)
+		monitor.SetBlockRange(register_info.FromBlock, register_info.ToBlock)
+		monitor.SetEventFilter(eventfilter)
+		monitor.SetSelectors(selectors)
+		monitor.SetSampling(register_info.Sampling)
 		monitor.SetLogger(register_info.PluginName)
-		// fmt.Println("opcode:",opcode,"sendfunc:",sendfunc)
+		registration.Options.apply(&monitor)
 		symGreeter, err := plugin.Lookup(sendfunc)
 		if err != nil {
-			fmt.Println("Can not find function",sendfunc," in plugin", err, "from path :", path)
-			panic(err)
+			log.Error("Skipping plugin handler", "plugin", register_info.PluginName, "opcode", opcode, "func", sendfunc, "path", path, "err", err)
+			registered = false
+			continue
 		}
-		rcvefunc, ok := symGreeter.(func(*collector.AllCollector) (byte,string))
-		if !ok {
-			fmt.Println("unexpected type from module symbol")
-			os.Exit(0)
+		rcvefunc, err := sendFuncOf(symGreeter)
+		if err != nil {
+			log.Error("Skipping plugin handler", "plugin", register_info.PluginName, "opcode", opcode, "func", sendfunc, "path", path, "err", err)
+			registered = false
+			continue
 		}
-		// fmt.Println("rcve",rcvefunc)
 		monitor.SetSendFunc(rcvefunc)
 		monitor.SetOpcode(opcode)
 		monitor.SetIAL_Optinon(opcode)
-		manage.RegisterOpcode(opcode,&monitor)
+		manage.RegisterOpcode(opcode, &monitor)
+	}
+	return registered
+}
+
+// sendFuncOf returns the handler symbol as a send function, or an error
+// naming the signature it has instead.
+func sendFuncOf(sym plugin.Symbol) (SendFuncType, error) {
+	send, ok := sym.(func(*collector.AllCollector) (byte, string))
+	if !ok {
+		return nil, fmt.Errorf("handler has type %T, want %T", sym, send)
 	}
-	fmt.Println("The end")
-	return true
+	return send, nil
 }
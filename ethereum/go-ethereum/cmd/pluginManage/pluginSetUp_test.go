@@ -0,0 +1,92 @@
+package pluginManage
+
+import (
+	"fmt"
+	"plugin"
+	"strings"
+	"testing"
+
+	"github.com/zhidandeng/collector"
+)
+
+// testSymbols stands in for an opened plugin.
+type testSymbols map[string]plugin.Symbol
+
+func (s testSymbols) Lookup(name string) (plugin.Symbol, error) {
+	if sym, ok := s[name]; ok {
+		return sym, nil
+	}
+	return nil, fmt.Errorf("symbol %s not found", name)
+}
+
+func TestRegisterPluginWrongHandlerType(t *testing.T) {
+	manifest := `{"pluginname":"P9","option":{"TXSTART":"Handle_TXSTART","TXEND":"Handle_TXEND"}}`
+	symbols := testSymbols{
+		"Register":       func() []byte { return []byte(manifest) },
+		"Handle_TXSTART": func(*collector.AllCollector) (byte, string) { return 0x00, "" },
+		"Handle_TXEND":   func(*collector.AllCollector) bool { return true },
+	}
+	manage := NewPluginManages()
+	if registerPlugin(manage, symbols, "P9.so") {
+		t.Error("registration with a wrongly typed handler reported complete")
+	}
+	if !manage.GetOpcodeRegister("TXSTART") {
+		t.Error("well typed handler not registered")
+	}
+	if manage.GetOpcodeRegister("TXEND") {
+		t.Error("wrongly typed handler registered")
+	}
+
+	_, err := sendFuncOf(symbols["Handle_TXEND"])
+	if err == nil {
+		t.Fatal("wrongly typed handler accepted")
+	}
+	for _, want := range []string{"func(*collector.AllCollector) bool", "func(*collector.AllCollector) (uint8, string)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not name %s", err, want)
+		}
+	}
+}
+
+// Tests that a plugin whose manifest names missing or wrongly typed symbols is
+// skipped instead of bringing the node down.
+func TestRegisterPluginUnusableSymbols(t *testing.T) {
+	handler := func(*collector.AllCollector) (byte, string) { return 0x00, "" }
+	tests := []struct {
+		name     string
+		manifest string
+		symbols  testSymbols
+	}{
+		{"no register", "", testSymbols{}},
+		{"register type", "", testSymbols{"Register": func() string { return "" }}},
+		{"missing batch", `{"pluginname":"P","batchfunc":"Batch","option":{"TXSTART":"Handle"}}`, testSymbols{"Handle": handler}},
+		{"batch type", `{"pluginname":"P","batchfunc":"Batch","option":{"TXSTART":"Handle"}}`, testSymbols{"Handle": handler, "Batch": func() {}}},
+		{"missing filter", `{"pluginname":"P","filter":"Filter","option":{"TXSTART":"Handle"}}`, testSymbols{"Handle": handler}},
+		{"filter type", `{"pluginname":"P","filter":"Filter","option":{"TXSTART":"Handle"}}`, testSymbols{"Handle": handler, "Filter": func(string) bool { return true }}},
+		{"selectors", `{"pluginname":"P","selectors":["0xzz"],"option":{"TXSTART":"Handle"}}`, testSymbols{"Handle": handler}},
+	}
+	for _, tt := range tests {
+		if tt.manifest != "" {
+			manifest := tt.manifest
+			tt.symbols["Register"] = func() []byte { return []byte(manifest) }
+		}
+		manage := NewPluginManages()
+		if registerPlugin(manage, tt.symbols, "P.so") {
+			t.Errorf("%s: registration reported complete", tt.name)
+		}
+		if manage.GetOpcodeRegister("TXSTART") {
+			t.Errorf("%s: plugin registered", tt.name)
+		}
+	}
+
+	// A missing handler only skips that handler.
+	manifest := `{"pluginname":"P","option":{"TXSTART":"Handle","TXEND":"Missing"}}`
+	manage := NewPluginManages()
+	symbols := testSymbols{"Register": func() []byte { return []byte(manifest) }, "Handle": handler}
+	if registerPlugin(manage, symbols, "P.so") {
+		t.Error("registration with a missing handler reported complete")
+	}
+	if !manage.GetOpcodeRegister("TXSTART") || manage.GetOpcodeRegister("TXEND") {
+		t.Error("missing handler not skipped on its own")
+	}
+}
@@ -0,0 +1,62 @@
+package pluginManage
+
+//add new file
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SetCloseFunc registers the optional Close() of a plugin, called once on
+// Shutdown.
+func (plg *PluginManages) SetCloseFunc(pluginName string, close func()) {
+	if plg.closers == nil {
+		plg.closers = make(map[string]func())
+	}
+	plg.closers[pluginName] = close
+}
+
+// Shutdown drains the plugin pipeline before the node exits: the events of
+// an unfinished block are dropped, the queues of the async plugins are worked
+// off, every exporter is flushed and closed and every plugin gets its Close()
+// call. It gives up when ctx expires, leaving
+// the remaining work to finish in the background.
+func (plg *PluginManages) Shutdown(ctx context.Context) error {
+	if plg == nil {
+		return nil
+	}
+	plg.DiscardBlock()
+
+	plg.exportersLock.Lock()
+	exporters := plg.exporters
+	plg.exporters = nil
+	plg.exportersLock.Unlock()
+	closers := plg.closers
+	plg.closers = nil
+
+	done := make(chan error, 1)
+	go func() {
+		plg.closePools()
+		var failed []string
+		for _, entry := range exporters {
+			if err := entry.exporter.Close(); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", entry.exporter.Name(), err))
+			}
+		}
+		for _, close := range closers {
+			close()
+		}
+		if len(failed) > 0 {
+			done <- fmt.Errorf("closing plugin exporters failed: %s", strings.Join(failed, "; "))
+			return
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
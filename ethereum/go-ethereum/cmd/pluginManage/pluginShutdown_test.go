@@ -0,0 +1,71 @@
+package pluginManage
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zhidandeng/collector"
+)
+
+// blockingExporter never returns from Close until release is closed.
+type blockingExporter struct{ release chan struct{} }
+
+func (e *blockingExporter) Name() string               { return "blocking" }
+func (e *blockingExporter) Export(env *Envelope) error { return nil }
+func (e *blockingExporter) Close() error               { <-e.release; return nil }
+
+func TestShutdownFlushes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	// An fsync interval far beyond the test keeps every line in the buffer.
+	exp, err := NewFileExporter(ExporterConfig{Name: "file", Path: path, FsyncInterval: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manage := NewPluginManages()
+	manage.AddExporter(exp)
+	var closed bool
+	manage.SetCloseFunc("plugin", func() { closed = true })
+
+	const events = 100
+	for i := 0; i < events; i++ {
+		manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+	}
+	if err := manage.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if !closed {
+		t.Error("plugin Close was not called")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var lines int
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		lines++
+	}
+	if lines != events {
+		t.Errorf("%d of %d events flushed", lines, events)
+	}
+	// Nothing is exported after shutdown.
+	manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	exp := &blockingExporter{release: make(chan struct{})}
+	defer close(exp.release)
+
+	manage := NewPluginManages()
+	manage.AddExporter(exp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := manage.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("shutdown returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,37 @@
+package pluginManage
+
+//add new file
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/zhidandeng/collector"
+)
+
+// slowThreshold returns the soft latency limit of plugin calls, 0 if unset.
+func (plg *PluginManages) slowThreshold() time.Duration {
+	plg.breakerLock.Lock()
+	defer plg.breakerLock.Unlock()
+	return plg.breakerSettings.slow
+}
+
+// pluginSlow reports a plugin call that completed but took longer than the
+// soft threshold, ahead of it running into the timeout. The report of a slow
+// handle_PLUGIN_SLOW handler is only logged, it would otherwise feed itself.
+func (plg *PluginManages) pluginSlow(monitor *MonitorType, opcode string, elapsed, threshold time.Duration) {
+	log.Warn("Plugin call slow", "plugin", monitor.GetPluginName(), "opcode", opcode, "elapsed", elapsed, "threshold", threshold)
+	if opcode == "handle_PLUGIN_SLOW" || !plg.GetOpcodeRegister("handle_PLUGIN_SLOW") {
+		return
+	}
+	info := collector.NewTransCollector()
+	info.Op = "handle_PLUGIN_SLOW"
+	info.TxHash = plg.tx.TxHash
+	info.SlowPluginInfo = collector.SlowPluginCollector{
+		Plugin:    monitor.GetPluginName(),
+		Opcode:    opcode,
+		Duration:  elapsed.Nanoseconds(),
+		Threshold: threshold.Nanoseconds(),
+	}
+	plg.SendDataToPlugin(info.Op, info.SendTransInfo(info.Op))
+}
@@ -0,0 +1,210 @@
+package pluginManage
+
+//add new file
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/zhidandeng/collector"
+)
+
+const (
+	defaultSocketTimeout   = time.Second
+	defaultSocketReconnect = time.Second
+)
+
+// SocketConfig describes a plugin living in a sidecar process reached over a
+// Unix domain socket. Every event of the listed opcodes is written to the
+// socket as a collector frame (see collector.WriteFrame) whose format byte
+// tells the sidecar how the AllCollector is encoded. An enforce sidecar
+// answers each frame with a collector decision frame holding the warning
+// level of the in-process plugins (0x00 allow, 0x01 warn, 0x02 block) and the
+// reason.
+type SocketConfig struct {
+	Name              string           `json:"name"`
+	Path              string           `json:"path"`
+	Mode              string           `json:"mode"`   // "monitor" (default) or "enforce"
+	DryRun            bool             `json:"dryrun"` // report block decisions instead of reverting
+	FromBlock         uint64           `json:"fromblock"`
+	ToBlock           uint64           `json:"toblock"`   // 0 leaves the block range open
+	Contracts         []common.Address `json:"contracts"` // only send the events of these contracts
+	Sampling          SamplingConfig   `json:"sampling"`  // only send this sample of the transactions
+	Opcodes           []string         `json:"opcodes"`
+	Format            string           `json:"format"`            // "json" (default) or "cbor"
+	Fields            []string         `json:"fields"`            // optional collector fields the sidecar needs
+	Timeout           string           `json:"timeout"`           // bound on writing an event and waiting for a decision
+	ReconnectInterval string           `json:"reconnectinterval"` // pause between connection attempts
+}
+
+// SocketTransport carries the events of one sidecar plugin. It redials the
+// socket after a lost connection. Until that succeeds a monitor sidecar
+// misses the events, an enforce sidecar fails closed and blocks them.
+type SocketTransport struct {
+	name      string
+	path      string
+	enforce   bool
+	format    string
+	timeout   time.Duration
+	reconnect time.Duration
+
+	lock     sync.Mutex
+	conn     net.Conn
+	in       *bufio.Reader
+	lastDial time.Time
+}
+
+// NewSocketTransport creates the transport of config. The sidecar does not
+// have to be listening yet.
+func NewSocketTransport(config SocketConfig) (*SocketTransport, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("socket plugin %q has no path", config.Name)
+	}
+	if config.Mode != "" && config.Mode != "monitor" && config.Mode != "enforce" {
+		return nil, fmt.Errorf("socket plugin %q has unknown mode %q", config.Name, config.Mode)
+	}
+	if !collector.ValidFormat(config.Format) {
+		return nil, fmt.Errorf("socket plugin %q has unknown format %q", config.Name, config.Format)
+	}
+	t := &SocketTransport{
+		name:      config.Name,
+		path:      config.Path,
+		enforce:   config.Mode == "enforce",
+		format:    config.Format,
+		timeout:   defaultSocketTimeout,
+		reconnect: defaultSocketReconnect,
+	}
+	var err error
+	if config.Timeout != "" {
+		if t.timeout, err = time.ParseDuration(config.Timeout); err != nil || t.timeout <= 0 {
+			return nil, fmt.Errorf("socket plugin %q has invalid timeout %q", config.Name, config.Timeout)
+		}
+	}
+	if config.ReconnectInterval != "" {
+		if t.reconnect, err = time.ParseDuration(config.ReconnectInterval); err != nil || t.reconnect <= 0 {
+			return nil, fmt.Errorf("socket plugin %q has invalid reconnect interval %q", config.Name, config.ReconnectInterval)
+		}
+	}
+	return t, nil
+}
+
+// Send hands data to the sidecar and, in enforce mode, returns its decision.
+// It has the signature of a plugin send function.
+func (t *SocketTransport) Send(data *collector.AllCollector) (byte, string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	decision, reason, err := t.exchange(data)
+	if err == nil {
+		return decision, reason
+	}
+	if t.conn != nil {
+		log.Warn("Plugin socket transport lost its sidecar", "plugin", t.name, "path", t.path, "err", err)
+		t.conn.Close()
+		t.conn, t.in = nil, nil
+	}
+	if t.enforce {
+		return 0x02, "socket plugin " + t.name + " unavailable: " + err.Error()
+	}
+	return 0x00, ""
+}
+
+func (t *SocketTransport) exchange(data *collector.AllCollector) (byte, string, error) {
+	if err := t.connect(); err != nil {
+		return 0, "", err
+	}
+	frame, err := collector.EncodeFrame(t.format, data)
+	if err != nil {
+		return 0, "", err
+	}
+	t.conn.SetDeadline(time.Now().Add(t.timeout))
+	if _, err := t.conn.Write(frame); err != nil {
+		return 0, "", err
+	}
+	if !t.enforce {
+		return 0x00, "", nil
+	}
+	answer, err := collector.ReadFrame(t.in)
+	if err != nil {
+		return 0, "", err
+	}
+	return answer.Decision()
+}
+
+func (t *SocketTransport) connect() error {
+	if t.conn != nil {
+		return nil
+	}
+	if wait := t.reconnect - time.Since(t.lastDial); wait > 0 {
+		return fmt.Errorf("not connected, next attempt in %v", wait)
+	}
+	t.lastDial = time.Now()
+	conn, err := net.DialTimeout("unix", t.path, t.timeout)
+	if err != nil {
+		return err
+	}
+	t.conn, t.in = conn, bufio.NewReader(conn)
+	return nil
+}
+
+// SetFormat changes the encoding of the following frames. Every frame names
+// its format, the connection is kept.
+func (t *SocketTransport) SetFormat(format string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.format = format
+}
+
+// Close disconnects from the sidecar.
+func (t *SocketTransport) Close() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn, t.in = nil, nil
+	}
+}
+
+// AddSocketPlugin registers the sidecar plugin of config for its opcodes.
+func (plg *PluginManages) AddSocketPlugin(config SocketConfig) error {
+	if len(config.Opcodes) == 0 {
+		return fmt.Errorf("socket plugin %q subscribes to no opcode", config.Name)
+	}
+	fields, err := ParseFieldMask(config.Fields)
+	if err != nil {
+		return fmt.Errorf("socket plugin %q: %v", config.Name, err)
+	}
+	transport, err := NewSocketTransport(config)
+	if err != nil {
+		return err
+	}
+	plg.RequireFields(fields)
+	var filter EventFilterFunc
+	if len(config.Contracts) > 0 {
+		filter = ContractFilter(config.Contracts)
+	}
+	for _, opcode := range config.Opcodes {
+		monitor := new(MonitorType)
+		monitor.SetPluginName(config.Name)
+		monitor.SetMode(config.Mode)
+		monitor.SetDryRun(config.DryRun)
+		monitor.SetBlockRange(config.FromBlock, config.ToBlock)
+		monitor.SetEventFilter(filter)
+		monitor.SetSampling(config.Sampling)
+		monitor.SetLogger(config.Name)
+		monitor.SetSendFunc(transport.Send)
+		monitor.SetOpcode(opcode)
+		monitor.SetIAL_Optinon(opcode)
+		plg.RegisterOpcode(opcode, monitor)
+	}
+	plg.SetCloseFunc(config.Name, transport.Close)
+	plg.sockets = append(plg.sockets, transport)
+	return nil
+}
@@ -0,0 +1,251 @@
+package pluginManage
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhidandeng/collector"
+	"github.com/zhidandeng/collector/client"
+)
+
+// sidecar accepts transports on a Unix socket, records the opcodes it is
+// sent and, when enforcing, blocks TRANS_CALL and allows everything else.
+type sidecar struct {
+	listener net.Listener
+	enforce  bool
+
+	lock     sync.Mutex
+	conns    []net.Conn
+	received []string
+}
+
+func newSidecar(t *testing.T, path string, enforce bool) *sidecar {
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &sidecar{listener: listener, enforce: enforce}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.lock.Lock()
+			s.conns = append(s.conns, conn)
+			s.lock.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { s.stop() })
+	return s
+}
+
+func (s *sidecar) serve(conn net.Conn) {
+	in := bufio.NewReader(conn)
+	for {
+		frame, err := collector.ReadFrame(in)
+		if err != nil {
+			return
+		}
+		data, err := frame.Decode()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		s.received = append(s.received, data.Option)
+		s.lock.Unlock()
+		if !s.enforce {
+			continue
+		}
+		level, reason := byte(0x00), ""
+		if data.Option == "TRANS_CALL" {
+			level, reason = 0x02, "blocked call"
+		}
+		if collector.WriteDecision(conn, level, reason) != nil {
+			return
+		}
+	}
+}
+
+// stop closes the listener and every connection, like a crashed sidecar.
+func (s *sidecar) stop() {
+	s.listener.Close()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *sidecar) count() (int, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.conns), len(s.received)
+}
+
+func TestSocketTransportEnforce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enforce.sock")
+	side := newSidecar(t, path, true)
+	transport, err := NewSocketTransport(SocketConfig{Name: "guard", Path: path, Mode: "enforce"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	if level, _ := transport.Send(collector.SendFlag("TXSTART")); level != 0x00 {
+		t.Errorf("TXSTART got level %#x, want allow", level)
+	}
+	if level, reason := transport.Send(collector.SendFlag("TRANS_CALL")); level != 0x02 || reason != "blocked call" {
+		t.Errorf("TRANS_CALL got %#x %q, want the sidecar's block", level, reason)
+	}
+	// Without its sidecar an enforce plugin blocks everything.
+	side.stop()
+	if level, _ := transport.Send(collector.SendFlag("TXSTART")); level != 0x02 {
+		t.Errorf("TXSTART without sidecar got level %#x, want block", level)
+	}
+}
+
+func TestSocketTransportMonitorReconnects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.sock")
+	side := newSidecar(t, path, false)
+	transport, err := NewSocketTransport(SocketConfig{Name: "watch", Path: path, ReconnectInterval: "1ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	if level, _ := transport.Send(collector.SendFlag("TXSTART")); level != 0x00 {
+		t.Fatalf("monitor got level %#x", level)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if _, received := side.count(); received == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sidecar did not receive the first event")
+		}
+	}
+	// Drop the connection; sending goes on without blocking and lands on a
+	// new connection once the transport noticed.
+	side.lock.Lock()
+	side.conns[0].Close()
+	side.lock.Unlock()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if level, _ := transport.Send(collector.SendFlag("TXEND")); level != 0x00 {
+			t.Fatalf("monitor got level %#x while reconnecting", level)
+		}
+		if conns, received := side.count(); conns == 2 && received >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			conns, received := side.count()
+			t.Fatalf("sidecar has %d connections and %d events after the drop", conns, received)
+		}
+	}
+}
+
+func TestSocketTransportCBOR(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cbor.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	frames := make(chan *collector.Frame, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		frame, err := collector.ReadFrame(bufio.NewReader(conn))
+		if err != nil {
+			t.Errorf("unreadable frame: %v", err)
+		}
+		frames <- frame
+	}()
+
+	transport, err := NewSocketTransport(SocketConfig{Name: "cbor", Path: path, Format: collector.FormatCBOR})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+	transport.Send(collector.SendFlag("TXSTART"))
+
+	frame := <-frames
+	if frame == nil {
+		t.Fatal("sidecar received no frame")
+	}
+	if frame.Format != collector.FormatCBOR || frame.Category != collector.CategoryFlag {
+		t.Errorf("frame of format %q category %d, want cbor flag", frame.Format, frame.Category)
+	}
+	if data, err := frame.Decode(); err != nil || data.Option != "TXSTART" {
+		t.Errorf("event frame decoded to %v (%v)", data, err)
+	}
+	if _, err := NewSocketTransport(SocketConfig{Name: "bad", Path: path, Format: "xml"}); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestSocketClientLibrary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.sock")
+	consumer, err := client.Listen(client.Config{
+		Path:    path,
+		Opcodes: []string{"TXSTART", "TRANS_CALL"},
+		Enforce: true,
+		Decide: func(data *collector.AllCollector) (byte, string) {
+			if data.Option == "TRANS_CALL" {
+				return 0x02, "no calls"
+			}
+			return 0x00, ""
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	transport, err := NewSocketTransport(SocketConfig{Name: "lib", Path: path, Mode: "enforce", ReconnectInterval: "1ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	next := func() string {
+		select {
+		case data := <-consumer.Events():
+			return data.Option
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event from the client")
+			return ""
+		}
+	}
+	if level, _ := transport.Send(collector.SendFlag("TXSTART")); level != 0x00 {
+		t.Errorf("TXSTART got level %#x, want allow", level)
+	}
+	if level, _ := transport.Send(collector.SendFlag("TXEND")); level != 0x00 {
+		t.Errorf("filtered TXEND got level %#x, want allow", level)
+	}
+	if level, reason := transport.Send(collector.SendFlag("TRANS_CALL")); level != 0x02 || reason != "no calls" {
+		t.Errorf("TRANS_CALL got %#x %q, want the client's block", level, reason)
+	}
+	if got := []string{next(), next()}; got[0] != "TXSTART" || got[1] != "TRANS_CALL" {
+		t.Fatalf("client delivered %q, want TXSTART and TRANS_CALL", got)
+	}
+	// Switching the format keeps the connection, the client decodes every
+	// frame by its format byte.
+	transport.SetFormat(collector.FormatCBOR)
+	if level, _ := transport.Send(collector.SendFlag("TXSTART")); level != 0x00 {
+		t.Errorf("TXSTART over cbor got level %#x, want allow", level)
+	}
+	if option := next(); option != "TXSTART" {
+		t.Errorf("client delivered %q over cbor, want TXSTART", option)
+	}
+	if err := consumer.Err(); err != nil {
+		t.Errorf("client failed decoding the stream: %v", err)
+	}
+}
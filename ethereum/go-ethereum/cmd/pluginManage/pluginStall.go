@@ -0,0 +1,126 @@
+package pluginManage
+
+//add new file
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/zhidandeng/collector"
+)
+
+const (
+	defaultStallBlockTime = 12 * time.Second
+	defaultStallWindow    = 5
+)
+
+// stallNow is the clock the dispatch time of a block is measured with.
+var stallNow = time.Now
+
+// StallConfig sheds the plugin load when it makes the node fall behind. A
+// block whose events took longer than Fraction of BlockTime to dispatch is
+// over budget. After Window blocks over budget in a row the monitor plugins
+// and exporters stop receiving transaction events, as if every transaction
+// was sampled away, until Window blocks in a row are within budget again.
+// Enforce plugins and block level events are never shed. Both changes are
+// logged and emitted as handle_PLUGIN_SHED.
+type StallConfig struct {
+	Fraction  float64 `json:"fraction"`  // 0 turns the detector off
+	BlockTime string  `json:"blocktime"` // 12s by default
+	Window    int     `json:"window"`    // 5 blocks by default
+}
+
+// stallDetector is the state of the detector. It is only used by the
+// goroutine processing blocks.
+type stallDetector struct {
+	budget time.Duration // dispatch time a block may take, 0 when off
+	window int
+
+	timing   bool          // a dispatch is being timed, nested ones are part of it
+	dispatch time.Duration // dispatch time of the current block
+	streak   int           // blocks in a row on the other side of the budget
+	shed     bool          // monitor plugins and exporters are shed
+}
+
+// SetStall configures the stall detector. It resets its state and re-enables
+// plugins shed before.
+func (plg *PluginManages) SetStall(config StallConfig) error {
+	if config.Fraction < 0 {
+		return fmt.Errorf("invalid stall fraction %v", config.Fraction)
+	}
+	blockTime := defaultStallBlockTime
+	if config.BlockTime != "" {
+		duration, err := time.ParseDuration(config.BlockTime)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid stall blocktime %q", config.BlockTime)
+		}
+		blockTime = duration
+	}
+	window := config.Window
+	if window <= 0 {
+		window = defaultStallWindow
+	}
+	plg.stall = stallDetector{
+		budget: time.Duration(config.Fraction * float64(blockTime)),
+		window: window,
+	}
+	return nil
+}
+
+// Shedding reports whether the stall detector shed the monitor plugins.
+func (plg *PluginManages) Shedding() bool {
+	return plg.stall.shed
+}
+
+// timeDispatch starts timing a dispatch and returns the function stopping it,
+// nil when the detector is off or an enclosing dispatch is timed already.
+func (plg *PluginManages) timeDispatch() func() {
+	if plg.stall.budget <= 0 || plg.stall.timing {
+		return nil
+	}
+	plg.stall.timing = true
+	start := stallNow()
+	return func() {
+		plg.stall.dispatch += stallNow().Sub(start)
+		plg.stall.timing = false
+	}
+}
+
+// EndBlock accounts the dispatch time of the block Process finished against
+// the stall budget, shedding or restoring the monitor plugins once a whole
+// window of blocks was on the other side of it.
+func (plg *PluginManages) EndBlock() {
+	if plg.stall.budget <= 0 {
+		return
+	}
+	dispatch := plg.stall.dispatch
+	plg.stall.dispatch = 0
+	if over := dispatch > plg.stall.budget; over != plg.stall.shed {
+		plg.stall.streak++
+	} else {
+		plg.stall.streak = 0
+	}
+	if plg.stall.streak < plg.stall.window {
+		return
+	}
+	plg.stall.shed = !plg.stall.shed
+	plg.stall.streak = 0
+	if plg.stall.shed {
+		log.Warn("Plugin dispatch falling behind, shedding monitor plugins", "number", plg.blockNumber, "dispatch", dispatch, "budget", plg.stall.budget, "blocks", plg.stall.window)
+	} else {
+		log.Info("Plugin dispatch within budget again, restoring monitor plugins", "number", plg.blockNumber, "dispatch", dispatch, "budget", plg.stall.budget)
+	}
+	if plg.GetOpcodeRegister("handle_PLUGIN_SHED") {
+		info := collector.NewBlockCollector()
+		info.Op = "Block" + fmt.Sprintf("%v", plg.blockNumber)
+		info.Number = fmt.Sprintf("%v", plg.blockNumber)
+		info.Shed = collector.ShedCollector{
+			Shed:     plg.stall.shed,
+			Dispatch: dispatch.Nanoseconds(),
+			Budget:   plg.stall.budget.Nanoseconds(),
+			Blocks:   plg.stall.window,
+		}
+		plg.SendDataToPlugin("handle_PLUGIN_SHED", info.SendBlockInfo("handle_PLUGIN_SHED"))
+	}
+}
@@ -0,0 +1,86 @@
+package pluginManage
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhidandeng/collector"
+)
+
+func TestStallSheddingMonitors(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	stallNow = func() time.Time { return now }
+	defer func() { stallNow = time.Now }()
+
+	manage := NewPluginManages()
+	// 10% of a 1s block: each block may spend 100ms in dispatch.
+	if err := manage.SetStall(StallConfig{Fraction: 0.1, BlockTime: "1s", Window: 3}); err != nil {
+		t.Fatal(err)
+	}
+	var monitored, enforced int
+	lag := 200 * time.Millisecond
+	testMonitor(manage, "monitor", "", "TXSTART", func(*collector.AllCollector) (byte, string) {
+		monitored++
+		now = now.Add(lag)
+		return 0x00, ""
+	})
+	testMonitor(manage, "enforce", "enforce", "TXSTART", func(*collector.AllCollector) (byte, string) {
+		enforced++
+		return 0x00, ""
+	})
+	var alerts []collector.ShedCollector
+	testMonitor(manage, "alerts", "", "handle_PLUGIN_SHED", func(data *collector.AllCollector) (byte, string) {
+		alerts = append(alerts, data.BlockInfo.Shed)
+		return 0x00, ""
+	})
+	block := func(number int) {
+		manage.SetBlockContext(big.NewInt(1), big.NewInt(int64(number)))
+		manage.Start()
+		manage.BeginTx(testTxHash(number), common.Address{}, nil)
+		if manage.GetOpcodeRegister("TXSTART") {
+			manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+		}
+		manage.FlushBlock()
+		manage.EndBlock()
+	}
+
+	// Two slow blocks are not a sustained lag yet.
+	for number := 1; number <= 2; number++ {
+		block(number)
+	}
+	if manage.Shedding() {
+		t.Fatal("shedding after two slow blocks")
+	}
+	block(3)
+	if !manage.Shedding() {
+		t.Fatal("not shedding after three slow blocks")
+	}
+	if len(alerts) != 1 || !alerts[0].Shed || alerts[0].Dispatch != lag.Nanoseconds() || alerts[0].Budget != (100*time.Millisecond).Nanoseconds() {
+		t.Fatalf("shed alerts %+v", alerts)
+	}
+	// Shed, the monitor plugin misses the transactions, the enforce plugin
+	// keeps seeing them and the dispatch is back within budget.
+	for number := 4; number <= 6; number++ {
+		block(number)
+	}
+	if monitored != 3 || enforced != 6 {
+		t.Errorf("monitor saw %d transactions, enforce %d, want 3 and 6", monitored, enforced)
+	}
+	if manage.Shedding() {
+		t.Fatal("still shedding after three blocks within budget")
+	}
+	if len(alerts) != 2 || alerts[1].Shed {
+		t.Fatalf("restore alerts %+v", alerts)
+	}
+	lag = 0
+	block(7)
+	if monitored != 4 || enforced != 7 {
+		t.Errorf("after restoring monitor saw %d transactions, enforce %d, want 4 and 7", monitored, enforced)
+	}
+
+	if err := manage.SetStall(StallConfig{Fraction: 0.1, BlockTime: "soon"}); err == nil {
+		t.Error("invalid block time accepted")
+	}
+}
@@ -0,0 +1,51 @@
+package pluginManage
+
+//add new file
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// StdoutExporter pretty-prints every event it gets as indented JSON, one
+// envelope after the other, for watching what the host emits while writing a
+// plugin. Marshalling and printing each event is slow: it is meant for
+// development and not for a node following a live chain.
+type StdoutExporter struct {
+	name string
+
+	lock sync.Mutex
+	out  io.Writer
+}
+
+// NewStdoutExporter creates a debug exporter writing to out, os.Stdout when
+// nil.
+func NewStdoutExporter(config ExporterConfig, out io.Writer) *StdoutExporter {
+	if out == nil {
+		out = os.Stdout
+	}
+	name := config.Name
+	if name == "" {
+		name = "stdout"
+	}
+	log.Warn("Plugin debug exporter prints every event, for development only", "exporter", name, "opcodes", config.Opcodes)
+	return &StdoutExporter{name: name, out: out}
+}
+
+func (e *StdoutExporter) Name() string { return e.name }
+
+func (e *StdoutExporter) Export(env *Envelope) error {
+	body, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	_, err = e.out.Write(append(body, '\n'))
+	return err
+}
+
+func (e *StdoutExporter) Close() error { return nil }
@@ -0,0 +1,179 @@
+package pluginManage
+
+//add new file
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/zhidandeng/collector"
+)
+
+// subscriptionBuffer is the number of events a subscriber may fall behind
+// before it is dropped.
+const subscriptionBuffer = 256
+
+// ErrSubscriberTooSlow is the error of a subscription dropped because its
+// consumer fell a whole buffer behind.
+var ErrSubscriberTooSlow = errors.New("subscriber fell behind, buffer full")
+
+// subscription is the exporter behind a channel handed out by Subscribe.
+type subscription struct {
+	ch      chan *collector.AllCollector
+	dropped metrics.Counter
+
+	lock   sync.Mutex // held across sends, a dispatch may still hold an unsubscribed one
+	closed bool
+	err    error // why the channel was closed before Unsubscribe
+}
+
+func (s *subscription) Name() string { return "subscription" }
+
+// Export queues the payload without ever waiting, so one stalled consumer
+// can not hold up the block or the other consumers. A subscriber whose buffer
+// is full misses the event and is dropped: an incomplete stream would look
+// like a complete one, a closed channel does not.
+func (s *subscription) Export(env *Envelope) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	select {
+	case s.ch <- env.Payload:
+		return nil
+	default:
+	}
+	s.err = ErrSubscriberTooSlow
+	s.dropped.Inc(1)
+	s.close()
+	return ErrSubscriberTooSlow
+}
+
+func (s *subscription) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.close()
+	return nil
+}
+
+func (s *subscription) close() {
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// Err returns why the subscription was closed by the manager, nil while it is
+// open.
+func (s *subscription) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+// Subscribe returns a channel delivering the events of the given opcodes, or
+// of every opcode when none is given, emitted by the following Process calls.
+// It lets programs embedding the processor consume events without building a
+// plugin. Every subscriber has a buffer of its own and the processing never
+// waits for one: a consumer falling a full buffer behind has its channel
+// closed, SubscriptionErr then reports ErrSubscriberTooSlow and the drop is
+// counted in plugin/subscriptions/dropped. Subscribing while a block is
+// being processed delivers the events emitted from then on.
+func (plg *PluginManages) Subscribe(opcodes ...string) <-chan *collector.AllCollector {
+	sub, canonical := plg.newSubscription(opcodes)
+	plg.AddExporter(sub, canonical...)
+	return sub.ch
+}
+
+// newSubscription creates an unregistered subscription and returns it with
+// the current names of opcodes.
+func (plg *PluginManages) newSubscription(opcodes []string) (*subscription, []string) {
+	sub := &subscription{
+		ch:      make(chan *collector.AllCollector, subscriptionBuffer),
+		dropped: metrics.GetOrRegisterCounter("plugin/subscriptions/dropped", nil),
+	}
+	canonical := make([]string, len(opcodes))
+	for i, opcode := range opcodes {
+		canonical[i] = plg.canonicalOpcode(opcode)
+	}
+	return sub, canonical
+}
+
+// SubscribeReplay is Subscribe for a consumer that connects late or
+// reconnects: the channel first delivers up to replay of the most recent
+// events of the opcodes that the first ring exporter still holds, oldest
+// first, then the live ones. Replayed events are copies with Replayed set. The
+// replay is capped by the subscription buffer, so it never drops the new
+// subscriber.
+func (plg *PluginManages) SubscribeReplay(replay int, opcodes ...string) (<-chan *collector.AllCollector, error) {
+	if replay > subscriptionBuffer {
+		replay = subscriptionBuffer
+	} else if replay < 0 {
+		replay = 0
+	}
+	recent, err := plg.RecentEvents(EventQuery{})
+	if err != nil {
+		return nil, err
+	}
+	sub, canonical := plg.newSubscription(opcodes)
+	wanted := make(map[string]bool, len(canonical))
+	for _, opcode := range canonical {
+		wanted[opcode] = true
+	}
+	var backlog []*collector.AllCollector
+	for _, env := range recent {
+		if env.Payload != nil && (len(opcodes) == 0 || wanted[env.Opcode]) {
+			event := *env.Payload
+			event.Replayed = true
+			backlog = append(backlog, &event)
+		}
+	}
+	if len(backlog) > replay {
+		backlog = backlog[len(backlog)-replay:]
+	}
+	// the backlog goes in before the subscription is registered, so live
+	// events can not overtake it
+	for _, event := range backlog {
+		sub.ch <- event
+	}
+	plg.AddExporter(sub, canonical...)
+	return sub.ch, nil
+}
+
+// Unsubscribe stops the delivery to a channel returned by Subscribe and
+// closes it, the events already queued can still be read. A dropped
+// subscription stays registered until it is unsubscribed.
+func (plg *PluginManages) Unsubscribe(ch <-chan *collector.AllCollector) {
+	plg.exportersLock.Lock()
+	entry := plg.removeExporter(func(entry *exporterEntry) bool {
+		sub, ok := entry.exporter.(*subscription)
+		return ok && (<-chan *collector.AllCollector)(sub.ch) == ch
+	})
+	plg.exportersLock.Unlock()
+
+	if entry != nil {
+		entry.exporter.Close()
+	}
+}
+
+// SubscriptionErr reports why the manager closed a channel returned by
+// Subscribe, nil while the subscription is open or once it is unsubscribed.
+// Consumers may call it while blocks are being processed.
+func (plg *PluginManages) SubscriptionErr(ch <-chan *collector.AllCollector) error {
+	if sub := plg.subscription(ch); sub != nil {
+		return sub.Err()
+	}
+	return nil
+}
+
+func (plg *PluginManages) subscription(ch <-chan *collector.AllCollector) *subscription {
+	for _, entry := range plg.exporterList() {
+		if sub, ok := entry.exporter.(*subscription); ok && (<-chan *collector.AllCollector)(sub.ch) == ch {
+			return sub
+		}
+	}
+	return nil
+}
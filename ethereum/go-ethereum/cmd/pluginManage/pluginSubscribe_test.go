@@ -0,0 +1,131 @@
+package pluginManage
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhidandeng/collector"
+)
+
+func TestSubscribeSlowConsumer(t *testing.T) {
+	manage := NewPluginManages()
+	fast := manage.Subscribe("TXSTART")
+	slow := manage.Subscribe("TXSTART")
+
+	// The fast consumer catches up every half buffer, the slow one never reads.
+	const rounds, perRound = 4, subscriptionBuffer / 2
+	var received int
+	for round := 0; round < rounds; round++ {
+		for i := 0; i < perRound; i++ {
+			manage.Start()
+			manage.BeginTx(testTxHash(round*perRound+i), common.Address{}, nil)
+			manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+		}
+		for len(fast) > 0 {
+			<-fast
+			received++
+		}
+	}
+	if received != rounds*perRound {
+		t.Errorf("fast subscriber received %d events, want %d", received, rounds*perRound)
+	}
+	if err := manage.SubscriptionErr(fast); err != nil {
+		t.Errorf("fast subscriber closed: %v", err)
+	}
+	if err := manage.SubscriptionErr(slow); err != ErrSubscriberTooSlow {
+		t.Errorf("slow subscriber error %v, want %v", err, ErrSubscriberTooSlow)
+	}
+	// The slow channel holds the events queued before the drop, then ends.
+	var queued int
+	for range slow {
+		queued++
+	}
+	if queued != subscriptionBuffer {
+		t.Errorf("slow subscriber kept %d events, want %d", queued, subscriptionBuffer)
+	}
+
+	manage.Unsubscribe(slow)
+	manage.Unsubscribe(fast)
+	if _, ok := <-fast; ok {
+		t.Error("fast channel still open after unsubscribing")
+	}
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	manage := NewPluginManages()
+	if _, err := manage.SubscribeReplay(10, "TXSTART"); err != ErrNoRingExporter {
+		t.Fatalf("replay without a ring exporter: %v, want %v", err, ErrNoRingExporter)
+	}
+	ring := NewRingExporter(ExporterConfig{Name: "ring", Size: 8})
+	manage.AddExporter(ring)
+
+	send := func(i int) {
+		manage.Start()
+		manage.BeginTx(testTxHash(i), common.Address{}, nil)
+		manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+		manage.SendDataToPlugin("TXEND", collector.SendFlag("TXEND"))
+	}
+	// The ring keeps the last four transactions, the replay asks for three.
+	for i := 0; i < 6; i++ {
+		send(i)
+	}
+	ch, err := manage.SubscribeReplay(3, "TXSTART")
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(6)
+	send(7)
+	manage.Unsubscribe(ch)
+
+	var got []*collector.AllCollector
+	for event := range ch {
+		got = append(got, event)
+	}
+	if len(got) != 5 {
+		t.Fatalf("got %d events, want 3 replayed and 2 live", len(got))
+	}
+	for i, event := range got {
+		want := TraceID(0, testTxHash(3+i))
+		if event.Option != "TXSTART" || event.TraceID != want {
+			t.Errorf("event %d: %s of trace %s, want TXSTART of %s", i, event.Option, event.TraceID, want)
+		}
+		if event.Replayed != (i < 3) {
+			t.Errorf("event %d: replayed %t", i, event.Replayed)
+		}
+	}
+	for _, env := range ring.Query(EventQuery{}) {
+		if env.Payload.Replayed {
+			t.Fatal("replay marked the event kept by the ring exporter")
+		}
+	}
+}
+
+// Tests that consumers may subscribe and unsubscribe while events are being
+// dispatched.
+func TestSubscribeDuringDispatch(t *testing.T) {
+	manage := NewPluginManages()
+	manage.AddExporter(NewRingExporter(ExporterConfig{Name: "ring", Size: 16}))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 500; i++ {
+			manage.Start()
+			manage.BeginTx(testTxHash(i), common.Address{}, nil)
+			manage.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		ch := manage.Subscribe("TXSTART")
+		replay, err := manage.SubscribeReplay(4, "TXSTART")
+		if err != nil {
+			t.Fatal(err)
+		}
+		manage.SubscriptionErr(ch)
+		manage.Unsubscribe(ch)
+		manage.Unsubscribe(replay)
+	}
+	<-done
+	if _, err := manage.RecentEvents(EventQuery{}); err != nil {
+		t.Errorf("ring exporter lost: %v", err)
+	}
+}
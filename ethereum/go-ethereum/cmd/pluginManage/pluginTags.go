@@ -0,0 +1,38 @@
+package pluginManage
+
+//add new file
+
+// TagTx attaches tag to the transaction being executed, the tags of every
+// plugin are sent along with its TXEND event. A tag already attached is not
+// repeated. Handlers of async plugins may still be running once the
+// transaction ended, their tags only count when they arrive before TXEND.
+func (plg *PluginManages) TagTx(tag string) {
+	if plg == nil || tag == "" {
+		return
+	}
+	plg.tagLock.Lock()
+	defer plg.tagLock.Unlock()
+	for _, have := range plg.tx.TAGS {
+		if have == tag {
+			return
+		}
+	}
+	plg.tx.TAGS = append(plg.tx.TAGS, tag)
+}
+
+// TxTags returns the tags attached to the transaction being executed.
+func (plg *PluginManages) TxTags() []string {
+	if plg == nil {
+		return nil
+	}
+	plg.tagLock.Lock()
+	defer plg.tagLock.Unlock()
+	return append([]string(nil), plg.tx.TAGS...)
+}
+
+// resetTags drops the tags of the previous transaction.
+func (plg *PluginManages) resetTags() {
+	plg.tagLock.Lock()
+	plg.tx.TAGS = nil
+	plg.tagLock.Unlock()
+}
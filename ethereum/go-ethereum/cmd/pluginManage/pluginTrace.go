@@ -0,0 +1,30 @@
+package pluginManage
+
+//add new file
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zhidandeng/collector"
+)
+
+// TraceID returns the id correlating the events of the transaction with the
+// given hash in the given block: the first 16 bytes of keccak256 over the
+// big endian block number and the hash. It is stable across runs and sinks,
+// and a transaction included again after a reorg gets a new one.
+func TraceID(blockNumber uint64, txHash common.Hash) string {
+	var number [8]byte
+	binary.BigEndian.PutUint64(number[:], blockNumber)
+	return hexutil.Encode(crypto.Keccak256(number[:], txHash[:])[:16])
+}
+
+// trace stamps data with the trace id of the current transaction. Block level
+// events belong to no transaction and carry none.
+func (plg *PluginManages) trace(opcode string, data *collector.AllCollector) {
+	if !blockLevelOps[opcode] {
+		data.TraceID = plg.traceID
+	}
+}
@@ -0,0 +1,190 @@
+package pluginManage
+
+//add new file
+
+import (
+	"fmt"
+	"plugin"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhidandeng/collector"
+)
+
+// ValidationCheck is the outcome of one check of a plugin. Warnings do not
+// fail the plugin.
+type ValidationCheck struct {
+	Name    string
+	Err     error
+	Warning bool
+}
+
+// ValidationReport lists the checks run on a plugin, in order.
+type ValidationReport struct {
+	Path   string
+	Checks []ValidationCheck
+}
+
+// Passed reports whether no check failed.
+func (r *ValidationReport) Passed() bool {
+	for _, check := range r.Checks {
+		if check.Err != nil && !check.Warning {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ValidationReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", r.Path)
+	for _, check := range r.Checks {
+		switch {
+		case check.Err == nil:
+			fmt.Fprintf(&b, "  PASS %s\n", check.Name)
+		case check.Warning:
+			fmt.Fprintf(&b, "  WARN %s: %v\n", check.Name, check.Err)
+		default:
+			fmt.Fprintf(&b, "  FAIL %s: %v\n", check.Name, check.Err)
+		}
+	}
+	if r.Passed() {
+		b.WriteString("plugin is valid\n")
+	} else {
+		b.WriteString("plugin is invalid\n")
+	}
+	return b.String()
+}
+
+func (r *ValidationReport) add(name string, err error) {
+	r.Checks = append(r.Checks, ValidationCheck{Name: name, Err: err})
+}
+
+func (r *ValidationReport) warn(name string, err error) {
+	r.Checks = append(r.Checks, ValidationCheck{Name: name, Err: err, Warning: true})
+}
+
+// ValidatePlugin runs the checks of the registration on the plugin at path
+// without registering it anywhere. Opening it checks it was built with the
+// Go toolchain and package versions of the node.
+func ValidatePlugin(path string) *ValidationReport {
+	report := &ValidationReport{Path: path}
+	opened, err := plugin.Open(path)
+	report.add("open and version check", err)
+	if err != nil {
+		return report
+	}
+	validatePlugin(report, opened)
+	return report
+}
+
+// validatePlugin checks the manifest and the symbols it references.
+func validatePlugin(report *ValidationReport, symbols pluginSymbols) {
+	sym, err := symbols.Lookup("Register")
+	if err != nil {
+		report.add("Register function", err)
+		return
+	}
+	register, ok := sym.(func() []byte)
+	if !ok {
+		report.add("Register function", fmt.Errorf("has type %T, want %T", sym, register))
+		return
+	}
+	report.add("Register function", nil)
+
+	info, err := ParseRegisterInfo(register())
+	report.add("manifest", err)
+	if err != nil {
+		return
+	}
+	if info.PluginName == "" {
+		report.add("plugin name", fmt.Errorf("manifest has no pluginname"))
+	} else {
+		report.add("plugin name", nil)
+	}
+	if err := validMode(info.Mode); err != nil {
+		report.add("mode", err)
+	}
+	switch info.Delivery {
+	case "", "event", "block", "aggregate":
+	default:
+		report.add("delivery", fmt.Errorf("unknown delivery %q", info.Delivery))
+	}
+	if len(info.OpCode) == 0 {
+		report.add("opcodes", fmt.Errorf("manifest subscribes to no opcode"))
+	}
+	opcodes := make([]string, 0, len(info.OpCode))
+	for opcode := range info.OpCode {
+		opcodes = append(opcodes, opcode)
+	}
+	sort.Strings(opcodes)
+	for _, opcode := range opcodes {
+		registration := info.OpCode[opcode]
+		name := "opcode " + opcode
+		if current, ok := opcodeAliases[opcode]; ok {
+			report.warn(name, fmt.Errorf("deprecated, use %s", current))
+		} else if IsOpExist(opcode) == 0 {
+			report.add(name, fmt.Errorf("unknown opcode"))
+			continue
+		}
+		if err := validMode(registration.Options.Mode); err != nil {
+			report.add(name+" mode", err)
+		}
+		sym, err := symbols.Lookup(registration.Func)
+		if err == nil {
+			_, err = sendFuncOf(sym)
+		}
+		report.add(name+" handler "+registration.Func, err)
+	}
+	if info.BatchFunc != "" {
+		report.add("batch function "+info.BatchFunc, lookupTyped(symbols, info.BatchFunc, func(sym plugin.Symbol) bool {
+			_, ok := sym.(func([]*collector.AllCollector))
+			return ok
+		}, "func([]*collector.AllCollector)"))
+	}
+	if info.Filter != "" {
+		report.add("filter function "+info.Filter, lookupTyped(symbols, info.Filter, func(sym plugin.Symbol) bool {
+			_, ok := sym.(func(string, common.Address) bool)
+			return ok
+		}, "func(string, common.Address) bool"))
+	}
+	// the optional hooks are skipped with a message when mistyped
+	optional := []struct {
+		name string
+		ok   func(plugin.Symbol) bool
+		want string
+	}{
+		{"Close", func(sym plugin.Symbol) bool { _, ok := sym.(func()); return ok }, "func()"},
+		{"SetHistory", func(sym plugin.Symbol) bool { _, ok := sym.(func(*History)); return ok }, "func(*pluginManage.History)"},
+		{"SetTagger", func(sym plugin.Symbol) bool { _, ok := sym.(func(func(string))); return ok }, "func(func(string))"},
+	}
+	for _, hook := range optional {
+		if _, err := symbols.Lookup(hook.name); err != nil {
+			continue
+		}
+		if err := lookupTyped(symbols, hook.name, hook.ok, hook.want); err != nil {
+			report.warn(hook.name+" function", fmt.Errorf("%v, it is ignored", err))
+		} else {
+			report.add(hook.name+" function", nil)
+		}
+	}
+}
+
+func validMode(mode string) error {
+	if mode != "" && mode != "monitor" && mode != "enforce" {
+		return fmt.Errorf("unknown mode %q", mode)
+	}
+	return nil
+}
+
+func lookupTyped(symbols pluginSymbols, name string, ok func(plugin.Symbol) bool, want string) error {
+	sym, err := symbols.Lookup(name)
+	if err != nil {
+		return err
+	}
+	if !ok(sym) {
+		return fmt.Errorf("has type %T, want %s", sym, want)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package pluginManage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zhidandeng/collector"
+)
+
+func TestValidatePlugin(t *testing.T) {
+	handler := func(*collector.AllCollector) (byte, string) { return 0x00, "" }
+	good := testSymbols{
+		"Register": func() []byte {
+			return []byte(`{"pluginname":"good","mode":"enforce","batchfunc":"Batch","option":{"TXSTART":"Handle","TRANS_CALL":{"func":"Handle","options":{"mode":"monitor"}}}}`)
+		},
+		"Handle": handler,
+		"Batch":  func([]*collector.AllCollector) {},
+		"Close":  func() {},
+	}
+	report := &ValidationReport{Path: "good.so"}
+	validatePlugin(report, good)
+	if !report.Passed() {
+		t.Fatalf("good plugin failed:\n%s", report)
+	}
+
+	broken := testSymbols{
+		"Register": func() []byte {
+			return []byte(`{"pluginname":"broken","delivery":"hourly","option":{"TXSTART":"Handle","NO_SUCH_OP":"Handle","TXEND":"Missing","TRANS_CALL":"Wrong"}}`)
+		},
+		"Handle":    handler,
+		"Wrong":     func(*collector.AllCollector) bool { return true },
+		"SetTagger": func(string) {},
+	}
+	report = &ValidationReport{Path: "broken.so"}
+	validatePlugin(report, broken)
+	if report.Passed() {
+		t.Fatalf("broken plugin passed:\n%s", report)
+	}
+	failed := make(map[string]bool)
+	for _, check := range report.Checks {
+		if check.Err != nil {
+			failed[check.Name] = true
+		}
+	}
+	for _, name := range []string{"delivery", "opcode NO_SUCH_OP", "opcode TXEND handler Missing", "opcode TRANS_CALL handler Wrong", "SetTagger function"} {
+		if !failed[name] {
+			t.Errorf("check %q did not fail:\n%s", name, report)
+		}
+	}
+	if failed["opcode TXSTART handler Handle"] {
+		t.Errorf("valid handler reported:\n%s", report)
+	}
+	if out := report.String(); !strings.Contains(out, "FAIL opcode NO_SUCH_OP: unknown opcode") || !strings.Contains(out, "plugin is invalid") {
+		t.Errorf("report reads:\n%s", out)
+	}
+
+	report = &ValidationReport{Path: "garbage.so"}
+	validatePlugin(report, testSymbols{"Register": func() []byte { return []byte("not json") }})
+	if report.Passed() {
+		t.Error("unparsable manifest passed")
+	}
+	if report := ValidatePlugin("does-not-exist.so"); report.Passed() {
+		t.Error("missing plugin file passed")
+	}
+}
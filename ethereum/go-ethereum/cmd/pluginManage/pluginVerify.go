@@ -0,0 +1,92 @@
+package pluginManage
+
+//add new file
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// EventRecorder is an exporter keeping the encoded events of every block, to
+// compare the stream of a live import with the one of a replay. Fields
+// measuring wall-clock time differ between any two runs and are zeroed
+// unless the recorder is created to keep them.
+type EventRecorder struct {
+	wallClock bool
+
+	lock   sync.Mutex
+	blocks map[uint64][][]byte
+}
+
+// NewEventRecorder creates a recorder, keeping the wall-clock fields when
+// wallClock is set.
+func NewEventRecorder(wallClock bool) *EventRecorder {
+	return &EventRecorder{wallClock: wallClock, blocks: make(map[uint64][][]byte)}
+}
+
+func (r *EventRecorder) Name() string { return "recorder" }
+
+func (r *EventRecorder) Export(env *Envelope) error {
+	payload := *env.Payload
+	if !r.wallClock {
+		payload.TransInfo.ExecDuration = 0
+	}
+	event, err := json.Marshal(&Envelope{Opcode: env.Opcode, ChainID: env.ChainID, BlockNumber: env.BlockNumber, TxHash: env.TxHash, Payload: &payload})
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.blocks[env.BlockNumber] = append(r.blocks[env.BlockNumber], event)
+	return nil
+}
+
+func (r *EventRecorder) Close() error { return nil }
+
+// WallClock reports whether the recorder keeps the wall-clock fields.
+func (r *EventRecorder) WallClock() bool { return r.wallClock }
+
+// Events returns the encoded events recorded for block number.
+func (r *EventRecorder) Events(number uint64) [][]byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([][]byte(nil), r.blocks[number]...)
+}
+
+// StreamDivergence is the first difference between two event streams. A
+// stream that ended early has no event at Index.
+type StreamDivergence struct {
+	Index  int
+	Live   []byte
+	Replay []byte
+}
+
+func (d *StreamDivergence) Error() string {
+	return fmt.Sprintf("event %d differs: live %s, replay %s", d.Index, orNone(d.Live), orNone(d.Replay))
+}
+
+func orNone(event []byte) string {
+	if event == nil {
+		return "<none>"
+	}
+	return string(event)
+}
+
+// DiffStreams compares the events of a live run with the ones of a replay
+// and returns a *StreamDivergence for the first event that differs.
+func DiffStreams(live, replay [][]byte) error {
+	for i := 0; i < len(live) || i < len(replay); i++ {
+		var l, r []byte
+		if i < len(live) {
+			l = live[i]
+		}
+		if i < len(replay) {
+			r = replay[i]
+		}
+		if l == nil || r == nil || !bytes.Equal(l, r) {
+			return &StreamDivergence{Index: i, Live: l, Replay: r}
+		}
+	}
+	return nil
+}
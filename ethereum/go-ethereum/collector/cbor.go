@@ -0,0 +1,349 @@
+package collector
+
+//add new file
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// CBOR (RFC 8949) major types.
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+const (
+	cborFalse = 0xf4
+	cborTrue  = 0xf5
+	cborNull  = 0xf6
+)
+
+// MarshalCBOR encodes v as CBOR. Structs become maps keyed by the names of
+// their JSON encoding, so a payload describes itself like its JSON form does.
+func MarshalCBOR(v interface{}) ([]byte, error) {
+	return appendCBOR(nil, reflect.ValueOf(v))
+}
+
+// UnmarshalCBOR decodes CBOR produced by MarshalCBOR into the value pointed
+// to by v. Map keys without a matching field are skipped.
+func UnmarshalCBOR(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("cbor: decode target must be a non-nil pointer")
+	}
+	d := &cborDecoder{data: data}
+	if err := d.decode(rv.Elem()); err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return fmt.Errorf("cbor: %d trailing bytes", len(d.data)-d.pos)
+	}
+	return nil
+}
+
+// cborField is a struct field and the map key it is encoded under.
+type cborField struct {
+	key   string
+	index int
+}
+
+var cborFieldCache sync.Map // reflect.Type -> []cborField
+
+func cborFields(t reflect.Type) []cborField {
+	if fields, ok := cborFieldCache.Load(t); ok {
+		return fields.([]cborField)
+	}
+	var fields []cborField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		key := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if name := strings.Split(tag, ",")[0]; name != "" {
+				key = name
+			}
+		}
+		fields = append(fields, cborField{key: key, index: i})
+	}
+	cborFieldCache.Store(t, fields)
+	return fields
+}
+
+func appendCBORHead(buf []byte, major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return append(buf, major|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(buf, major|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return append(buf, major|25, byte(arg>>8), byte(arg))
+	case arg <= math.MaxUint32:
+		return append(buf, major|26, byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+	}
+	buf = append(buf, major|27, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(buf[len(buf)-8:], arg)
+	return buf
+}
+
+func appendCBOR(buf []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return append(buf, cborNull), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, cborTrue), nil
+		}
+		return append(buf, cborFalse), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := v.Int(); n < 0 {
+			return appendCBORHead(buf, cborNegInt, uint64(-1-n)), nil
+		}
+		return appendCBORHead(buf, cborUint, uint64(v.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return appendCBORHead(buf, cborUint, v.Uint()), nil
+	case reflect.String:
+		buf = appendCBORHead(buf, cborText, uint64(v.Len()))
+		return append(buf, v.String()...), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(buf, cborNull), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			buf = appendCBORHead(buf, cborBytes, uint64(v.Len()))
+			return append(buf, v.Bytes()...), nil
+		}
+		buf = appendCBORHead(buf, cborArray, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			var err error
+			if buf, err = appendCBOR(buf, v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return append(buf, cborNull), nil
+		}
+		return appendCBOR(buf, v.Elem())
+	case reflect.Struct:
+		fields := cborFields(v.Type())
+		buf = appendCBORHead(buf, cborMap, uint64(len(fields)))
+		for _, field := range fields {
+			buf = appendCBORHead(buf, cborText, uint64(len(field.key)))
+			buf = append(buf, field.key...)
+			var err error
+			if buf, err = appendCBOR(buf, v.Field(field.index)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("cbor: unsupported type %s", v.Type())
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+var errCBORShort = errors.New("cbor: unexpected end of data")
+
+// head reads the initial byte of an item and its argument.
+func (d *cborDecoder) head() (byte, uint64, error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, errCBORShort
+	}
+	initial := d.data[d.pos]
+	d.pos++
+	major, info := initial>>5, uint64(initial&0x1f)
+	if info < 24 {
+		return major, info, nil
+	}
+	if info > 27 {
+		return 0, 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+	}
+	size := 1 << (info - 24)
+	if len(d.data)-d.pos < size {
+		return 0, 0, errCBORShort
+	}
+	var arg uint64
+	for _, b := range d.data[d.pos : d.pos+size] {
+		arg = arg<<8 | uint64(b)
+	}
+	d.pos += size
+	return major, arg, nil
+}
+
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if uint64(len(d.data)-d.pos) < n {
+		return nil, errCBORShort
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// skip steps over one item.
+func (d *cborDecoder) skip() error {
+	major, arg, err := d.head()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case cborBytes, cborText:
+		_, err = d.take(arg)
+		return err
+	case cborArray, cborMap, cborTag:
+		items := arg
+		if major == cborMap {
+			items *= 2
+		} else if major == cborTag {
+			items = 1
+		}
+		for i := uint64(0); i < items; i++ {
+			if err := d.skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *cborDecoder) decode(v reflect.Value) error {
+	start := d.pos
+	major, arg, err := d.head()
+	if err != nil {
+		return err
+	}
+	if major == cborSimple && arg == cborNull&0x1f {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	mismatch := func() error {
+		return fmt.Errorf("cbor: major type %d at offset %d can not be decoded into %s", major, start, v.Type())
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if major != cborSimple || (arg != cborFalse&0x1f && arg != cborTrue&0x1f) {
+			return mismatch()
+		}
+		v.SetBool(arg == cborTrue&0x1f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch {
+		case major == cborUint && arg <= math.MaxInt64:
+			n = int64(arg)
+		case major == cborNegInt && arg <= math.MaxInt64:
+			n = -1 - int64(arg)
+		default:
+			return mismatch()
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("cbor: %d overflows %s", n, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if major != cborUint {
+			return mismatch()
+		}
+		if v.OverflowUint(arg) {
+			return fmt.Errorf("cbor: %d overflows %s", arg, v.Type())
+		}
+		v.SetUint(arg)
+	case reflect.String:
+		if major != cborText {
+			return mismatch()
+		}
+		b, err := d.take(arg)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(b))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if major != cborBytes {
+				return mismatch()
+			}
+			b, err := d.take(arg)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(append([]byte{}, b...))
+			return nil
+		}
+		if major != cborArray {
+			return mismatch()
+		}
+		if arg > uint64(len(d.data)-d.pos) {
+			return errCBORShort // every element takes at least one byte
+		}
+		slice := reflect.MakeSlice(v.Type(), int(arg), int(arg))
+		for i := 0; i < int(arg); i++ {
+			if err := d.decode(slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Ptr:
+		d.pos = start
+		elem := reflect.New(v.Type().Elem())
+		if err := d.decode(elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Struct:
+		if major != cborMap {
+			return mismatch()
+		}
+		fields := cborFields(v.Type())
+		for i := uint64(0); i < arg; i++ {
+			keyMajor, keyLen, err := d.head()
+			if err != nil {
+				return err
+			}
+			if keyMajor != cborText {
+				return fmt.Errorf("cbor: map key of major type %d in %s", keyMajor, v.Type())
+			}
+			key, err := d.take(keyLen)
+			if err != nil {
+				return err
+			}
+			index := -1
+			for _, field := range fields {
+				if field.key == string(key) {
+					index = field.index
+					break
+				}
+			}
+			if index < 0 {
+				err = d.skip()
+			} else {
+				err = d.decode(v.Field(index))
+			}
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cbor: unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Package client consumes the event stream of a node from Go. On the socket
+// transport the node is the side that dials: a SocketConfig of the node names
+// the Unix socket and the opcodes sent over it, and this package listens on
+// that socket, so a consumer only deals in decoded AllCollector values.
+package client
+
+//add new file
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/zhidandeng/collector"
+)
+
+// DecideFunc answers an event sent to an enforce sidecar with the warning
+// level of the in-process plugins (0x00 allow, 0x01 warn, 0x02 block) and a
+// reason.
+type DecideFunc func(data *collector.AllCollector) (byte, string)
+
+// Config describes the consumer end of a SocketConfig of the node.
+type Config struct {
+	Path    string     // socket the node dials, SocketConfig.Path
+	Opcodes []string   // events delivered, every event the node sends when empty
+	Enforce bool       // the node runs the socket in "enforce" mode and waits for decisions
+	Decide  DecideFunc // decision of an enforce consumer, every event is allowed when nil
+	Buffer  int        // events queued for the consumer, 256 by default
+}
+
+const defaultBuffer = 256
+
+// Client accepts the connections of the node and delivers the events sent
+// over them. The node redials after losing its connection or switching the
+// format, each new connection is served like the first.
+type Client struct {
+	config   Config
+	opcodes  map[string]bool
+	listener net.Listener
+	events   chan *collector.AllCollector
+
+	lock   sync.Mutex
+	conns  map[net.Conn]bool
+	err    error // first decoding error, the connection it happened on is dropped
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Listen creates the socket at config.Path and starts accepting the node.
+func Listen(config Config) (*Client, error) {
+	if config.Path == "" {
+		return nil, errors.New("client: no socket path")
+	}
+	listener, err := net.Listen("unix", config.Path)
+	if err != nil {
+		return nil, err
+	}
+	buffer := config.Buffer
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+	c := &Client{
+		config:   config,
+		listener: listener,
+		events:   make(chan *collector.AllCollector, buffer),
+		conns:    make(map[net.Conn]bool),
+	}
+	if len(config.Opcodes) > 0 {
+		c.opcodes = make(map[string]bool, len(config.Opcodes))
+		for _, opcode := range config.Opcodes {
+			c.opcodes[opcode] = true
+		}
+	}
+	c.wg.Add(1)
+	go c.accept()
+	return c, nil
+}
+
+// Events returns the channel the events are delivered on, in the order the
+// node sent them. It is closed by Close. A consumer not keeping up holds the
+// node back until its write times out, the node then misses the event.
+func (c *Client) Events() <-chan *collector.AllCollector {
+	return c.events
+}
+
+// Err returns the first error decoding the stream, nil if there was none.
+func (c *Client) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+// Close stops listening, drops the connections and closes the event channel.
+func (c *Client) Close() error {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return nil
+	}
+	c.closed = true
+	err := c.listener.Close()
+	for conn := range c.conns {
+		conn.Close()
+	}
+	c.lock.Unlock()
+
+	// unblock connections waiting on a full channel
+	go func() {
+		for range c.events {
+		}
+	}()
+	c.wg.Wait()
+	close(c.events)
+	return err
+}
+
+func (c *Client) accept() {
+	defer c.wg.Done()
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+		c.lock.Lock()
+		if c.closed {
+			c.lock.Unlock()
+			conn.Close()
+			return
+		}
+		c.conns[conn] = true
+		c.wg.Add(1)
+		c.lock.Unlock()
+		go c.serve(conn)
+	}
+}
+
+// serve decodes the frames of one connection, each by its own format byte.
+func (c *Client) serve(conn net.Conn) {
+	defer c.wg.Done()
+	defer func() {
+		c.lock.Lock()
+		delete(c.conns, conn)
+		c.lock.Unlock()
+		conn.Close()
+	}()
+	in := bufio.NewReader(conn)
+	for {
+		frame, err := collector.ReadFrame(in)
+		if err != nil {
+			if err != io.EOF && !c.isClosed() {
+				c.fail(err)
+			}
+			return
+		}
+		data, err := frame.Decode()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		if c.config.Enforce {
+			level, reason := byte(0x00), ""
+			if c.config.Decide != nil && c.wanted(data) {
+				level, reason = c.config.Decide(data)
+			}
+			if err := collector.WriteDecision(conn, level, reason); err != nil {
+				return
+			}
+		}
+		if c.wanted(data) {
+			c.events <- data
+		}
+	}
+}
+
+func (c *Client) wanted(data *collector.AllCollector) bool {
+	return c.opcodes == nil || c.opcodes[data.Option]
+}
+
+func (c *Client) isClosed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.closed
+}
+
+func (c *Client) fail(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zhidandeng/collector"
+)
+
+// dialNode connects to c like the socket transport of a node does.
+func dialNode(t *testing.T, path string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn, bufio.NewReader(conn)
+}
+
+func next(t *testing.T, c *Client) *collector.AllCollector {
+	select {
+	case data := <-c.Events():
+		return data
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event from the client")
+		return nil
+	}
+}
+
+func TestClientEnforce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enforce.sock")
+	c, err := Listen(Config{
+		Path:    path,
+		Opcodes: []string{"TXSTART", "TRANS_CALL"},
+		Enforce: true,
+		Decide: func(data *collector.AllCollector) (byte, string) {
+			if data.Option == "TRANS_CALL" {
+				return 0x02, "no calls"
+			}
+			return 0x00, ""
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	conn, in := dialNode(t, path)
+
+	// Frames of both formats may follow each other on one connection, and
+	// every frame is answered, filtered or not.
+	sends := []struct {
+		format string
+		option string
+		level  byte
+		reason string
+	}{
+		{collector.FormatJSON, "TXSTART", 0x00, ""},
+		{collector.FormatCBOR, "TXEND", 0x00, ""},
+		{collector.FormatCBOR, "TRANS_CALL", 0x02, "no calls"},
+	}
+	for _, send := range sends {
+		if err := collector.WriteFrame(conn, send.format, collector.SendFlag(send.option)); err != nil {
+			t.Fatal(err)
+		}
+		frame, err := collector.ReadFrame(in)
+		if err != nil {
+			t.Fatalf("%s: no decision: %v", send.option, err)
+		}
+		level, reason, err := frame.Decision()
+		if err != nil || level != send.level || reason != send.reason {
+			t.Errorf("%s: decision %#x %q (%v), want %#x %q", send.option, level, reason, err, send.level, send.reason)
+		}
+	}
+	if a, b := next(t, c).Option, next(t, c).Option; a != "TXSTART" || b != "TRANS_CALL" {
+		t.Errorf("delivered %s and %s, want TXSTART and TRANS_CALL", a, b)
+	}
+	if err := c.Err(); err != nil {
+		t.Errorf("stream error %v", err)
+	}
+}
+
+func TestClientMonitor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.sock")
+	c, err := Listen(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, _ := dialNode(t, path)
+	for _, option := range []string{"TXSTART", "TXEND"} {
+		if err := collector.WriteFrame(conn, collector.FormatJSON, collector.SendFlag(option)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a, b := next(t, c).Option, next(t, c).Option; a != "TXSTART" || b != "TXEND" {
+		t.Errorf("delivered %s and %s, want every event in order", a, b)
+	}
+	// A frame that does not decode is reported and drops the connection.
+	if _, err := conn.Write([]byte{0, 0, 0, 3, collector.CategoryFlag, collector.FrameJSON, '{'}); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); c.Err() == nil; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("undecodable frame not reported")
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+	if _, ok := <-c.Events(); ok {
+		t.Error("event channel open after Close")
+	}
+	if _, err := Listen(Config{}); err == nil {
+		t.Error("listening without a path")
+	}
+}
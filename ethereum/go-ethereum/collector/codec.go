@@ -0,0 +1,49 @@
+package collector
+
+//add new file
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Serialization formats of an AllCollector handed to a sink or plugin.
+const (
+	FormatJSON = "json"
+	FormatCBOR = "cbor"
+)
+
+// ContentType returns the MIME type tagging a payload in format.
+func ContentType(format string) string {
+	if format == FormatCBOR {
+		return "application/cbor"
+	}
+	return "application/json"
+}
+
+// ValidFormat reports whether format is known, the empty format being JSON.
+func ValidFormat(format string) bool {
+	return format == "" || format == FormatJSON || format == FormatCBOR
+}
+
+// Marshal encodes data in format, JSON when format is empty.
+func Marshal(format string, data *AllCollector) ([]byte, error) {
+	switch format {
+	case "", FormatJSON:
+		return json.Marshal(data)
+	case FormatCBOR:
+		return MarshalCBOR(data)
+	}
+	return nil, fmt.Errorf("unknown collector format %q", format)
+}
+
+// Unmarshal decodes a payload encoded by Marshal in the same format.
+func Unmarshal(format string, payload []byte, data *AllCollector) error {
+	switch format {
+	case "", FormatJSON:
+		return json.Unmarshal(payload, data)
+	case FormatCBOR:
+		return UnmarshalCBOR(payload, data)
+	}
+	return fmt.Errorf("unknown collector format %q", format)
+}
@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// fill sets every field reachable from v to a distinct non-zero value, so a
+// round trip covers the fields added later too.
+func fill(v reflect.Value, seed *int) {
+	*seed++
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int64:
+		v.SetInt(int64(*seed) * -1000003)
+	case reflect.Uint8, reflect.Uint64:
+		v.SetUint(uint64(*seed))
+	case reflect.String:
+		v.SetString("value-" + string(rune('a'+*seed%26)) + string(bytes.Repeat([]byte{'x'}, *seed)))
+	case reflect.Slice:
+		slice := reflect.MakeSlice(v.Type(), 3, 3)
+		for i := 0; i < slice.Len(); i++ {
+			fill(slice.Index(i), seed)
+		}
+		v.Set(slice)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			fill(v.Field(i), seed)
+		}
+	default:
+		panic("fill: unhandled kind " + v.Kind().String())
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	var data AllCollector
+	seed := 0
+	fill(reflect.ValueOf(&data).Elem(), &seed)
+
+	sizes := make(map[string]int)
+	for _, format := range []string{FormatJSON, FormatCBOR} {
+		payload, err := Marshal(format, &data)
+		if err != nil {
+			t.Fatalf("%s: encoding failed: %v", format, err)
+		}
+		sizes[format] = len(payload)
+		var decoded AllCollector
+		if err := Unmarshal(format, payload, &decoded); err != nil {
+			t.Fatalf("%s: decoding failed: %v", format, err)
+		}
+		if !reflect.DeepEqual(decoded, data) {
+			t.Errorf("%s: round trip changed the event\nhave %+v\nwant %+v", format, decoded, data)
+		}
+	}
+	t.Logf("encoded sizes: json %d bytes, cbor %d bytes", sizes[FormatJSON], sizes[FormatCBOR])
+	if sizes[FormatCBOR] >= sizes[FormatJSON] {
+		t.Errorf("cbor payload of %d bytes not smaller than json's %d", sizes[FormatCBOR], sizes[FormatJSON])
+	}
+}
+
+func TestCodecEmptyEvent(t *testing.T) {
+	data := SendFlag("TXSTART")
+	payload, err := Marshal(FormatCBOR, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded AllCollector
+	if err := Unmarshal(FormatCBOR, payload, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, data) {
+		t.Errorf("round trip of an empty event gave %+v", decoded)
+	}
+	if err := Unmarshal(FormatCBOR, payload[:len(payload)-1], &decoded); err == nil {
+		t.Error("truncated payload decoded without error")
+	}
+}
@@ -4,6 +4,10 @@ package collector
 
 type AllCollector struct {
 	Option             	string          `json:"option"`
+	Seq					uint64			`json:"seq"`		//per block emission order, from 1
+	TraceID				string			`json:"trace_id"`	//same for every event of a transaction, empty for block level events
+	EventKey			string			`json:"event_key"`	//idempotency key, same on every copy of the event
+	Replayed			bool			`json:"replayed"`	//historical event a subscriber was caught up with, not a live one
 	InsInfo            	InsCollector    `json:"ins_info"`
 	TransInfo			TransCollector 	`json:"trans_info"`
 	BlockInfo			BlockCollector	`json:"block_info"`
@@ -15,7 +19,7 @@ type InsCollector struct {
 	Pc                  uint64   	      `json:"pc"`                  //pc
 	PcNext              string   	      `json:"pcnext"`              //next PC
 	CallLayer			int   		      `json:"calllayer"`		   //call layer
-	AccountValue        AccountValueInfo  `json:"calllayer"`           //from-to-value
+	AccountValue        AccountValueInfo  `json:"accountvalue"`           //from-to-value
 	OpInOut             OpInOutInfo       `json:"opinout"`             //input and output of opcode
 	StoreValue          SstoreValueInfo   `json:"storevalue"`          //SSTORE PreValue/CurrentValue
 	Gas                 GasInfo   	      `json:"gas"`                 //pre-allocated gas and read used gas
@@ -61,7 +65,7 @@ type AccountValueInfo struct{
 
 // transactions information
 type TransCollector struct {
-	Op 					string 			`json:"trans_txhash"`
+	Op 					string 			`json:"trans_op"`
 	TxHash       		string 			`json:"trans_txhash"`
 	BlockNumber  		string 			`json:"trans_blocknumber"`
 	BlockTime			string 			`json:"trans_blocktime"`
@@ -75,9 +79,32 @@ type TransCollector struct {
 	CallLayer           int 			`json:"trans_calllayer"`
 	CreateInfo   		CreateCollector `json:"trans_createcollector"`
 	CallInfo            CallCollector	`json:"trans_callcollector"`
+	PrecompileInfo      PrecompileCollector `json:"trans_precompilecollector"`
+	ReentrancyInfo      ReentrancyCollector `json:"trans_reentrancycollector"`
+	NonceInfo           NonceCollector      `json:"trans_noncecollector"`
+	WouldBlockInfo      WouldBlockCollector `json:"trans_wouldblockcollector"`
+	RevertInfo          RevertCollector     `json:"trans_revertcollector"`
+	AnomalyInfo         AnomalyCollector    `json:"trans_anomalycollector"`
 	Nonce				uint64			`json:"trans_nonce"`
 	Pc					uint64			`json:"trans_pc"`
 	IsSuccess			bool 			`json:"trans_issucess"`
+	RepeatCount			uint64			`json:"trans_repeatcount"`		//handle_CALL_REPEAT: identical calls left out
+	GasUsedSubtree		uint64			`json:"trans_gasusedsubtree"`	//gas the internal call and everything it called consumed
+	ExecDuration		int64			`json:"trans_execduration"`	//EXTERNALINFOEND: nanoseconds the transaction took to execute
+	Tags				[]string		`json:"trans_tags"`			//TXEND: tags plugins attached to the transaction
+	Touched				[]TouchedCollector	`json:"trans_touched"`	//handle_TOUCHED_SET
+	FailureReason		string			`json:"trans_failurereason"`	//EXTERNALINFOEND: category of the error a failed transaction ended with
+	DeepCalls			DeepCallsCollector	`json:"trans_deepcalls"`	//handle_DEEP_CALLS
+	SlowPluginInfo		SlowPluginCollector	`json:"trans_slowplugincollector"`	//handle_PLUGIN_SLOW
+	PreState			PreStateCollector	`json:"trans_prestate"`	//EXTERNALINFOSTART, with the prestate field enabled
+	PostState			PostStateCollector	`json:"trans_poststate"`	//EXTERNALINFOEND, with the poststate field enabled
+	RedeployInfo		RedeployCollector	`json:"trans_redeploycollector"`	//handle_REDEPLOY
+	InternalCalls		int				`json:"trans_internalcalls"`	//TXEND: frames entered below the transaction's own, calls and creations
+	MaxCallDepth		int				`json:"trans_maxcalldepth"`	//TXEND: deepest call stack reached, 1 without internal calls
+	LogCount			int				`json:"trans_logcount"`		//handle_TX_SUMMARY: logs the transaction emitted
+	Created				string			`json:"trans_created"`		//handle_TX_SUMMARY: address of the contract a successful creation deployed
+	IntrinsicGas		uint64			`json:"trans_intrinsicgas"`	//EXTERNALINFOEND: gas charged before execution, base cost and calldata
+	ExecutionGas		uint64			`json:"trans_executiongas"`	//EXTERNALINFOEND: rest of trans_gasused, net of the refund
 }
 
 // block information
@@ -98,17 +125,201 @@ type BlockCollector struct{
 	Extra       		[]byte      `json:"block_extraData"`
 	MixDigest   		string    	`json:"block_mixHash"`
 	Nonce       		uint64     	`json:"block_nonce"`
+	TotalDifficulty		string		`json:"block_totalDifficulty"`	//including this block
+	BaseFee				string		`json:"block_baseFeePerGas"`	//empty before London
+	Signer				string		`json:"block_signer"`			//clique signer recovered from Extra
+	ExtraTag			string		`json:"block_extraTag"`			//printable text of Extra on other engines, e.g. a pool tag
+	ForkRules			ForkRulesCollector	`json:"block_forkrules"`	//handle_FORK_RULES
+	Aggregate			AggregateCollector	`json:"block_aggregate"`	//handle_BLOCK_END of an aggregating plugin
+	GasStats			GasStatsCollector	`json:"block_gasstats"`	//handle_BLOCK_GAS_STATS
+	Shed				ShedCollector		`json:"block_shed"`		//handle_PLUGIN_SHED
+	AccountProofs		[]AccountProofCollector	`json:"block_accountproofs"`	//handle_ACCOUNT_PROOF, against StateRoot
+}
+
+// hard fork rules active for a block
+type ForkRulesCollector struct{
+	Homestead			bool		`json:"forkrules_homestead"`
+	EIP150				bool		`json:"forkrules_eip150"`
+	EIP155				bool		`json:"forkrules_eip155"`
+	EIP158				bool		`json:"forkrules_eip158"`
+	Byzantium			bool		`json:"forkrules_byzantium"`
+	Constantinople		bool		`json:"forkrules_constantinople"`
+	Petersburg			bool		`json:"forkrules_petersburg"`
+	Istanbul			bool		`json:"forkrules_istanbul"`
+	Berlin				bool		`json:"forkrules_berlin"`
+	London				bool		`json:"forkrules_london"`
+	Shanghai			bool		`json:"forkrules_shanghai"`
+	Cancun				bool		`json:"forkrules_cancun"`
 }
 
 type CreateCollector struct {
 	ContractAddr      	string 		`json:"contractaddr"`
 	ContractDeployCode 	[]byte 		`json:"contractinputcode"`
 	ContractRuntimeCode []byte 		`json:"contractretcode"`
+	ContractRuntimeSize int			`json:"contractretsize"`		//len(ContractRuntimeCode)
+	ExceedsCodeSizeLimit bool		`json:"contractoversize"`		//runtime code above the EIP-170 limit
+	Deployer			string		`json:"contractdeployer"`		//sender of the transaction or the creating contract
+	DeployerNonce		uint64		`json:"contractdeployernonce"`	//nonce of Deployer the creation used
+	Attempt				int			`json:"contractattempt"`		//order the creation started in within the transaction, from 1
 }
 
 type CallCollector struct{
 	InputData    		[]byte 		`json:"trans_inputdata"`
 	ContractCode 		[]byte 		`json:"trans_contractcode"`
+	StorageAddr			string		`json:"trans_storageaddr"`		//account whose storage the code runs on, the caller for DELEGATECALL/CALLCODE
+	IsPrecompile		bool		`json:"trans_isprecompile"`		//callee is a precompiled contract
+	PrecompileName		string		`json:"trans_precompilename"`
+	DecodedInput		DecodedCall	`json:"trans_decodedinput"`		//InputData decoded against the ABI of the callee, if one is configured
+	Input				[]byte		`json:"trans_input"`				//TRANS_*CALL: calldata of the internal call as it entered, cut to the payload cap
+	Output				[]byte		`json:"trans_output"`			//TRANS_*CALL: return or revert data of the internal call, cut to the payload cap
+	InputSize			int			`json:"trans_inputsize"`		//length of the calldata before the cap
+	OutputSize			int			`json:"trans_outputsize"`		//length of the return data before the cap
+}
+
+// calldata decoded against a contract ABI
+type DecodedCall struct{
+	Method				string			`json:"decoded_method"`
+	Signature			string			`json:"decoded_signature"`		//transfer(address,uint256)
+	Args				[]DecodedArg	`json:"decoded_args"`
+}
+
+type DecodedArg struct{
+	Name				string		`json:"arg_name"`
+	Type				string		`json:"arg_type"`
+	Value				string		`json:"arg_value"`				//decimal numbers, 0x prefixed hex for addresses and bytes
+}
+
+// precompiled contract invocation
+type PrecompileCollector struct{
+	Name				string		`json:"precompile_name"`		//ecrecover, sha256, modexp...
+	Input				[]byte		`json:"precompile_input"`
+	Output				[]byte		`json:"precompile_output"`
+	GasCost				uint64		`json:"precompile_gascost"`
+	Err					string		`json:"precompile_err"`
+}
+
+// contract entered again while still on the call stack
+type ReentrancyCollector struct{
+	Address				string		`json:"reentrancy_address"`
+	Depths				[]int		`json:"reentrancy_depths"`		//call depths of every active frame of Address, innermost last
+}
+
+// transaction nonce out of sequence for its sender
+type NonceCollector struct{
+	Sender				string		`json:"nonce_sender"`
+	Expected			uint64		`json:"nonce_expected"`			//account nonce before the transaction
+	Actual				uint64		`json:"nonce_actual"`			//nonce of the transaction
+}
+
+// block decision of a dry-run plugin that was not acted upon
+type WouldBlockCollector struct{
+	Plugin				string		`json:"wouldblock_plugin"`
+	Opcode				string		`json:"wouldblock_opcode"`		//event the plugin decided on
+	Level				byte		`json:"wouldblock_level"`
+	Reason				string		`json:"wouldblock_reason"`
+}
+
+// transaction state rolled back because a plugin blocked it
+type RevertCollector struct{
+	SnapshotID			int			`json:"revert_snapshot"`
+	Plugin				string		`json:"revert_plugin"`			//plugin whose decision blocked the transaction
+	Reason				string		`json:"revert_reason"`
+}
+
+// transaction above a configured calldata size or value limit
+type AnomalyCollector struct{
+	Threshold			string		`json:"anomaly_threshold"`		//calldata or value
+	Limit				string		`json:"anomaly_limit"`			//configured limit, bytes or wei
+	Actual				string		`json:"anomaly_actual"`
+}
+
+// accounts of a transaction before it ran; the recipient is left empty for a contract creation
+type PreStateCollector struct{
+	FromBalance			string		`json:"prestate_frombalance"`
+	FromNonce			uint64		`json:"prestate_fromnonce"`
+	ToBalance			string		`json:"prestate_tobalance"`
+	ToHasCode			bool		`json:"prestate_tohascode"`
+}
+
+// the same accounts once the transaction ran
+type PostStateCollector struct{
+	FromBalance			string		`json:"poststate_frombalance"`
+	FromNonce			uint64		`json:"poststate_fromnonce"`
+	ToBalance			string		`json:"poststate_tobalance"`
+	ToHasCode			bool		`json:"poststate_tohascode"`
+}
+
+// contract deployed at the address of a contract destroyed before
+type RedeployCollector struct{
+	Address				string		`json:"redeploy_address"`
+	OldCodeHash			string		`json:"redeploy_oldcodehash"`	//code of the destroyed contract
+	NewCodeHash			string		`json:"redeploy_newcodehash"`
+}
+
+// internal calls of a transaction below the capture depth, left out of the event stream
+type DeepCallsCollector struct{
+	MaxDepth			int			`json:"deepcalls_maxdepth"`		//deepest call layer emitted
+	Calls				uint64		`json:"deepcalls_calls"`
+	Deepest				int			`json:"deepcalls_deepest"`		//deepest call layer reached
+	GasUsed				uint64		`json:"deepcalls_gasused"`		//gas the calls left out consumed
+}
+
+// plugin call that completed above the soft latency threshold
+type SlowPluginCollector struct{
+	Plugin				string		`json:"slowplugin_plugin"`
+	Opcode				string		`json:"slowplugin_opcode"`		//event the plugin was handling
+	Duration			int64		`json:"slowplugin_duration"`		//nanoseconds
+	Threshold			int64		`json:"slowplugin_threshold"`		//nanoseconds
+}
+
+// account a transaction accessed, with the storage slots it read or wrote
+type TouchedCollector struct{
+	Address				string		`json:"touched_address"`
+	Slots				[]string	`json:"touched_slots"`
+}
+
+// effective gas prices paid in a block, wei; the prices are empty without transactions
+type GasStatsCollector struct{
+	Transactions		uint64		`json:"gasstats_transactions"`
+	MinGasPrice			string		`json:"gasstats_min"`
+	MedianGasPrice		string		`json:"gasstats_median"`
+	P90GasPrice			string		`json:"gasstats_p90"`
+	MaxGasPrice			string		`json:"gasstats_max"`
+	TotalFees			string		`json:"gasstats_totalfees"`		//gas used times effective gas price, summed
+}
+
+// monitor plugins shed or restored by the stall detector
+type ShedCollector struct{
+	Shed				bool		`json:"shed_shed"`				//true when shedding starts, false when the plugins are restored
+	Dispatch			int64		`json:"shed_dispatch"`			//nanoseconds the events of the block took to dispatch
+	Budget				int64		`json:"shed_budget"`			//nanoseconds they may take
+	Blocks				int			`json:"shed_blocks"`			//blocks in a row it took to decide
+}
+
+// Merkle proof of an account, in the form eth_getProof returns it
+type AccountProofCollector struct{
+	Address				string		`json:"accountproof_address"`
+	Balance				string		`json:"accountproof_balance"`
+	Nonce				uint64		`json:"accountproof_nonce"`
+	CodeHash			string		`json:"accountproof_codehash"`
+	StorageHash			string		`json:"accountproof_storagehash"`	//root of the storage trie the storage proofs are against
+	Proof				[]string	`json:"accountproof_proof"`		//RLP encoded trie nodes from the root, 0x prefixed
+	Storage				[]StorageProofCollector	`json:"accountproof_storage"`
+}
+
+type StorageProofCollector struct{
+	Key					string		`json:"storageproof_key"`
+	Value				string		`json:"storageproof_value"`
+	Proof				[]string	`json:"storageproof_proof"`
+}
+
+// events of a block folded for one aggregating plugin
+type AggregateCollector struct{
+	Events				uint64		`json:"aggregate_events"`		//events folded in
+	Transactions		uint64		`json:"aggregate_transactions"`	//transactions they belong to
+	TotalValue			string		`json:"aggregate_totalvalue"`	//sum of trans_value, wei
+	GasUsed				uint64		`json:"aggregate_gasused"`		//sum of trans_gasused
+	Trans				[]TransCollector	`json:"aggregate_trans"`	//transaction payloads among the events
 }
 
 
@@ -127,9 +338,53 @@ func NewTransCollector() *TransCollector {
 func NewCreateCollector() *CreateCollector {
 	return &CreateCollector{}
 }
+// SetRuntimeCode records the runtime code returned by the init code together
+// with its size, flagging code larger than limit bytes.
+func (cc *CreateCollector) SetRuntimeCode(code []byte, limit int) {
+	cc.ContractRuntimeCode = code
+	cc.ContractRuntimeSize = len(code)
+	cc.ExceedsCodeSizeLimit = len(code) > limit
+}
+
 func NewCallCollector() *CallCollector {
 	return &CallCollector{}
 }
+func NewPrecompileCollector() *PrecompileCollector {
+	return &PrecompileCollector{}
+}
+func NewReentrancyCollector() *ReentrancyCollector {
+	return &ReentrancyCollector{}
+}
+func NewForkRulesCollector() *ForkRulesCollector {
+	return &ForkRulesCollector{}
+}
+func NewDeepCallsCollector() *DeepCallsCollector {
+	return &DeepCallsCollector{}
+}
+func NewPreStateCollector() *PreStateCollector {
+	return &PreStateCollector{}
+}
+func NewPostStateCollector() *PostStateCollector {
+	return &PostStateCollector{}
+}
+func NewRedeployCollector() *RedeployCollector {
+	return &RedeployCollector{}
+}
+func NewRevertCollector() *RevertCollector {
+	return &RevertCollector{}
+}
+func NewWouldBlockCollector() *WouldBlockCollector {
+	return &WouldBlockCollector{}
+}
+func NewNonceCollector() *NonceCollector {
+	return &NonceCollector{}
+}
+func NewGasStatsCollector() *GasStatsCollector {
+	return &GasStatsCollector{TotalFees: "0"}
+}
+func NewAggregateCollector() *AggregateCollector {
+	return &AggregateCollector{TotalValue: "0"}
+}
 func NewCollectorDataT() *AllCollector {
 	return &AllCollector{}
 }
@@ -0,0 +1,187 @@
+package collector
+
+//add new file
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A frame delimits one AllCollector on a byte stream:
+//
+//	length   4 bytes, big endian, of everything after it
+//	category 1 byte, see CategoryOf
+//	format   1 byte, FrameJSON or FrameCBOR
+//	payload  the AllCollector encoded in format
+//
+// The category lets a consumer skip the events it does not care about
+// without decoding them. The answer of an enforce consumer travels back in a
+// CategoryDecision frame of format FrameRaw, see EncodeDecision.
+
+// Event categories of a frame.
+const (
+	CategoryFlag        byte = 0 // bare markers such as TXSTART, carrying the option only
+	CategoryInstruction byte = 1 // InsInfo
+	CategoryTransaction byte = 2 // TransInfo
+	CategoryBlock       byte = 3 // BlockInfo
+	CategoryDecision    byte = 4 // answer of an enforce consumer to the last event
+)
+
+// Format bytes of a frame.
+const (
+	FrameRaw  byte = 0 // payload not encoded, decisions only
+	FrameJSON byte = 1
+	FrameCBOR byte = 2
+)
+
+const frameHeader = 2 // category and format bytes
+
+// MaxFrameSize bounds the length a frame may announce.
+const MaxFrameSize = 16 << 20
+
+var errFrameTooLarge = errors.New("collector frame too large")
+
+// Frame is one decoded frame, its payload still encoded.
+type Frame struct {
+	Category byte
+	Format   string // "" for FrameRaw
+	Payload  []byte
+}
+
+// Decode decodes the payload of the frame.
+func (f *Frame) Decode() (*AllCollector, error) {
+	if f.Category == CategoryDecision {
+		return nil, errors.New("collector frame is a decision")
+	}
+	data := new(AllCollector)
+	if err := Unmarshal(f.Format, f.Payload, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// CategoryOf returns the category of data from the part of it that is filled.
+func CategoryOf(data *AllCollector) byte {
+	switch {
+	case data.BlockInfo.Op != "":
+		return CategoryBlock
+	case data.TransInfo.Op != "":
+		return CategoryTransaction
+	case data.InsInfo.OpName != "":
+		return CategoryInstruction
+	}
+	return CategoryFlag
+}
+
+func formatByte(format string) (byte, error) {
+	switch format {
+	case "", FormatJSON:
+		return FrameJSON, nil
+	case FormatCBOR:
+		return FrameCBOR, nil
+	}
+	return 0, fmt.Errorf("unknown collector format %q", format)
+}
+
+func frameFormat(b byte) (string, error) {
+	switch b {
+	case FrameRaw:
+		return "", nil
+	case FrameJSON:
+		return FormatJSON, nil
+	case FrameCBOR:
+		return FormatCBOR, nil
+	}
+	return "", fmt.Errorf("unknown frame format byte %#x", b)
+}
+
+// EncodeFrame encodes data in format and frames it.
+func EncodeFrame(format string, data *AllCollector) ([]byte, error) {
+	fb, err := formatByte(format)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := Marshal(format, data)
+	if err != nil {
+		return nil, err
+	}
+	return encodeFrame(CategoryOf(data), fb, payload)
+}
+
+// EncodeDecision frames the answer of an enforce consumer: the warning level
+// of the in-process plugins (0x00 allow, 0x01 warn, 0x02 block) followed by
+// the reason.
+func EncodeDecision(level byte, reason string) ([]byte, error) {
+	return encodeFrame(CategoryDecision, FrameRaw, append([]byte{level}, reason...))
+}
+
+func encodeFrame(category, format byte, payload []byte) ([]byte, error) {
+	if len(payload)+frameHeader > MaxFrameSize {
+		return nil, errFrameTooLarge
+	}
+	frame := make([]byte, 4, 4+frameHeader+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(frameHeader+len(payload)))
+	frame = append(frame, category, format)
+	return append(frame, payload...), nil
+}
+
+// WriteFrame writes data to w as one frame.
+func WriteFrame(w io.Writer, format string, data *AllCollector) error {
+	frame, err := EncodeFrame(format, data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(frame)
+	return err
+}
+
+// WriteDecision writes the answer of an enforce consumer to w as one frame.
+func WriteDecision(w io.Writer, level byte, reason string) error {
+	frame, err := EncodeDecision(level, reason)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(frame)
+	return err
+}
+
+// Decision returns the level and reason of a decision frame.
+func (f *Frame) Decision() (byte, string, error) {
+	if f.Category != CategoryDecision {
+		return 0, "", fmt.Errorf("collector frame of category %d is no decision", f.Category)
+	}
+	if len(f.Payload) == 0 {
+		return 0, "", errors.New("empty decision frame")
+	}
+	return f.Payload[0], string(f.Payload[1:]), nil
+}
+
+// ReadFrame reads the next frame from r. At the end of the stream it returns
+// io.EOF, within a frame io.ErrUnexpectedEOF.
+func ReadFrame(r io.Reader) (*Frame, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(prefix[:])
+	if n < frameHeader {
+		return nil, fmt.Errorf("collector frame of %d bytes has no header", n)
+	}
+	if n > MaxFrameSize {
+		return nil, errFrameTooLarge
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	format, err := frameFormat(body[1])
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Category: body[0], Format: format, Payload: body[frameHeader:]}, nil
+}
@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	ins := NewCollector()
+	ins.OpName = "SSTORE"
+	ins.Pc = 7
+	trans := NewTransCollector()
+	trans.Op = "EXTERNALINFOSTART"
+	trans.TxHash = "0x01"
+	trans.CallInfo.InputData = []byte{0x09, 0x5e, 0xa7, 0xb3}
+	block := NewBlockCollector()
+	block.Op = "Block1"
+	block.Number = "1"
+	events := []struct {
+		data     *AllCollector
+		category byte
+	}{
+		{SendFlag("TXSTART"), CategoryFlag},
+		{ins.SendInsInfo(), CategoryInstruction},
+		{trans.SendTransInfo("EXTERNALINFOSTART"), CategoryTransaction},
+		{block.SendBlockInfo("handle_BLOCK_INFO"), CategoryBlock},
+	}
+	for _, format := range []string{FormatJSON, FormatCBOR} {
+		var stream bytes.Buffer
+		for _, event := range events {
+			if err := WriteFrame(&stream, format, event.data); err != nil {
+				t.Fatalf("%s: %v", format, err)
+			}
+		}
+		for i, event := range events {
+			frame, err := ReadFrame(&stream)
+			if err != nil {
+				t.Fatalf("%s frame %d: %v", format, i, err)
+			}
+			if frame.Category != event.category || frame.Format != format {
+				t.Errorf("%s frame %d: category %d format %s, want %d %s", format, i, frame.Category, frame.Format, event.category, format)
+			}
+			decoded, err := frame.Decode()
+			if err != nil {
+				t.Fatalf("%s frame %d: %v", format, i, err)
+			}
+			if !reflect.DeepEqual(decoded, event.data) {
+				t.Errorf("%s frame %d decoded to %+v, want %+v", format, i, decoded, event.data)
+			}
+		}
+		if _, err := ReadFrame(&stream); err != io.EOF {
+			t.Errorf("%s: read past the last frame returned %v, want io.EOF", format, err)
+		}
+	}
+}
+
+func TestFrameErrors(t *testing.T) {
+	if _, err := EncodeFrame("protobuf", SendFlag("TXSTART")); err == nil {
+		t.Error("unknown format encoded")
+	}
+	frame, err := EncodeFrame(FormatJSON, SendFlag("TXSTART"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFrame(bytes.NewReader(frame[:len(frame)-1])); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated frame returned %v, want io.ErrUnexpectedEOF", err)
+	}
+	bad := append([]byte{}, frame...)
+	bad[5] = 0x7f
+	if _, err := ReadFrame(bytes.NewReader(bad)); err == nil {
+		t.Error("frame with an unknown format byte accepted")
+	}
+	if _, err := ReadFrame(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff})); err != errFrameTooLarge {
+		t.Errorf("oversized length returned %v, want errFrameTooLarge", err)
+	}
+}
+
+func TestDecisionFrame(t *testing.T) {
+	var stream bytes.Buffer
+	if err := WriteDecision(&stream, 0x02, "blocked call"); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFrame(&stream, FormatJSON, SendFlag("TXSTART")); err != nil {
+		t.Fatal(err)
+	}
+	frame, err := ReadFrame(&stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level, reason, err := frame.Decision(); err != nil || level != 0x02 || reason != "blocked call" {
+		t.Errorf("decision %#x %q (%v), want 0x02 blocked call", level, reason, err)
+	}
+	if _, err := frame.Decode(); err == nil {
+		t.Error("decision decoded as an event")
+	}
+	frame, err = ReadFrame(&stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := frame.Decision(); err == nil {
+		t.Error("event read as a decision")
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/zhidandeng/collector
+
+go 1.16
@@ -0,0 +1,106 @@
+package collector
+
+//add new file
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SchemaVersion is bumped whenever a field of an emitted collector type is
+// added, removed or changes its meaning, so that consumers can tell which
+// layout a payload follows.
+const SchemaVersion = 38
+
+// FieldSchema describes one field of a collector type.
+type FieldSchema struct {
+	Name string `json:"name"` // Go field name
+	JSON string `json:"json"` // key in the JSON encoding
+	Type string `json:"type"` // Go type, nested collector types by name
+}
+
+// TypeSchema describes one collector type as emitted by the node.
+type TypeSchema struct {
+	Name    string        `json:"name"`
+	Version int           `json:"version"`
+	Fields  []FieldSchema `json:"fields"`
+}
+
+// schemaTypes lists every type that can appear in an emitted AllCollector.
+var schemaTypes = []interface{}{
+	AllCollector{},
+	InsCollector{},
+	SstoreValueInfo{},
+	CheckInfo{},
+	GasInfo{},
+	OpInOutInfo{},
+	AccountValueInfo{},
+	TransCollector{},
+	BlockCollector{},
+	ForkRulesCollector{},
+	CreateCollector{},
+	CallCollector{},
+	DecodedCall{},
+	DecodedArg{},
+	PrecompileCollector{},
+	ReentrancyCollector{},
+	NonceCollector{},
+	WouldBlockCollector{},
+	RevertCollector{},
+	AnomalyCollector{},
+	AggregateCollector{},
+	GasStatsCollector{},
+	TouchedCollector{},
+	PreStateCollector{},
+	DeepCallsCollector{},
+	SlowPluginCollector{},
+	ShedCollector{},
+	PostStateCollector{},
+	RedeployCollector{},
+	AccountProofCollector{},
+	StorageProofCollector{},
+}
+
+// Schemas returns the schema of every collector type, derived from the Go
+// definitions so it can not drift from what is actually emitted.
+func Schemas() []TypeSchema {
+	schemas := make([]TypeSchema, 0, len(schemaTypes))
+	for _, value := range schemaTypes {
+		schemas = append(schemas, schemaOf(reflect.TypeOf(value)))
+	}
+	return schemas
+}
+
+func schemaOf(typ reflect.Type) TypeSchema {
+	schema := TypeSchema{Name: typ.Name(), Version: SchemaVersion}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Fields = append(schema.Fields, FieldSchema{Name: field.Name, JSON: name, Type: typeName(field.Type)})
+	}
+	return schema
+}
+
+func typeName(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return "bytes"
+		}
+		return "[]" + typeName(typ.Elem())
+	case reflect.Map:
+		return "map[" + typeName(typ.Key()) + "]" + typeName(typ.Elem())
+	case reflect.Ptr:
+		return typeName(typ.Elem())
+	}
+	return typ.Name()
+}
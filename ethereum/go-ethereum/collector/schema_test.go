@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSchemasListEveryCollector(t *testing.T) {
+	want := []string{
+		"AllCollector", "InsCollector", "SstoreValueInfo", "CheckInfo", "GasInfo", "OpInOutInfo",
+		"AccountValueInfo", "TransCollector", "BlockCollector", "ForkRulesCollector", "CreateCollector", "CallCollector",
+		"DecodedCall", "DecodedArg",
+		"PrecompileCollector", "ReentrancyCollector", "NonceCollector",
+		"WouldBlockCollector", "RevertCollector", "AnomalyCollector", "AggregateCollector", "GasStatsCollector", "TouchedCollector",
+		"PreStateCollector", "DeepCallsCollector", "SlowPluginCollector", "ShedCollector",
+		"PostStateCollector", "RedeployCollector",
+		"AccountProofCollector", "StorageProofCollector",
+	}
+	have := make(map[string]TypeSchema)
+	for _, schema := range Schemas() {
+		have[schema.Name] = schema
+	}
+	for _, name := range want {
+		schema, ok := have[name]
+		if !ok {
+			t.Errorf("schema for %s missing", name)
+			continue
+		}
+		if len(schema.Fields) == 0 {
+			t.Errorf("schema for %s has no fields", name)
+		}
+		if schema.Version != SchemaVersion {
+			t.Errorf("schema for %s has version %d, want %d", name, schema.Version, SchemaVersion)
+		}
+		seen := make(map[string]bool)
+		for _, field := range schema.Fields {
+			if field.Type == "" {
+				t.Errorf("%s.%s has no type", name, field.Name)
+			}
+			if seen[field.JSON] {
+				t.Errorf("%s has duplicate json key %q", name, field.JSON)
+			}
+			seen[field.JSON] = true
+		}
+	}
+}
+
+func TestSchemaMatchesEncoding(t *testing.T) {
+	enc, err := json.Marshal(NewTransCollector())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys map[string]interface{}
+	if err := json.Unmarshal(enc, &keys); err != nil {
+		t.Fatal(err)
+	}
+	for _, schema := range Schemas() {
+		if schema.Name != "TransCollector" {
+			continue
+		}
+		for _, field := range schema.Fields {
+			if _, ok := keys[field.JSON]; !ok {
+				t.Errorf("field %s encoded without key %q", field.Name, field.JSON)
+			}
+		}
+	}
+}
@@ -411,6 +411,11 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			triedb.SaveCachePeriodically(bc.cacheConfig.TrieCleanJournal, bc.cacheConfig.TrieCleanRejournal, bc.quit)
 		}()
 	}
+	//add
+	if chainConfig.TransferDataPlg != nil {
+		chainConfig.TransferDataPlg.SetStateAt(bc.pluginStateAt)
+	}
+	//add
 	return bc, nil
 }
 
@@ -0,0 +1,49 @@
+package core
+
+//add new file
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// minExtraTag is the shortest printable run of the extradata taken as a tag.
+const minExtraTag = 3
+
+// pluginExtraInfo decodes the extradata of header for handle_BLOCK_INFO. On a
+// clique chain it returns the signer sealing the block, recovered by the
+// engine from the seal in the extradata. Otherwise it returns the printable
+// runs of the extradata, which is where PoW pools and clients put their tag.
+func (p *StateProcessor) pluginExtraInfo(header *types.Header) (signer string, tag string) {
+	if p.config.Clique != nil {
+		author, err := p.engine.Author(header)
+		if err != nil {
+			log.Debug("Plugin could not recover the clique signer", "number", header.Number, "err", err)
+			return "", ""
+		}
+		return author.String(), ""
+	}
+	return "", extraTag(header.Extra)
+}
+
+// extraTag joins the runs of at least minExtraTag printable ASCII characters
+// of extra, e.g. the pool name or the RLP encoded client version.
+func extraTag(extra []byte) string {
+	var runs []string
+	start := -1
+	for i := 0; i <= len(extra); i++ {
+		if i < len(extra) && extra[i] >= 0x20 && extra[i] < 0x7f {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minExtraTag {
+			runs = append(runs, strings.TrimSpace(string(extra[start:i])))
+		}
+		start = -1
+	}
+	return strings.Join(runs, " ")
+}
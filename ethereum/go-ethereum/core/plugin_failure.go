@@ -0,0 +1,28 @@
+package core
+
+//add new file
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// pluginFailureReason classifies the error a transaction failed with. The
+// execution errors of the EVM come from result.Err and are classified by
+// vm.PluginFailureReason; an error returned by ApplyMessage itself means the
+// transaction could not be applied at all, the common causes of that have
+// their own category.
+func pluginFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrNonceTooLow), errors.Is(err, ErrNonceTooHigh), errors.Is(err, ErrNonceMax):
+		return "invalid_nonce"
+	case errors.Is(err, ErrIntrinsicGas):
+		return "intrinsic_gas"
+	case errors.Is(err, ErrInsufficientFunds), errors.Is(err, ErrInsufficientFundsForTransfer):
+		return "insufficient_funds"
+	case errors.Is(err, ErrGasLimitReached):
+		return "block_gas_limit"
+	}
+	return vm.PluginFailureReason(err)
+}
@@ -0,0 +1,56 @@
+package core
+
+//add new file
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zhidandeng/collector"
+)
+
+// pluginGasStats summarises the effective gas prices the transactions of a
+// block paid, receipts holding the gas each one used. The percentiles take
+// the nearest rank, so they are always a price one of the transactions paid.
+// The prices are left empty for a block without transactions.
+func pluginGasStats(txs types.Transactions, receipts types.Receipts, baseFee *big.Int) collector.GasStatsCollector {
+	stats := collector.NewGasStatsCollector()
+	stats.Transactions = uint64(len(txs))
+	if len(txs) == 0 {
+		return *stats
+	}
+	prices := make([]*big.Int, len(txs))
+	fees := new(big.Int)
+	for i, tx := range txs {
+		prices[i] = effectiveGasPrice(tx, baseFee)
+		fees.Add(fees, new(big.Int).Mul(prices[i], new(big.Int).SetUint64(receipts[i].GasUsed)))
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Cmp(prices[j]) < 0 })
+
+	stats.MinGasPrice = prices[0].String()
+	stats.MedianGasPrice = nearestRank(prices, 50).String()
+	stats.P90GasPrice = nearestRank(prices, 90).String()
+	stats.MaxGasPrice = prices[len(prices)-1].String()
+	stats.TotalFees = fees.String()
+	return *stats
+}
+
+// effectiveGasPrice is the price per gas tx pays, the way AsMessage derives
+// it once the base fee is in force.
+func effectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return new(big.Int).Set(tx.GasPrice())
+	}
+	return math.BigMin(new(big.Int).Add(tx.GasTipCap(), baseFee), tx.GasFeeCap())
+}
+
+// nearestRank returns the p-th percentile of the ascending sorted values.
+func nearestRank(sorted []*big.Int, p int) *big.Int {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,23 @@
+package core
+
+//add new file
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/cmd/pluginManage"
+)
+
+// pluginStateAt opens the state of canonical block number for the plugins.
+// A missing block or pruned state yields pluginManage.ErrStateUnavailable.
+func (bc *BlockChain) pluginStateAt(number uint64) (pluginManage.StateReader, error) {
+	header := bc.GetHeaderByNumber(number)
+	if header == nil {
+		return nil, fmt.Errorf("%w: no canonical block %d", pluginManage.ErrStateUnavailable, number)
+	}
+	statedb, err := bc.StateAt(header.Root)
+	if err != nil {
+		return nil, fmt.Errorf("%w: block %d: %v", pluginManage.ErrStateUnavailable, number, err)
+	}
+	return statedb, nil
+}
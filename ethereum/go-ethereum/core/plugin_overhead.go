@@ -0,0 +1,169 @@
+package core
+
+//add new file
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// OpcodeOverhead is the dispatch cost of one plugin opcode.
+type OpcodeOverhead struct {
+	Opcode string
+	Events uint64
+	Time   time.Duration
+}
+
+// PluginOverhead compares processing the same blocks with and without the
+// plugin subsystem.
+type PluginOverhead struct {
+	Blocks  int
+	Rounds  int
+	GasUsed uint64 // gas of one pass over the blocks
+
+	BaseTime, PluginTime     time.Duration
+	BaseAllocs, PluginAllocs uint64
+	BaseBytes, PluginBytes   uint64
+
+	// Opcodes breaks the dispatch share of the overhead down by opcode,
+	// most expensive first. Filling the collectors inside the EVM is not
+	// attributed to an opcode and only shows up in Time.
+	Opcodes []OpcodeOverhead
+}
+
+// Time is the processing time the plugins add, per pass over the blocks.
+func (o *PluginOverhead) Time() time.Duration {
+	if o.PluginTime < o.BaseTime || o.Rounds == 0 {
+		return 0
+	}
+	return (o.PluginTime - o.BaseTime) / time.Duration(o.Rounds)
+}
+
+// Allocs is the number of heap allocations the plugins add, per pass.
+func (o *PluginOverhead) Allocs() uint64 {
+	if o.PluginAllocs < o.BaseAllocs || o.Rounds == 0 {
+		return 0
+	}
+	return (o.PluginAllocs - o.BaseAllocs) / uint64(o.Rounds)
+}
+
+// Bytes is the heap volume the plugins allocate, per pass.
+func (o *PluginOverhead) Bytes() uint64 {
+	if o.PluginBytes < o.BaseBytes || o.Rounds == 0 {
+		return 0
+	}
+	return (o.PluginBytes - o.BaseBytes) / uint64(o.Rounds)
+}
+
+// GasEquivalent expresses Time as the gas the node processes in the same
+// time without plugins.
+func (o *PluginOverhead) GasEquivalent() uint64 {
+	if o.BaseTime <= 0 {
+		return 0
+	}
+	base := o.BaseTime / time.Duration(o.Rounds)
+	return uint64(float64(o.GasUsed) * float64(o.Time()) / float64(base))
+}
+
+func (o *PluginOverhead) String() string {
+	report := fmt.Sprintf("blocks=%d rounds=%d gas=%d\n", o.Blocks, o.Rounds, o.GasUsed)
+	report += fmt.Sprintf("time   %v -> %v, overhead %v per pass (%d gas)\n",
+		o.BaseTime/time.Duration(o.Rounds), o.PluginTime/time.Duration(o.Rounds), o.Time(), o.GasEquivalent())
+	report += fmt.Sprintf("allocs overhead %d (%d bytes) per pass\n", o.Allocs(), o.Bytes())
+	for _, op := range o.Opcodes {
+		report += fmt.Sprintf("  %-24s events=%-8d dispatch=%v\n", op.Opcode, op.Events/uint64(o.Rounds), op.Time/time.Duration(o.Rounds))
+	}
+	return report
+}
+
+// MeasurePluginOverhead processes every block rounds times with the plugin
+// subsystem switched off and rounds times with it on, and reports the
+// difference. The blocks must extend the head of chain, which they are
+// inserted into afterwards with the plugins switched off.
+func MeasurePluginOverhead(chain *BlockChain, blocks []*types.Block, rounds int) (*PluginOverhead, error) {
+	manage := chain.Config().TransferDataPlg
+	if manage == nil {
+		return nil, fmt.Errorf("chain has no plugin manager")
+	}
+	if rounds < 1 {
+		rounds = 1
+	}
+	enabled := manage.Enabled()
+	defer manage.SetEnabled(enabled)
+
+	overhead := &PluginOverhead{Blocks: len(blocks), Rounds: rounds}
+	opcodes := make(map[string]*OpcodeOverhead)
+
+	// process runs block on its parent state rounds times and returns the
+	// elapsed time, allocations and allocated bytes.
+	process := func(block *types.Block, plugins bool) (time.Duration, uint64, uint64, error) {
+		parent := chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+		if parent == nil {
+			return 0, 0, 0, fmt.Errorf("missing parent of block %d", block.NumberU64())
+		}
+		manage.SetEnabled(plugins)
+		var elapsed time.Duration
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		for i := 0; i < rounds; i++ {
+			statedb, err := state.New(parent.Root(), chain.StateCache(), nil)
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			start := time.Now()
+			if _, _, _, err := chain.Processor().Process(block, statedb, vm.Config{}); err != nil {
+				return 0, 0, 0, err
+			}
+			elapsed += time.Since(start)
+		}
+		runtime.ReadMemStats(&after)
+		return elapsed, after.Mallocs - before.Mallocs, after.TotalAlloc - before.TotalAlloc, nil
+	}
+	for _, block := range blocks {
+		elapsed, allocs, bytes, err := process(block, false)
+		if err != nil {
+			return nil, err
+		}
+		overhead.BaseTime += elapsed
+		overhead.BaseAllocs += allocs
+		overhead.BaseBytes += bytes
+
+		manage.StartMeasure()
+		elapsed, allocs, bytes, err = process(block, true)
+		stats := manage.StopMeasure()
+		if err != nil {
+			return nil, err
+		}
+		overhead.PluginTime += elapsed
+		overhead.PluginAllocs += allocs
+		overhead.PluginBytes += bytes
+		for opcode, stat := range stats {
+			op, ok := opcodes[opcode]
+			if !ok {
+				op = &OpcodeOverhead{Opcode: opcode}
+				opcodes[opcode] = op
+			}
+			op.Events += stat.Events
+			op.Time += stat.Time
+		}
+		overhead.GasUsed += block.GasUsed()
+
+		manage.SetEnabled(false)
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			return nil, err
+		}
+	}
+	for _, op := range opcodes {
+		overhead.Opcodes = append(overhead.Opcodes, *op)
+	}
+	sort.Slice(overhead.Opcodes, func(i, j int) bool {
+		return overhead.Opcodes[i].Time > overhead.Opcodes[j].Time
+	})
+	return overhead, nil
+}
@@ -0,0 +1,36 @@
+package core
+
+//add new file
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/zhidandeng/collector"
+)
+
+// pluginPreState reads the sender and recipient of a transaction before it
+// runs, so consumers can work out what it changed without querying the state
+// themselves. The recipient is nil for a contract creation.
+func pluginPreState(db vm.StateDB, from common.Address, to *common.Address) *collector.PreStateCollector {
+	prestate := collector.NewPreStateCollector()
+	prestate.FromBalance = db.GetBalance(from).String()
+	prestate.FromNonce = db.GetNonce(from)
+	if to != nil {
+		prestate.ToBalance = db.GetBalance(*to).String()
+		prestate.ToHasCode = db.GetCodeSize(*to) > 0
+	}
+	return prestate
+}
+
+// pluginPostState reads the same accounts as pluginPreState once the
+// transaction ran.
+func pluginPostState(db vm.StateDB, from common.Address, to *common.Address) *collector.PostStateCollector {
+	poststate := collector.NewPostStateCollector()
+	poststate.FromBalance = db.GetBalance(from).String()
+	poststate.FromNonce = db.GetNonce(from)
+	if to != nil {
+		poststate.ToBalance = db.GetBalance(*to).String()
+		poststate.ToHasCode = db.GetCodeSize(*to) > 0
+	}
+	return poststate
+}
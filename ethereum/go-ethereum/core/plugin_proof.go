@@ -0,0 +1,63 @@
+package core
+
+//add new file
+
+import (
+	"github.com/ethereum/go-ethereum/cmd/pluginManage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/zhidandeng/collector"
+)
+
+// pluginAccountProofs proves accounts against root, the root statedb hashes
+// to once the block is finalised. An account the state does not hold gets a
+// proof of its absence.
+func pluginAccountProofs(statedb *state.StateDB, root common.Hash, accounts []pluginManage.ProofAccount) []collector.AccountProofCollector {
+	proofs := make([]collector.AccountProofCollector, 0, len(accounts))
+	for _, account := range accounts {
+		proof, err := statedb.GetProof(account.Address)
+		if err != nil {
+			log.Warn("Plugin account proof failed", "root", root, "address", account.Address, "err", err)
+			continue
+		}
+		accountproof := collector.AccountProofCollector{
+			Address:     account.Address.String(),
+			Balance:     statedb.GetBalance(account.Address).String(),
+			Nonce:       statedb.GetNonce(account.Address),
+			CodeHash:    statedb.GetCodeHash(account.Address).String(),
+			StorageHash: types.EmptyRootHash.String(),
+			Proof:       pluginProofNodes(proof),
+		}
+		storage := statedb.StorageTrie(account.Address)
+		if storage != nil {
+			accountproof.StorageHash = storage.Hash().String()
+		}
+		for _, slot := range account.Slots {
+			storageproof := collector.StorageProofCollector{
+				Key:   slot.String(),
+				Value: statedb.GetState(account.Address, slot).Big().String(),
+			}
+			if storage != nil {
+				if proof, err := statedb.GetStorageProof(account.Address, slot); err == nil {
+					storageproof.Proof = pluginProofNodes(proof)
+				} else {
+					log.Warn("Plugin storage proof failed", "root", root, "address", account.Address, "slot", slot, "err", err)
+				}
+			}
+			accountproof.Storage = append(accountproof.Storage, storageproof)
+		}
+		proofs = append(proofs, accountproof)
+	}
+	return proofs
+}
+
+func pluginProofNodes(proof [][]byte) []string {
+	nodes := make([]string, len(proof))
+	for i, node := range proof {
+		nodes[i] = hexutil.Encode(node)
+	}
+	return nodes
+}
@@ -0,0 +1,20 @@
+package core
+
+//add new file
+
+import (
+	"github.com/ethereum/go-ethereum/cmd/pluginManage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// pluginRecordDestroyed hands the contracts a transaction self-destructed to
+// the manager once its state is finalised. Contracts whose SELFDESTRUCT was
+// reverted along with their frame still exist and are left out.
+func pluginRecordDestroyed(manage *pluginManage.PluginManages, db vm.StateDB, destroyed map[string]string) {
+	for addr, codeHash := range destroyed {
+		if address := common.HexToAddress(addr); !db.Exist(address) {
+			manage.RecordDestroyed(address, common.HexToHash(codeHash))
+		}
+	}
+}
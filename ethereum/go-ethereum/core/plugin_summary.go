@@ -0,0 +1,39 @@
+package core
+
+//add new file
+
+import (
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zhidandeng/collector"
+)
+
+// pluginTxSummary assembles the handle_TX_SUMMARY payload of an executed
+// transaction, the fields its EXTERNALINFOSTART and EXTERNALINFOEND events
+// spread over in one flat record. Created is only set when a contract
+// creation succeeded.
+func pluginTxSummary(msg types.Message, tx *types.Transaction, receipt *types.Receipt, result *ExecutionResult) *collector.TransCollector {
+	summary := collector.NewTransCollector()
+	summary.Op = "handle_TX_SUMMARY"
+	summary.TxHash = tx.Hash().String()
+	summary.BlockNumber = receipt.BlockNumber.String()
+	summary.From = msg.From().String()
+	summary.Value = msg.Value().String()
+	summary.GasUsed = result.UsedGas
+	summary.GasPrice = msg.GasPrice().String()
+	summary.GasLimit = msg.Gas()
+	summary.Nonce = tx.Nonce()
+	summary.IsSuccess = !result.Failed()
+	summary.FailureReason = pluginFailureReason(result.Err)
+	summary.LogCount = len(receipt.Logs)
+	if msg.To() != nil {
+		summary.CallType = "CALL"
+		summary.To = msg.To().String()
+	} else {
+		summary.CallType = "CREATE"
+		summary.To = receipt.ContractAddress.String()
+		if summary.IsSuccess {
+			summary.Created = receipt.ContractAddress.String()
+		}
+	}
+	return summary
+}
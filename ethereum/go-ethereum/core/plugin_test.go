@@ -0,0 +1,2476 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/cmd/pluginManage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/zhidandeng/collector"
+)
+
+var (
+	pluginTestKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	pluginTestAddr   = crypto.PubkeyToAddress(pluginTestKey.PublicKey)
+)
+
+// pluginTestConfig returns a fresh London chain config carrying its own
+// plugin manager.
+func pluginTestConfig() *params.ChainConfig {
+	config := *params.TestChainConfig
+	config.TransferDataPlg = pluginManage.NewPluginManages()
+	return &config
+}
+
+// recordOpcodes registers an in-process monitor for every opcode on manage
+// and returns the slice the received events are appended to.
+func recordOpcodes(manage *pluginManage.PluginManages, opcodes ...string) *[]*collector.AllCollector {
+	var events []*collector.AllCollector
+	for _, opcode := range opcodes {
+		monitor := new(pluginManage.MonitorType)
+		monitor.SetPluginName("recorder")
+		monitor.SetOpcode(opcode)
+		monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+			events = append(events, data)
+			return 0x00, ""
+		})
+		manage.RegisterOpcode(opcode, monitor)
+	}
+	return &events
+}
+
+// generatePluginTestChain builds n blocks with gen on a fresh genesis that
+// funds pluginTestAddr, and returns a blockchain positioned at genesis
+// together with the generated blocks, ready to be imported through Process.
+func generatePluginTestChain(t testing.TB, config *params.ChainConfig, alloc GenesisAlloc, n int, gen func(int, *BlockGen)) (*BlockChain, []*types.Block) {
+	t.Helper()
+	if alloc == nil {
+		alloc = GenesisAlloc{}
+	}
+	alloc[pluginTestAddr] = GenesisAccount{Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))}
+
+	// Blocks are generated with a separate manager so that only the import
+	// reaches the manager under test.
+	manage := config.TransferDataPlg
+	config.TransferDataPlg = pluginManage.NewPluginManages()
+
+	gspec := &Genesis{Config: config, Alloc: alloc, BaseFee: big.NewInt(params.InitialBaseFee)}
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+	blocks, _ := GenerateChain(config, genesis, ethash.NewFaker(), db, n, gen)
+
+	config.TransferDataPlg = manage
+	db = rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	chain, err := NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	t.Cleanup(chain.Stop)
+	return chain, blocks
+}
+
+// pluginTestTx signs a transaction from pluginTestAddr.
+func pluginTestTx(config *params.ChainConfig, block *BlockGen, to *common.Address, value *big.Int, gas uint64, data []byte) *types.Transaction {
+	signer := types.LatestSigner(config)
+	var tx *types.Transaction
+	if to == nil {
+		tx = types.NewContractCreation(block.TxNonce(pluginTestAddr), value, gas, block.header.BaseFee, data)
+	} else {
+		tx = types.NewTransaction(block.TxNonce(pluginTestAddr), *to, value, gas, block.header.BaseFee, data)
+	}
+	tx, _ = types.SignTx(tx, signer, pluginTestKey)
+	return tx
+}
+
+func TestPluginEventCounters(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	opcodes := []string{"EXTERNALINFOSTART", "EXTERNALINFOEND", "handle_BLOCK_INFO", "TXSTART", "TXEND"}
+	recordOpcodes(manage, opcodes...)
+
+	txsPerBlock := []int{3, 1}
+	chain, blocks := generatePluginTestChain(t, config, nil, len(txsPerBlock), func(i int, b *BlockGen) {
+		to := common.Address{0xaa}
+		for j := 0; j < txsPerBlock[i]; j++ {
+			b.AddTx(pluginTestTx(config, b, &to, big.NewInt(1), params.TxGas, nil))
+		}
+	})
+	for i, block := range blocks {
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("block %d: import failed: %v", i, err)
+		}
+		for _, opcode := range opcodes {
+			want := uint64(txsPerBlock[i])
+			if opcode == "handle_BLOCK_INFO" {
+				want = 1
+			}
+			if have := manage.BlockEventCount(opcode); have != want {
+				t.Errorf("block %d: %s emitted %d times, want %d", i, opcode, have, want)
+			}
+		}
+	}
+	if have := manage.EventCount("TXEND"); have != 4 {
+		t.Errorf("cumulative TXEND count %d, want 4", have)
+	}
+	if have := manage.EventCount("handle_BLOCK_INFO"); have != 2 {
+		t.Errorf("cumulative handle_BLOCK_INFO count %d, want 2", have)
+	}
+}
+
+func TestPluginAddressFilter(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "TXSTART", "EXTERNALINFOSTART", "TXEND")
+
+	targets := []common.Address{{0xa1}, {0xa2}, {0xa3}}
+	manage.SetFilter(pluginManage.FilterConfig{Addresses: []common.Address{targets[1]}})
+
+	var watched common.Hash
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		for j := range targets {
+			tx := pluginTestTx(config, b, &targets[j], big.NewInt(1), params.TxGas, nil)
+			if j == 1 {
+				watched = tx.Hash()
+			}
+			b.AddTx(tx)
+		}
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	var ops []string
+	for _, event := range *events {
+		ops = append(ops, event.Option)
+		if event.Option == "EXTERNALINFOSTART" && event.TransInfo.TxHash != watched.String() {
+			t.Errorf("EXTERNALINFOSTART of filtered transaction %s delivered", event.TransInfo.TxHash)
+		}
+	}
+	if len(ops) != 3 {
+		t.Fatalf("delivered %v, want the three events of the watched transaction", ops)
+	}
+	if have := manage.BlockEventCount("TXSTART"); have != 3 {
+		t.Errorf("TXSTART emitted %d times, want 3", have)
+	}
+}
+
+// pluginCallCode returns contract code that calls each of targets in turn
+// with all remaining gas and no calldata.
+func pluginCallCode(targets ...common.Address) []byte {
+	var code []byte
+	for _, target := range targets {
+		// PUSH1 0 x5 (retSize, retOffset, inSize, inOffset, value)
+		code = append(code, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00)
+		code = append(code, 0x73) // PUSH20
+		code = append(code, target.Bytes()...)
+		code = append(code, 0x5a, 0xf1, 0x50) // GAS CALL POP
+	}
+	return append(code, 0x00) // STOP
+}
+
+func TestPluginBlockInfoFees(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "handle_BLOCK_INFO")
+
+	chain, blocks := generatePluginTestChain(t, config, nil, 2, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &common.Address{0xaa}, big.NewInt(1), params.TxGas, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != len(blocks) {
+		t.Fatalf("got %d block events, want %d", len(*events), len(blocks))
+	}
+	for i, event := range *events {
+		header := blocks[i].Header()
+		if event.BlockInfo.BaseFee != header.BaseFee.String() {
+			t.Errorf("block %d: base fee %q, want %v", i+1, event.BlockInfo.BaseFee, header.BaseFee)
+		}
+		if td := chain.GetTd(header.Hash(), header.Number.Uint64()); event.BlockInfo.TotalDifficulty != td.String() {
+			t.Errorf("block %d: total difficulty %q, want %v", i+1, event.BlockInfo.TotalDifficulty, td)
+		}
+	}
+}
+
+func TestPluginEventSequence(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "handle_BLOCK_INFO", "TXSTART", "EXTERNALINFOSTART", "EXTERNALINFOEND", "TXEND", "handle_BLOCK_END")
+
+	to := common.Address{0xda}
+	chain, blocks := generatePluginTestChain(t, config, nil, 2, func(i int, b *BlockGen) {
+		for j := 0; j <= i; j++ {
+			b.AddTx(pluginTestTx(config, b, &to, common.Big1, params.TxGas, nil))
+		}
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	// block info and end, four events per transaction
+	if want := 2*2 + 4*(1+2); len(*events) != want {
+		t.Fatalf("got %d events, want %d", len(*events), want)
+	}
+	var block, next uint64
+	for i, event := range *events {
+		if event.Option == "handle_BLOCK_INFO" {
+			block, next = block+1, 1
+		}
+		if event.Seq != next {
+			t.Fatalf("event %d (%s) of block %d has sequence number %d, want %d", i, event.Option, block, event.Seq, next)
+		}
+		next++
+	}
+}
+
+func TestPluginTraceID(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "handle_BLOCK_INFO", "TXSTART", "EXTERNALINFOSTART", "TRANS_CALL", "EXTERNALINFOEND", "TXEND", "handle_BLOCK_END")
+
+	caller, callee := common.Address{0xc0}, common.Address{0xc1}
+	alloc := GenesisAlloc{
+		caller: {Code: pluginCallCode(callee), Balance: common.Big0},
+		callee: {Code: []byte{0x00}, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 100000, nil))
+		b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 100000, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	// Every transaction starts with TXSTART, the block level events are not
+	// part of any.
+	var traces [][]string
+	for _, event := range *events {
+		switch event.Option {
+		case "handle_BLOCK_INFO", "handle_BLOCK_END":
+			if event.TraceID != "" {
+				t.Errorf("%s carries trace id %s", event.Option, event.TraceID)
+			}
+			continue
+		case "TXSTART":
+			traces = append(traces, nil)
+		}
+		traces[len(traces)-1] = append(traces[len(traces)-1], event.TraceID)
+	}
+	txs := blocks[0].Transactions()
+	if len(traces) != len(txs) {
+		t.Fatalf("got events of %d transactions, want %d", len(traces), len(txs))
+	}
+	for i, trace := range traces {
+		want := pluginManage.TraceID(blocks[0].NumberU64(), txs[i].Hash())
+		if len(trace) != 5 {
+			t.Errorf("transaction %d: got %d events, want 5", i, len(trace))
+		}
+		for j, id := range trace {
+			if id != want {
+				t.Errorf("transaction %d event %d: trace id %q, want %s", i, j, id, want)
+			}
+		}
+	}
+	if traces[0][0] == traces[1][0] {
+		t.Errorf("transactions share trace id %s", traces[0][0])
+	}
+}
+
+func TestPluginReplayVerification(t *testing.T) {
+	for _, wallClock := range []bool{false, true} {
+		config := pluginTestConfig()
+		live := pluginManage.NewEventRecorder(wallClock)
+		config.TransferDataPlg.AddExporter(live)
+
+		caller, callee := common.Address{0xdc}, common.Address{0xdd}
+		alloc := GenesisAlloc{
+			caller: {Code: pluginCallCode(callee), Balance: common.Big0},
+			callee: {Code: []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00}, Balance: common.Big0}, // SSTORE(0, 1)
+		}
+		chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+			b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 200000, nil))
+			b.AddTx(pluginTestTx(config, b, &callee, common.Big1, 200000, nil))
+		})
+		if _, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("import failed: %v", err)
+		}
+		if len(live.Events(1)) == 0 {
+			t.Fatal("nothing recorded during the import")
+		}
+		err := chain.VerifyPluginReplay(blocks[0], live)
+		if !wallClock {
+			if err != nil {
+				t.Errorf("replay of a deterministic block diverged: %v", err)
+			}
+			continue
+		}
+		// execution durations are measured anew by the replay
+		var divergence *pluginManage.StreamDivergence
+		if !errors.As(err, &divergence) {
+			t.Fatalf("replay compared with wall-clock fields returned %v, want a divergence", err)
+		}
+		if !bytes.Contains(divergence.Live, []byte(`"EXTERNALINFOEND"`)) {
+			t.Errorf("first divergence at event %d is not an EXTERNALINFOEND: %s", divergence.Index, divergence.Live)
+		}
+	}
+}
+
+func TestPluginForkRules(t *testing.T) {
+	config := pluginTestConfig()
+	config.ShanghaiBlock = big.NewInt(2)
+	events := recordOpcodes(config.TransferDataPlg, "handle_FORK_RULES")
+
+	chain, blocks := generatePluginTestChain(t, config, nil, 3, func(i int, b *BlockGen) {})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != len(blocks) {
+		t.Fatalf("got %d fork rules events, want %d", len(*events), len(blocks))
+	}
+	for i, event := range *events {
+		rules := event.BlockInfo.ForkRules
+		if event.BlockInfo.Number != blocks[i].Number().String() {
+			t.Errorf("event %d for block %s", i, event.BlockInfo.Number)
+		}
+		if !rules.Byzantium || !rules.Berlin || !rules.London || rules.Cancun {
+			t.Errorf("block %d: rules %+v, want Byzantium to London active and Cancun not", i+1, rules)
+		}
+		if want := i+1 >= 2; rules.Shanghai != want {
+			t.Errorf("block %d: Shanghai %v, want %v", i+1, rules.Shanghai, want)
+		}
+	}
+}
+
+func TestPluginBlockGasStats(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "handle_BLOCK_GAS_STATS")
+
+	// legacy transactions paying the base fee plus a tip of 1 to 10 gwei, out
+	// of order, followed by an empty block
+	var baseFee *big.Int
+	recipient := common.Address{0xd9}
+	chain, blocks := generatePluginTestChain(t, config, nil, 2, func(i int, b *BlockGen) {
+		if i > 0 {
+			return
+		}
+		baseFee = b.header.BaseFee
+		for _, tip := range []int64{7, 3, 10, 1, 5, 9, 2, 8, 4, 6} {
+			price := new(big.Int).Add(baseFee, big.NewInt(tip*params.GWei))
+			tx := types.NewTransaction(b.TxNonce(pluginTestAddr), recipient, common.Big0, params.TxGas, price, nil)
+			tx, _ = types.SignTx(tx, types.LatestSigner(config), pluginTestKey)
+			b.AddTx(tx)
+		}
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 2 {
+		t.Fatalf("got %d gas stats events, want 2", len(*events))
+	}
+	price := func(tip int64) string {
+		return new(big.Int).Add(baseFee, big.NewInt(tip*params.GWei)).String()
+	}
+	fees := new(big.Int).Mul(new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(10)), big.NewInt(55*params.GWei)), big.NewInt(int64(params.TxGas)))
+	want := collector.GasStatsCollector{
+		Transactions:   10,
+		MinGasPrice:    price(1),
+		MedianGasPrice: price(5),
+		P90GasPrice:    price(9),
+		MaxGasPrice:    price(10),
+		TotalFees:      fees.String(),
+	}
+	if stats := (*events)[0].BlockInfo.GasStats; stats != want {
+		t.Errorf("block 1 gas stats %+v, want %+v", stats, want)
+	}
+	if stats := (*events)[1].BlockInfo.GasStats; stats != (collector.GasStatsCollector{TotalFees: "0"}) {
+		t.Errorf("empty block gas stats %+v, want no prices and no fees", stats)
+	}
+}
+
+func TestPluginBlockExtraInfo(t *testing.T) {
+	// A clique header sealed by pluginTestKey.
+	config := pluginTestConfig()
+	config.Clique = &params.CliqueConfig{Period: 15, Epoch: 30000}
+	processor := NewStateProcessor(config, nil, clique.New(config.Clique, rawdb.NewMemoryDatabase()))
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(2), Extra: make([]byte, 32+crypto.SignatureLength)}
+	seal, err := crypto.Sign(clique.SealHash(header).Bytes(), pluginTestKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(header.Extra[32:], seal)
+	if signer, tag := processor.pluginExtraInfo(header); signer != pluginTestAddr.String() || tag != "" {
+		t.Errorf("clique header decoded to signer %s tag %q, want signer %s", signer, tag, pluginTestAddr)
+	}
+
+	// PoW extradata only yields its printable text.
+	processor = NewStateProcessor(pluginTestConfig(), nil, ethash.NewFaker())
+	for _, test := range []struct {
+		extra []byte
+		tag   string
+	}{
+		{[]byte("\xd8\x83\x01\x0a\x11\x84geth\x88go1.17.2\x85linux"), "geth go1.17.2 linux"},
+		{[]byte("ethermine-eu1"), "ethermine-eu1"},
+		{[]byte{0x01, 0x02, 0xfe, 'a', 'b'}, ""},
+		{nil, ""},
+	} {
+		header := &types.Header{Number: big.NewInt(1), Extra: test.extra}
+		if signer, tag := processor.pluginExtraInfo(header); signer != "" || tag != test.tag {
+			t.Errorf("extradata %x decoded to signer %q tag %q, want tag %q", test.extra, signer, tag, test.tag)
+		}
+	}
+}
+
+func TestPluginBlockRange(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+
+	var (
+		numbers  []string
+		txstarts int
+	)
+	for _, opcode := range []string{"handle_BLOCK_INFO", "TXSTART"} {
+		monitor := new(pluginManage.MonitorType)
+		monitor.SetPluginName("backfill")
+		monitor.SetBlockRange(2, 3)
+		monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+			if data.Option == "TXSTART" {
+				txstarts++
+			} else {
+				numbers = append(numbers, data.BlockInfo.Number)
+			}
+			return 0x00, ""
+		})
+		manage.RegisterOpcode(opcode, monitor)
+	}
+	chain, blocks := generatePluginTestChain(t, config, nil, 4, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &common.Address{0xaa}, big.NewInt(1), params.TxGas, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if strings.Join(numbers, ",") != "2,3" || txstarts != 2 {
+		t.Fatalf("got blocks %v with %d transactions, want blocks 2 and 3 with 2", numbers, txstarts)
+	}
+	if manage.GetOpcodeRegister("TXSTART") {
+		t.Error("plugin still subscribed after its block range")
+	}
+}
+
+func TestPluginCallFilter(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "TXSTART", "TRANS_CALL", "TXEND")
+
+	var (
+		caller  = common.Address{0xc0}
+		allowed = common.Address{0xc1}
+		ignored = common.Address{0xc2}
+	)
+	manage.SetFilter(pluginManage.FilterConfig{CallAddresses: []common.Address{allowed}})
+
+	alloc := GenesisAlloc{
+		caller:  {Code: pluginCallCode(ignored, allowed, ignored), Balance: common.Big0},
+		allowed: {Code: []byte{0x00}, Balance: common.Big0},
+		ignored: {Code: []byte{0x00}, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 200000, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	var ops []string
+	for _, event := range *events {
+		ops = append(ops, event.Option)
+		if event.Option == "TRANS_CALL" && event.TransInfo.To != allowed.String() {
+			t.Errorf("call to non-allowed contract %s delivered", event.TransInfo.To)
+		}
+	}
+	if want := []string{"TXSTART", "TRANS_CALL", "TXEND"}; strings.Join(ops, ",") != strings.Join(want, ",") {
+		t.Errorf("delivered %v, want %v", ops, want)
+	}
+	if have := manage.BlockEventCount("TRANS_CALL"); have != 3 {
+		t.Errorf("TRANS_CALL emitted %d times, want 3", have)
+	}
+}
+
+func TestPluginPrecompile(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "handle_PRECOMPILE", "TRANS_CALL")
+
+	// ecrecover input recovering pluginTestAddr from a signature over hash.
+	hash := crypto.Keccak256([]byte("noda"))
+	sig, err := crypto.Sign(hash, pluginTestKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := make([]byte, 128)
+	copy(input, hash)
+	input[63] = sig[64] + 27
+	copy(input[64:], sig[:64])
+
+	ecrecover := common.BytesToAddress([]byte{1})
+	caller := common.Address{0xc0}
+	alloc := GenesisAlloc{caller: {Code: pluginCallCode(ecrecover), Balance: common.Big0}}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &ecrecover, common.Big0, 100000, input))
+		b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 100000, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	var precompiles []collector.TransCollector
+	for _, event := range *events {
+		switch event.Option {
+		case "handle_PRECOMPILE":
+			precompiles = append(precompiles, event.TransInfo)
+		case "TRANS_CALL":
+			if info := event.TransInfo.CallInfo; !info.IsPrecompile || info.PrecompileName != "ecrecover" {
+				t.Errorf("TRANS_CALL to ecrecover tagged %v/%q", info.IsPrecompile, info.PrecompileName)
+			}
+		}
+	}
+	if len(precompiles) != 2 {
+		t.Fatalf("got %d precompile events, want 2", len(precompiles))
+	}
+	direct := precompiles[0]
+	if direct.From != pluginTestAddr.String() || direct.To != ecrecover.String() || !direct.IsSuccess {
+		t.Errorf("direct call reported as %s -> %s, success %v", direct.From, direct.To, direct.IsSuccess)
+	}
+	if info := direct.PrecompileInfo; info.Name != "ecrecover" || info.GasCost != params.EcrecoverGas {
+		t.Errorf("precompile %q cost %d, want ecrecover cost %d", info.Name, info.GasCost, params.EcrecoverGas)
+	}
+	if have := common.BytesToAddress(direct.PrecompileInfo.Output); have != pluginTestAddr {
+		t.Errorf("recovered %s, want %s", have, pluginTestAddr)
+	}
+	if !bytes.Equal(direct.PrecompileInfo.Input, input) {
+		t.Errorf("input %x, want %x", direct.PrecompileInfo.Input, input)
+	}
+	if internal := precompiles[1]; internal.From != caller.String() || internal.CallType != "CALL" || !internal.CallInfo.IsPrecompile {
+		t.Errorf("internal call reported as %+v", internal)
+	}
+}
+
+func TestPluginCreateCodeSize(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "EXTERNALINFOEND")
+
+	// initCode returns size zero bytes as runtime code.
+	initCode := func(size int) []byte {
+		return []byte{0x61, byte(size >> 8), byte(size), 0x60, 0x00, 0xf3} // PUSH2 size PUSH1 0 RETURN
+	}
+	sizes := []int{100, params.MaxCodeSize + 1}
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		for _, size := range sizes {
+			b.AddTx(pluginTestTx(config, b, nil, common.Big0, 1000000, initCode(size)))
+		}
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != len(sizes) {
+		t.Fatalf("got %d EXTERNALINFOEND events, want %d", len(*events), len(sizes))
+	}
+	for i, event := range *events {
+		info := event.TransInfo.CreateInfo
+		if info.ContractRuntimeSize != sizes[i] || len(info.ContractRuntimeCode) != sizes[i] {
+			t.Errorf("deployment %d: reported size %d, code %d bytes, want %d", i, info.ContractRuntimeSize, len(info.ContractRuntimeCode), sizes[i])
+		}
+		oversize := sizes[i] > params.MaxCodeSize
+		if info.ExceedsCodeSizeLimit != oversize {
+			t.Errorf("deployment %d: size limit flag %v, want %v", i, info.ExceedsCodeSizeLimit, oversize)
+		}
+		if event.TransInfo.IsSuccess == oversize {
+			t.Errorf("deployment %d: success %v with size %d", i, event.TransInfo.IsSuccess, sizes[i])
+		}
+	}
+}
+
+func TestPluginCreateDeployer(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "EXTERNALINFOEND", "TRANS_CREATE")
+
+	// The factory runs CREATE with empty init code.
+	factory := common.Address{0xfa}
+	alloc := GenesisAlloc{
+		factory: {Code: []byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0xf0, 0x00}, Nonce: 1, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &factory, common.Big0, 100000, nil))
+		b.AddTx(pluginTestTx(config, b, nil, common.Big0, 100000, []byte{0x00}))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	want := []struct {
+		op       string
+		deployer common.Address
+		nonce    uint64
+	}{
+		{"TRANS_CREATE", factory, 1},
+		{"EXTERNALINFOEND", pluginTestAddr, 1},
+	}
+	var creates []*collector.AllCollector
+	for _, event := range *events {
+		if event.TransInfo.CreateInfo.ContractAddr != "" {
+			creates = append(creates, event)
+		}
+	}
+	if len(creates) != len(want) {
+		t.Fatalf("got %d creations, want %d", len(creates), len(want))
+	}
+	for i, event := range creates {
+		info := event.TransInfo.CreateInfo
+		if event.Option != want[i].op || info.Deployer != want[i].deployer.String() || info.DeployerNonce != want[i].nonce {
+			t.Errorf("creation %d: %s by %s with nonce %d, want %s by %s with nonce %d", i, event.Option, info.Deployer, info.DeployerNonce, want[i].op, want[i].deployer, want[i].nonce)
+		}
+		if addr := crypto.CreateAddress(want[i].deployer, want[i].nonce); info.ContractAddr != addr.String() {
+			t.Errorf("creation %d: address %s, want %s", i, info.ContractAddr, addr)
+		}
+	}
+}
+
+func TestPluginCreateAttempts(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "TRANS_CREATE", "EXTERNALINFOEND")
+
+	// createCode runs CREATE with the five bytes of init code.
+	createCode := func(init []byte) []byte {
+		code := append([]byte{0x64}, init...)
+		return append(code, 0x60, 0x00, 0x52, 0x60, 0x05, 0x60, 0x1b, 0x60, 0x00, 0xf0, 0x50)
+	}
+	var (
+		reverting = []byte{0x60, 0x00, 0x60, 0x00, 0xfd} // REVERT(0, 0)
+		returning = []byte{0x60, 0x00, 0x60, 0x00, 0xf3} // RETURN(0, 0)
+	)
+	factory := common.Address{0xfb}
+	code := append(createCode(reverting), createCode(returning)...)
+	alloc := GenesisAlloc{
+		factory: {Code: append(code, 0x00), Nonce: 1, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &factory, common.Big0, 200000, nil))
+		// a creating transaction whose constructor fails to create
+		b.AddTx(pluginTestTx(config, b, nil, common.Big0, 200000, append(createCode(reverting), 0x00)))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	created := crypto.CreateAddress(pluginTestAddr, 1)
+	want := []struct {
+		op      string
+		to      string // EXTERNALINFOEND only names created contracts
+		attempt int
+		success bool
+		reason  string
+	}{
+		{"TRANS_CREATE", crypto.CreateAddress(factory, 1).String(), 1, false, "reverted"},
+		{"TRANS_CREATE", crypto.CreateAddress(factory, 2).String(), 2, true, ""},
+		{"EXTERNALINFOEND", "", 0, true, ""},
+		{"TRANS_CREATE", crypto.CreateAddress(created, 1).String(), 2, false, "reverted"},
+		{"EXTERNALINFOEND", created.String(), 1, true, ""},
+	}
+	if len(*events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(*events), len(want))
+	}
+	for i, event := range *events {
+		info := event.TransInfo
+		if event.Option != want[i].op || info.To != want[i].to || info.CreateInfo.Attempt != want[i].attempt {
+			t.Errorf("event %d: %s to %q, attempt %d, want %s to %q, attempt %d", i, event.Option, info.To, info.CreateInfo.Attempt, want[i].op, want[i].to, want[i].attempt)
+		}
+		if info.IsSuccess != want[i].success || info.FailureReason != want[i].reason {
+			t.Errorf("event %d: success %t %q, want %t %q", i, info.IsSuccess, info.FailureReason, want[i].success, want[i].reason)
+		}
+	}
+}
+
+func TestPluginReentrancy(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "handle_REENTRANCY")
+
+	var (
+		reentrant = common.Address{0xd0}
+		caller    = common.Address{0xd1}
+		callee    = common.Address{0xd2}
+	)
+	alloc := GenesisAlloc{
+		// Calls itself once with one byte of calldata, then stops.
+		reentrant: {Code: []byte{
+			0x36, 0x60, 0x12, 0x57, // CALLDATASIZE PUSH1 0x12 JUMPI
+			0x60, 0x00, 0x60, 0x00, 0x60, 0x01, 0x60, 0x00, 0x60, 0x00, // retSize retOffset inSize=1 inOffset value
+			0x30, 0x5a, 0xf1, 0x50, // ADDRESS GAS CALL POP
+			0x5b, 0x00, // JUMPDEST STOP
+		}, Balance: common.Big0},
+		caller: {Code: pluginCallCode(callee, callee), Balance: common.Big0},
+		callee: {Code: []byte{0x00}, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 200000, nil))
+		b.AddTx(pluginTestTx(config, b, &reentrant, common.Big0, 200000, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	// Only the second transaction reenters; repeated sequential calls to
+	// callee in the first one do not count.
+	if len(*events) != 1 {
+		t.Fatalf("got %d reentrancy events, want 1", len(*events))
+	}
+	info := (*events)[0].TransInfo
+	if info.ReentrancyInfo.Address != reentrant.String() || info.From != reentrant.String() || info.CallType != "CALL" {
+		t.Errorf("reentrancy reported as %s -> %s (%s)", info.From, info.ReentrancyInfo.Address, info.CallType)
+	}
+	if depths := info.ReentrancyInfo.Depths; len(depths) != 2 || depths[0] != 1 || depths[1] != 2 {
+		t.Errorf("reentrancy depths %v, want [1 2]", depths)
+	}
+}
+
+func TestPluginCallDepth(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "EXTERNALINFOSTART", "TRANS_CALL", "EXTERNALINFOEND")
+
+	var (
+		outer  = common.Address{0xe1}
+		middle = common.Address{0xe2}
+		inner  = common.Address{0xe3}
+	)
+	alloc := GenesisAlloc{
+		outer:  {Code: pluginCallCode(middle, middle), Balance: common.Big0},
+		middle: {Code: pluginCallCode(inner), Balance: common.Big0},
+		inner:  {Code: []byte{0x00}, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &outer, common.Big0, 200000, nil))
+		b.AddTx(pluginTestTx(config, b, nil, common.Big0, 200000, []byte{0x00}))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	// Calls are reported when they return, innermost first.
+	want := []struct {
+		op    string
+		to    common.Address
+		layer int
+	}{
+		{"EXTERNALINFOSTART", outer, 1},
+		{"TRANS_CALL", inner, 3},
+		{"TRANS_CALL", middle, 2},
+		{"TRANS_CALL", inner, 3},
+		{"TRANS_CALL", middle, 2},
+		{"EXTERNALINFOEND", outer, 1},
+		{"EXTERNALINFOSTART", common.Address{}, 1},
+		{"EXTERNALINFOEND", common.Address{}, 1},
+	}
+	if len(*events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(*events), len(want))
+	}
+	for i, event := range *events {
+		info := event.TransInfo
+		if event.Option != want[i].op || info.CallLayer != want[i].layer {
+			t.Errorf("event %d: %s at layer %d, want %s at layer %d", i, event.Option, info.CallLayer, want[i].op, want[i].layer)
+		}
+		if want[i].op == "TRANS_CALL" && info.To != want[i].to.String() {
+			t.Errorf("event %d: call to %s, want %s", i, info.To, want[i].to)
+		}
+	}
+}
+
+func TestPluginMaxCallDepth(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "TRANS_CALL", "handle_DEEP_CALLS", "TXEND")
+	manage.SetMaxCallDepth(2)
+
+	// A chain of contracts each calling the next one, the last one stops.
+	chain := []common.Address{{0xe1}, {0xe2}, {0xe3}, {0xe4}, {0xe5}}
+	alloc := GenesisAlloc{chain[len(chain)-1]: {Code: []byte{0x00}, Balance: common.Big0}}
+	for i := 0; i < len(chain)-1; i++ {
+		alloc[chain[i]] = GenesisAccount{Code: pluginCallCode(chain[i+1]), Balance: common.Big0}
+	}
+	bc, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &chain[0], common.Big0, 500000, nil))
+	})
+	if _, err := bc.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	// Only the call into layer 2 is emitted, the three below are summarized.
+	if len(*events) != 3 {
+		t.Fatalf("got %d events, want 3", len(*events))
+	}
+	call, summary := (*events)[0], (*events)[1]
+	if call.Option != "TRANS_CALL" || call.TransInfo.To != chain[1].String() || call.TransInfo.CallLayer != 2 {
+		t.Errorf("first event %s to %s at layer %d, want the call to %s at layer 2", call.Option, call.TransInfo.To, call.TransInfo.CallLayer, chain[1])
+	}
+	if summary.Option != "handle_DEEP_CALLS" || (*events)[2].Option != "TXEND" {
+		t.Fatalf("got %s and %s after the call, want handle_DEEP_CALLS and TXEND", summary.Option, (*events)[2].Option)
+	}
+	deep := summary.TransInfo.DeepCalls
+	if deep.MaxDepth != 2 || deep.Calls != 3 || deep.Deepest != 5 {
+		t.Errorf("summary %+v, want 3 calls down to layer 5 below depth 2", deep)
+	}
+	if deep.GasUsed == 0 || deep.GasUsed >= call.TransInfo.GasUsedSubtree {
+		t.Errorf("summarized calls used %d gas, the call above them %d", deep.GasUsed, call.TransInfo.GasUsedSubtree)
+	}
+	if summary.TransInfo.TxHash != blocks[0].Transactions()[0].Hash().String() {
+		t.Errorf("summary of transaction %s", summary.TransInfo.TxHash)
+	}
+}
+
+func TestPluginInternalCallIO(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "TRANS_CALL")
+
+	caller, callee := common.Address{0xc3}, common.Address{0xc4}
+	// MSTORE(0, 0xdeadbeef << 224), then CALL(gas, callee, 0, 0, 4, 0, 32):
+	// the return data lands on the calldata it was called with.
+	code := []byte{0x63, 0xde, 0xad, 0xbe, 0xef, 0x60, 0xe0, 0x1b, 0x60, 0x00, 0x52,
+		0x60, 0x20, 0x60, 0x00, 0x60, 0x04, 0x60, 0x00, 0x60, 0x00, 0x73}
+	code = append(code, callee.Bytes()...)
+	code = append(code, 0x5a, 0xf1, 0x50, 0x00)
+	alloc := GenesisAlloc{
+		caller: {Code: code, Balance: common.Big0},
+		// MSTORE(0, 42) RETURN(0, 32)
+		callee: {Code: []byte{0x60, 0x2a, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 100000, []byte{0x01, 0x02}))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 1 {
+		t.Fatalf("got %d TRANS_CALL events, want 1", len(*events))
+	}
+	input, output := []byte{0xde, 0xad, 0xbe, 0xef}, common.BigToHash(big.NewInt(42)).Bytes()
+	call := (*events)[0].TransInfo.CallInfo
+	if !bytes.Equal(call.Input, input) || call.InputSize != len(input) {
+		t.Errorf("internal call input %x (%d bytes), want %x", call.Input, call.InputSize, input)
+	}
+	if !bytes.Equal(call.Output, output) || call.OutputSize != len(output) {
+		t.Errorf("internal call output %x (%d bytes), want %x", call.Output, call.OutputSize, output)
+	}
+
+	manage.SetMaxPayload(2)
+	*events = nil
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	if _, _, _, err := chain.Processor().Process(blocks[0], statedb, vm.Config{}); err != nil {
+		t.Fatalf("block failed: %v", err)
+	}
+	call = (*events)[0].TransInfo.CallInfo
+	if !bytes.Equal(call.Input, input[:2]) || !bytes.Equal(call.Output, output[:2]) {
+		t.Errorf("capped input %x and output %x, want %x and %x", call.Input, call.Output, input[:2], output[:2])
+	}
+	if call.InputSize != len(input) || call.OutputSize != len(output) {
+		t.Errorf("capped sizes %d and %d, want the full %d and %d", call.InputSize, call.OutputSize, len(input), len(output))
+	}
+}
+
+func TestPluginGasUsedSubtree(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "TRANS_CALL")
+
+	var (
+		outer   = common.Address{0xe1}
+		cheap   = common.Address{0xe2}
+		middle  = common.Address{0xe3}
+		heavy   = common.Address{0xe4}
+		invalid = common.Address{0xe5}
+	)
+	alloc := GenesisAlloc{
+		outer:  {Code: pluginCallCode(cheap, middle, invalid), Balance: common.Big0},
+		cheap:  {Code: []byte{0x00}, Balance: common.Big0},
+		middle: {Code: pluginCallCode(heavy), Balance: common.Big0},
+		// SSTORE to two fresh slots
+		heavy:   {Code: []byte{0x60, 0x01, 0x60, 0x01, 0x55, 0x60, 0x01, 0x60, 0x02, 0x55, 0x00}, Balance: common.Big0},
+		invalid: {Code: []byte{0xfe}, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &outer, common.Big0, 200000, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	used := make(map[string]uint64)
+	for _, event := range *events {
+		used[event.TransInfo.To] = event.TransInfo.GasUsedSubtree
+	}
+	if len(used) != 4 {
+		t.Fatalf("got calls to %d contracts, want 4", len(used))
+	}
+	if used[cheap.String()] != 0 {
+		t.Errorf("call to a STOP contract used %d gas", used[cheap.String()])
+	}
+	if used[heavy.String()] < 2*params.SstoreSetGasEIP2200 {
+		t.Errorf("storing call used %d gas, want at least %d", used[heavy.String()], 2*params.SstoreSetGasEIP2200)
+	}
+	// The middle frame is charged for its nested call plus its own few opcodes.
+	if diff := used[middle.String()] - used[heavy.String()]; used[middle.String()] < used[heavy.String()] || diff > params.ColdAccountAccessCostEIP2929+100 {
+		t.Errorf("middle subtree used %d gas, nested call %d", used[middle.String()], used[heavy.String()])
+	}
+	// A frame running out of gas is charged everything it was given.
+	receipt := chain.GetReceiptsByHash(blocks[0].Hash())[0]
+	if used[invalid.String()] == 0 || used[invalid.String()]+used[middle.String()] > receipt.GasUsed {
+		t.Errorf("failing call used %d gas, transaction %d", used[invalid.String()], receipt.GasUsed)
+	}
+}
+
+func TestPluginExecDuration(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "EXTERNALINFOEND")
+
+	caller, callee := common.Address{0xc0}, common.Address{0xc1}
+	alloc := GenesisAlloc{
+		caller: {Code: pluginCallCode(callee, callee), Balance: common.Big0},
+		callee: {Code: []byte{0x00}, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 200000, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 1 {
+		t.Fatalf("got %d EXTERNALINFOEND events, want 1", len(*events))
+	}
+	if duration := (*events)[0].TransInfo.ExecDuration; duration <= 0 {
+		t.Errorf("execution duration %dns, want a positive duration", duration)
+	}
+}
+
+func TestPluginGasBreakdown(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "EXTERNALINFOEND")
+
+	store := common.Address{0xc2}
+	alloc := GenesisAlloc{
+		// SSTORE(0, CALLDATASIZE)
+		store: {Code: []byte{0x36, 0x60, 0x00, 0x55, 0x00}, Balance: common.Big0},
+	}
+	calldata := []byte{0x00, 0x01, 0x02, 0x00}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &store, common.Big0, 100000, calldata))
+		b.AddTx(pluginTestTx(config, b, nil, common.Big0, 100000, []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00})) // SSTORE(0, 1)
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	receipts := chain.GetReceiptsByHash(blocks[0].Hash())
+	if len(*events) != len(receipts) {
+		t.Fatalf("got %d EXTERNALINFOEND events, want %d", len(*events), len(receipts))
+	}
+	for i, tx := range blocks[0].Transactions() {
+		info := (*events)[i].TransInfo
+		intrinsic, _ := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, true, true)
+		if info.IntrinsicGas != intrinsic {
+			t.Errorf("transaction %d: intrinsic gas %d, want %d", i, info.IntrinsicGas, intrinsic)
+		}
+		if info.ExecutionGas == 0 {
+			t.Errorf("transaction %d: no execution gas", i)
+		}
+		if sum := info.IntrinsicGas + info.ExecutionGas; sum != receipts[i].GasUsed || info.GasUsed != receipts[i].GasUsed {
+			t.Errorf("transaction %d: intrinsic %d + execution %d = %d, receipt used %d", i, info.IntrinsicGas, info.ExecutionGas, sum, receipts[i].GasUsed)
+		}
+	}
+}
+
+func TestPluginFailureReason(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "EXTERNALINFOEND")
+
+	var (
+		loop      = common.Address{0xf1}
+		underflow = common.Address{0xf2}
+		stop      = common.Address{0xf3}
+	)
+	alloc := GenesisAlloc{
+		// JUMPDEST PUSH1 0 JUMP
+		loop:      {Code: []byte{0x5b, 0x60, 0x00, 0x56}, Balance: common.Big0},
+		underflow: {Code: []byte{0x01}, Balance: common.Big0},
+		stop:      {Code: []byte{0x00}, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &loop, common.Big0, 50000, nil))
+		b.AddTx(pluginTestTx(config, b, &underflow, common.Big0, 50000, nil))
+		b.AddTx(pluginTestTx(config, b, &stop, common.Big0, 50000, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	want := []string{"out_of_gas", "stack_underflow", ""}
+	if len(*events) != len(want) {
+		t.Fatalf("got %d EXTERNALINFOEND events, want %d", len(*events), len(want))
+	}
+	for i, event := range *events {
+		info := event.TransInfo
+		if info.FailureReason != want[i] || info.IsSuccess != (want[i] == "") {
+			t.Errorf("transaction %d: success %t reason %q, want %q", i, info.IsSuccess, info.FailureReason, want[i])
+		}
+	}
+	// The 63/64 gas rule keeps a transaction from getting anywhere near the
+	// call depth limit, and a nested call hitting it only fails that call, so
+	// the depth error is checked as the EVM returns it.
+	if reason := pluginFailureReason(fmt.Errorf("call: %w", vm.ErrDepth)); reason != "max_call_depth" {
+		t.Errorf("max call depth classified as %q", reason)
+	}
+	if reason := pluginFailureReason(ErrNonceTooLow); reason != "invalid_nonce" {
+		t.Errorf("stale nonce classified as %q", reason)
+	}
+}
+
+func TestPluginFailureReasonInvalidNonce(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "EXTERNALINFOEND")
+
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &common.Address{0xaa}, big.NewInt(1), params.TxGas, nil))
+		b.AddTx(pluginTestTx(config, b, &common.Address{0xaa}, big.NewInt(1), params.TxGas, nil))
+	})
+	// Swapping the transactions makes the first one fail before it executes,
+	// so ApplyMessage returns no result at all.
+	txs := blocks[0].Transactions()
+	swapped := blocks[0].WithBody(types.Transactions{txs[1], txs[0]}, nil)
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	if _, _, _, err := chain.Processor().Process(swapped, statedb, vm.Config{}); err == nil {
+		t.Fatal("misordered block processed without error")
+	}
+	if len(*events) != 1 {
+		t.Fatalf("got %d EXTERNALINFOEND events, want 1", len(*events))
+	}
+	info := (*events)[0].TransInfo
+	if info.IsSuccess || info.FailureReason != "invalid_nonce" {
+		t.Errorf("success %t reason %q, want invalid_nonce", info.IsSuccess, info.FailureReason)
+	}
+	if info.GasUsed != 0 || info.IntrinsicGas != 0 || info.ExecutionGas != 0 {
+		t.Errorf("gas %d (intrinsic %d, execution %d) reported for an unapplied transaction", info.GasUsed, info.IntrinsicGas, info.ExecutionGas)
+	}
+}
+
+func TestPluginPreState(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "EXTERNALINFOSTART")
+	manage.SetFields(pluginManage.FieldPreState)
+
+	contract, account := common.Address{0xd1}, common.Address{0xd2}
+	alloc := GenesisAlloc{
+		contract: {Code: []byte{0x00}, Balance: big.NewInt(5)},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &contract, big.NewInt(7), 50000, nil))
+		b.AddTx(pluginTestTx(config, b, &account, big.NewInt(3), params.TxGas, nil))
+		b.AddTx(pluginTestTx(config, b, &account, big.NewInt(3), params.TxGas, nil))
+		b.AddTx(pluginTestTx(config, b, nil, common.Big0, 100000, []byte{0x00}))
+	})
+	header := blocks[0].Header()
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	var (
+		gp      = new(GasPool).AddGas(header.GasLimit)
+		usedGas uint64
+		want    []collector.PreStateCollector
+	)
+	for i, tx := range blocks[0].Transactions() {
+		expected := collector.PreStateCollector{
+			FromBalance: statedb.GetBalance(pluginTestAddr).String(),
+			FromNonce:   statedb.GetNonce(pluginTestAddr),
+		}
+		if to := tx.To(); to != nil {
+			expected.ToBalance = statedb.GetBalance(*to).String()
+			expected.ToHasCode = len(statedb.GetCode(*to)) > 0
+		}
+		want = append(want, expected)
+		statedb.Prepare(tx.Hash(), i)
+		if _, err := ApplyTransaction(config, chain, &header.Coinbase, gp, statedb, header, tx, &usedGas, vm.Config{}); err != nil {
+			t.Fatalf("transaction %d failed: %v", i, err)
+		}
+	}
+	if len(*events) != len(want) {
+		t.Fatalf("got %d EXTERNALINFOSTART events, want %d", len(*events), len(want))
+	}
+	for i, event := range *events {
+		if have := event.TransInfo.PreState; have != want[i] {
+			t.Errorf("transaction %d: pre-state %+v, want %+v", i, have, want[i])
+		}
+	}
+
+	// Without the field no state is read for it.
+	manage.SetFields(0)
+	*events = nil
+	statedb, _ = state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	usedGas = 0
+	tx := blocks[0].Transactions()[0]
+	statedb.Prepare(tx.Hash(), 0)
+	if _, err := ApplyTransaction(config, chain, &header.Coinbase, new(GasPool).AddGas(header.GasLimit), statedb, header, tx, &usedGas, vm.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*events) != 1 || (*events)[0].TransInfo.PreState != (collector.PreStateCollector{}) {
+		t.Errorf("pre-state filled in without the field: %+v", *events)
+	}
+	if _, err := pluginManage.ParseFieldMask([]string{"midstate"}); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
+
+func TestPluginPostStateOnly(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	starts := recordOpcodes(manage, "EXTERNALINFOSTART")
+	ends := recordOpcodes(manage, "EXTERNALINFOEND")
+	info, err := pluginManage.ParseRegisterInfo([]byte(`{"pluginname":"after","fields":["poststate"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, _ := pluginManage.ParseFieldMask(info.Fields)
+	manage.RequireFields(fields)
+	if manage.WantsField(pluginManage.FieldPreState) {
+		t.Fatal("a plugin asking for the post-state requires the pre-state")
+	}
+
+	account := common.Address{0xd3}
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &account, big.NewInt(9), params.TxGas, nil))
+	})
+	header := blocks[0].Header()
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	tx := blocks[0].Transactions()[0]
+	statedb.Prepare(tx.Hash(), 0)
+	var usedGas uint64
+	if _, err := ApplyTransaction(config, chain, &header.Coinbase, new(GasPool).AddGas(header.GasLimit), statedb, header, tx, &usedGas, vm.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	want := collector.PostStateCollector{
+		FromBalance: statedb.GetBalance(pluginTestAddr).String(),
+		FromNonce:   1,
+		ToBalance:   "9",
+	}
+	if len(*starts) != 1 || (*starts)[0].TransInfo.PreState != (collector.PreStateCollector{}) {
+		t.Errorf("pre-state captured for a post-state plugin: %+v", *starts)
+	}
+	if len(*ends) != 1 || (*ends)[0].TransInfo.PostState != want {
+		t.Errorf("post-state %+v, want %+v", *ends, want)
+	}
+	if _, err := pluginManage.ParseRegisterInfo([]byte(`{"pluginname":"bad","fields":["midstate"]}`)); err == nil {
+		t.Error("manifest with an unknown field accepted")
+	}
+}
+
+func TestPluginValueTransfer(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "handle_VALUE_TRANSFER")
+
+	forwarder, payee, caller := common.Address{0xf1}, common.Address{0xf2}, common.Address{0xf3}
+	// PUSH1 0 x4 (retSize, retOffset, inSize, inOffset) CALLVALUE PUSH20 payee GAS CALL POP STOP
+	forward := []byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x34, 0x73}
+	forward = append(forward, payee.Bytes()...)
+	forward = append(forward, 0x5a, 0xf1, 0x50, 0x00)
+	alloc := GenesisAlloc{
+		forwarder: {Code: forward, Balance: common.Big0},
+		// calls the forwarder without value
+		caller: {Code: pluginCallCode(forwarder), Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &forwarder, big.NewInt(5), 100000, nil))
+		b.AddTx(pluginTestTx(config, b, &forwarder, big.NewInt(7), 100000, []byte{0x01}))
+		b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 100000, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	txs := blocks[0].Transactions()
+	// A frame is reported when it returns, the forwarded transfer first.
+	want := []struct {
+		tx       *types.Transaction
+		from, to common.Address
+		value    string
+		layer    int
+	}{
+		{txs[0], forwarder, payee, "5", 2},
+		{txs[0], pluginTestAddr, forwarder, "5", 1},
+		{txs[1], forwarder, payee, "7", 2},
+	}
+	if len(*events) != len(want) {
+		t.Fatalf("got %d transfers, want %d", len(*events), len(want))
+	}
+	for i, event := range *events {
+		info := event.TransInfo
+		if info.TxHash != want[i].tx.Hash().String() || info.From != want[i].from.String() || info.To != want[i].to.String() || info.Value != want[i].value || info.CallLayer != want[i].layer {
+			t.Errorf("transfer %d: %s %s -> %s %s wei at layer %d, want %s -> %s %s wei at layer %d", i, info.TxHash, info.From, info.To, info.Value, info.CallLayer, want[i].from, want[i].to, want[i].value, want[i].layer)
+		}
+	}
+}
+
+func TestPluginTxTags(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "TXEND")
+
+	// two plugins tag the transaction sending value, one of them twice
+	for _, name := range []string{"labeler", "classifier"} {
+		name := name
+		monitor := new(pluginManage.MonitorType)
+		monitor.SetPluginName(name)
+		monitor.SetOpcode("EXTERNALINFOSTART")
+		monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+			if data.TransInfo.Value != "0" {
+				manage.TagTx("value-transfer")
+				manage.TagTx(name)
+			}
+			return 0x00, ""
+		})
+		manage.RegisterOpcode("EXTERNALINFOSTART", monitor)
+	}
+	recipient := common.Address{0xd7}
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &recipient, big.NewInt(1), 21000, nil))
+		b.AddTx(pluginTestTx(config, b, &recipient, common.Big0, 21000, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 2 {
+		t.Fatalf("got %d TXEND events, want 2", len(*events))
+	}
+	txs := blocks[0].Transactions()
+	want := []string{"value-transfer", "labeler", "classifier"}
+	if end := (*events)[0].TransInfo; end.TxHash != txs[0].Hash().String() || !reflect.DeepEqual(end.Tags, want) {
+		t.Errorf("TXEND of the value transfer: tx %s tags %v, want tx %s tags %v", end.TxHash, end.Tags, txs[0].Hash().String(), want)
+	}
+	if end := (*events)[1].TransInfo; end.TxHash != txs[1].Hash().String() || len(end.Tags) != 0 {
+		t.Errorf("TXEND of the plain transfer: tx %s tags %v, want tx %s untagged", end.TxHash, end.Tags, txs[1].Hash().String())
+	}
+}
+
+func TestPluginSelectors(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	if _, err := pluginManage.ParseSelectors([]string{"0x095ea7"}); err == nil {
+		t.Fatal("3 byte selector accepted")
+	}
+	approve, err := pluginManage.ParseSelectors([]string{"0x095ea7b3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received [][]byte
+	monitor := new(pluginManage.MonitorType)
+	monitor.SetPluginName("approvals")
+	monitor.SetOpcode("EXTERNALINFOSTART")
+	monitor.SetSelectors(approve)
+	monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+		received = append(received, data.TransInfo.CallInfo.InputData)
+		return 0x00, ""
+	})
+	manage.RegisterOpcode("EXTERNALINFOSTART", monitor)
+
+	token := common.Address{0xdb}
+	calls := [][]byte{
+		append(common.FromHex("0xa9059cbb"), make([]byte, 64)...), // transfer
+		append(common.FromHex("0x095ea7b3"), make([]byte, 64)...), // approve
+		nil,
+		common.FromHex("0x095e"),
+	}
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		for _, data := range calls {
+			b.AddTx(pluginTestTx(config, b, &token, common.Big0, 100000, data))
+		}
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(received) != 1 || !bytes.Equal(received[0], calls[1]) {
+		t.Errorf("plugin received calls %x, want only the approve call", received)
+	}
+}
+
+func TestPluginAggregateDelivery(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+
+	var calls []*collector.AllCollector
+	for _, opcode := range []string{"EXTERNALINFOSTART", "handle_BLOCK_END"} {
+		monitor := new(pluginManage.MonitorType)
+		monitor.SetPluginName("totals")
+		monitor.SetDelivery("aggregate")
+		monitor.SetOpcode(opcode)
+		monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+			calls = append(calls, data)
+			return 0x00, ""
+		})
+		manage.RegisterOpcode(opcode, monitor)
+	}
+	recipient := common.Address{0xd8}
+	chain, blocks := generatePluginTestChain(t, config, nil, 2, func(i int, b *BlockGen) {
+		for value := int64(1); value <= int64(3-i); value++ {
+			b.AddTx(pluginTestTx(config, b, &recipient, big.NewInt(value), 21000, nil))
+		}
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("plugin called %d times, want once per block", len(calls))
+	}
+	for i, want := range []struct {
+		txs   uint64
+		value string
+	}{{3, "6"}, {2, "3"}} {
+		if op := calls[i].Option; op != "handle_BLOCK_END" {
+			t.Fatalf("call %d has option %s, want handle_BLOCK_END", i, op)
+		}
+		agg := calls[i].BlockInfo.Aggregate
+		if agg.Events != want.txs || agg.Transactions != want.txs || len(agg.Trans) != int(want.txs) || agg.TotalValue != want.value {
+			t.Errorf("block %d aggregate %d events of %d txs (%d payloads) worth %s, want %d of %d worth %s",
+				i+1, agg.Events, agg.Transactions, len(agg.Trans), agg.TotalValue, want.txs, want.txs, want.value)
+		}
+	}
+}
+
+func TestPluginTouchedSet(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "handle_TOUCHED_SET")
+
+	caller, store := common.Address{0xde}, common.Address{0xdf}
+	alloc := GenesisAlloc{
+		caller: {Code: pluginCallCode(store), Balance: common.Big0},
+		// SLOAD(1) POP SSTORE(2, 7)
+		store: {Code: []byte{0x60, 0x01, 0x54, 0x50, 0x60, 0x07, 0x60, 0x02, 0x55, 0x00}, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 200000, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 1 {
+		t.Fatalf("got %d touched set events, want 1", len(*events))
+	}
+	touched := make(map[string][]string)
+	for _, account := range (*events)[0].TransInfo.Touched {
+		touched[account.Address] = account.Slots
+	}
+	for _, addr := range []common.Address{pluginTestAddr, caller, store} {
+		if _, ok := touched[addr.String()]; !ok {
+			t.Errorf("touched set %v misses %s", touched, addr)
+		}
+	}
+	if _, ok := touched[common.BytesToAddress([]byte{0x01}).String()]; ok {
+		t.Error("touched set lists the ecrecover precompile")
+	}
+	want := []string{common.BigToHash(big.NewInt(1)).String(), common.BigToHash(big.NewInt(2)).String()}
+	if slots := touched[store.String()]; !reflect.DeepEqual(slots, want) {
+		t.Errorf("slots of the store contract %v, want the read slot and the written slot %v", slots, want)
+	}
+}
+
+func TestPluginTxSummary(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "EXTERNALINFOSTART", "EXTERNALINFOEND", "handle_TX_SUMMARY")
+
+	logger, underflow := common.Address{0xe1}, common.Address{0xe2}
+	alloc := GenesisAlloc{
+		// LOG0(0, 0) LOG0(0, 0) STOP
+		logger:    {Code: []byte{0x60, 0x00, 0x60, 0x00, 0xa0, 0x60, 0x00, 0x60, 0x00, 0xa0, 0x00}, Balance: common.Big0},
+		underflow: {Code: []byte{0x01}, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &logger, big.NewInt(9), 100000, nil))
+		b.AddTx(pluginTestTx(config, b, &underflow, common.Big0, 50000, nil))
+		b.AddTx(pluginTestTx(config, b, nil, common.Big0, 100000, []byte{0x00}))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	txs := blocks[0].Transactions()
+	receipts := chain.GetReceiptsByHash(blocks[0].Hash())
+	if len(*events) != 3*len(txs) {
+		t.Fatalf("got %d events, want %d", len(*events), 3*len(txs))
+	}
+	for i, tx := range txs {
+		start, end, summary := (*events)[3*i].TransInfo, (*events)[3*i+1].TransInfo, (*events)[3*i+2].TransInfo
+		if summary.Op != "handle_TX_SUMMARY" || summary.TxHash != tx.Hash().String() {
+			t.Fatalf("transaction %d: event %s of %s in place of the summary", i, summary.Op, summary.TxHash)
+		}
+		to := start.To
+		if to == "" {
+			to = end.To
+		}
+		if summary.From != start.From || summary.To != to || summary.Value != start.Value || summary.Nonce != start.Nonce || summary.GasLimit != start.GasLimit {
+			t.Errorf("transaction %d: summary %s -> %s %s wei, want %s -> %s %s wei", i, summary.From, summary.To, summary.Value, start.From, to, start.Value)
+		}
+		if summary.GasUsed != end.GasUsed || summary.IsSuccess != end.IsSuccess || summary.FailureReason != end.FailureReason {
+			t.Errorf("transaction %d: summary used %d gas, success %t %q, want %d, %t %q", i, summary.GasUsed, summary.IsSuccess, summary.FailureReason, end.GasUsed, end.IsSuccess, end.FailureReason)
+		}
+		if summary.LogCount != len(receipts[i].Logs) {
+			t.Errorf("transaction %d: summary counts %d logs, receipt has %d", i, summary.LogCount, len(receipts[i].Logs))
+		}
+	}
+	summaries := []collector.TransCollector{(*events)[2].TransInfo, (*events)[5].TransInfo, (*events)[8].TransInfo}
+	if summaries[0].LogCount != 2 || summaries[0].Created != "" {
+		t.Errorf("call summary: %d logs, created %q", summaries[0].LogCount, summaries[0].Created)
+	}
+	if summaries[1].IsSuccess || summaries[1].FailureReason != "stack_underflow" {
+		t.Errorf("failed call summary: success %t, reason %q", summaries[1].IsSuccess, summaries[1].FailureReason)
+	}
+	if created := receipts[2].ContractAddress.String(); summaries[2].Created != created || summaries[2].CallType != "CREATE" {
+		t.Errorf("creation summary: %s created %q, want %s", summaries[2].CallType, summaries[2].Created, created)
+	}
+}
+
+func TestPluginDelegateCallStorage(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "TRANS_CALL", "TRANS_DELEGATECALL")
+
+	var (
+		proxy  = common.Address{0xf1}
+		impl   = common.Address{0xf2}
+		caller = common.Address{0xf3}
+	)
+	proxyCode := []byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x73} // retSize retOffset inSize inOffset PUSH20
+	proxyCode = append(proxyCode, impl.Bytes()...)
+	proxyCode = append(proxyCode, 0x5a, 0xf4, 0x50, 0x00) // GAS DELEGATECALL POP STOP
+
+	alloc := GenesisAlloc{
+		proxy:  {Code: proxyCode, Balance: common.Big0},
+		impl:   {Code: []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00}, Balance: common.Big0}, // SSTORE(0, 1)
+		caller: {Code: pluginCallCode(impl), Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &proxy, common.Big0, 200000, nil))
+		b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 200000, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 2 {
+		t.Fatalf("got %d call events, want 2", len(*events))
+	}
+	for i, want := range []struct {
+		op      string
+		storage common.Address
+	}{
+		{"TRANS_DELEGATECALL", proxy},
+		{"TRANS_CALL", impl},
+	} {
+		event := (*events)[i]
+		if event.Option != want.op || event.TransInfo.To != impl.String() {
+			t.Errorf("event %d: %s to %s, want %s to %s", i, event.Option, event.TransInfo.To, want.op, impl)
+		}
+		if have := event.TransInfo.CallInfo.StorageAddr; have != want.storage.String() {
+			t.Errorf("event %d: storage context %s, want %s", i, have, want.storage)
+		}
+	}
+	state, _ := chain.State()
+	if state.GetState(proxy, common.Hash{}) != common.BigToHash(common.Big1) {
+		t.Error("delegatecall did not write the proxy storage")
+	}
+}
+
+func TestPluginBlockDelivery(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+
+	var flushes [][]*collector.AllCollector
+	opcodes := []string{"handle_BLOCK_INFO", "TXSTART", "TXEND", "handle_BLOCK_END"}
+	for _, opcode := range opcodes {
+		monitor := new(pluginManage.MonitorType)
+		monitor.SetPluginName("batcher")
+		monitor.SetOpcode(opcode)
+		monitor.SetDelivery("block")
+		monitor.SetSendFunc(func(*collector.AllCollector) (byte, string) {
+			t.Error("block delivery plugin received a single event")
+			return 0x00, ""
+		})
+		monitor.SetBatchFunc(func(events []*collector.AllCollector) {
+			flushes = append(flushes, events)
+		})
+		manage.RegisterOpcode(opcode, monitor)
+	}
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		to := common.Address{0xaa}
+		for j := 0; j < 3; j++ {
+			b.AddTx(pluginTestTx(config, b, &to, big.NewInt(1), params.TxGas, nil))
+		}
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(flushes) != 1 {
+		t.Fatalf("got %d flushes, want 1", len(flushes))
+	}
+	want := []string{"handle_BLOCK_INFO", "TXSTART", "TXEND", "TXSTART", "TXEND", "TXSTART", "TXEND", "handle_BLOCK_END"}
+	var ops []string
+	for _, event := range flushes[0] {
+		ops = append(ops, event.Option)
+	}
+	if strings.Join(ops, ",") != strings.Join(want, ",") {
+		t.Errorf("flushed %v, want %v", ops, want)
+	}
+}
+
+func TestPluginCallDedup(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "TRANS_CALL", "handle_CALL_REPEAT")
+
+	var (
+		looper = common.Address{0xb1}
+		target = common.Address{0xb2}
+	)
+	// Calls target three times from the same instruction.
+	code := []byte{0x60, 0x03, 0x5b} // PUSH1 3 JUMPDEST
+	code = append(code, pluginCallCode(target)...)
+	code = append(code[:len(code)-1], 0x60, 0x01, 0x90, 0x03, 0x80, 0x60, 0x02, 0x57, 0x00) // counter-1, loop while non-zero
+
+	alloc := GenesisAlloc{
+		looper: {Code: code, Balance: common.Big0},
+		target: {Code: []byte{0x00}, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 2, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &looper, common.Big0, 200000, nil))
+	})
+	// Without dedup every call is emitted.
+	if _, err := chain.InsertChain(blocks[:1]); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 3 {
+		t.Fatalf("got %d events without dedup, want 3", len(*events))
+	}
+	*events = nil
+	manage.SetDedup(true)
+	if _, err := chain.InsertChain(blocks[1:]); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 2 {
+		t.Fatalf("got %d events with dedup, want the call and one repeat count", len(*events))
+	}
+	call, repeat := (*events)[0], (*events)[1]
+	if call.Option != "TRANS_CALL" || call.TransInfo.To != target.String() {
+		t.Errorf("first event %s to %s, want TRANS_CALL to %s", call.Option, call.TransInfo.To, target)
+	}
+	if repeat.Option != "handle_CALL_REPEAT" || repeat.TransInfo.RepeatCount != 2 || repeat.TransInfo.To != target.String() {
+		t.Errorf("repeat event %s to %s counted %d, want 2 repeats to %s", repeat.Option, repeat.TransInfo.To, repeat.TransInfo.RepeatCount, target)
+	}
+	if have := manage.BlockEventCount("TRANS_CALL"); have != 3 {
+		t.Errorf("TRANS_CALL emitted %d times, want 3", have)
+	}
+}
+
+func TestPluginNonceAnomaly(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "handle_NONCE_ANOMALY")
+	// The misordered transaction fails before it executes, the end event
+	// must still go out without a result to read from.
+	ends := recordOpcodes(manage, "EXTERNALINFOEND")
+
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &common.Address{0xaa}, big.NewInt(1), params.TxGas, nil))
+		b.AddTx(pluginTestTx(config, b, &common.Address{0xaa}, big.NewInt(1), params.TxGas, nil))
+	})
+	// The in-order block raises nothing.
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	if _, _, _, err := chain.Processor().Process(blocks[0], statedb, vm.Config{}); err != nil {
+		t.Fatalf("in-order block failed: %v", err)
+	}
+	if len(*events) != 0 {
+		t.Fatalf("in-order block raised %d nonce anomalies", len(*events))
+	}
+	*ends = (*ends)[:0]
+	// Swapping the transactions puts nonce 1 before nonce 0.
+	txs := blocks[0].Transactions()
+	swapped := blocks[0].WithBody(types.Transactions{txs[1], txs[0]}, nil)
+	statedb, _ = state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	if _, _, _, err := chain.Processor().Process(swapped, statedb, vm.Config{}); err == nil {
+		t.Fatal("misordered block processed without error")
+	}
+	if len(*events) != 1 {
+		t.Fatalf("got %d nonce anomalies, want 1", len(*events))
+	}
+	info := (*events)[0].TransInfo.NonceInfo
+	if info.Sender != pluginTestAddr.String() || info.Expected != 0 || info.Actual != 1 {
+		t.Errorf("anomaly %+v, want sender %v expected 0 actual 1", info, pluginTestAddr)
+	}
+	if len(*ends) != 1 || (*ends)[0].TransInfo.IsSuccess {
+		t.Fatalf("got %d end events for the misordered block, want 1 failed", len(*ends))
+	}
+}
+
+func TestPluginTxAnomaly(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "handle_TX_ANOMALY")
+	if err := manage.SetAnomaly(pluginManage.AnomalyConfig{MaxCalldata: 64, MaxValue: "1000000000000000000"}); err != nil {
+		t.Fatal(err)
+	}
+
+	to := common.Address{0xaa}
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &to, big.NewInt(1), 50000, make([]byte, 100)))
+		b.AddTx(pluginTestTx(config, b, &to, new(big.Int).Mul(big.NewInt(2), big.NewInt(params.Ether)), params.TxGas, nil))
+		b.AddTx(pluginTestTx(config, b, &to, big.NewInt(params.Ether), 50000, make([]byte, 64)))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	txs := blocks[0].Transactions()
+	want := []struct {
+		tx                       *types.Transaction
+		threshold, limit, actual string
+	}{
+		{txs[0], pluginManage.AnomalyCalldata, "64", "100"},
+		{txs[1], pluginManage.AnomalyValue, "1000000000000000000", "2000000000000000000"},
+	}
+	if len(*events) != len(want) {
+		t.Fatalf("got %d anomalies, want %d", len(*events), len(want))
+	}
+	for i, event := range *events {
+		info := event.TransInfo.AnomalyInfo
+		if event.TransInfo.TxHash != want[i].tx.Hash().String() {
+			t.Errorf("anomaly %d raised for %s, want %s", i, event.TransInfo.TxHash, want[i].tx.Hash().String())
+		}
+		if info.Threshold != want[i].threshold || info.Limit != want[i].limit || info.Actual != want[i].actual {
+			t.Errorf("anomaly %d: %+v, want %s above %s: %s", i, info, want[i].threshold, want[i].limit, want[i].actual)
+		}
+	}
+	if err := manage.SetAnomaly(pluginManage.AnomalyConfig{MaxValue: "lots"}); err == nil {
+		t.Error("invalid maxvalue accepted")
+	}
+}
+
+func TestPluginHistory(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	history := manage.History()
+
+	to := common.Address{0xaa}
+	chain, blocks := generatePluginTestChain(t, config, nil, 2, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &to, big.NewInt(1), params.TxGas, nil))
+	})
+	// While block 2 executes, the plugin compares the balance at block 1
+	// with the one at genesis.
+	var before, earlier *big.Int
+	var readErr error
+	monitor := new(pluginManage.MonitorType)
+	monitor.SetPluginName("history")
+	monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+		if chain.CurrentBlock().NumberU64() != 1 {
+			return 0x00, ""
+		}
+		if before, readErr = history.BalanceAt(to, 1); readErr != nil {
+			return 0x00, ""
+		}
+		earlier, readErr = history.BalanceAt(to, 0)
+		return 0x00, ""
+	})
+	manage.RegisterOpcode("TXSTART", monitor)
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if readErr != nil {
+		t.Fatalf("historical read failed: %v", readErr)
+	}
+	if before == nil || before.Cmp(big.NewInt(1)) != 0 || earlier.Sign() != 0 {
+		t.Fatalf("balances at blocks 1 and 0 are %v and %v, want 1 and 0", before, earlier)
+	}
+	current, err := history.BalanceAt(to, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Cmp(before) <= 0 {
+		t.Errorf("current balance %v not above the earlier %v", current, before)
+	}
+	if _, err := history.BalanceAt(to, 10); !errors.Is(err, pluginManage.ErrStateUnavailable) {
+		t.Errorf("reading a future block returned %v, want %v", err, pluginManage.ErrStateUnavailable)
+	}
+}
+
+func TestPluginDryRun(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "handle_WOULD_BLOCK")
+
+	monitor := new(pluginManage.MonitorType)
+	monitor.SetPluginName("guard")
+	monitor.SetMode("enforce")
+	monitor.SetDryRun(true)
+	monitor.Logger = &pluginManage.WarnTxLog{FileName: filepath.Join(t.TempDir(), "guard")}
+	monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+		return 0x02, "value transfer"
+	})
+	manage.RegisterOpcode("EXTERNALINFOSTART", monitor)
+
+	to := common.Address{0xaa}
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &to, big.NewInt(1), params.TxGas, nil))
+	})
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	if _, _, _, err := chain.Processor().Process(blocks[0], statedb, vm.Config{}); err != nil {
+		t.Fatalf("block failed: %v", err)
+	}
+	if len(*events) != 1 {
+		t.Fatalf("got %d would-block events, want 1", len(*events))
+	}
+	info := (*events)[0].TransInfo.WouldBlockInfo
+	if info.Plugin != "guard" || info.Opcode != "EXTERNALINFOSTART" || info.Level != 0x02 || info.Reason != "value transfer" {
+		t.Errorf("would-block event %+v", info)
+	}
+	if balance := statedb.GetBalance(to); balance.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("recipient balance %v, want 1: dry-run block reverted the transaction", balance)
+	}
+	if !monitor.GetStatus() {
+		t.Error("dry-run block disabled the plugin")
+	}
+}
+
+func TestPluginBlockingPolicy(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	if err := manage.SetBlockingPolicy(pluginManage.BlockingConfig{Allow: []string{"handle_NOT_AN_OPCODE"}}); err == nil {
+		t.Fatal("policy with an unknown opcode accepted")
+	}
+	if err := manage.SetBlockingPolicy(pluginManage.BlockingConfig{Allow: []string{"EXTERNALINFOEND"}}); err != nil {
+		t.Fatal(err)
+	}
+	monitor := new(pluginManage.MonitorType)
+	monitor.SetPluginName("guard")
+	monitor.SetMode("enforce")
+	monitor.Logger = &pluginManage.WarnTxLog{FileName: filepath.Join(t.TempDir(), "guard")}
+	monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+		return 0x02, "value transfer"
+	})
+	manage.RegisterOpcode("EXTERNALINFOSTART", monitor)
+
+	to := common.Address{0xab}
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &to, big.NewInt(1), params.TxGas, nil))
+	})
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	if _, _, _, err := chain.Processor().Process(blocks[0], statedb, vm.Config{}); err != nil {
+		t.Fatalf("block failed: %v", err)
+	}
+	if balance := statedb.GetBalance(to); balance.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("recipient balance %v, want 1: block decision on EXTERNALINFOSTART reverted the transaction", balance)
+	}
+	if !monitor.GetStatus() {
+		t.Error("ignored block decision disabled the plugin")
+	}
+}
+
+func TestPluginStateReverted(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "handle_STATE_REVERTED")
+
+	blocked, allowed := common.Address{0xaa}, common.Address{0xbb}
+	monitor := new(pluginManage.MonitorType)
+	monitor.SetPluginName("guard")
+	monitor.SetMode("enforce")
+	monitor.Logger = &pluginManage.WarnTxLog{FileName: filepath.Join(t.TempDir(), "guard")}
+	monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+		if data.TransInfo.To == blocked.String() {
+			return 0x02, "blocked recipient"
+		}
+		return 0x00, ""
+	})
+	manage.RegisterOpcode("EXTERNALINFOSTART", monitor)
+
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &blocked, big.NewInt(1), params.TxGas, nil))
+		b.AddTx(pluginTestTx(config, b, &allowed, big.NewInt(1), params.TxGas, nil))
+	})
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	if _, _, _, err := chain.Processor().Process(blocks[0], statedb, vm.Config{}); err != nil {
+		t.Fatalf("block failed: %v", err)
+	}
+	if len(*events) != 1 {
+		t.Fatalf("got %d revert events, want 1", len(*events))
+	}
+	event := (*events)[0].TransInfo
+	if event.TxHash != blocks[0].Transactions()[0].Hash().String() {
+		t.Errorf("revert reported for %s, want the first transaction", event.TxHash)
+	}
+	if event.RevertInfo.Plugin != "guard" || event.RevertInfo.Reason != "blocked recipient" {
+		t.Errorf("revert attributed to %q (%q)", event.RevertInfo.Plugin, event.RevertInfo.Reason)
+	}
+	if statedb.GetBalance(blocked).Sign() != 0 || statedb.GetBalance(allowed).Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("balances %v and %v, want 0 and 1", statedb.GetBalance(blocked), statedb.GetBalance(allowed))
+	}
+}
+
+// TestPluginPerChainManagers processes two chains at the same time, each with
+// its own plugin manager. One of them blocks part of its transactions, which
+// must neither revert nor be seen by the other.
+func TestPluginSnapshotWithoutEnforcer(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "handle_STATE_REVERTED")
+
+	// A monitor plugin answering with a block decision does not make the
+	// manager record a snapshot, so there is nothing to revert.
+	monitor := new(pluginManage.MonitorType)
+	monitor.SetPluginName("watcher")
+	monitor.Logger = &pluginManage.WarnTxLog{FileName: filepath.Join(t.TempDir(), "watcher")}
+	monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+		return 0x02, "suspicious"
+	})
+	manage.RegisterOpcode("EXTERNALINFOSTART", monitor)
+	if manage.Enforcing() {
+		t.Fatal("monitor plugin counted as enforcing")
+	}
+
+	payee := common.Address{0xaa}
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &payee, big.NewInt(1), params.TxGas, nil))
+	})
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	if _, _, _, err := chain.Processor().Process(blocks[0], statedb, vm.Config{}); err != nil {
+		t.Fatalf("block failed: %v", err)
+	}
+	if len(*events) != 0 {
+		t.Errorf("got %d revert events without an enforce plugin", len(*events))
+	}
+	if statedb.GetBalance(payee).Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("payee balance %v, want 1", statedb.GetBalance(payee))
+	}
+	if config.TransferDataPlg.TxState().PLUGIN_SNAPSHOT_FLAG {
+		t.Error("snapshot recorded without an enforce plugin")
+	}
+
+	monitor.SetMode("enforce")
+	monitor.SetDryRun(true)
+	if manage.Enforcing() {
+		t.Error("dry-run enforce plugin counted as enforcing")
+	}
+	monitor.SetDryRun(false)
+	if !manage.Enforcing() {
+		t.Error("enforce plugin not counted as enforcing")
+	}
+}
+
+func TestPluginStdoutExporter(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	var out bytes.Buffer
+	opcodes := []string{"EXTERNALINFOSTART", "EXTERNALINFOEND", "handle_BLOCK_END"}
+	manage.AddExporter(pluginManage.NewStdoutExporter(pluginManage.ExporterConfig{Opcodes: opcodes}, &out), opcodes...)
+
+	to := common.Address{0xaa}
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &to, big.NewInt(1), params.TxGas, nil))
+		b.AddTx(pluginTestTx(config, b, &to, big.NewInt(1), params.TxGas, nil))
+	})
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	if _, _, _, err := chain.Processor().Process(blocks[0], statedb, vm.Config{}); err != nil {
+		t.Fatalf("block failed: %v", err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("\n  \"opcode\"")) {
+		t.Errorf("events not indented:\n%s", out.String())
+	}
+	txs := blocks[0].Transactions()
+	want := []struct{ opcode, txHash string }{
+		{"EXTERNALINFOSTART", txs[0].Hash().String()},
+		{"EXTERNALINFOEND", txs[0].Hash().String()},
+		{"EXTERNALINFOSTART", txs[1].Hash().String()},
+		{"EXTERNALINFOEND", txs[1].Hash().String()},
+		{"handle_BLOCK_END", ""},
+	}
+	decoder := json.NewDecoder(&out)
+	for i := 0; ; i++ {
+		var env pluginManage.Envelope
+		if err := decoder.Decode(&env); err == io.EOF {
+			if i != len(want) {
+				t.Errorf("got %d events, want %d", i, len(want))
+			}
+			break
+		} else if err != nil {
+			t.Fatalf("event %d is not valid json: %v", i, err)
+		}
+		if i >= len(want) {
+			t.Fatalf("unexpected event %s", env.Opcode)
+		}
+		if env.Opcode != want[i].opcode || env.TxHash != want[i].txHash || env.Payload == nil || env.Payload.Option != want[i].opcode {
+			t.Errorf("event %d: %s of %q, want %s of %q", i, env.Opcode, env.TxHash, want[i].opcode, want[i].txHash)
+		}
+	}
+}
+
+func TestPluginPerChainManagers(t *testing.T) {
+	const blocksPerChain, txsPerBlock = 4, 4
+	var (
+		contract = common.Address{0xc0}
+		target   = common.Address{0xc1}
+	)
+	alloc := func() GenesisAlloc {
+		return GenesisAlloc{
+			contract: {Code: pluginCallCode(target), Balance: common.Big0},
+			target:   {Code: []byte{0x00}, Balance: common.Big0},
+		}
+	}
+	type testChain struct {
+		chain  *BlockChain
+		blocks []*types.Block
+		events *[]*collector.AllCollector
+		state  *state.StateDB
+		err    error
+	}
+	newChain := func(guarded bool) *testChain {
+		config := pluginTestConfig()
+		manage := config.TransferDataPlg
+		events := recordOpcodes(manage, "EXTERNALINFOSTART", "TRANS_CALL")
+		if guarded {
+			monitor := new(pluginManage.MonitorType)
+			monitor.SetPluginName("guard")
+			monitor.SetMode("enforce")
+			monitor.Logger = &pluginManage.WarnTxLog{FileName: filepath.Join(t.TempDir(), "guard")}
+			monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+				if data.TransInfo.Value == "2" {
+					return 0x02, "value 2"
+				}
+				return 0x00, ""
+			})
+			manage.RegisterOpcode("EXTERNALINFOSTART", monitor)
+		}
+		chain, blocks := generatePluginTestChain(t, config, alloc(), blocksPerChain, func(i int, b *BlockGen) {
+			for j := 0; j < txsPerBlock; j++ {
+				b.AddTx(pluginTestTx(config, b, &contract, big.NewInt(int64(1+j%2)), 200000, nil))
+			}
+		})
+		statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+		return &testChain{chain: chain, blocks: blocks, events: events, state: statedb}
+	}
+	guarded, plain := newChain(true), newChain(false)
+
+	var wg sync.WaitGroup
+	for _, c := range []*testChain{guarded, plain} {
+		wg.Add(1)
+		go func(c *testChain) {
+			defer wg.Done()
+			for _, block := range c.blocks {
+				if _, _, _, err := c.chain.Processor().Process(block, c.state, vm.Config{}); err != nil {
+					c.err = err
+					return
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	for name, c := range map[string]*testChain{"guarded": guarded, "plain": plain} {
+		if c.err != nil {
+			t.Fatalf("%s chain: block failed: %v", name, c.err)
+		}
+		want := make(map[string]bool)
+		for _, block := range c.blocks {
+			for _, tx := range block.Transactions() {
+				want[tx.Hash().String()] = true
+			}
+		}
+		var txs, calls int
+		for _, event := range *c.events {
+			switch event.Option {
+			case "EXTERNALINFOSTART":
+				txs++
+				if !want[event.TransInfo.TxHash] {
+					t.Errorf("%s chain: received transaction %s of the other chain", name, event.TransInfo.TxHash)
+				}
+			case "TRANS_CALL":
+				calls++
+				if event.TransInfo.To != target.String() || event.TransInfo.CallLayer != 2 {
+					t.Errorf("%s chain: call to %s at layer %d, want %s at 2", name, event.TransInfo.To, event.TransInfo.CallLayer, target.String())
+				}
+			}
+		}
+		if txs != len(want) || calls != len(want) {
+			t.Errorf("%s chain: %d EXTERNALINFOSTART and %d TRANS_CALL events for %d transactions", name, txs, calls, len(want))
+		}
+	}
+	// value 1 and 2 alternate; the guard reverted the transfers of 2
+	txs := int64(blocksPerChain * txsPerBlock)
+	if balance := guarded.state.GetBalance(contract); balance.Cmp(big.NewInt(txs/2)) != 0 {
+		t.Errorf("guarded chain: contract balance %v, want %d", balance, txs/2)
+	}
+	if balance := plain.state.GetBalance(contract); balance.Cmp(big.NewInt(txs/2*3)) != 0 {
+		t.Errorf("plain chain: contract balance %v, want %d", balance, txs/2*3)
+	}
+}
+
+// TestPluginProcessorOwnManager runs two processors of one chain, and so of
+// one chain config, at the same time. The second has a manager of its own
+// that blocks part of the transactions; the chain's manager must neither see
+// its events nor have its transactions reverted.
+func TestPluginProcessorOwnManager(t *testing.T) {
+	const blocks, txsPerBlock = 4, 4
+	var (
+		contract = common.Address{0xc0}
+		target   = common.Address{0xc1}
+	)
+	config := pluginTestConfig()
+	shared := recordOpcodes(config.TransferDataPlg, "EXTERNALINFOSTART")
+	chain, chainBlocks := generatePluginTestChain(t, config, GenesisAlloc{
+		contract: {Code: pluginCallCode(target), Balance: common.Big0},
+		target:   {Code: []byte{0x00}, Balance: common.Big0},
+	}, blocks, func(i int, b *BlockGen) {
+		for j := 0; j < txsPerBlock; j++ {
+			b.AddTx(pluginTestTx(config, b, &contract, big.NewInt(int64(1+j%2)), 200000, nil))
+		}
+	})
+
+	own := pluginManage.NewPluginManages()
+	owned := recordOpcodes(own, "EXTERNALINFOSTART")
+	monitor := new(pluginManage.MonitorType)
+	monitor.SetPluginName("guard")
+	monitor.SetMode("enforce")
+	monitor.Logger = &pluginManage.WarnTxLog{FileName: filepath.Join(t.TempDir(), "guard")}
+	monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+		if data.TransInfo.Value == "2" {
+			return 0x02, "value 2"
+		}
+		return 0x00, ""
+	})
+	own.RegisterOpcode("EXTERNALINFOSTART", monitor)
+	processor := NewStateProcessor(config, chain, chain.Engine())
+	processor.SetPlugins(own)
+
+	var (
+		wg     sync.WaitGroup
+		states [2]*state.StateDB
+		errs   [2]error
+	)
+	for i, p := range []Processor{chain.Processor(), processor} {
+		states[i], _ = state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+		wg.Add(1)
+		go func(i int, p Processor) {
+			defer wg.Done()
+			for _, block := range chainBlocks {
+				if _, _, _, err := p.Process(block, states[i], vm.Config{}); err != nil {
+					errs[i] = err
+					return
+				}
+			}
+		}(i, p)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("processor %d: block failed: %v", i, err)
+		}
+	}
+	txs := blocks * txsPerBlock
+	if len(*shared) != txs || len(*owned) != txs {
+		t.Errorf("chain manager got %d and own manager %d EXTERNALINFOSTART events, want %d each", len(*shared), len(*owned), txs)
+	}
+	// value 1 and 2 alternate; only the own manager's guard reverted the 2s
+	if balance := states[0].GetBalance(contract); balance.Cmp(big.NewInt(int64(txs*3/2))) != 0 {
+		t.Errorf("chain processor: contract balance %v, want %d", balance, txs*3/2)
+	}
+	if balance := states[1].GetBalance(contract); balance.Cmp(big.NewInt(int64(txs/2))) != 0 {
+		t.Errorf("own processor: contract balance %v, want %d", balance, txs/2)
+	}
+	if balance, err := own.History().BalanceAt(pluginTestAddr, 0); err != nil || balance.Sign() == 0 {
+		t.Errorf("own manager has no history: %v %v", balance, err)
+	}
+}
+
+func TestPluginSubscribe(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+
+	to := common.Address{0xaa}
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		for j := 0; j < 3; j++ {
+			b.AddTx(pluginTestTx(config, b, &to, big.NewInt(1), params.TxGas, nil))
+		}
+	})
+	events := manage.Subscribe("EXTERNALINFOSTART", "TXEND", "handle_BLOCK_END")
+	done := make(chan []string)
+	go func() {
+		var received []string
+		for event := range events {
+			received = append(received, event.Option)
+		}
+		done <- received
+	}()
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	if _, _, _, err := chain.Processor().Process(blocks[0], statedb, vm.Config{}); err != nil {
+		t.Fatalf("block failed: %v", err)
+	}
+	manage.Unsubscribe(events)
+
+	want := strings.Repeat("EXTERNALINFOSTART,TXEND,", 3) + "handle_BLOCK_END"
+	if have := strings.Join(<-done, ","); have != want {
+		t.Errorf("received %s, want %s", have, want)
+	}
+	if manage.GetOpcodeRegister("TXEND") {
+		t.Error("TXEND still emitted after unsubscribing")
+	}
+}
+
+func TestPluginProcessCancel(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "TXEND")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var started int
+	monitor := new(pluginManage.MonitorType)
+	monitor.SetPluginName("canceller")
+	monitor.SetOpcode("EXTERNALINFOSTART")
+	monitor.SetSendFunc(func(data *collector.AllCollector) (byte, string) {
+		// the job is cancelled while the second transaction runs
+		if started++; started == 2 {
+			cancel()
+		}
+		return 0x00, ""
+	})
+	manage.RegisterOpcode("EXTERNALINFOSTART", monitor)
+
+	to := common.Address{0xaa}
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		for j := 0; j < 5; j++ {
+			b.AddTx(pluginTestTx(config, b, &to, big.NewInt(1), params.TxGas, nil))
+		}
+	})
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	_, _, _, err := chain.Processor().(*StateProcessor).ProcessContext(ctx, blocks[0], statedb, vm.Config{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("process returned %v, want a context error", err)
+	}
+	if !strings.Contains(err.Error(), "before tx 2") {
+		t.Errorf("error %q does not name the transaction processing stopped at", err)
+	}
+	if started != 2 {
+		t.Errorf("%d transactions started, want 2", started)
+	}
+	// the TXEND of the transaction running when the job was cancelled is not
+	// delivered anymore
+	if len(*events) != 1 {
+		t.Errorf("%d TXEND events delivered, want 1", len(*events))
+	}
+	if balance := statedb.GetBalance(to); balance.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("recipient balance %v, want 2", balance)
+	}
+}
+
+func TestPluginDisabled(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "handle_BLOCK_INFO", "TXSTART", "EXTERNALINFOSTART", "TXEND")
+	manage.SetEnabled(false)
+
+	chain, blocks := generatePluginTestChain(t, config, nil, 1, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &common.Address{0xaa}, big.NewInt(1), params.TxGas, nil))
+		b.AddTx(pluginTestTx(config, b, nil, common.Big0, 100000, []byte{0x00}))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 0 || manage.EventCount("TXSTART") != 0 {
+		t.Errorf("disabled plugin subsystem emitted %d events", len(*events))
+	}
+}
+
+func TestPluginOverheadReport(t *testing.T) {
+	config := pluginTestConfig()
+	recordOpcodes(config.TransferDataPlg, "TXSTART", "TRANS_CALL", "TXEND")
+
+	caller, target := common.Address{0xc0}, common.Address{0xc1}
+	alloc := GenesisAlloc{
+		caller: {Code: pluginCallCode(target, target), Balance: common.Big0},
+		target: {Code: []byte{0x00}, Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 2, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 200000, nil))
+		b.AddTx(pluginTestTx(config, b, &caller, common.Big0, 200000, nil))
+	})
+	const rounds = 3
+	report, err := MeasurePluginOverhead(chain, blocks, rounds)
+	if err != nil {
+		t.Fatalf("measurement failed: %v", err)
+	}
+	if report.BaseTime <= 0 || report.PluginTime <= 0 || report.Time() < 0 {
+		t.Errorf("implausible timings: base %v, plugins %v, overhead %v", report.BaseTime, report.PluginTime, report.Time())
+	}
+	if want := blocks[0].GasUsed() + blocks[1].GasUsed(); report.GasUsed != want {
+		t.Errorf("report covers %d gas, want %d", report.GasUsed, want)
+	}
+	events := make(map[string]uint64)
+	for _, op := range report.Opcodes {
+		events[op.Opcode] = op.Events
+	}
+	if events["TXSTART"] != 4*rounds || events["TRANS_CALL"] != 8*rounds {
+		t.Errorf("opcode breakdown %v, want 4 TXSTART and 8 TRANS_CALL per round", events)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 2 {
+		t.Errorf("chain head %d after measuring, want 2", head)
+	}
+	if !config.TransferDataPlg.Enabled() {
+		t.Error("measurement left the plugin subsystem disabled")
+	}
+}
+
+// BenchmarkPluginOverhead compares block processing with the plugin subsystem
+// enabled but nothing subscribed against the subsystem switched off.
+func BenchmarkPluginOverhead(b *testing.B) {
+	for _, bench := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"enabled-no-plugins", true},
+		{"disabled", false},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			config := pluginTestConfig()
+			config.TransferDataPlg.SetEnabled(bench.enabled)
+
+			looper, target := common.Address{0xb1}, common.Address{0xb2}
+			code := []byte{0x60, 0x20, 0x5b} // PUSH1 32 JUMPDEST
+			code = append(code, pluginCallCode(target)...)
+			code = append(code[:len(code)-1], 0x60, 0x01, 0x90, 0x03, 0x80, 0x60, 0x02, 0x57, 0x00)
+			alloc := GenesisAlloc{
+				looper: {Code: code, Balance: common.Big0},
+				target: {Code: []byte{0x60, 0x01, 0x60, 0x01, 0x01, 0x50, 0x00}, Balance: common.Big0},
+			}
+			chain, blocks := generatePluginTestChain(b, config, alloc, 1, func(i int, block *BlockGen) {
+				for j := 0; j < 20; j++ {
+					block.AddTx(pluginTestTx(config, block, &looper, common.Big0, 500000, nil))
+				}
+			})
+			root := chain.Genesis().Root()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				statedb, _ := state.New(root, chain.StateCache(), nil)
+				if _, _, _, err := chain.Processor().Process(blocks[0], statedb, vm.Config{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPluginEnforceSnapshot compares importing a block with a monitor
+// plugin and with an enforce plugin, which has the manager record the
+// snapshot of every transaction.
+func BenchmarkPluginEnforceSnapshot(b *testing.B) {
+	for _, mode := range []string{"monitor", "enforce"} {
+		b.Run(mode, func(b *testing.B) {
+			config := pluginTestConfig()
+			monitor := new(pluginManage.MonitorType)
+			monitor.SetPluginName(mode)
+			monitor.SetMode(mode)
+			monitor.SetSendFunc(func(*collector.AllCollector) (byte, string) {
+				return 0x00, ""
+			})
+			config.TransferDataPlg.RegisterOpcode("TXEND", monitor)
+
+			payee := common.Address{0xb1}
+			chain, blocks := generatePluginTestChain(b, config, nil, 1, func(i int, block *BlockGen) {
+				for j := 0; j < 100; j++ {
+					block.AddTx(pluginTestTx(config, block, &payee, big.NewInt(1), params.TxGas, nil))
+				}
+			})
+			root := chain.Genesis().Root()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				statedb, _ := state.New(root, chain.StateCache(), nil)
+				if _, _, _, err := chain.Processor().Process(blocks[0], statedb, vm.Config{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestPluginRedeploy(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "handle_REDEPLOY")
+
+	// The factory runs CREATE2 with salt 0 on its call data. The init code
+	// deploys PUSH1 <block number> SELFDESTRUCT, so the same init code, and
+	// so the same address, yields different code in every block.
+	factory := common.Address{0xe1}
+	factoryCode := []byte{
+		0x36, 0x60, 0x00, 0x60, 0x00, 0x37, // CALLDATACOPY(0, 0, CALLDATASIZE)
+		0x60, 0x00, 0x36, 0x60, 0x00, 0x60, 0x00, 0xf5, // CREATE2(0, 0, CALLDATASIZE, 0)
+		0x00,
+	}
+	initCode := []byte{
+		0x60, 0x60, 0x60, 0x00, 0x53, // MSTORE8(0, PUSH1)
+		0x43, 0x60, 0x01, 0x53, // MSTORE8(1, NUMBER)
+		0x60, 0xff, 0x60, 0x02, 0x53, // MSTORE8(2, SELFDESTRUCT)
+		0x60, 0x03, 0x60, 0x00, 0xf3, // RETURN(0, 3)
+	}
+	child := crypto.CreateAddress2(factory, [32]byte{}, crypto.Keccak256(initCode))
+	alloc := GenesisAlloc{factory: {Code: factoryCode, Balance: common.Big0}}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 2, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &factory, common.Big0, 200000, initCode))
+		if i == 0 {
+			b.AddTx(pluginTestTx(config, b, &child, common.Big0, 50000, nil))
+		}
+	})
+	if _, err := chain.InsertChain(blocks[:1]); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 0 {
+		t.Fatalf("first deployment flagged: %+v", *events)
+	}
+	if codeHash, ok := manage.Destroyed(child); !ok || codeHash != crypto.Keccak256Hash([]byte{0x60, 0x01, 0xff}) {
+		t.Fatalf("destroyed child recorded as %x (%v)", codeHash, ok)
+	}
+	if _, err := chain.InsertChain(blocks[1:]); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 1 {
+		t.Fatalf("got %d handle_REDEPLOY events, want 1", len(*events))
+	}
+	info := (*events)[0].TransInfo
+	want := collector.RedeployCollector{
+		Address:     child.String(),
+		OldCodeHash: crypto.Keccak256Hash([]byte{0x60, 0x01, 0xff}).String(),
+		NewCodeHash: crypto.Keccak256Hash([]byte{0x60, 0x02, 0xff}).String(),
+	}
+	if info.RedeployInfo != want || info.From != factory.String() || info.CallType != "CREATE2" {
+		t.Errorf("handle_REDEPLOY from %s (%s) %+v, want %+v", info.From, info.CallType, info.RedeployInfo, want)
+	}
+}
+
+// verifyPluginProof checks proof, as handle_ACCOUNT_PROOF encodes it, against
+// root and returns the value it proves key holds.
+func verifyPluginProof(t *testing.T, root common.Hash, key []byte, proof []string) []byte {
+	t.Helper()
+	db := memorydb.New()
+	for _, encoded := range proof {
+		node := hexutil.MustDecode(encoded)
+		db.Put(crypto.Keccak256(node), node)
+	}
+	value, err := trie.VerifyProof(root, crypto.Keccak256(key), db)
+	if err != nil {
+		t.Fatalf("proof of %x does not verify against %x: %v", key, root, err)
+	}
+	return value
+}
+
+func TestPluginAccountProof(t *testing.T) {
+	config := pluginTestConfig()
+	manage := config.TransferDataPlg
+	events := recordOpcodes(manage, "handle_ACCOUNT_PROOF")
+
+	contract, recipient, absent := common.Address{0xf1}, common.Address{0xf2}, common.Address{0xf3}
+	slot := common.Hash{31: 0x01}
+	alloc := GenesisAlloc{
+		contract: {Code: []byte{0x00}, Balance: common.Big1, Storage: map[common.Hash]common.Hash{slot: {31: 0x2a}}},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 2, func(i int, b *BlockGen) {
+		b.AddTx(pluginTestTx(config, b, &recipient, big.NewInt(5), params.TxGas, nil))
+	})
+	// Without watched addresses nothing is proven.
+	if _, err := chain.InsertChain(blocks[:1]); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 0 {
+		t.Fatalf("proofs emitted without watched addresses: %+v", *events)
+	}
+	manage.SetProofAccounts([]pluginManage.ProofAccount{
+		{Address: contract, Slots: []common.Hash{slot, {31: 0x02}}},
+		{Address: recipient},
+		{Address: absent},
+	})
+	if _, err := chain.InsertChain(blocks[1:]); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 1 {
+		t.Fatalf("got %d handle_ACCOUNT_PROOF events, want 1", len(*events))
+	}
+	info := (*events)[0].BlockInfo
+	root := blocks[1].Root()
+	if info.StateRoot != root.String() || len(info.AccountProofs) != 3 {
+		t.Fatalf("proofs against %s for %d accounts, want %s and 3", info.StateRoot, len(info.AccountProofs), root)
+	}
+	wantBalances := []*big.Int{common.Big1, big.NewInt(10), nil}
+	for i, address := range []common.Address{contract, recipient, absent} {
+		proof := info.AccountProofs[i]
+		value := verifyPluginProof(t, root, address.Bytes(), proof.Proof)
+		if wantBalances[i] == nil {
+			if value != nil {
+				t.Errorf("absent account proven to hold %x", value)
+			}
+			continue
+		}
+		var account types.StateAccount
+		if err := rlp.DecodeBytes(value, &account); err != nil {
+			t.Fatal(err)
+		}
+		if account.Balance.Cmp(wantBalances[i]) != 0 || proof.Balance != wantBalances[i].String() || account.Root.String() != proof.StorageHash {
+			t.Errorf("account %x proven with balance %v and storage %x, event says %s and %s", address, account.Balance, account.Root, proof.Balance, proof.StorageHash)
+		}
+	}
+	storage := info.AccountProofs[0].Storage
+	if len(storage) != 2 || storage[0].Value != "42" || storage[1].Value != "0" {
+		t.Fatalf("storage proofs %+v", storage)
+	}
+	storageRoot := common.HexToHash(info.AccountProofs[0].StorageHash)
+	var stored []byte
+	if err := rlp.DecodeBytes(verifyPluginProof(t, storageRoot, slot.Bytes(), storage[0].Proof), &stored); err != nil || new(big.Int).SetBytes(stored).Int64() != 42 {
+		t.Errorf("slot proven to hold %x (%v), want 42", stored, err)
+	}
+	if value := verifyPluginProof(t, storageRoot, common.Hash{31: 0x02}.Bytes(), storage[1].Proof); value != nil {
+		t.Errorf("empty slot proven to hold %x", value)
+	}
+}
+
+func TestPluginTxCallComplexity(t *testing.T) {
+	config := pluginTestConfig()
+	events := recordOpcodes(config.TransferDataPlg, "TXEND")
+
+	// a calls b and then the account d; b calls c, which calls the account e.
+	a, b, c := common.Address{0xc1}, common.Address{0xc2}, common.Address{0xc3}
+	d, e := common.Address{0xc4}, common.Address{0xc5}
+	alloc := GenesisAlloc{
+		a: {Code: pluginCallCode(b, d), Balance: common.Big0},
+		b: {Code: pluginCallCode(c), Balance: common.Big0},
+		c: {Code: pluginCallCode(e), Balance: common.Big0},
+	}
+	chain, blocks := generatePluginTestChain(t, config, alloc, 1, func(i int, block *BlockGen) {
+		block.AddTx(pluginTestTx(config, block, &a, common.Big0, 200000, nil))
+		block.AddTx(pluginTestTx(config, block, &d, big.NewInt(1), params.TxGas, nil))
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(*events) != 2 {
+		t.Fatalf("got %d TXEND events, want 2", len(*events))
+	}
+	want := [][2]int{{4, 4}, {0, 1}}
+	for i, event := range *events {
+		info := event.TransInfo
+		if info.InternalCalls != want[i][0] || info.MaxCallDepth != want[i][1] {
+			t.Errorf("transaction %d: %d internal calls %d deep, want %d and %d", i, info.InternalCalls, info.MaxCallDepth, want[i][0], want[i][1])
+		}
+	}
+}
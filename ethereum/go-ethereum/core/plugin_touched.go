@@ -0,0 +1,31 @@
+package core
+
+//add new file
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zhidandeng/collector"
+)
+
+// pluginTouchedSet turns the access list a transaction ended with into the
+// handle_TOUCHED_SET payload. The precompiles are warm from the start of
+// every transaction whether it calls them or not and are left out.
+func pluginTouchedSet(list types.AccessList, precompiles []common.Address) []collector.TouchedCollector {
+	skip := make(map[common.Address]bool, len(precompiles))
+	for _, addr := range precompiles {
+		skip[addr] = true
+	}
+	touched := make([]collector.TouchedCollector, 0, len(list))
+	for _, tuple := range list {
+		if skip[tuple.Address] {
+			continue
+		}
+		slots := make([]string, len(tuple.StorageKeys))
+		for i, slot := range tuple.StorageKeys {
+			slots[i] = slot.String()
+		}
+		touched = append(touched, collector.TouchedCollector{Address: tuple.Address.String(), Slots: slots})
+	}
+	return touched
+}
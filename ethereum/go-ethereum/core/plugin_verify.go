@@ -0,0 +1,38 @@
+package core
+
+//add new file
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/cmd/pluginManage"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// VerifyPluginReplay processes block a second time on the state of its parent
+// and compares the plugin events of that replay with the ones live recorded
+// when the block was imported. It returns a *pluginManage.StreamDivergence
+// for the first event that is not byte for byte the same. The replay goes
+// through the plugins like any import, so it should run on a node whose
+// plugins tolerate seeing a block twice.
+func (bc *BlockChain) VerifyPluginReplay(block *types.Block, live *pluginManage.EventRecorder) error {
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return fmt.Errorf("parent of block %d unknown", block.NumberU64())
+	}
+	statedb, err := bc.StateAt(parent.Root)
+	if err != nil {
+		return err
+	}
+	// a recorder still attached also records the replay
+	recorded := live.Events(block.NumberU64())
+	manage := bc.chainConfig.TransferDataPlg
+	replay := pluginManage.NewEventRecorder(live.WallClock())
+	manage.AddExporter(replay)
+	defer manage.RemoveExporter(replay)
+
+	if _, _, _, err := bc.processor.Process(block, statedb, bc.vmConfig); err != nil {
+		return fmt.Errorf("replay of block %d failed: %w", block.NumberU64(), err)
+	}
+	return pluginManage.DiffStreams(recorded, replay.Events(block.NumberU64()))
+}
@@ -18,6 +18,7 @@
 package state
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
@@ -1056,3 +1057,29 @@ func (s *StateDB) AddressInAccessList(addr common.Address) bool {
 func (s *StateDB) SlotInAccessList(addr common.Address, slot common.Hash) (addressPresent bool, slotPresent bool) {
 	return s.accessList.Contains(addr, slot)
 }
+
+//add
+// AccessList returns the addresses and storage slots in the access list of the
+// transaction being executed, sorted. What a reverted call added is no longer
+// in it.
+func (s *StateDB) AccessList() types.AccessList {
+	list := make(types.AccessList, 0, len(s.accessList.addresses))
+	for addr, idx := range s.accessList.addresses {
+		tuple := types.AccessTuple{Address: addr, StorageKeys: []common.Hash{}}
+		if idx >= 0 {
+			for slot := range s.accessList.slots[idx] {
+				tuple.StorageKeys = append(tuple.StorageKeys, slot)
+			}
+			sort.Slice(tuple.StorageKeys, func(i, j int) bool {
+				return bytes.Compare(tuple.StorageKeys[i][:], tuple.StorageKeys[j][:]) < 0
+			})
+		}
+		list = append(list, tuple)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return bytes.Compare(list[i].Address[:], list[j].Address[:]) < 0
+	})
+	return list
+}
+
+//add
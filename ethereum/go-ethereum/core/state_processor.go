@@ -17,14 +17,14 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"github.com/zhidandeng/collector"
-	"github.com/ethereum/go-ethereum/cmd/pluginManage"
-	"github.com/ethereum/go-ethereum/dan"
-	"github.com/ethereum/go-ethereum/dzd"
 	"math/big"
 	"strconv"
+	"time"
 
+	"github.com/ethereum/go-ethereum/cmd/pluginManage"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/misc"
@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -43,6 +44,9 @@ type StateProcessor struct {
 	config *params.ChainConfig // Chain configuration options
 	bc     *BlockChain         // Canonical block chain
 	engine consensus.Engine    // Consensus engine used for block rewards
+	//add
+	plugins *pluginManage.PluginManages // Plugin manager fed by this processor
+	//add
 }
 
 // NewStateProcessor initialises a new StateProcessor.
@@ -51,9 +55,25 @@ func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consen
 		config: config,
 		bc:     bc,
 		engine: engine,
+		//add
+		plugins: config.TransferDataPlg,
+		//add
 	}
 }
 
+//add
+// SetPlugins gives the processor a plugin manager of its own instead of the
+// one of its chain config, so that processors sharing a config do not share
+// their plugins and transaction state.
+func (p *StateProcessor) SetPlugins(manage *pluginManage.PluginManages) {
+	p.plugins = manage
+	if manage != nil && p.bc != nil {
+		manage.SetStateAt(p.bc.pluginStateAt)
+	}
+}
+
+//add
+
 // Process processes the state changes according to the Ethereum rules by running
 // the transaction messages using the statedb and applying any rewards to both
 // the processor (coinbase) and any included uncles.
@@ -62,6 +82,15 @@ func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consen
 // returns the amount of gas that was used in the process. If any of the
 // transactions failed to execute due to insufficient gas it will return an error.
 func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	return p.ProcessContext(context.Background(), block, statedb, cfg)
+}
+
+//add
+// ProcessContext is Process bound to ctx. Once ctx is cancelled the plugin
+// dispatch stops delivering events and processing ends at the next
+// transaction boundary with an error wrapping ctx.Err().
+func (p *StateProcessor) ProcessContext(ctx context.Context, block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	//add
 	var (
 		receipts    types.Receipts
 		usedGas     = new(uint64)
@@ -76,7 +105,22 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		misc.ApplyDAOHardFork(statedb)
 	}
 	//add
-	if p.config.TransferDataPlg.GetOpcodeRegister("handle_BLOCK_INFO") {
+	// The EVM and the transactions reach the plugins through the chain
+	// config, they get a copy carrying the manager of this processor.
+	config := p.config
+	if config.TransferDataPlg != p.plugins {
+		scoped := *p.config
+		scoped.TransferDataPlg = p.plugins
+		config = &scoped
+	}
+	plugins := p.plugins.Enabled()
+	if plugins {
+		p.plugins.SetContext(ctx)
+		p.plugins.SetBlockContext(p.config.ChainID, header.Number)
+		// drop what a failed or mined block left in the block buffers
+		p.plugins.DiscardBlock()
+	}
+	if p.plugins.GetOpcodeRegister("handle_BLOCK_INFO") {
 		blockcollector := collector.NewBlockCollector()
 		blockcollector.Op = "Block" + fmt.Sprintf("%v", header.Number)
 		blockcollector.ParentHash = header.ParentHash.String()
@@ -93,20 +137,73 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		blockcollector.Extra = header.Extra
 		blockcollector.MixDigest = header.MixDigest.String()
 		blockcollector.Nonce = header.Nonce.Uint64()
-		p.config.TransferDataPlg.SendDataToPlugin("handle_BLOCK_INFO", blockcollector.SendBlockInfo("handle_BLOCK_INFO"))
+		if ptd := p.bc.GetTd(header.ParentHash, header.Number.Uint64()-1); ptd != nil {
+			blockcollector.TotalDifficulty = new(big.Int).Add(ptd, header.Difficulty).String()
+		}
+		if header.BaseFee != nil {
+			blockcollector.BaseFee = header.BaseFee.String()
+		}
+		blockcollector.Signer, blockcollector.ExtraTag = p.pluginExtraInfo(header)
+		p.plugins.SendDataToPlugin("handle_BLOCK_INFO", blockcollector.SendBlockInfo("handle_BLOCK_INFO"))
+	}
+	if p.plugins.GetOpcodeRegister("handle_FORK_RULES") {
+		blockcollector := collector.NewBlockCollector()
+		blockcollector.Op = "Block" + fmt.Sprintf("%v", header.Number)
+		blockcollector.Number = header.Number.String()
+		forkrules := collector.NewForkRulesCollector()
+		forkrules.Homestead = p.config.IsHomestead(header.Number)
+		forkrules.EIP150 = p.config.IsEIP150(header.Number)
+		forkrules.EIP155 = p.config.IsEIP155(header.Number)
+		forkrules.EIP158 = p.config.IsEIP158(header.Number)
+		forkrules.Byzantium = p.config.IsByzantium(header.Number)
+		forkrules.Constantinople = p.config.IsConstantinople(header.Number)
+		forkrules.Petersburg = p.config.IsPetersburg(header.Number)
+		forkrules.Istanbul = p.config.IsIstanbul(header.Number)
+		forkrules.Berlin = p.config.IsBerlin(header.Number)
+		forkrules.London = p.config.IsLondon(header.Number)
+		forkrules.Shanghai = p.config.IsShanghai(header.Number)
+		forkrules.Cancun = p.config.IsCancun(header.Number)
+		blockcollector.ForkRules = *forkrules
+		p.plugins.SendDataToPlugin("handle_FORK_RULES", blockcollector.SendBlockInfo("handle_FORK_RULES"))
 	}
 	//add
 	blockContext := NewEVMBlockContext(header, p.bc, nil)
-	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, p.config, cfg)
+	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, config, cfg)
+	//add
+	vmenv.SetTxStart(plugins)
+	//add
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
+		//add
+		if err := ctx.Err(); err != nil {
+			if plugins {
+				p.plugins.DiscardBlock()
+			}
+			return nil, nil, 0, fmt.Errorf("processing stopped before tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+		}
+		//add
 		msg, err := tx.AsMessage(types.MakeSigner(p.config, header.Number), header.BaseFee)
 		if err != nil {
+			//add
+			if plugins {
+				p.plugins.DiscardBlock()
+			}
+			//add
 			return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
 		}
 		statedb.Prepare(tx.Hash(), i)
-		receipt, err := applyTransaction(msg, p.config, nil, gp, statedb, blockNumber, blockHash, tx, usedGas, vmenv)
+		//add
+		if plugins {
+			pluginTxStart(vmenv, msg, tx, blockContext)
+		}
+		//add
+		receipt, err := applyTransaction(msg, config, nil, gp, statedb, blockNumber, blockHash, tx, usedGas, vmenv)
 		if err != nil {
+			//add
+			if plugins {
+				p.plugins.DiscardBlock()
+			}
+			//add
 			return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
 		}
 		receipts = append(receipts, receipt)
@@ -115,6 +212,40 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
 	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles())
 
+	//add
+	if p.plugins.GetOpcodeRegister("handle_BLOCK_END") {
+		blockcollector := collector.NewBlockCollector()
+		blockcollector.Op = "Block" + fmt.Sprintf("%v", header.Number)
+		blockcollector.Number = header.Number.String()
+		blockcollector.GasLimit = header.GasLimit
+		blockcollector.GasUsed = *usedGas
+		blockcollector.Time = header.Time
+		p.plugins.SendDataToPlugin("handle_BLOCK_END", blockcollector.SendBlockInfo("handle_BLOCK_END"))
+	}
+	if p.plugins.GetOpcodeRegister("handle_BLOCK_GAS_STATS") {
+		blockcollector := collector.NewBlockCollector()
+		blockcollector.Op = "Block" + fmt.Sprintf("%v", header.Number)
+		blockcollector.Number = header.Number.String()
+		blockcollector.GasStats = pluginGasStats(block.Transactions(), receipts, header.BaseFee)
+		p.plugins.SendDataToPlugin("handle_BLOCK_GAS_STATS", blockcollector.SendBlockInfo("handle_BLOCK_GAS_STATS"))
+	}
+	if plugins && p.plugins.GetOpcodeRegister("handle_ACCOUNT_PROOF") {
+		if accounts := p.plugins.ProofAccounts(); len(accounts) > 0 {
+			// the root the validator is about to check the header against
+			root := statedb.IntermediateRoot(p.config.IsEIP158(header.Number))
+			blockcollector := collector.NewBlockCollector()
+			blockcollector.Op = "Block" + fmt.Sprintf("%v", header.Number)
+			blockcollector.Number = header.Number.String()
+			blockcollector.StateRoot = root.String()
+			blockcollector.AccountProofs = pluginAccountProofs(statedb, root, accounts)
+			p.plugins.SendDataToPlugin("handle_ACCOUNT_PROOF", blockcollector.SendBlockInfo("handle_ACCOUNT_PROOF"))
+		}
+	}
+	if plugins {
+		p.plugins.FlushBlock()
+		p.plugins.EndBlock()
+	}
+	//add
 	return receipts, allLogs, *usedGas, nil
 }
 
@@ -124,20 +255,46 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, author *com
 	evm.Reset(txContext, statedb)
 
 	// Apply the transaction to the current state (included in the env).
+	//add
+	execStart := time.Now()
+	//add
 	result, err := ApplyMessage(evm, msg, gp)
 	//add
-
-	if dzd.BLOCKING_FLAG == true {
-		statedb.RevertToSnapshot(dzd.PLUGIN_SNAPSHOT_ID)
+	execDuration := time.Since(execStart)
+	plugins := config.TransferDataPlg.Enabled()
+	txstate := config.TransferDataPlg.TxState()
+	if plugins && txstate.BLOCKING_FLAG == true && !txstate.PLUGIN_SNAPSHOT_FLAG {
+		// no enforce plugin, no snapshot was recorded to revert to
+		log.Warn("Plugin block decision ignored, no enforce plugin registered", "tx", tx.Hash(), "plugin", txstate.BLOCKING_PLUGIN, "reason", txstate.BLOCKING_REASON)
+	} else if plugins && txstate.BLOCKING_FLAG == true {
+		statedb.RevertToSnapshot(txstate.PLUGIN_SNAPSHOT_ID)
+		if config.TransferDataPlg.GetOpcodeRegister("handle_STATE_REVERTED") {
+			tcrevert := collector.NewTransCollector()
+			tcrevert.Op = "handle_STATE_REVERTED"
+			tcrevert.TxHash = tx.Hash().String()
+			revertcollector := collector.NewRevertCollector()
+			revertcollector.SnapshotID = txstate.PLUGIN_SNAPSHOT_ID
+			revertcollector.Plugin = txstate.BLOCKING_PLUGIN
+			revertcollector.Reason = txstate.BLOCKING_REASON
+			tcrevert.RevertInfo = *revertcollector
+			config.TransferDataPlg.SendDataToPlugin("handle_STATE_REVERTED", tcrevert.SendTransInfo("handle_STATE_REVERTED"))
+		}
+	}
+	// only touched behind GetOpcodeRegister, which is false when disabled
+	var tcend *collector.TransCollector
+	if plugins {
+		tcend = collector.NewTransCollector()
 	}
-	tcend := collector.NewTransCollector()
 
 	vmenv := evm
 	if vmenv.ChainConfig().TransferDataPlg.GetOpcodeRegister("EXTERNALINFOEND") {
 		tcend.Op = "EXTERNALINFOEND"
 		tcend.TxHash = tx.Hash().String()
-		tcend.GasUsed = result.UsedGas
-		tcend.CallLayer = 1
+		tcend.ExecDuration = execDuration.Nanoseconds()
+		tcend.CallLayer = txstate.CallDepth()
+		if vmenv.ChainConfig().TransferDataPlg.WantsField(pluginManage.FieldPostState) {
+			tcend.PostState = *pluginPostState(vmenv.StateDB, msg.From(), msg.To())
+		}
 	}
 	//add
 
@@ -145,11 +302,20 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, author *com
 		//add
 		if vmenv.ChainConfig().TransferDataPlg.GetOpcodeRegister("EXTERNALINFOEND") {
 			tcend.IsSuccess = false
+			tcend.FailureReason = pluginFailureReason(err)
 			vmenv.ChainConfig().TransferDataPlg.SendDataToPlugin("EXTERNALINFOEND", tcend.SendTransInfo("EXTERNALINFOEND"))
 		}
 		//add
 		return nil, err
 	}
+	//add
+	// result is nil when the message could not be applied at all
+	if vmenv.ChainConfig().TransferDataPlg.GetOpcodeRegister("EXTERNALINFOEND") {
+		tcend.GasUsed = result.UsedGas
+		tcend.IntrinsicGas = result.IntrinsicGas
+		tcend.ExecutionGas = result.UsedGas - result.IntrinsicGas
+	}
+	//add
 
 	// Update the state with pending changes.
 	var root []byte
@@ -158,6 +324,11 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, author *com
 	} else {
 		root = statedb.IntermediateRoot(config.IsEIP158(blockNumber)).Bytes()
 	}
+	//add
+	if plugins && len(txstate.SELFDESTRUCTED) > 0 {
+		pluginRecordDestroyed(config.TransferDataPlg, statedb, txstate.SELFDESTRUCTED)
+	}
+	//add
 	*usedGas += result.UsedGas
 
 	// Create a new receipt for the transaction, storing the intermediate root and gas used
@@ -181,9 +352,18 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, author *com
 			createcollector := collector.NewCreateCollector()
 			createcollector.ContractAddr = receipt.ContractAddress.String()
 			createcollector.ContractDeployCode = msg.Data()
+			createcollector.Deployer = evm.TxContext.Origin.String()
+			createcollector.DeployerNonce = tx.Nonce()
+			createcollector.Attempt = 1
+			var runtimecode []byte
 			if vmenv.StateDB.Exist(receipt.ContractAddress) {
-				createcollector.ContractRuntimeCode = vmenv.StateDB.GetCode(receipt.ContractAddress)
+				runtimecode = vmenv.StateDB.GetCode(receipt.ContractAddress)
+			}
+			// oversized code is returned but never stored
+			if result.Err == vm.ErrMaxCodeSizeExceeded {
+				runtimecode = result.ReturnData
 			}
+			createcollector.SetRuntimeCode(runtimecode, params.MaxCodeSize)
 			tcend.CreateInfo = *createcollector
 		}
 		//add
@@ -205,15 +385,37 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, author *com
 	} else {
 		if vmenv.ChainConfig().TransferDataPlg.GetOpcodeRegister("EXTERNALINFOEND") {
 			tcend.IsSuccess = false
+			tcend.FailureReason = pluginFailureReason(result.Err)
 			vmenv.ChainConfig().TransferDataPlg.SendDataToPlugin("EXTERNALINFOEND", tcend.SendTransInfo("EXTERNALINFOEND"))
 		}
 	}
 
-	dzd.CALL_STACK = dzd.CALL_STACK[:len(dzd.CALL_STACK)-1]
-
-	if vmenv.ChainConfig().TransferDataPlg.GetOpcodeRegister("TXEND") {
-		vmenv.ChainConfig().TransferDataPlg.SendDataToPlugin("TXEND", collector.SendFlag("TXEND"))
-		vmenv.ChainConfig().TransferDataPlg.Stop()
+	if plugins {
+		txstate.CALL_STACK = txstate.CALL_STACK[:len(txstate.CALL_STACK)-1]
+
+		vmenv.ChainConfig().TransferDataPlg.EndTx()
+		if vmenv.ChainConfig().TransferDataPlg.GetOpcodeRegister("handle_TOUCHED_SET") {
+			tctouched := collector.NewTransCollector()
+			tctouched.Op = "handle_TOUCHED_SET"
+			tctouched.TxHash = tx.Hash().String()
+			rules := config.Rules(blockNumber, evm.Context.Random != nil)
+			tctouched.Touched = pluginTouchedSet(statedb.AccessList(), vm.ActivePrecompiles(rules))
+			vmenv.ChainConfig().TransferDataPlg.SendDataToPlugin("handle_TOUCHED_SET", tctouched.SendTransInfo("handle_TOUCHED_SET"))
+		}
+		if vmenv.ChainConfig().TransferDataPlg.GetOpcodeRegister("handle_TX_SUMMARY") {
+			tcsummary := pluginTxSummary(msg, tx, receipt, result)
+			vmenv.ChainConfig().TransferDataPlg.SendDataToPlugin("handle_TX_SUMMARY", tcsummary.SendTransInfo("handle_TX_SUMMARY"))
+		}
+		if vmenv.ChainConfig().TransferDataPlg.GetOpcodeRegister("TXEND") {
+			tctxend := collector.NewTransCollector()
+			tctxend.Op = "TXEND"
+			tctxend.TxHash = tx.Hash().String()
+			tctxend.Tags = vmenv.ChainConfig().TransferDataPlg.TxTags()
+			tctxend.InternalCalls = txstate.InternalCalls()
+			tctxend.MaxCallDepth = txstate.MAX_CALL_DEPTH
+			vmenv.ChainConfig().TransferDataPlg.SendDataToPlugin("TXEND", tctxend.SendTransInfo("TXEND"))
+			vmenv.ChainConfig().TransferDataPlg.Stop()
+		}
 	}
 	//add
 	return receipt, err
@@ -233,45 +435,87 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, config, cfg)
 
 	//add
-	vmenv.SetTxStart(true)
-	vmenv.ChainConfig().TransferDataPlg.Start()
-
-	if dan.IsReg {
-		// //whole folder fresh有问题，如果全部移除，是无法删掉旧的的。需要用new去新增
-		// pluginManage.StartRun(vmenv.ChainConfig().TransferDataPlg)
-		// dan.IsReg = false
-
-		//single plugin
-		pluginManage.RegisterPlugin(vmenv.ChainConfig().TransferDataPlg, dan.RegPath)
-		dan.RegPath = dan.Clear
-		dan.IsReg = false
+	if config.TransferDataPlg.Enabled() {
+		vmenv.SetTxStart(true)
+		vmenv.ChainConfig().TransferDataPlg.SetBlockContext(config.ChainID, header.Number)
+		pluginTxStart(vmenv, msg, tx, blockContext)
 	}
+	//add
 
-	if dan.IsUn {
-		vmenv.ChainConfig().TransferDataPlg.UnRegisterPlg()
-		dan.IsUn = false
-		dan.UnPlg = dan.Clear
-	}
+	return applyTransaction(msg, config, author, gp, statedb, header.Number, header.Hash(), tx, usedGas, vmenv)
+}
 
-	dzd.CALL_LAYER = 0
-	dzd.CALL_STACK = nil
-	dzd.ALL_STACK = nil
-	dzd.EXTERNAL_FLAG = true
-	dzd.BLOCKING_FLAG = false
-	dzd.PLUGIN_SNAPSHOT_ID = 0
-	dzd.CALLVALID_MAP = make(map[int]bool)
-	dzd.TxHash = tx.Hash().String()
+//add
+// pluginTxStart resets the per-transaction plugin state and emits the TXSTART
+// and EXTERNALINFOSTART events of tx. Process and ApplyTransaction share it so
+// imported and mined blocks produce the same event stream.
+func pluginTxStart(vmenv *vm.EVM, msg types.Message, tx *types.Transaction, blockContext vm.BlockContext) {
+	vmenv.ChainConfig().TransferDataPlg.Start()
+	vmenv.ChainConfig().TransferDataPlg.BeginTx(tx.Hash(), msg.From(), msg.To())
+
+	vmenv.ChainConfig().TransferDataPlg.ApplyRequests()
+
+	txstate := vmenv.ChainConfig().TransferDataPlg.TxState()
+	txstate.CALL_LAYER = 0
+	txstate.CALL_STACK = nil
+	txstate.ALL_STACK = nil
+	// the snapshot of the external call is only recorded when a plugin may
+	// block the transaction
+	txstate.PLUGIN_SNAPSHOT_FLAG = vmenv.ChainConfig().TransferDataPlg.Enforcing()
+	txstate.EXTERNAL_FLAG = txstate.PLUGIN_SNAPSHOT_FLAG
+	txstate.BLOCKING_FLAG = false
+	txstate.BLOCKING_PLUGIN = ""
+	txstate.BLOCKING_REASON = ""
+	txstate.PLUGIN_SNAPSHOT_ID = 0
+	txstate.CALLVALID_MAP = make(map[int]bool)
+	txstate.TxHash = tx.Hash().String()
+	txstate.SELFDESTRUCTED = nil
+	txstate.MAX_CALL_DEPTH = 0
+	txstate.CREATE_ATTEMPTS = 0
+	if msg.To() == nil {
+		txstate.CREATE_ATTEMPTS = 1
+	}
 
 	if msg.To() != nil {
-		dzd.CALL_LAYER += 1
-		dzd.CALL_STACK = append(dzd.CALL_STACK, msg.To().String()+"#"+strconv.Itoa(dzd.CALL_LAYER))
-		dzd.ALL_STACK = append(dzd.ALL_STACK, msg.To().String())
+		txstate.CALL_LAYER += 1
+		txstate.CALL_STACK = append(txstate.CALL_STACK, msg.To().String()+"#"+strconv.Itoa(txstate.CALL_LAYER))
+		txstate.NoteDepth()
+		txstate.ALL_STACK = append(txstate.ALL_STACK, msg.To().String())
 	}
 
 	if vmenv.ChainConfig().TransferDataPlg.GetOpcodeRegister("TXSTART") {
 		vmenv.ChainConfig().TransferDataPlg.SendDataToPlugin("TXSTART", collector.SendFlag("TXSTART"))
 	}
 
+	// a nonce out of sequence fails the transaction, report it before it does
+	if expected := vmenv.StateDB.GetNonce(msg.From()); tx.Nonce() != expected && vmenv.ChainConfig().TransferDataPlg.GetOpcodeRegister("handle_NONCE_ANOMALY") {
+		tcnonce := collector.NewTransCollector()
+		tcnonce.Op = "handle_NONCE_ANOMALY"
+		tcnonce.TxHash = tx.Hash().String()
+		tcnonce.From = msg.From().String()
+		tcnonce.Nonce = tx.Nonce()
+		noncecollector := collector.NewNonceCollector()
+		noncecollector.Sender = msg.From().String()
+		noncecollector.Expected = expected
+		noncecollector.Actual = tx.Nonce()
+		tcnonce.NonceInfo = *noncecollector
+		vmenv.ChainConfig().TransferDataPlg.SendDataToPlugin("handle_NONCE_ANOMALY", tcnonce.SendTransInfo("handle_NONCE_ANOMALY"))
+	}
+	if vmenv.ChainConfig().TransferDataPlg.GetOpcodeRegister("handle_TX_ANOMALY") {
+		for _, anomaly := range vmenv.ChainConfig().TransferDataPlg.TxAnomalies(msg.Data(), msg.Value()) {
+			tcanomaly := collector.NewTransCollector()
+			tcanomaly.Op = "handle_TX_ANOMALY"
+			tcanomaly.TxHash = tx.Hash().String()
+			tcanomaly.From = msg.From().String()
+			if msg.To() != nil {
+				tcanomaly.To = msg.To().String()
+			}
+			tcanomaly.Value = msg.Value().String()
+			tcanomaly.AnomalyInfo = anomaly
+			vmenv.ChainConfig().TransferDataPlg.SendDataToPlugin("handle_TX_ANOMALY", tcanomaly.SendTransInfo("handle_TX_ANOMALY"))
+		}
+	}
+
 	tcstart := collector.NewTransCollector()
 
 	//external collector
@@ -285,22 +529,29 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 		tcstart.GasPrice = msg.GasPrice().String()
 		tcstart.GasLimit = msg.Gas()
 		tcstart.Nonce = tx.Nonce()
-		tcstart.CallLayer = 1
+		tcstart.CallLayer = txstate.CallDepth()
+		if msg.To() == nil {
+			// the creation frame is only entered by evm.Create
+			tcstart.CallLayer++
+		}
 		if msg.To() != nil {
 			tcstart.CallType = "CALL"
 			tcstart.To = msg.To().String()
 
 			callcollector := collector.NewCallCollector()
+			callcollector.StorageAddr = msg.To().String()
 			if vmenv.StateDB.Exist(*msg.To()) {
 				callcollector.ContractCode = vmenv.StateDB.GetCode(*msg.To())
 			}
 			callcollector.InputData = msg.Data()
 			tcstart.CallInfo = *callcollector
 		}
+		if vmenv.ChainConfig().TransferDataPlg.WantsField(pluginManage.FieldPreState) {
+			tcstart.PreState = *pluginPreState(vmenv.StateDB, msg.From(), msg.To())
+		}
 		vmenv.ChainConfig().TransferDataPlg.SendDataToPlugin("EXTERNALINFOSTART", tcstart.SendTransInfo("EXTERNALINFOSTART"))
 
 	}
-	//add
-
-	return applyTransaction(msg, config, author, gp, statedb, header.Number, header.Hash(), tx, usedGas, vmenv)
 }
+
+//add
@@ -85,6 +85,9 @@ type ExecutionResult struct {
 	UsedGas    uint64 // Total used gas but include the refunded gas
 	Err        error  // Any error encountered during the execution(listed in core/vm/errors.go)
 	ReturnData []byte // Returned data from evm(function result or data supplied with revert opcode)
+	//add
+	IntrinsicGas uint64 // Part of UsedGas charged before execution: base cost, calldata and access list
+	//add
 }
 
 // Unwrap returns the internal evm error which allows us for further
@@ -359,6 +362,9 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 		UsedGas:    st.gasUsed(),
 		Err:        vmerr,
 		ReturnData: ret,
+		//add
+		IntrinsicGas: gas,
+		//add
 	}, nil
 }
 
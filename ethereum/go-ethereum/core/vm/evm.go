@@ -18,8 +18,10 @@ package vm
 
 import (
 	"github.com/ethereum/go-ethereum/dzd"
+	"github.com/zhidandeng/collector"
 	"math/big"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -59,6 +61,175 @@ func (evm *EVM) precompile(addr common.Address) (PrecompiledContract, bool) {
 	return p, ok
 }
 
+//add
+// precompileNames names the precompiled contracts in the plugin collectors.
+var precompileNames = map[common.Address]string{
+	common.BytesToAddress([]byte{1}): "ecrecover",
+	common.BytesToAddress([]byte{2}): "sha256",
+	common.BytesToAddress([]byte{3}): "ripemd160",
+	common.BytesToAddress([]byte{4}): "identity",
+	common.BytesToAddress([]byte{5}): "modexp",
+	common.BytesToAddress([]byte{6}): "bn256Add",
+	common.BytesToAddress([]byte{7}): "bn256ScalarMul",
+	common.BytesToAddress([]byte{8}): "bn256Pairing",
+	common.BytesToAddress([]byte{9}): "blake2f",
+}
+
+// precompileName reports whether addr is a precompiled contract under the
+// current rules, and its name.
+func (evm *EVM) precompileName(addr common.Address) (string, bool) {
+	if _, ok := evm.precompile(addr); !ok {
+		return "", false
+	}
+	return precompileNames[addr], true
+}
+
+// sendPrecompile emits the handle_PRECOMPILE event of a precompiled contract
+// run by a call of the given type.
+func (evm *EVM) sendPrecompile(callType string, caller, addr common.Address, input, output []byte, gasCost uint64, err error) {
+	if !evm.isTxStart || !evm.chainConfig.TransferDataPlg.GetOpcodeRegister("handle_PRECOMPILE") {
+		return
+	}
+	name := precompileNames[addr]
+
+	info := collector.NewTransCollector()
+	info.Op = "handle_PRECOMPILE"
+	info.TxHash = evm.pluginTx().TxHash
+	info.From = caller.String()
+	info.To = addr.String()
+	info.CallType = callType
+	info.CallLayer = evm.pluginTx().CallDepth()
+	info.IsSuccess = err == nil
+	info.CallInfo.InputData = input
+	info.CallInfo.IsPrecompile = true
+	info.CallInfo.PrecompileName = name
+
+	precompilecollector := collector.NewPrecompileCollector()
+	precompilecollector.Name = name
+	precompilecollector.Input = input
+	precompilecollector.Output = output
+	precompilecollector.GasCost = gasCost
+	if err != nil {
+		precompilecollector.Err = err.Error()
+	}
+	info.PrecompileInfo = *precompilecollector
+	evm.chainConfig.TransferDataPlg.SendDataToPlugin(info.Op, info.SendTransInfo(info.Op))
+}
+
+// sendValueTransfer emits handle_VALUE_TRANSFER for a call that moved ether
+// without calling a function, the edges of a fund flow graph. Only calls
+// that succeeded are reported; an enclosing frame can still revert them.
+func (evm *EVM) sendValueTransfer(caller, addr common.Address, value *big.Int) {
+	if !evm.isTxStart || !evm.chainConfig.TransferDataPlg.GetOpcodeRegister("handle_VALUE_TRANSFER") {
+		return
+	}
+	info := collector.NewTransCollector()
+	info.Op = "handle_VALUE_TRANSFER"
+	info.TxHash = evm.pluginTx().TxHash
+	info.From = caller.String()
+	info.To = addr.String()
+	info.Value = value.String()
+	info.CallType = "CALL"
+	info.CallLayer = evm.pluginTx().CallDepth()
+	info.IsSuccess = true
+	evm.chainConfig.TransferDataPlg.SendDataToPlugin(info.Op, info.SendTransInfo(info.Op))
+}
+
+// pluginCallInput copies the input of an internal call for its TRANS_*
+// event before the call runs, as the callee may overwrite the memory args
+// points into. It is nil when nobody takes the event.
+func (evm *EVM) pluginCallInput(opcode string, args []byte) []byte {
+	if !evm.isTxStart || !evm.chainConfig.TransferDataPlg.GetOpcodeRegister(opcode) {
+		return nil
+	}
+	return evm.chainConfig.TransferDataPlg.CapPayload(args)
+}
+
+// pluginCreateAttempt numbers a CREATE or CREATE2 about to run in the order
+// the creations of the transaction start, a creating transaction being the
+// first. It is 0 outside of plugin processing.
+func (evm *EVM) pluginCreateAttempt() int {
+	if !evm.isTxStart {
+		return 0
+	}
+	evm.pluginTx().CREATE_ATTEMPTS++
+	return evm.pluginTx().CREATE_ATTEMPTS
+}
+
+// pluginSelfdestruct notes the code hash of a contract running SELFDESTRUCT.
+// The note only becomes a record of the manager once the transaction went
+// through and the contract is gone.
+func (evm *EVM) pluginSelfdestruct(addr common.Address) {
+	if !evm.isTxStart || !evm.chainConfig.TransferDataPlg.GetOpcodeRegister("handle_REDEPLOY") {
+		return
+	}
+	tx := evm.pluginTx()
+	if tx.SELFDESTRUCTED == nil {
+		tx.SELFDESTRUCTED = make(map[string]string)
+	}
+	tx.SELFDESTRUCTED[addr.String()] = evm.StateDB.GetCodeHash(addr).String()
+}
+
+// checkRedeploy emits handle_REDEPLOY when a contract was just deployed at an
+// address a destroyed contract lived at. It runs while the frame of the
+// creation is still on the CALL_STACK.
+func (evm *EVM) checkRedeploy(callType string, deployer, addr common.Address) {
+	if !evm.isTxStart || !evm.chainConfig.TransferDataPlg.GetOpcodeRegister("handle_REDEPLOY") {
+		return
+	}
+	oldCodeHash, ok := evm.chainConfig.TransferDataPlg.Destroyed(addr)
+	if !ok {
+		return
+	}
+	info := collector.NewTransCollector()
+	info.Op = "handle_REDEPLOY"
+	info.TxHash = evm.pluginTx().TxHash
+	info.From = deployer.String()
+	info.To = addr.String()
+	info.CallType = callType
+	info.CallLayer = evm.pluginTx().CallDepth()
+
+	redeploycollector := collector.NewRedeployCollector()
+	redeploycollector.Address = addr.String()
+	redeploycollector.OldCodeHash = oldCodeHash.String()
+	redeploycollector.NewCodeHash = evm.StateDB.GetCodeHash(addr).String()
+	info.RedeployInfo = *redeploycollector
+	evm.chainConfig.TransferDataPlg.SendDataToPlugin(info.Op, info.SendTransInfo(info.Op))
+}
+
+// checkReentrancy emits handle_REENTRANCY when a call of the given type enters
+// addr while addr is still executing further up the call stack. It must run
+// before addr is pushed on the CALL_STACK of the transaction.
+func (evm *EVM) checkReentrancy(callType string, caller, addr common.Address) {
+	if !evm.chainConfig.TransferDataPlg.GetOpcodeRegister("handle_REENTRANCY") {
+		return
+	}
+	prefix := addr.String() + "#"
+	var depths []int
+	for i, entry := range evm.pluginTx().CALL_STACK {
+		if strings.HasPrefix(entry, prefix) {
+			depths = append(depths, i+1)
+		}
+	}
+	if len(depths) == 0 {
+		return
+	}
+	info := collector.NewTransCollector()
+	info.Op = "handle_REENTRANCY"
+	info.TxHash = evm.pluginTx().TxHash
+	info.From = caller.String()
+	info.To = addr.String()
+	info.CallType = callType
+	info.CallLayer = evm.pluginTx().CallDepth() + 1
+
+	reentrancycollector := collector.NewReentrancyCollector()
+	reentrancycollector.Address = addr.String()
+	reentrancycollector.Depths = append(depths, len(evm.pluginTx().CALL_STACK)+1)
+	info.ReentrancyInfo = *reentrancycollector
+	evm.chainConfig.TransferDataPlg.SendDataToPlugin(info.Op, info.SendTransInfo(info.Op))
+}
+//add
+
 // BlockContext provides the EVM with auxiliary information. Once provided
 // it shouldn't be modified.
 type BlockContext struct {
@@ -175,7 +346,7 @@ func (evm *EVM) Interpreter() *EVMInterpreter {
 func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas uint64, value *big.Int) (ret []byte, leftOverGas uint64, err error) {
 	//add
 	if evm.isTxStart {
-		dzd.CALLVALID_MAP[dzd.CALL_LAYER] = false
+		evm.pluginTx().CALLVALID_MAP[evm.pluginTx().CALL_LAYER] = false
 
 	}
 	//add
@@ -207,9 +378,9 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 		evm.StateDB.CreateAccount(addr)
 	}
 	//add
-	if evm.isTxStart && dzd.EXTERNAL_FLAG {
-		dzd.PLUGIN_SNAPSHOT_ID = snapshot
-		dzd.EXTERNAL_FLAG = false
+	if evm.isTxStart && evm.pluginTx().EXTERNAL_FLAG {
+		evm.pluginTx().PLUGIN_SNAPSHOT_ID = snapshot
+		evm.pluginTx().EXTERNAL_FLAG = false
 	}
 	//add
 	evm.Context.Transfer(evm.StateDB, caller.Address(), addr, value)
@@ -231,7 +402,13 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	}
 
 	if isPrecompile {
+		//add
+		suppliedGas := gas
+		//add
 		ret, gas, err = RunPrecompiledContract(p, input, gas)
+		//add
+		evm.sendPrecompile("CALL", caller.Address(), addr, input, ret, suppliedGas-gas, err)
+		//add
 	} else {
 		// Initialise a new contract and set the code that is to be used by the EVM.
 		// The contract is a scoped environment for this execution context only.
@@ -260,6 +437,11 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 		//} else {
 		//	evm.StateDB.DiscardSnapshot(snapshot)
 	}
+	//add
+	if err == nil && len(input) == 0 && value.Sign() != 0 {
+		evm.sendValueTransfer(caller.Address(), addr, value)
+	}
+	//add
 	return ret, gas, err
 }
 
@@ -273,7 +455,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte, gas uint64, value *big.Int) (ret []byte, leftOverGas uint64, err error) {
 	//add
 	if evm.isTxStart {
-		dzd.CALLVALID_MAP[dzd.CALL_LAYER] = false
+		evm.pluginTx().CALLVALID_MAP[evm.pluginTx().CALL_LAYER] = false
 
 	}
 	//add
@@ -291,7 +473,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	}
 	//add
 	if evm.isTxStart {
-		dzd.CALLVALID_MAP[dzd.CALL_LAYER] = true
+		evm.pluginTx().CALLVALID_MAP[evm.pluginTx().CALL_LAYER] = true
 
 	}
 	//add
@@ -307,7 +489,13 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 
 	// It is allowed to call precompiles, even via delegatecall
 	if p, isPrecompile := evm.precompile(addr); isPrecompile {
+		//add
+		suppliedGas := gas
+		//add
 		ret, gas, err = RunPrecompiledContract(p, input, gas)
+		//add
+		evm.sendPrecompile("CALLCODE", caller.Address(), addr, input, ret, suppliedGas-gas, err)
+		//add
 	} else {
 		addrCopy := addr
 		// Initialise a new contract and set the code that is to be used by the EVM.
@@ -334,7 +522,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []byte, gas uint64) (ret []byte, leftOverGas uint64, err error) {
 	//add
 	if evm.isTxStart {
-		dzd.CALLVALID_MAP[dzd.CALL_LAYER] = false
+		evm.pluginTx().CALLVALID_MAP[evm.pluginTx().CALL_LAYER] = false
 
 	}
 	//add
@@ -344,7 +532,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	}
 	//add
 	if evm.isTxStart {
-		dzd.CALLVALID_MAP[dzd.CALL_LAYER] = true
+		evm.pluginTx().CALLVALID_MAP[evm.pluginTx().CALL_LAYER] = true
 
 	}
 	//add
@@ -360,7 +548,13 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 
 	// It is allowed to call precompiles, even via delegatecall
 	if p, isPrecompile := evm.precompile(addr); isPrecompile {
+		//add
+		suppliedGas := gas
+		//add
 		ret, gas, err = RunPrecompiledContract(p, input, gas)
+		//add
+		evm.sendPrecompile("DELEGATECALL", caller.Address(), addr, input, ret, suppliedGas-gas, err)
+		//add
 	} else {
 		addrCopy := addr
 		// Initialise a new contract and make initialise the delegate values
@@ -385,7 +579,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte, gas uint64) (ret []byte, leftOverGas uint64, err error) {
 	//add
 	if evm.isTxStart {
-		dzd.CALLVALID_MAP[dzd.CALL_LAYER] = false
+		evm.pluginTx().CALLVALID_MAP[evm.pluginTx().CALL_LAYER] = false
 
 	}
 	//add
@@ -400,7 +594,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	// We could change this, but for now it's left for legacy reasons
 	//add
 	if evm.isTxStart {
-		dzd.CALLVALID_MAP[dzd.CALL_LAYER] = true
+		evm.pluginTx().CALLVALID_MAP[evm.pluginTx().CALL_LAYER] = true
 
 	}
 	//add
@@ -421,7 +615,13 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	}
 
 	if p, isPrecompile := evm.precompile(addr); isPrecompile {
+		//add
+		suppliedGas := gas
+		//add
 		ret, gas, err = RunPrecompiledContract(p, input, gas)
+		//add
+		evm.sendPrecompile("STATICCALL", caller.Address(), addr, input, ret, suppliedGas-gas, err)
+		//add
 	} else {
 		// At this point, we use a copy of address. If we don't, the go compiler will
 		// leak the 'contract' to the outer scope, and make allocation for 'contract'
@@ -490,9 +690,9 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 		evm.StateDB.SetNonce(address, 1)
 	}
 	//add
-	if evm.isTxStart && dzd.EXTERNAL_FLAG {
-		dzd.PLUGIN_SNAPSHOT_ID = snapshot
-		dzd.EXTERNAL_FLAG = false
+	if evm.isTxStart && evm.pluginTx().EXTERNAL_FLAG {
+		evm.pluginTx().PLUGIN_SNAPSHOT_ID = snapshot
+		evm.pluginTx().EXTERNAL_FLAG = false
 	}
 	//add
 	evm.Context.Transfer(evm.StateDB, caller.Address(), address, value)
@@ -562,9 +762,10 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 	contractAddr = crypto.CreateAddress(caller.Address(), evm.StateDB.GetNonce(caller.Address()))
 	//
 	if evm.isTxStart {
-		dzd.CALL_LAYER += 1
-		dzd.CALL_STACK = append(dzd.CALL_STACK, contractAddr.String()+"#"+strconv.Itoa(dzd.CALL_LAYER))
-		dzd.ALL_STACK = append(dzd.ALL_STACK, contractAddr.String())
+		evm.pluginTx().CALL_LAYER += 1
+		evm.pluginTx().CALL_STACK = append(evm.pluginTx().CALL_STACK, contractAddr.String()+"#"+strconv.Itoa(evm.pluginTx().CALL_LAYER))
+		evm.pluginTx().NoteDepth()
+		evm.pluginTx().ALL_STACK = append(evm.pluginTx().ALL_STACK, contractAddr.String())
 	}
 	//
 	return evm.create(caller, &codeAndHash{code: code}, gas, value, contractAddr, CREATE)
@@ -579,9 +780,10 @@ func (evm *EVM) Create2(caller ContractRef, code []byte, gas uint64, endowment *
 	contractAddr = crypto.CreateAddress2(caller.Address(), salt.Bytes32(), codeAndHash.Hash().Bytes())
 	//add
 	if evm.isTxStart {
-		dzd.CALL_LAYER += 1
-		dzd.CALL_STACK = append(dzd.CALL_STACK, contractAddr.String()+"#"+strconv.Itoa(dzd.CALL_LAYER))
-		dzd.ALL_STACK = append(dzd.ALL_STACK, contractAddr.String())
+		evm.pluginTx().CALL_LAYER += 1
+		evm.pluginTx().CALL_STACK = append(evm.pluginTx().CALL_STACK, contractAddr.String()+"#"+strconv.Itoa(evm.pluginTx().CALL_LAYER))
+		evm.pluginTx().NoteDepth()
+		evm.pluginTx().ALL_STACK = append(evm.pluginTx().ALL_STACK, contractAddr.String())
 	}
 	//add
 	return evm.create(caller, codeAndHash, gas, endowment, contractAddr, CREATE2)
@@ -595,4 +797,8 @@ func (evm *EVM) IsTxStart() bool { return evm.isTxStart }
 
 func (evm *EVM) SetTxStart(flag bool) { evm.isTxStart = flag }
 
+// pluginTx returns the plugin state of the running transaction, kept by the
+// plugin manager of this chain. Only valid while isTxStart is set.
+func (evm *EVM) pluginTx() *dzd.TxState { return evm.chainConfig.TransferDataPlg.TxState() }
+
 //add
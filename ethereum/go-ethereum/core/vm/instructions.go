@@ -19,13 +19,13 @@ package vm
 import (
 	"fmt"
 	"github.com/zhidandeng/collector"
-	"github.com/ethereum/go-ethereum/dzd"
 	"strconv"
 	"strings"
 	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/holiman/uint256"
 	"golang.org/x/crypto/sha3"
@@ -608,7 +608,7 @@ func opCreate(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]b
 
 	if scope.Stack.flag {
 		scope.Stack.collector.OpName = "CREATESTART"
-		scope.Stack.collector.CallLayer = dzd.CALL_LAYER + 1
+		scope.Stack.collector.CallLayer = interpreter.evm.pluginTx().CALL_LAYER + 1
 		scope.Stack.collector.AccountValue.CallContract = ""
 		scope.Stack.collector.OpInOut.OpArgs = append(scope.Stack.collector.OpInOut.OpArgs, value.String(), offset.String(), size.String())
 		scope.Stack.collector.OpInOut.InputData = input
@@ -618,6 +618,11 @@ func opCreate(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]b
 		data := scope.Stack.collector.SendInsInfo()
 		interpreter.evm.chainConfig.TransferDataPlg.SendDataToPlugin(scope.Stack.collector.OpName, data)
 	}
+	var nonce uint64
+	if interpreter.evm.isTxStart {
+		nonce = interpreter.evm.StateDB.GetNonce(scope.Contract.Address())
+	}
+	attempt := interpreter.evm.pluginCreateAttempt()
 	//add new
 	res, addr, returnGas, suberr := interpreter.evm.Create(scope.Contract, input, gas, bigVal)
 	//add new
@@ -643,30 +648,42 @@ func opCreate(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]b
 	scope.Contract.Gas += returnGas
 
 	//add
+	if !stackvalue.IsZero() {
+		interpreter.evm.checkRedeploy("CREATE", scope.Contract.Address(), addr)
+	}
 	if interpreter.evm.isTxStart && interpreter.evm.ChainConfig().TransferDataPlg.GetOpcodeRegister("TRANS_CREATE") {
 		invokeinfo := collector.NewTransCollector()
 		invokeinfo.Op = "TRANS_CREATE"
 		invokeinfo.Pc = *pc
+		// a creation failing before it started returns no address
+		if addr == (common.Address{}) {
+			addr = crypto.CreateAddress(scope.Contract.Address(), nonce)
+		}
 		invokeinfo.From = scope.Contract.Address().String()
 		invokeinfo.To = addr.String()
 		invokeinfo.Value = value.String()
 		invokeinfo.CallType = "CREATE"
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
-		temp_arr := strings.Split(temp_str, "#")
-		invokeinfo.CallLayer, _ = strconv.Atoi(temp_arr[1])
+		invokeinfo.CallLayer = interpreter.evm.pluginTx().CallDepth()
+		invokeinfo.GasUsedSubtree = gas - returnGas
 
 		createcollector := collector.NewCreateCollector()
 		createcollector.ContractAddr = addr.String()
 		createcollector.ContractDeployCode = input
-		createcollector.ContractRuntimeCode = res
+		createcollector.Deployer = scope.Contract.Address().String()
+		createcollector.DeployerNonce = nonce
+		createcollector.SetRuntimeCode(res, params.MaxCodeSize)
+		createcollector.Attempt = attempt
 		invokeinfo.CreateInfo = *createcollector
 
-		invokeinfo.IsSuccess = (suberr != nil)
+		invokeinfo.IsSuccess = !stackvalue.IsZero()
+		if !invokeinfo.IsSuccess {
+			invokeinfo.FailureReason = PluginFailureReason(suberr)
+		}
 		interpreter.evm.ChainConfig().TransferDataPlg.SendDataToPlugin(invokeinfo.Op, invokeinfo.SendTransInfo(invokeinfo.Op))
 	}
 	if scope.Stack.flag {
 		scope.Stack.collector.OpName = "CREATEEND"
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
+		temp_str := interpreter.evm.pluginTx().CALL_STACK[len(interpreter.evm.pluginTx().CALL_STACK)-1]
 		temp_arr := strings.Split(temp_str, "#")
 		scope.Stack.collector.CallLayer, _ = strconv.Atoi(temp_arr[1])
 		scope.Stack.collector.AccountValue.CallContract = addr.String()
@@ -676,7 +693,7 @@ func opCreate(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]b
 	}
 
 	if interpreter.evm.isTxStart {
-		dzd.CALL_STACK = dzd.CALL_STACK[:len(dzd.CALL_STACK)-1]
+		interpreter.evm.pluginTx().CALL_STACK = interpreter.evm.pluginTx().CALL_STACK[:len(interpreter.evm.pluginTx().CALL_STACK)-1]
 	}
 	//add
 	if suberr == ErrExecutionReverted {
@@ -705,7 +722,7 @@ func opCreate2(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]
 	//add
 	if scope.Stack.flag {
 		scope.Stack.collector.OpName = "CREATE2START"
-		scope.Stack.collector.CallLayer = dzd.CALL_LAYER + 1
+		scope.Stack.collector.CallLayer = interpreter.evm.pluginTx().CALL_LAYER + 1
 		scope.Stack.collector.AccountValue.CallContract = ""
 		scope.Stack.collector.OpInOut.OpArgs = append(scope.Stack.collector.OpInOut.OpArgs, endowment.String(), offset.String(), size.String(), salt.String())
 		scope.Stack.collector.OpInOut.InputData = input
@@ -723,6 +740,13 @@ func opCreate2(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]
 	if !endowment.IsZero() {
 		bigEndowment = endowment.ToBig()
 	}
+	//add
+	var nonce uint64
+	if interpreter.evm.isTxStart {
+		nonce = interpreter.evm.StateDB.GetNonce(scope.Contract.Address())
+	}
+	attempt := interpreter.evm.pluginCreateAttempt()
+	//add
 	res, addr, returnGas, suberr := interpreter.evm.Create2(scope.Contract, input, gas,
 		bigEndowment, &salt)
 	//add
@@ -741,28 +765,40 @@ func opCreate2(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]
 	scope.Stack.push(&stackvalue)
 	scope.Contract.Gas += returnGas
 	//add
+	if !stackvalue.IsZero() {
+		interpreter.evm.checkRedeploy("CREATE2", scope.Contract.Address(), addr)
+	}
 	if interpreter.evm.isTxStart && interpreter.evm.ChainConfig().TransferDataPlg.GetOpcodeRegister("TRANS_CREATE2") {
 		invokeinfo := collector.NewTransCollector()
 		invokeinfo.Op = "TRANS_CREATE2"
 		invokeinfo.Pc = *pc
+		// a creation failing before it started returns no address
+		if addr == (common.Address{}) {
+			addr = crypto.CreateAddress2(scope.Contract.Address(), salt.Bytes32(), crypto.Keccak256(input))
+		}
 		invokeinfo.From = scope.Contract.Address().String()
 		invokeinfo.To = addr.String()
 		invokeinfo.Value = endowment.String()
 		invokeinfo.CallType = "CREATE"
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
-		temp_arr := strings.Split(temp_str, "#")
-		invokeinfo.CallLayer, _ = strconv.Atoi(temp_arr[1])
+		invokeinfo.CallLayer = interpreter.evm.pluginTx().CallDepth()
+		invokeinfo.GasUsedSubtree = gas - returnGas
 		createcollector := collector.NewCreateCollector()
 		createcollector.ContractAddr = addr.String()
 		createcollector.ContractDeployCode = input
-		createcollector.ContractRuntimeCode = res
+		createcollector.Deployer = scope.Contract.Address().String()
+		createcollector.DeployerNonce = nonce
+		createcollector.SetRuntimeCode(res, params.MaxCodeSize)
+		createcollector.Attempt = attempt
 		invokeinfo.CreateInfo = *createcollector
-		invokeinfo.IsSuccess = (suberr != nil)
+		invokeinfo.IsSuccess = !stackvalue.IsZero()
+		if !invokeinfo.IsSuccess {
+			invokeinfo.FailureReason = PluginFailureReason(suberr)
+		}
 		interpreter.evm.ChainConfig().TransferDataPlg.SendDataToPlugin(invokeinfo.Op, invokeinfo.SendTransInfo(invokeinfo.Op))
 	}
 	if scope.Stack.flag {
 		scope.Stack.collector.OpName = "CREATE2END"
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
+		temp_str := interpreter.evm.pluginTx().CALL_STACK[len(interpreter.evm.pluginTx().CALL_STACK)-1]
 		temp_arr := strings.Split(temp_str, "#")
 		scope.Stack.collector.CallLayer, _ = strconv.Atoi(temp_arr[1])
 		scope.Stack.collector.AccountValue.CallContract = addr.String()
@@ -772,7 +808,7 @@ func opCreate2(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]
 	}
 
 	if interpreter.evm.isTxStart {
-		dzd.CALL_STACK = dzd.CALL_STACK[:len(dzd.CALL_STACK)-1]
+		interpreter.evm.pluginTx().CALL_STACK = interpreter.evm.pluginTx().CALL_STACK[:len(interpreter.evm.pluginTx().CALL_STACK)-1]
 	}
 	//add
 	if suberr == ErrExecutionReverted {
@@ -808,14 +844,16 @@ func opCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byt
 	}
 	//add
 	if interpreter.evm.isTxStart {
-		dzd.CALL_LAYER += 1
-		dzd.CALL_STACK = append(dzd.CALL_STACK, toAddr.String()+"#"+strconv.Itoa(dzd.CALL_LAYER))
-		dzd.ALL_STACK = append(dzd.ALL_STACK, toAddr.String())
+		interpreter.evm.pluginTx().CALL_LAYER += 1
+		interpreter.evm.checkReentrancy("CALL", scope.Contract.Address(), toAddr)
+		interpreter.evm.pluginTx().CALL_STACK = append(interpreter.evm.pluginTx().CALL_STACK, toAddr.String()+"#"+strconv.Itoa(interpreter.evm.pluginTx().CALL_LAYER))
+		interpreter.evm.pluginTx().NoteDepth()
+		interpreter.evm.pluginTx().ALL_STACK = append(interpreter.evm.pluginTx().ALL_STACK, toAddr.String())
 	}
 
 	if stack.flag {
 		stack.collector.OpName = "CALLSTART"
-		stack.collector.CallLayer = dzd.CALL_LAYER
+		stack.collector.CallLayer = interpreter.evm.pluginTx().CALL_LAYER
 		stack.collector.AccountValue.CallContract = toAddr.String()
 		stack.collector.AccountValue.FromAddr = scope.Contract.Address().String()
 		stack.collector.AccountValue.ToAddr = toAddr.String()
@@ -827,6 +865,7 @@ func opCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byt
 		data := stack.collector.SendInsInfo()
 		interpreter.evm.chainConfig.TransferDataPlg.SendDataToPlugin(stack.collector.OpName, data)
 	}
+	callInput := interpreter.evm.pluginCallInput("TRANS_CALL", args)
 	//add
 	ret, returnGas, err := interpreter.evm.Call(scope.Contract, toAddr, args, gas, bigVal)
 	//add
@@ -874,14 +913,17 @@ func opCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byt
 		invokeinfo.From = scope.Contract.Address().String()
 		invokeinfo.To = toAddr.String()
 		invokeinfo.Value = value.String()
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
-		temp_arr := strings.Split(temp_str, "#")
-		invokeinfo.CallLayer, _ = strconv.Atoi(temp_arr[1])
+		invokeinfo.CallLayer = interpreter.evm.pluginTx().CallDepth()
+		invokeinfo.GasUsedSubtree = gas - returnGas
 
 		invokeinfo.CallType = "CALL"
 		callcollector := collector.NewCallCollector()
 		callcollector.ContractCode = interpreter.evm.StateDB.GetCode(toAddr)
+		callcollector.PrecompileName, callcollector.IsPrecompile = interpreter.evm.precompileName(toAddr)
+		callcollector.StorageAddr = toAddr.String()
 		callcollector.InputData = args
+		callcollector.Input, callcollector.InputSize = callInput, len(args)
+		callcollector.Output, callcollector.OutputSize = interpreter.evm.ChainConfig().TransferDataPlg.CapPayload(ret), len(ret)
 		invokeinfo.CallInfo = *callcollector
 		if err == nil || err == ErrInsufficientBalance || err == ErrDepth {
 			invokeinfo.IsSuccess = true
@@ -893,17 +935,17 @@ func opCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byt
 
 	if scope.Stack.flag {
 		scope.Stack.collector.OpName = "CALLEND"
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
+		temp_str := interpreter.evm.pluginTx().CALL_STACK[len(interpreter.evm.pluginTx().CALL_STACK)-1]
 		temp_arr := strings.Split(temp_str, "#")
 		scope.Stack.collector.CallLayer, _ = strconv.Atoi(temp_arr[1])
 		scope.Stack.collector.AccountValue.CallContract = toAddr.String()
 		scope.Stack.collector.OpInOut.OpResult = temp.String()
-		//stack.collector.CheckErr.IsInternalSucceeded = dzd.CALLVALID_MAP[dzd.CALL_LAYER] && stack.collector.CheckErr.IsInternalSucceeded
-		scope.Stack.collector.CheckErr.IsCallValid = dzd.CALLVALID_MAP[scope.Stack.collector.CallLayer]
+		//stack.collector.CheckErr.IsInternalSucceeded = interpreter.evm.pluginTx().CALLVALID_MAP[interpreter.evm.pluginTx().CALL_LAYER] && stack.collector.CheckErr.IsInternalSucceeded
+		scope.Stack.collector.CheckErr.IsCallValid = interpreter.evm.pluginTx().CALLVALID_MAP[scope.Stack.collector.CallLayer]
 	}
 
 	if interpreter.evm.isTxStart {
-		dzd.CALL_STACK = dzd.CALL_STACK[:len(dzd.CALL_STACK)-1]
+		interpreter.evm.pluginTx().CALL_STACK = interpreter.evm.pluginTx().CALL_STACK[:len(interpreter.evm.pluginTx().CALL_STACK)-1]
 	}
 	//add
 	return ret, nil
@@ -929,14 +971,16 @@ func opCallCode(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([
 	}
 	//add
 	if interpreter.evm.isTxStart {
-		dzd.CALL_LAYER += 1
-		dzd.CALL_STACK = append(dzd.CALL_STACK, toAddr.String()+"#"+strconv.Itoa(dzd.CALL_LAYER))
-		dzd.ALL_STACK = append(dzd.ALL_STACK, toAddr.String())
+		interpreter.evm.pluginTx().CALL_LAYER += 1
+		interpreter.evm.checkReentrancy("CALLCODE", scope.Contract.Address(), toAddr)
+		interpreter.evm.pluginTx().CALL_STACK = append(interpreter.evm.pluginTx().CALL_STACK, toAddr.String()+"#"+strconv.Itoa(interpreter.evm.pluginTx().CALL_LAYER))
+		interpreter.evm.pluginTx().NoteDepth()
+		interpreter.evm.pluginTx().ALL_STACK = append(interpreter.evm.pluginTx().ALL_STACK, toAddr.String())
 	}
 
 	if scope.Stack.flag {
 		scope.Stack.collector.OpName = "CALLCODESTART"
-		scope.Stack.collector.CallLayer = dzd.CALL_LAYER
+		scope.Stack.collector.CallLayer = interpreter.evm.pluginTx().CALL_LAYER
 		scope.Stack.collector.AccountValue.CallContract = toAddr.String()
 		scope.Stack.collector.AccountValue.FromAddr = scope.Contract.Address().String()
 		scope.Stack.collector.AccountValue.ToAddr = toAddr.String()
@@ -948,6 +992,7 @@ func opCallCode(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([
 		data := scope.Stack.collector.SendInsInfo()
 		interpreter.evm.chainConfig.TransferDataPlg.SendDataToPlugin(scope.Stack.collector.OpName, data)
 	}
+	callInput := interpreter.evm.pluginCallInput("TRANS_CALLCODE", args)
 	//add
 	ret, returnGas, err := interpreter.evm.CallCode(scope.Contract, toAddr, args, gas, bigVal)
 
@@ -998,14 +1043,17 @@ func opCallCode(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([
 		invokeinfo.From = scope.Contract.Address().String()
 		invokeinfo.To = toAddr.String()
 		invokeinfo.Value = value.String()
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
-		temp_arr := strings.Split(temp_str, "#")
-		invokeinfo.CallLayer, _ = strconv.Atoi(temp_arr[1])
+		invokeinfo.CallLayer = interpreter.evm.pluginTx().CallDepth()
+		invokeinfo.GasUsedSubtree = gas - returnGas
 
 		invokeinfo.CallType = "CALL"
 		callcollector := collector.NewCallCollector()
 		callcollector.ContractCode = interpreter.evm.StateDB.GetCode(toAddr)
+		callcollector.PrecompileName, callcollector.IsPrecompile = interpreter.evm.precompileName(toAddr)
+		callcollector.StorageAddr = scope.Contract.Address().String()
 		callcollector.InputData = args
+		callcollector.Input, callcollector.InputSize = callInput, len(args)
+		callcollector.Output, callcollector.OutputSize = interpreter.evm.ChainConfig().TransferDataPlg.CapPayload(ret), len(ret)
 		invokeinfo.CallInfo = *callcollector
 		if err == nil || err == ErrInsufficientBalance || err == ErrDepth {
 			invokeinfo.IsSuccess = true
@@ -1017,7 +1065,7 @@ func opCallCode(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([
 
 	if stack.flag {
 		stack.collector.OpName = "CALLCODEEND"
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
+		temp_str := interpreter.evm.pluginTx().CALL_STACK[len(interpreter.evm.pluginTx().CALL_STACK)-1]
 		temp_arr := strings.Split(temp_str, "#")
 		stack.collector.CallLayer, _ = strconv.Atoi(temp_arr[1])
 		stack.collector.AccountValue.CallContract = toAddr.String()
@@ -1025,7 +1073,7 @@ func opCallCode(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([
 	}
 
 	if interpreter.evm.isTxStart {
-		dzd.CALL_STACK = dzd.CALL_STACK[:len(dzd.CALL_STACK)-1]
+		interpreter.evm.pluginTx().CALL_STACK = interpreter.evm.pluginTx().CALL_STACK[:len(interpreter.evm.pluginTx().CALL_STACK)-1]
 	}
 	//add
 	return ret, nil
@@ -1044,14 +1092,16 @@ func opDelegateCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext
 	args := scope.Memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 	//add
 	if interpreter.evm.isTxStart {
-		dzd.CALL_LAYER += 1
-		dzd.CALL_STACK = append(dzd.CALL_STACK, toAddr.String()+"#"+strconv.Itoa(dzd.CALL_LAYER))
-		dzd.ALL_STACK = append(dzd.ALL_STACK, toAddr.String())
+		interpreter.evm.pluginTx().CALL_LAYER += 1
+		interpreter.evm.checkReentrancy("DELEGATECALL", scope.Contract.Address(), toAddr)
+		interpreter.evm.pluginTx().CALL_STACK = append(interpreter.evm.pluginTx().CALL_STACK, toAddr.String()+"#"+strconv.Itoa(interpreter.evm.pluginTx().CALL_LAYER))
+		interpreter.evm.pluginTx().NoteDepth()
+		interpreter.evm.pluginTx().ALL_STACK = append(interpreter.evm.pluginTx().ALL_STACK, toAddr.String())
 	}
 
 	if stack.flag {
 		stack.collector.OpName = "DELEGATECALLSTART"
-		stack.collector.CallLayer = dzd.CALL_LAYER
+		stack.collector.CallLayer = interpreter.evm.pluginTx().CALL_LAYER
 		stack.collector.AccountValue.CallContract = toAddr.String()
 		stack.collector.AccountValue.FromAddr = scope.Contract.Address().String()
 		stack.collector.AccountValue.ToAddr = toAddr.String()
@@ -1062,6 +1112,7 @@ func opDelegateCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext
 		data := stack.collector.SendInsInfo()
 		interpreter.evm.chainConfig.TransferDataPlg.SendDataToPlugin(stack.collector.OpName, data)
 	}
+	callInput := interpreter.evm.pluginCallInput("TRANS_DELEGATECALL", args)
 	//add
 	ret, returnGas, err := interpreter.evm.DelegateCall(scope.Contract, toAddr, args, gas)
 	//add
@@ -1108,14 +1159,17 @@ func opDelegateCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext
 		invokeinfo.Pc = *pc
 		invokeinfo.From = scope.Contract.Address().String()
 		invokeinfo.To = toAddr.String()
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
-		temp_arr := strings.Split(temp_str, "#")
-		invokeinfo.CallLayer, _ = strconv.Atoi(temp_arr[1])
+		invokeinfo.CallLayer = interpreter.evm.pluginTx().CallDepth()
+		invokeinfo.GasUsedSubtree = gas - returnGas
 
 		invokeinfo.CallType = "CALL"
 		callcollector := collector.NewCallCollector()
 		callcollector.ContractCode = interpreter.evm.StateDB.GetCode(toAddr)
+		callcollector.PrecompileName, callcollector.IsPrecompile = interpreter.evm.precompileName(toAddr)
+		callcollector.StorageAddr = scope.Contract.Address().String()
 		callcollector.InputData = args
+		callcollector.Input, callcollector.InputSize = callInput, len(args)
+		callcollector.Output, callcollector.OutputSize = interpreter.evm.ChainConfig().TransferDataPlg.CapPayload(ret), len(ret)
 		invokeinfo.CallInfo = *callcollector
 		if err == nil || err == ErrDepth {
 			invokeinfo.IsSuccess = true
@@ -1127,7 +1181,7 @@ func opDelegateCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext
 	}
 	if stack.flag {
 		stack.collector.OpName = "DELEGATECALLEND"
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
+		temp_str := interpreter.evm.pluginTx().CALL_STACK[len(interpreter.evm.pluginTx().CALL_STACK)-1]
 		temp_arr := strings.Split(temp_str, "#")
 		stack.collector.CallLayer, _ = strconv.Atoi(temp_arr[1])
 		stack.collector.AccountValue.CallContract = toAddr.String()
@@ -1135,7 +1189,7 @@ func opDelegateCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext
 	}
 
 	if interpreter.evm.isTxStart {
-		dzd.CALL_STACK = dzd.CALL_STACK[:len(dzd.CALL_STACK)-1]
+		interpreter.evm.pluginTx().CALL_STACK = interpreter.evm.pluginTx().CALL_STACK[:len(interpreter.evm.pluginTx().CALL_STACK)-1]
 	}
 
 	//add
@@ -1155,14 +1209,16 @@ func opStaticCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext)
 	args := scope.Memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 	//add
 	if interpreter.evm.isTxStart {
-		dzd.CALL_LAYER += 1
-		dzd.CALL_STACK = append(dzd.CALL_STACK, toAddr.String()+"#"+strconv.Itoa(dzd.CALL_LAYER))
-		dzd.ALL_STACK = append(dzd.ALL_STACK, toAddr.String())
+		interpreter.evm.pluginTx().CALL_LAYER += 1
+		interpreter.evm.checkReentrancy("STATICCALL", scope.Contract.Address(), toAddr)
+		interpreter.evm.pluginTx().CALL_STACK = append(interpreter.evm.pluginTx().CALL_STACK, toAddr.String()+"#"+strconv.Itoa(interpreter.evm.pluginTx().CALL_LAYER))
+		interpreter.evm.pluginTx().NoteDepth()
+		interpreter.evm.pluginTx().ALL_STACK = append(interpreter.evm.pluginTx().ALL_STACK, toAddr.String())
 	}
 
 	if stack.flag {
 		stack.collector.OpName = "STATICCALLSTART"
-		stack.collector.CallLayer = dzd.CALL_LAYER
+		stack.collector.CallLayer = interpreter.evm.pluginTx().CALL_LAYER
 		stack.collector.AccountValue.CallContract = toAddr.String()
 		stack.collector.AccountValue.FromAddr = scope.Contract.Address().String()
 		stack.collector.AccountValue.ToAddr = toAddr.String()
@@ -1173,6 +1229,7 @@ func opStaticCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext)
 		data := stack.collector.SendInsInfo()
 		interpreter.evm.chainConfig.TransferDataPlg.SendDataToPlugin(stack.collector.OpName, data)
 	}
+	callInput := interpreter.evm.pluginCallInput("TRANS_STATICCALL", args)
 	//add
 	ret, returnGas, err := interpreter.evm.StaticCall(scope.Contract, toAddr, args, gas)
 	//add
@@ -1223,14 +1280,17 @@ func opStaticCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext)
 		invokeinfo.Pc = *pc
 		invokeinfo.From = scope.Contract.Address().String()
 		invokeinfo.To = toAddr.String()
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
-		temp_arr := strings.Split(temp_str, "#")
-		invokeinfo.CallLayer, _ = strconv.Atoi(temp_arr[1])
+		invokeinfo.CallLayer = interpreter.evm.pluginTx().CallDepth()
+		invokeinfo.GasUsedSubtree = gas - returnGas
 
 		invokeinfo.CallType = "CALL"
 		callcollector := collector.NewCallCollector()
 		callcollector.ContractCode = interpreter.evm.StateDB.GetCode(toAddr)
+		callcollector.PrecompileName, callcollector.IsPrecompile = interpreter.evm.precompileName(toAddr)
+		callcollector.StorageAddr = toAddr.String()
 		callcollector.InputData = args
+		callcollector.Input, callcollector.InputSize = callInput, len(args)
+		callcollector.Output, callcollector.OutputSize = interpreter.evm.ChainConfig().TransferDataPlg.CapPayload(ret), len(ret)
 		invokeinfo.CallInfo = *callcollector
 		if err == nil || err == ErrDepth {
 			invokeinfo.IsSuccess = true
@@ -1241,7 +1301,7 @@ func opStaticCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext)
 	}
 	if stack.flag {
 		stack.collector.OpName = "STATICCALLEND"
-		temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
+		temp_str := interpreter.evm.pluginTx().CALL_STACK[len(interpreter.evm.pluginTx().CALL_STACK)-1]
 		temp_arr := strings.Split(temp_str, "#")
 		stack.collector.CallLayer, _ = strconv.Atoi(temp_arr[1])
 		stack.collector.AccountValue.CallContract = toAddr.String()
@@ -1249,7 +1309,7 @@ func opStaticCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext)
 	}
 
 	if interpreter.evm.isTxStart {
-		dzd.CALL_STACK = dzd.CALL_STACK[:len(dzd.CALL_STACK)-1]
+		interpreter.evm.pluginTx().CALL_STACK = interpreter.evm.pluginTx().CALL_STACK[:len(interpreter.evm.pluginTx().CALL_STACK)-1]
 	}
 	//add
 
@@ -1298,6 +1358,7 @@ func opSelfdestruct(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext
 	interpreter.evm.StateDB.AddBalance(beneficiary.Bytes20(), balance)
 	interpreter.evm.StateDB.Suicide(scope.Contract.Address())
 	//add
+	interpreter.evm.pluginSelfdestruct(scope.Contract.Address())
 	if scope.Stack.flag {
 		scope.Stack.collector.AccountValue.Value = balance.String()
 		scope.Stack.collector.AccountValue.FromAddr = scope.Contract.Address().String()
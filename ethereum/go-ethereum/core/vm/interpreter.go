@@ -19,7 +19,6 @@ package vm
 import (
 	"fmt"
 	"github.com/zhidandeng/collector"
-	"github.com/ethereum/go-ethereum/dzd"
 	"hash"
 	"strconv"
 	"strings"
@@ -271,7 +270,7 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			if operation.dynamicGas != nil {
 				stack.collector.Gas.RealGasUsed += cost
 			}
-			temp_str := dzd.CALL_STACK[len(dzd.CALL_STACK)-1]
+			temp_str := in.evm.pluginTx().CALL_STACK[len(in.evm.pluginTx().CALL_STACK)-1]
 			temp_arr := strings.Split(temp_str, "#")
 			stack.collector.AccountValue.CallContract = temp_arr[0]
 			temp_int, _ := strconv.Atoi(temp_arr[1])
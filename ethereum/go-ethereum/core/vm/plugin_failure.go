@@ -0,0 +1,56 @@
+package vm
+
+//add new file
+
+import (
+	"errors"
+)
+
+// pluginFailures maps the execution errors of the EVM to the category they
+// are reported under. The strings are part of the event format, plugins
+// match on them, so they must not change once released.
+var pluginFailures = []struct {
+	err    error
+	reason string
+}{
+	{ErrOutOfGas, "out_of_gas"},
+	{ErrCodeStoreOutOfGas, "code_store_out_of_gas"},
+	{ErrDepth, "max_call_depth"},
+	{ErrInsufficientBalance, "insufficient_balance"},
+	{ErrContractAddressCollision, "address_collision"},
+	{ErrExecutionReverted, "reverted"},
+	{ErrMaxCodeSizeExceeded, "code_size_exceeded"},
+	{ErrInvalidJump, "invalid_jump"},
+	{ErrWriteProtection, "write_protection"},
+	{ErrReturnDataOutOfBounds, "return_data_out_of_bounds"},
+	{ErrGasUintOverflow, "gas_uint_overflow"},
+	{ErrInvalidCode, "invalid_code"},
+	{ErrNonceUintOverflow, "nonce_overflow"},
+}
+
+// PluginFailureReason classifies an execution error of the EVM. A nil error
+// has no reason, an error the EVM does not return is "other".
+func PluginFailureReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, failure := range pluginFailures {
+		if errors.Is(err, failure.err) {
+			return failure.reason
+		}
+	}
+	var (
+		overflow  *ErrStackOverflow
+		underflow *ErrStackUnderflow
+		invalid   *ErrInvalidOpCode
+	)
+	switch {
+	case errors.As(err, &overflow):
+		return "stack_overflow"
+	case errors.As(err, &underflow):
+		return "stack_underflow"
+	case errors.As(err, &invalid):
+		return "invalid_opcode"
+	}
+	return "other"
+}
@@ -1,9 +0,0 @@
-package dan
-
-var IsReg bool = false //是否注册一个新插件
-var RegPath string     //注册新插件的路径
-
-var IsUn bool = false //是否卸载一个插件
-var UnPlg string      //卸载的插件的名字
-
-var Clear string //清空变量
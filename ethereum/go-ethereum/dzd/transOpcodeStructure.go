@@ -2,17 +2,47 @@ package dzd
 
 //add new file
 
-var BLOCK_TIME_LIST [100000][3]string
-var CLEAR_BLOCK_TIME_LIST [100000][3]string
-var COUNT_ARRAY int
+// TxState is the plugin bookkeeping of the transaction being executed. Each
+// plugin manager owns its own, so chains processed side by side in one
+// process do not see each other's call stacks or blocking decisions.
+type TxState struct {
+	TxHash               string
+	CALL_LAYER           int
+	CALL_STACK           []string //call contract
+	ALL_STACK            []string //all contract
+	BLOCKING_FLAG        bool     //是否阻断交易
+	BLOCKING_PLUGIN      string   //plugin that set BLOCKING_FLAG
+	BLOCKING_REASON      string
+	EXTERNAL_FLAG        bool //external call/create
+	PLUGIN_SNAPSHOT_FLAG bool //an enforce plugin is registered, PLUGIN_SNAPSHOT_ID is recorded
+	PLUGIN_SNAPSHOT_ID   int
+	CALLVALID_MAP        map[int]bool
+	TAGS                 []string          //tags plugins attached to the transaction
+	CREATE_ATTEMPTS      int               //CREATE/CREATE2 started so far, a creating transaction counting as the first
+	SELFDESTRUCTED       map[string]string //code hash of the contracts that ran SELFDESTRUCT, by address
+	MAX_CALL_DEPTH       int               //deepest CALL_STACK of the transaction so far
+}
 
-//add new
-var TxHash string
-var CALL_LAYER int
-var CALL_STACK []string //call contract
-var ALL_STACK []string  //all contract
-var BLOCKING_FLAG bool  //是否阻断交易
-var EXTERNAL_FLAG bool  //external call/create
-var PLUGIN_SNAPSHOT_FLAG bool
-var PLUGIN_SNAPSHOT_ID int
-var CALLVALID_MAP map[int]bool
+// CallDepth returns the number of frames on CALL_STACK, 1 being the frame of
+// the transaction itself. Unlike CALL_LAYER, which numbers every frame the
+// transaction enters, it goes down again when a call returns.
+func (s *TxState) CallDepth() int {
+	return len(s.CALL_STACK)
+}
+
+// NoteDepth records the depth of CALL_STACK in MAX_CALL_DEPTH when the
+// transaction did not go that deep before. It runs after every frame pushed.
+func (s *TxState) NoteDepth() {
+	if depth := len(s.CALL_STACK); depth > s.MAX_CALL_DEPTH {
+		s.MAX_CALL_DEPTH = depth
+	}
+}
+
+// InternalCalls returns the number of frames the transaction entered below
+// its own, calls and creations alike.
+func (s *TxState) InternalCalls() int {
+	if s.CALL_LAYER < 1 {
+		return 0
+	}
+	return s.CALL_LAYER - 1
+}
@@ -21,7 +21,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/ethereum/go-ethereum/dan"
+	"github.com/ethereum/go-ethereum/cmd/pluginManage"
+	"github.com/zhidandeng/collector"
 	"io"
 	"math/big"
 	"os"
@@ -244,6 +245,26 @@ func (api *AdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+//add
+// SetPlgFilter replaces the address filter of the plugin manager. Only the
+// transactions touching one of the addresses reach monitor plugins and
+// exporters; an empty filter lets every transaction through.
+func (api *AdminAPI) SetPlgFilter(filter pluginManage.FilterConfig) pluginManage.FilterConfig {
+	api.eth.BlockChain().Config().TransferDataPlg.SetFilter(filter)
+	return filter
+}
+
+// SetPlgFormat switches the payload format of the http exporters and socket
+// plugins to "json" or "cbor". It takes effect with the next block.
+func (api *AdminAPI) SetPlgFormat(format string) (string, error) {
+	if err := api.eth.BlockChain().Config().TransferDataPlg.RequestFormat(format); err != nil {
+		return "", err
+	}
+	return "format switches at the next block", nil
+}
+
+//add
+
 // DebugAPI is the collection of Ethereum full node APIs for debugging the
 // protocol.
 type DebugAPI struct {
@@ -595,15 +616,36 @@ func (api *EthereumAPI) RegisterPlg(plgName string) string {
 	
 	path := "/home/dan/plugin/" + plgName + ".so"
 	fmt.Println("path: "+path)
-	dan.IsReg = true
-	dan.RegPath = path
+	api.e.BlockChain().Config().TransferDataPlg.RequestRegister(path)
 	return "RegisterStart"
 }
 
 func (api *EthereumAPI) UnregisterPlg(plgName string) string {
-	dan.UnPlg = plgName
-	dan.IsUn = true
+	api.e.BlockChain().Config().TransferDataPlg.RequestUnregister(plgName)
 	return "UnRegister Start"
 }
 
+// CollectorSchema returns the field layout of every collector type the node
+// emits, tagged with the collector SchemaVersion.
+func (api *EthereumAPI) CollectorSchema() []collector.TypeSchema {
+	return collector.Schemas()
+}
+
+// PlgFilter returns the address filter of the plugin manager.
+func (api *EthereumAPI) PlgFilter() pluginManage.FilterConfig {
+	return api.e.BlockChain().Config().TransferDataPlg.Filter()
+}
+
+// PlgEvents returns the recent collector events retained by the ring
+// exporter that match query, oldest first.
+func (api *EthereumAPI) PlgEvents(query pluginManage.EventQuery) ([]*pluginManage.Envelope, error) {
+	return api.e.BlockChain().Config().TransferDataPlg.RecentEvents(query)
+}
+
+// ListPlugins returns the registered plugins with the state of their circuit
+// breakers.
+func (api *EthereumAPI) ListPlugins() []pluginManage.PluginInfo {
+	return api.e.BlockChain().Config().TransferDataPlg.ListPlugins()
+}
+
 //add
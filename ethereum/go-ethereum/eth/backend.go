@@ -18,6 +18,7 @@
 package eth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
@@ -25,8 +26,10 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/cmd/pluginManage"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
@@ -58,6 +61,13 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+//add
+// pluginShutdownTimeout bounds how long Stop waits for the plugin exporters
+// and plugins to flush.
+const pluginShutdownTimeout = 10 * time.Second
+
+//add
+
 // Config contains the configuration options of the ETH protocol.
 // Deprecated: use ethconfig.Config instead.
 type Config = ethconfig.Config
@@ -201,6 +211,12 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			Preimages:           config.Preimages,
 		}
 	)
+	//add
+	// The plugin manager has to exist before the chain so the chain can hand
+	// it the historical state source, the miner picks up the same instance.
+	chainConfig.TransferDataPlg = pluginManage.NewPluginManages()
+	pluginManage.SetUpPlugin(chainConfig.TransferDataPlg)
+	//add
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit)
 	if err != nil {
 		return nil, err
@@ -553,6 +569,13 @@ func (s *Ethereum) Stop() error {
 	s.txPool.Stop()
 	s.miner.Close()
 	s.blockchain.Stop()
+	//add
+	pluginCtx, cancel := context.WithTimeout(context.Background(), pluginShutdownTimeout)
+	if err := s.blockchain.Config().TransferDataPlg.Shutdown(pluginCtx); err != nil {
+		log.Warn("Plugin pipeline not flushed on shutdown", "err", err)
+	}
+	cancel()
+	//add
 	s.engine.Close()
 
 	// Clean shutdown marker as the last thing before closing db
@@ -0,0 +1,86 @@
+package eth
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/cmd/pluginManage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/zhidandeng/collector"
+)
+
+var pluginTestAddr = common.HexToAddress("0x0100000000000000000000000000000000000001")
+
+// newPluginTestService assembles a node with pluginTestAddr funded at genesis.
+func newPluginTestService(t *testing.T) *Ethereum {
+	stack, err := node.New(&node.Config{
+		P2P: p2p.Config{
+			ListenAddr:  "0.0.0.0:0",
+			NoDiscovery: true,
+			MaxPeers:    25,
+		}})
+	if err != nil {
+		t.Fatal("can't create node:", err)
+	}
+	t.Cleanup(func() { stack.Close() })
+
+	config := *params.AllEthashProtocolChanges
+	genesis := &core.Genesis{
+		Config:   &config,
+		GasLimit: params.GenesisGasLimit,
+		BaseFee:  big.NewInt(params.InitialBaseFee),
+		Alloc:    core.GenesisAlloc{pluginTestAddr: {Balance: big.NewInt(1000)}},
+	}
+	ethservice, err := New(stack, &ethconfig.Config{Genesis: genesis, Ethash: ethash.Config{PowMode: ethash.ModeFake}, TrieDirtyCache: 16, TrieCleanCache: 16})
+	if err != nil {
+		t.Fatal("can't create eth service:", err)
+	}
+	return ethservice
+}
+
+// Tests that a node assembled by New hands its plugin manager the chain as the
+// historical state source, which needs the manager to exist before the chain.
+func TestPluginHistoryWired(t *testing.T) {
+	ethservice := newPluginTestService(t)
+	manage := ethservice.BlockChain().Config().TransferDataPlg
+	if manage == nil {
+		t.Fatal("no plugin manager on the chain config")
+	}
+	balance, err := manage.History().BalanceAt(pluginTestAddr, 0)
+	if err != nil {
+		t.Fatalf("historical state not wired: %v", err)
+	}
+	if balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Fatalf("balance at genesis: have %v, want 1000", balance)
+	}
+}
+
+// Tests that the plugin settings changing what leaves the node are only
+// reachable through the admin API.
+func TestPluginAdminAPI(t *testing.T) {
+	ethservice := newPluginTestService(t)
+	admin := NewAdminAPI(ethservice)
+	if _, err := admin.SetPlgFormat("protobuf"); err == nil {
+		t.Error("unknown format accepted")
+	}
+	if _, err := admin.SetPlgFormat(collector.FormatCBOR); err != nil {
+		t.Errorf("cbor rejected: %v", err)
+	}
+	filter := admin.SetPlgFilter(pluginManage.FilterConfig{Addresses: []common.Address{pluginTestAddr}})
+	if have := ethservice.BlockChain().Config().TransferDataPlg.Filter(); !reflect.DeepEqual(have, filter) {
+		t.Errorf("filter %+v, want %+v", have, filter)
+	}
+	public := reflect.TypeOf(NewEthereumAPI(ethservice))
+	for _, method := range []string{"SetPlgFormat", "SetPlgFilter"} {
+		if _, ok := public.MethodByName(method); ok {
+			t.Errorf("%s exposed in the eth namespace", method)
+		}
+	}
+}
@@ -48,6 +48,7 @@ require (
 	github.com/olekukonko/tablewriter v0.0.5
 	github.com/peterh/liner v1.1.1-0.20190123174540-a2c9a5303de7
 	github.com/prometheus/tsdb v0.7.1
+	github.com/rabbitmq/amqp091-go v1.3.0
 	github.com/rjeczalik/notify v0.9.1
 	github.com/rs/cors v1.7.0
 	github.com/shirou/gopsutil v3.21.4-0.20210419000835-c7a38de76ee5+incompatible
@@ -78,7 +79,9 @@ require (
 	//github.com/modern-go/reflect2 v1.0.1 // indirect
 	github.com/naoina/go-stringutil v0.1.0 // indirect
 	github.com/tklauser/go-sysconf v0.3.5 // indirect
-	github.com/zhidandeng/collector v0.0.0-20221126143458-10e92babf92d // indirect
+	github.com/zhidandeng/collector v0.0.0-20221126143458-10e92babf92d
 	golang.org/x/net v0.0.0-20220607020251-c690dde0001d // indirect
 	golang.org/x/xerrors v0.0.0-20220517211312-f3a8303e98df // indirect
 )
+
+replace github.com/zhidandeng/collector => ./collector
@@ -189,6 +189,16 @@ web3._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new web3._extend.Method({
+			name: 'setPlgFilter',
+			call: 'admin_setPlgFilter',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setPlgFormat',
+			call: 'admin_setPlgFormat',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -509,6 +519,16 @@ web3._extend({
 			call: 'eth_unregisterPlg',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'plgEvents',
+			call: 'eth_plgEvents',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'listPlugins',
+			call: 'eth_listPlugins',
+			params: 0
+		}),
 		new web3._extend.Method({
 			name: 'sign',
 			call: 'eth_sign',
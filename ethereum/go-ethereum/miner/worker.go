@@ -278,8 +278,10 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		resubmitAdjustCh:   make(chan *intervalAdjust, resubmitAdjustChanSize),
 	}
 	//add
-	worker.chainConfig.TransferDataPlg = pluginManage.NewPluginManages()
-	pluginManage.SetUpPlugin(worker.chainConfig.TransferDataPlg)
+	if worker.chainConfig.TransferDataPlg == nil {
+		worker.chainConfig.TransferDataPlg = pluginManage.NewPluginManages()
+		pluginManage.SetUpPlugin(worker.chainConfig.TransferDataPlg)
+	}
 	//add
 	// Subscribe NewTxsEvent for tx pool
 	worker.txsSub = eth.TxPool().SubscribeNewTxsEvent(worker.txsCh)
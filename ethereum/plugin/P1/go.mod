@@ -9,3 +9,5 @@ require (
 	github.com/stretchr/testify v1.8.1 // indirect
 	github.com/zhidandeng/collector v0.0.0-20221126143458-10e92babf92d
 )
+
+replace github.com/zhidandeng/collector => ../../go-ethereum/collector